@@ -5,13 +5,11 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/pachapman/openid2go/openid"
-	"github.com/gorilla/context"
 	"github.com/justinas/alice"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/openidctx"
 )
 
-const UserKey = 0
-
 func authenticatedHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "The user was authenticated successfully!")
 }
@@ -21,7 +19,7 @@ func unauthenticatedHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func meHandler(w http.ResponseWriter, r *http.Request) {
-	u := context.Get(r, UserKey).(*openid.User)
+	u, _ := openidctx.User(r.Context())
 	fmt.Fprintf(w, "Hello %v! this is all I know about you: %+v.", u.ID, u)
 }
 
@@ -39,18 +37,12 @@ func myMiddleware(h http.Handler) http.Handler {
 var provider *openid.Provider
 var configuration *openid.Configuration
 
-type userHandlerAdapter struct {
-	h http.Handler
-}
-
-func (a *userHandlerAdapter) ServeHTTPWithUser(u *openid.User, rw http.ResponseWriter, req *http.Request) {
-	context.Set(req, UserKey, u)
-	a.h.ServeHTTP(rw, req)
-}
-
-func (a *userHandlerAdapter) myAuthenticateUser(h http.Handler) http.Handler {
-	a.h = h
-	return openid.AuthenticateUser(configuration, a)
+// myAuthenticateUser adapts AuthenticateUser to an alice constructor. The user is
+// retrieved by the next handlers from the request context through openidctx.
+func myAuthenticateUser(h http.Handler) http.Handler {
+	return openid.AuthenticateUser(configuration, func(u *openid.User, w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	})
 }
 
 func myAuthenticate(h http.Handler) http.Handler {
@@ -60,9 +52,7 @@ func myAuthenticate(h http.Handler) http.Handler {
 func main() {
 	configuration, _ = openid.NewConfiguration(openid.ProvidersGetter(getProviders_googlePlayground))
 
-	adapter := new(userHandlerAdapter)
-
-	http.Handle("/me", alice.New(timeoutMiddleware, myMiddleware, adapter.myAuthenticateUser).ThenFunc(meHandler))
+	http.Handle("/me", alice.New(timeoutMiddleware, myMiddleware, myAuthenticateUser).ThenFunc(meHandler))
 	http.Handle("/authn", alice.New(timeoutMiddleware, myMiddleware, myAuthenticate).ThenFunc(authenticatedHandler))
 	http.HandleFunc("/", unauthenticatedHandler)
 
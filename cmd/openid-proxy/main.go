@@ -0,0 +1,136 @@
+// Command openid-proxy is an authenticating reverse proxy: it validates the tokens of the requests
+// with the openid package and forwards the authenticated requests to an upstream service, along
+// with the identity of the user in the X-User-Subject, X-User-Issuer and X-User-Claims headers.
+// Run it as a sidecar of services written in any language:
+//
+//	OPENID_ISSUER=https://accounts.google.com OPENID_CLIENT_IDS=client1 \
+//	    openid-proxy -listen :4180 -upstream http://127.0.0.1:8080 -skip /healthz,/metrics
+//
+// The providers are read from the file given by -providers, see openid.NewProvidersFile, or from
+// the environment variables of openid.ProvidersFromEnv.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+// options are the command line options of the proxy.
+type options struct {
+	listen    string
+	upstream  string
+	providers string
+	skip      string
+	preflight bool
+}
+
+func parseOptions(args []string) (*options, error) {
+	o := &options{}
+	fs := flag.NewFlagSet("openid-proxy", flag.ContinueOnError)
+	fs.StringVar(&o.listen, "listen", envOr("OPENID_PROXY_LISTEN", ":4180"), "the address the proxy listens on")
+	fs.StringVar(&o.upstream, "upstream", os.Getenv("OPENID_PROXY_UPSTREAM"), "the URL of the service the authenticated requests are forwarded to")
+	fs.StringVar(&o.providers, "providers", os.Getenv("OPENID_PROXY_PROVIDERS"), "the JSON or YAML file of the providers, the OPENID_ environment variables when empty")
+	fs.StringVar(&o.skip, "skip", os.Getenv("OPENID_PROXY_SKIP"), "the comma separated patterns of the requests forwarded without authentication, i.e.: /healthz,GET /public/*")
+	fs.BoolVar(&o.preflight, "skip-preflight", os.Getenv("OPENID_PROXY_SKIP_PREFLIGHT") == "true", "forward the CORS preflight requests without authentication")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if o.upstream == "" {
+		return nil, fmt.Errorf("the upstream is required")
+	}
+
+	return o, nil
+}
+
+func envOr(name string, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+
+	return def
+}
+
+// newHandler returns the handler authenticating the requests with the options and forwarding
+// them to the upstream.
+func newHandler(o *options) (http.Handler, error) {
+	upstream, err := url.Parse(o.upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []func(*openid.Configuration) error{openid.IdentityHeaders()}
+	if o.providers != "" {
+		pf, err := openid.NewProvidersFile(o.providers, time.Minute)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, openid.ProvidersGetter(pf.Providers))
+	} else {
+		opts = append(opts, openid.EnvProviders())
+	}
+
+	if o.skip != "" {
+		opts = append(opts, openid.SkipPaths(strings.Split(o.skip, ",")...))
+	}
+
+	if o.preflight {
+		opts = append(opts, openid.SkipPreflight())
+	}
+
+	conf, err := openid.NewConfiguration(func(c *openid.Configuration) error {
+		for _, opt := range opts {
+			if err := opt(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return openid.Authenticate(conf, httputil.NewSingleHostReverseProxy(upstream)), nil
+}
+
+func main() {
+	o, err := parseOptions(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	h, err := newHandler(o)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: o.listen, Handler: h}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	log.Printf("Forwarding the requests authenticated on %v to %v.", o.listen, o.upstream)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
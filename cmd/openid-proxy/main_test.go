@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+func TestProxy(t *testing.T) {
+	var ti *openid.TokenIssuer
+	op := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+	defer op.Close()
+
+	ti, _ = openid.NewTokenIssuer(op.URL, openid.NewKeySet())
+	ti.Rotate("RS256")
+
+	var subject string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.Header.Get(openid.UserSubjectHeaderName)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	dir, _ := ioutil.TempDir("", "openid-proxy")
+	defer os.RemoveAll(dir)
+
+	providers := filepath.Join(dir, "providers.json")
+	ioutil.WriteFile(providers, []byte(fmt.Sprintf(`{"providers": [{"issuer": %q, "client_ids": ["app"]}]}`, op.URL)), 0600)
+
+	o, err := parseOptions([]string{"-upstream", upstream.URL, "-providers", providers, "-skip", "/healthz"})
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	h, err := newHandler(o)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	tests := []struct {
+		path    string
+		token   string
+		status  int
+		subject string
+	}{
+		{"/api", ts, http.StatusNoContent, "user1"},
+		{"/api", "", http.StatusBadRequest, ""},
+		{"/healthz", "", http.StatusNoContent, ""},
+	}
+
+	for _, test := range tests {
+		subject = ""
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		r.Header.Set(openid.UserSubjectHeaderName, "forged")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status || subject != test.subject {
+			t.Errorf("Expected the status %v and subject %q for %v, but got %v and %q", test.status, test.subject, test.path, w.Code, subject)
+		}
+	}
+}
+
+func TestParseOptions_WhenUpstreamMissing(t *testing.T) {
+	os.Unsetenv("OPENID_PROXY_UPSTREAM")
+	if _, err := parseOptions(nil); err == nil {
+		t.Error("An error was expected but not returned")
+	}
+}
@@ -0,0 +1,38 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Authorize middleware authenticates the requests as AuthenticateUserContext and forwards them to
+// the next handler(h) when the authenticated user satisfies the policy, separating the failed
+// authentications, rejected with status 401/Unauthorized, from the denied authorizations, rejected
+// with status 403/Forbidden:
+//
+//	h := openid.Authorize(conf, func(u *openid.User, r *http.Request) error {
+//	    if u.Claims["tenant"] != r.Header.Get("X-Tenant") {
+//	        return errors.New("the user does not belong to the tenant")
+//	    }
+//	    return nil
+//	}, tenantHandler)
+//
+// The errors of the policy that are not a *ValidationError are handled by the ErrorHandlerFunc as
+// a ValidationError with the code ValidationErrorPolicyDenied and status 403/Forbidden, wrapping
+// them. The policy receives a nil user when the request was skipped or the ErrorHandlerFunc chose to
+// continue after a failed authentication.
+func Authorize(conf *Configuration, policy Policy, h http.Handler) http.Handler {
+	return AuthenticateUserContext(conf, authorizationMiddleware(conf, "", func(r *http.Request, u *User) error {
+		err := policy(u, r)
+		if _, ok := err.(*ValidationError); err == nil || ok {
+			return err
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorPolicyDenied,
+			Message:    fmt.Sprintf("The request was denied by the policy: %v", err),
+			Err:        err,
+			HTTPStatus: http.StatusForbidden,
+		}
+	})(h))
+}
@@ -0,0 +1,70 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Authorize(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	var handled error
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ErrorHandler(func(e error, w http.ResponseWriter, r *http.Request) bool {
+		handled = e
+		return validationErrorToHTTPStatus(e, w, r)
+	}))
+
+	h := Authorize(c, func(u *User, r *http.Request) error {
+		switch u.Claims["tenant"] {
+		case "t1":
+			return nil
+		case "t2":
+			return RequireScope("admin")(u, r)
+		}
+
+		return errors.New("the user does not belong to the tenant")
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		claims map[string]interface{}
+		status int
+		code   ValidationErrorCode
+	}{
+		{map[string]interface{}{"aud": "app", "tenant": "t1"}, http.StatusNoContent, 0},
+		{map[string]interface{}{"aud": "app", "tenant": "t2"}, http.StatusForbidden, ValidationErrorInsufficientScope},
+		{map[string]interface{}{"aud": "app", "tenant": "t3"}, http.StatusForbidden, ValidationErrorPolicyDenied},
+		{map[string]interface{}{"aud": "other", "tenant": "t1"}, http.StatusUnauthorized, ValidationErrorAudienceNotFound},
+	}
+
+	for _, test := range tests {
+		handled = nil
+		claims := map[string]interface{}{"sub": "user1"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Expected the status %v for the claims %v, but got %v", test.status, test.claims, w.Code)
+		}
+
+		if test.status != http.StatusNoContent {
+			if ve, ok := handled.(*ValidationError); !ok || ve.Code != test.code {
+				t.Errorf("Expected the error code %v for the claims %v, but got %v", test.code, test.claims, handled)
+			}
+		}
+	}
+}
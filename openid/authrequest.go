@@ -0,0 +1,45 @@
+package openid
+
+import (
+	"net/http"
+)
+
+// The headers describing the original request sent by NGINX to the auth_request endpoint, as set
+// by the configuration of AuthRequestHandler.
+const (
+	originalMethodHeaderName = "X-Original-Method"
+	originalHostHeaderName   = "X-Original-Host"
+	originalURIHeaderName    = "X-Original-URI"
+)
+
+// AuthRequestHandler returns the handler of the subrequests of the auth_request module of NGINX,
+// so NGINX authenticates the requests before proxying them to the services:
+//
+//	location = /auth {
+//	    internal;
+//	    proxy_pass              http://openid-auth:8080/auth;
+//	    proxy_pass_request_body off;
+//	    proxy_set_header        Content-Length "";
+//	    proxy_set_header        X-Original-Method $request_method;
+//	    proxy_set_header        X-Original-Host $host;
+//	    proxy_set_header        X-Original-URI $request_uri;
+//	}
+//
+//	location /api/ {
+//	    auth_request     /auth;
+//	    auth_request_set $user_subject $upstream_http_x_user_subject;
+//	    proxy_set_header X-User-Subject $user_subject;
+//	    proxy_pass       http://api;
+//	}
+//
+// The token is read from the headers of the original request forwarded by NGINX, the Authorization
+// header by default, and the original request is rebuilt from the X-Original-Method, X-Original-Host
+// and X-Original-URI headers, so the SkipPaths and SkipPreflight options of the configuration apply
+// to it. The handler responds with status 204/No Content and the identity headers of the user, see
+// IdentityHeaders, when it is authenticated, and as the ForwardAuthHandler otherwise: NGINX only
+// forwards the 401/Unauthorized and 403/Forbidden responses to the clients.
+func AuthRequestHandler(conf *Configuration) http.Handler {
+	return gatewayHandler(conf, http.StatusNoContent, func(r *http.Request) *http.Request {
+		return originalRequest(r, originalMethodHeaderName, originalHostHeaderName, originalURIHeaderName)
+	})
+}
@@ -0,0 +1,49 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_AuthRequestHandler(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), SkipPaths("/healthz"))
+
+	h := AuthRequestHandler(c.Route(RouteScopes("read")))
+	read, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "scope": "read"}, time.Hour)
+	none, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	tests := []struct {
+		uri     string
+		token   string
+		status  int
+		subject string
+	}{
+		{"/api/orders", read, http.StatusNoContent, "user1"},
+		{"/api/orders", none, http.StatusForbidden, ""},
+		{"/api/orders", "", http.StatusUnauthorized, ""},
+		{"/healthz", "", http.StatusNoContent, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/auth", nil)
+		r.Header.Set("X-Original-Method", http.MethodGet)
+		r.Header.Set("X-Original-URI", test.uri)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status || w.Header().Get(UserSubjectHeaderName) != test.subject {
+			t.Errorf("Expected the status %v and subject %q for %v, but got %v and %q", test.status, test.subject, test.uri, w.Code, w.Header().Get(UserSubjectHeaderName))
+		}
+	}
+}
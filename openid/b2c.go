@@ -0,0 +1,83 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// policyPlaceholder is replaced in the DiscoveryURL of a provider by the Azure AD B2C policy
+// of each token.
+const policyPlaceholder = "{policy}"
+
+// The claims naming the Azure AD B2C policy of a token, the trust framework policy of custom
+// policies and the authentication context class of user flows.
+const (
+	trustFrameworkPolicyClaimName = "tfp"
+	authContextClassClaimName     = "acr"
+)
+
+// isTemplate returns true when the issuer of the provider is a template or its DiscoveryURL
+// contains the policy placeholder, so its configuration depends on each token.
+func (p *Provider) isTemplate() bool {
+	return isIssuerTemplate(p.Issuer) || strings.Contains(p.DiscoveryURL, policyPlaceholder)
+}
+
+// keysIssuer returns the issuer the signing keys of the provider are cached for, followed by the
+// policy of the token for the providers whose DiscoveryURL contains the policy placeholder.
+func (p *Provider) keysIssuer() string {
+	if p.policy == "" {
+		return p.Issuer
+	}
+
+	return p.Issuer + "#" + p.policy
+}
+
+// policyProvider returns the provider p of the token jt, or a copy whose DiscoveryURL is the one of
+// the policy of the token when it contains the policy placeholder. Policy names are case insensitive,
+// and the policies missing from the Policies of the provider are rejected.
+func policyProvider(jt *jwt.Token, p *Provider) (*Provider, error) {
+	if !strings.Contains(p.DiscoveryURL, policyPlaceholder) {
+		return p, nil
+	}
+
+	claims := jt.Claims.(jwt.MapClaims)
+	policy, _ := claims[trustFrameworkPolicyClaimName].(string)
+	if policy == "" {
+		policy, _ = claims[authContextClassClaimName].(string)
+	}
+
+	if policy == "" || strings.ContainsAny(policy, "/?#%{} ") {
+		return nil, &ValidationError{
+			Code:       ValidationErrorTokenPolicyNotFound,
+			Message:    fmt.Sprintf("The token of the provider %v does not name a valid policy in its 'tfp' or 'acr' claim.", p.Issuer),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	if !containsPolicy(p.Policies, policy) {
+		return nil, &ValidationError{
+			Code:       ValidationErrorTokenPolicyNotFound,
+			Message:    fmt.Sprintf("The policy %v of the token is not allowed by the provider %v.", policy, p.Issuer),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	pc := *p
+	pc.policy = strings.ToLower(policy)
+	pc.DiscoveryURL = strings.Replace(p.DiscoveryURL, policyPlaceholder, pc.policy, -1)
+	return &pc, nil
+}
+
+// containsPolicy returns true when the policies contain the policy, case insensitive.
+func containsPolicy(policies []string, policy string) bool {
+	for _, p := range policies {
+		if strings.EqualFold(p, policy) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_validate_AzureADB2CPolicies(t *testing.T) {
+	tis := map[string]*TokenIssuer{}
+	var iss string
+	var fetched []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := strings.Split(r.URL.Path, "/")[1]
+		fetched = append(fetched, policy)
+		ti, ok := tis[policy]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, wellKnownOpenIDConfiguration) {
+			json.NewEncoder(w).Encode(map[string]string{"issuer": iss, "jwks_uri": "http://" + r.Host + "/" + policy + "/keys"})
+			return
+		}
+
+		JwksHandler(ti.keys).ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	iss = s.URL + "/tenant/v2.0/"
+	for _, policy := range []string{"b2c_1_signin", "b2c_1a_edit", "b2c_1_other"} {
+		tis[policy], _ = NewTokenIssuer(iss, NewKeySet())
+		tis[policy].Rotate("RS256")
+	}
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: iss, ClientIDs: []string{"app"}, DiscoveryURL: s.URL + "/{policy}/v2.0" + wellKnownOpenIDConfiguration,
+			Policies: []string{"B2C_1_SignIn", "B2C_1A_Edit"}}}, nil
+	}))
+
+	tests := []struct {
+		issuer string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"b2c_1_signin", map[string]interface{}{"acr": "B2C_1_SignIn"}, true},
+		{"b2c_1a_edit", map[string]interface{}{"tfp": "B2C_1A_Edit"}, true},
+		{"b2c_1a_edit", map[string]interface{}{"tfp": "B2C_1_SignIn"}, false},
+		{"b2c_1_signin", map[string]interface{}{}, false},
+		{"b2c_1_signin", map[string]interface{}{"tfp": "../b2c_1_signin"}, false},
+		{"b2c_1_other", map[string]interface{}{"tfp": "B2C_1_Other"}, false},
+	}
+
+	for _, test := range tests {
+		claims := map[string]interface{}{"sub": "user1", "aud": "app"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := tis[test.issuer].Issue(claims, time.Hour)
+		_, p, err := c.idTokenValidator().validate(nil, ts)
+
+		if test.valid && (err != nil || p.Issuer != iss) {
+			t.Errorf("Expected the token of %v with the claims %v to be valid, but got %v", test.issuer, test.claims, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("Expected the token of %v with the claims %v to be rejected.", test.issuer, test.claims)
+		}
+	}
+
+	for _, policy := range fetched {
+		if policy == "b2c_1_other" {
+			t.Error("Expected the documents of the policies not allowed not to be retrieved")
+		}
+	}
+
+	_, _, err := c.idTokenValidator().getProvider(nil, createValidatedToken(iss, "app", "kid"))
+	expectValidationError(t, err, ValidationErrorTokenPolicyNotFound, http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,102 @@
+package openid
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is the interface implemented by the caches of the documents retrieved from the providers,
+// their discovery documents and signing keys. Implementations must be safe for concurrent use and
+// should treat their own failures as cache misses. The values are internal to this package and
+// are not serializable, caches storing them outside of the process, i.e.: redisstore.Cache,
+// implement SharedCache instead and are registered with NewSharedProviderCache.
+//
+// Get returns the value stored with the key, or false when there is none or it expired.
+// Set stores the value v with the key for ttl, or until deleted when ttl is zero.
+// Delete removes the value stored with the key, if any.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, v interface{}, ttl time.Duration)
+	Delete(key string)
+}
+
+// ProviderCache option stores the discovery documents and signing keys retrieved from the
+// providers in the cache c instead of the default one, an unbounded memory cache. The times
+// the documents are used for, i.e.: configured with JwksCaching, do not depend on the cache,
+// only the entries evicted by the cache are retrieved again earlier.
+func ProviderCache(c Cache) func(*Configuration) error {
+	return func(conf *Configuration) error {
+		kp := conf.idTokenValidator().keyGetter.(*signingKeyProvider)
+		kp.cache = c
+
+		sksp := kp.keySetGetter.(*signingKeySetProvider)
+		sksp.configGetter.(*httpConfigurationProvider).conditional.cache = c
+		sksp.jwksGetter.(*httpJwksProvider).conditional.cache = c
+		return nil
+	}
+}
+
+type memoryCacheEntry struct {
+	value      interface{}
+	expiration time.Time
+}
+
+// memoryCache is a Cache keeping the values in memory.
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]memoryCacheEntry
+	expiries   expiryQueue
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewMemoryCache returns a Cache that keeps up to maxEntries values in memory. Expired values
+// are removed when new values are stored and, once the cache is full, new values are not cached
+// until others expire or are deleted. A maxEntries of zero does not limit the number of values.
+func NewMemoryCache(maxEntries int) Cache {
+	return &memoryCache{entries: make(map[string]memoryCacheEntry), maxEntries: maxEntries, now: time.Now}
+}
+
+func (c *memoryCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || (!e.expiration.IsZero() && !c.now().Before(e.expiration)) {
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+func (c *memoryCache) Set(key string, v interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expiries.expire(now, func(k string, exp time.Time) {
+		if e, ok := c.entries[k]; ok && e.expiration.Equal(exp) {
+			delete(c.entries, k)
+		}
+	})
+
+	_, replace := c.entries[key]
+	if !replace && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		return
+	}
+
+	e := memoryCacheEntry{value: v}
+	if ttl > 0 {
+		e.expiration = now.Add(ttl)
+		c.expiries.add(key, e.expiration)
+	}
+
+	c.entries[key] = e
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
@@ -0,0 +1,82 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_memoryCache(t *testing.T) {
+	now := time.Now()
+	c := NewMemoryCache(2).(*memoryCache)
+	c.now = func() time.Time { return now }
+
+	c.Set("k1", "v1", time.Minute)
+	c.Set("k2", "v2", 0)
+	c.Set("k3", "v3", 0)
+
+	if v, ok := c.Get("k1"); !ok || v != "v1" {
+		t.Error("Expected the value v1, but got", v)
+	}
+
+	if _, ok := c.Get("k3"); ok {
+		t.Error("Expected the value k3 not to be cached while the cache is full.")
+	}
+
+	now = now.Add(time.Minute)
+
+	if _, ok := c.Get("k1"); ok {
+		t.Error("Expected the value k1 to be expired.")
+	}
+
+	c.Set("k3", "v3", 0)
+	c.Delete("k2")
+
+	if _, ok := c.Get("k2"); ok {
+		t.Error("Expected the value k2 to be deleted.")
+	}
+
+	if v, ok := c.Get("k3"); !ok || v != "v3" {
+		t.Error("Expected the value v3, but got", v)
+	}
+
+	c.Set("k4", "v4", time.Minute)
+	c.Set("k4", "v4", 2*time.Minute)
+	now = now.Add(time.Minute)
+	c.Set("k5", "v5", 0)
+
+	if v, ok := c.Get("k4"); !ok || v != "v4" || len(c.expiries) != 1 {
+		t.Error("Expected the value v4 stored again to expire later, but got", v)
+	}
+}
+
+func Test_ProviderCache(t *testing.T) {
+	pc := NewMemoryCache(0)
+	c, err := NewConfiguration(ProviderCache(pc))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	sksp := kp.keySetGetter.(*signingKeySetProvider)
+	if sksp.configGetter.(*httpConfigurationProvider).conditional.cache != pc || sksp.jwksGetter.(*httpJwksProvider).conditional.cache != pc {
+		t.Error("Expected the discovery documents and jwk sets to be stored in the provider cache.")
+	}
+
+	kg := &mockSigningKeySetGetter{}
+	kp.keySetGetter = kg
+	kg.On("get", (*http.Request)(nil), &Provider{Issuer: "issuer"}).Return([]signingKey{{keyID: "kid1", key: []byte("key")}}, time.Hour, nil).Once()
+
+	expectKey(t, kp, "issuer", "kid1", "key")
+
+	if _, ok := pc.Get(signingKeysCacheKeyPrefix + "issuer"); !ok {
+		t.Error("Expected the keys to be stored in the provider cache.")
+	}
+
+	pc.Delete(signingKeysCacheKeyPrefix + "issuer")
+	kg.On("get", (*http.Request)(nil), &Provider{Issuer: "issuer"}).Return([]signingKey{{keyID: "kid1", key: []byte("key2")}}, time.Hour, nil).Once()
+
+	expectKey(t, kp, "issuer", "kid1", "key2")
+
+	kg.AssertExpectations(t)
+}
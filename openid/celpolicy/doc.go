@@ -0,0 +1,17 @@
+/*Package celpolicy implements openid.Policy values from Common Expression Language (CEL)
+expressions (https://github.com/google/cel-go), so the authorization rules of each route can be
+declared without writing Go:
+
+	admin, err := celpolicy.Expression("'admin' in claims.roles && request.method != 'DELETE'")
+
+	mux := openid.NewServeMux(configuration)
+	mux.HandleFunc("/admin/", adminHandler)
+	openid.Protect(mux, "/admin/", admin)
+
+The expressions must evaluate to a boolean and can reference the variables:
+
+	claims  map(string, dyn)   the claims of the authenticated user token.
+	request map(string, dyn)   the members method, host and path (strings) and headers
+	                           (map(string, list(string))) of the request.
+*/
+package celpolicy
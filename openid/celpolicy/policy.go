@@ -0,0 +1,84 @@
+package celpolicy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/cel-go/cel"
+	"github.com/pachapman/openid2go/openid"
+)
+
+// Expression compiles the CEL expression and returns an openid.Policy allowing the requests for
+// which it evaluates to true. Other requests are rejected with status 403/Forbidden, including the
+// ones for which the evaluation fails, i.e.: the token does not contain a claim referenced by the
+// expression. Use the has macro to test for optional claims, i.e.: has(claims.roles).
+// An error is returned when the expression does not compile or is known not to evaluate to a boolean.
+func Expression(expr string) (openid.Policy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("The expression %v must evaluate to a boolean, but evaluates to %v.", expr, t)
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *openid.User, r *http.Request) error {
+		claims := map[string]interface{}{}
+		if u != nil {
+			claims = u.Claims
+		}
+
+		out, _, err := prg.Eval(map[string]interface{}{"claims": claims, "request": requestVariable(r)})
+		if err == nil {
+			if allowed, ok := out.Value().(bool); ok && allowed {
+				return nil
+			}
+		}
+
+		return &openid.ValidationError{
+			Code:       openid.ValidationErrorPolicyDenied,
+			Message:    fmt.Sprintf("The request was denied by the policy %v.", expr),
+			Err:        err,
+			HTTPStatus: http.StatusForbidden,
+		}
+	}, nil
+}
+
+// MustExpression is like Expression but panics if the expression does not compile. It simplifies
+// the declaration of policies with constant expressions.
+func MustExpression(expr string) openid.Policy {
+	p, err := Expression(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func requestVariable(r *http.Request) map[string]interface{} {
+	headers := make(map[string][]string, len(r.Header))
+	for k, v := range r.Header {
+		headers[k] = v
+	}
+
+	return map[string]interface{}{
+		"method":  r.Method,
+		"host":    r.Host,
+		"path":    r.URL.Path,
+		"headers": headers,
+	}
+}
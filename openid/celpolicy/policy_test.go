@@ -0,0 +1,64 @@
+package celpolicy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+func Test_Expression_WhenInvalid(t *testing.T) {
+	for _, expr := range []string{"claims.sub ==", "size(claims)", "unknown == 'a'"} {
+		if _, err := Expression(expr); err == nil {
+			t.Error("Expected an error for the expression", expr)
+		}
+	}
+}
+
+func Test_Expression(t *testing.T) {
+	p := MustExpression("'admin' in claims.roles && request.method != 'DELETE'")
+
+	tests := []struct {
+		user    *openid.User
+		method  string
+		allowed bool
+	}{
+		{&openid.User{Claims: map[string]interface{}{"roles": []interface{}{"reader", "admin"}}}, http.MethodGet, true},
+		{&openid.User{Claims: map[string]interface{}{"roles": []interface{}{"admin"}}}, http.MethodDelete, false},
+		{&openid.User{Claims: map[string]interface{}{"roles": []interface{}{"reader"}}}, http.MethodGet, false},
+		{&openid.User{Claims: map[string]interface{}{}}, http.MethodGet, false},
+		{nil, http.MethodGet, false},
+	}
+
+	for _, test := range tests {
+		err := p(test.user, httptest.NewRequest(test.method, "/admin/", nil))
+
+		if test.allowed {
+			if err != nil {
+				t.Error("An error was returned but not expected", err)
+			}
+
+			continue
+		}
+
+		if ve, ok := err.(*openid.ValidationError); !ok || ve.Code != openid.ValidationErrorPolicyDenied || ve.HTTPStatus != http.StatusForbidden {
+			t.Errorf("Expected a policy denied error for %+v %v, but got %v", test.user, test.method, err)
+		}
+	}
+}
+
+func Test_Expression_Request(t *testing.T) {
+	p := MustExpression("request.path.startsWith('/reports/') && request.headers['X-Tenant'][0] == claims.tenant")
+
+	r := httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+	r.Header.Set("X-Tenant", "t1")
+
+	if err := p(&openid.User{Claims: map[string]interface{}{"tenant": "t1"}}, r); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if err := p(&openid.User{Claims: map[string]interface{}{"tenant": "t2"}}, r); err == nil {
+		t.Error("Expected an error for a different tenant")
+	}
+}
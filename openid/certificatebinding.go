@@ -0,0 +1,101 @@
+package openid
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const confirmationClaimName = "cnf"
+const x5tS256ConfirmationName = "x5t#S256"
+
+// PeerCertificateFunc represents the function used to provide the client certificate
+// presented during the TLS handshake of the request r.
+// The default implementation returns the leaf certificate found in r.TLS. Services
+// that have TLS terminated upstream, i.e.: by a load balancer, can register their own
+// implementation that extracts the certificate from a forwarded header instead.
+// If the certificate is not available this function should return a nil certificate.
+type PeerCertificateFunc func(r *http.Request) (*x509.Certificate, error)
+
+// CertificateBoundTokens option enables the validation of certificate-bound access tokens
+// as described by https://tools.ietf.org/html/rfc8705#section-3.
+// Once enabled every token must contain the 'cnf' claim with the 'x5t#S256' member and its
+// value must match the SHA-256 thumbprint of the client certificate returned by pc.
+// When pc is nil the certificate is obtained from the TLS connection state of the request.
+func CertificateBoundTokens(pc PeerCertificateFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if pc == nil {
+			pc = tlsPeerCertificate
+		}
+
+		c.tokenCheckers = append(c.tokenCheckers, &certificateBindingChecker{pc})
+		return nil
+	}
+}
+
+func tlsPeerCertificate(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+
+	return r.TLS.PeerCertificates[0], nil
+}
+
+type certificateBindingChecker struct {
+	certGetter PeerCertificateFunc
+}
+
+func (cb *certificateBindingChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	tp := getCertificateThumbprint(t)
+
+	if tp == "" {
+		return &ValidationError{
+			Code:       ValidationErrorCertificateBindingNotFound,
+			Message:    "The token 'cnf' claim was not found or did not contain the 'x5t#S256' member.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	cert, err := cb.certGetter(r)
+
+	if err != nil {
+		return &ValidationError{
+			Code:       ValidationErrorClientCertificateNotFound,
+			Message:    "Failure while retrieving the client certificate.",
+			Err:        err,
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	if cert == nil {
+		return &ValidationError{
+			Code:       ValidationErrorClientCertificateNotFound,
+			Message:    "The request was not authenticated with a client certificate.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	if tp != certificateThumbprint(cert) {
+		return &ValidationError{
+			Code:       ValidationErrorCertificateBindingMismatch,
+			Message:    "The token is not bound to the client certificate presented with the request.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return nil
+}
+
+func getCertificateThumbprint(t *jwt.Token) string {
+	cnf, _ := t.Claims.(jwt.MapClaims)[confirmationClaimName].(map[string]interface{})
+	tp, _ := cnf[x5tS256ConfirmationName].(string)
+	return tp
+}
+
+func certificateThumbprint(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
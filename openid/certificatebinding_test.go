@@ -0,0 +1,112 @@
+package openid
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func Test_certificateBindingChecker_WhenTokenHasNoConfirmation(t *testing.T) {
+	cb := &certificateBindingChecker{tlsPeerCertificate}
+	jt := jwt.New(jwt.SigningMethodRS256)
+
+	err := cb.check(&http.Request{}, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorCertificateBindingNotFound, http.StatusUnauthorized, nil)
+}
+
+func Test_certificateBindingChecker_WhenRequestHasNoCertificate(t *testing.T) {
+	cb := &certificateBindingChecker{tlsPeerCertificate}
+	jt := createCertificateBoundToken("thumbprint")
+
+	err := cb.check(&http.Request{}, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorClientCertificateNotFound, http.StatusUnauthorized, nil)
+}
+
+func Test_certificateBindingChecker_WhenCertificateGetterReturnsError(t *testing.T) {
+	ee := errors.New("Error getting the certificate")
+	cb := &certificateBindingChecker{func(r *http.Request) (*x509.Certificate, error) {
+		return nil, ee
+	}}
+	jt := createCertificateBoundToken("thumbprint")
+
+	err := cb.check(&http.Request{}, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorClientCertificateNotFound, http.StatusUnauthorized, ee)
+}
+
+func Test_certificateBindingChecker_WhenThumbprintDoesNotMatch(t *testing.T) {
+	cert := createTestCertificate(t)
+	cb := &certificateBindingChecker{tlsPeerCertificate}
+	jt := createCertificateBoundToken("thumbprint")
+	r := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+
+	err := cb.check(r, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorCertificateBindingMismatch, http.StatusUnauthorized, nil)
+}
+
+func Test_certificateBindingChecker_WhenThumbprintMatches(t *testing.T) {
+	cert := createTestCertificate(t)
+	cb := &certificateBindingChecker{tlsPeerCertificate}
+	jt := createCertificateBoundToken(certificateThumbprint(cert))
+	r := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+
+	if err := cb.check(r, jt, nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
+
+func Test_certificateBindingChecker_UsingCustomCertificateGetter(t *testing.T) {
+	cert := createTestCertificate(t)
+	cb := &certificateBindingChecker{func(r *http.Request) (*x509.Certificate, error) {
+		return cert, nil
+	}}
+	jt := createCertificateBoundToken(certificateThumbprint(cert))
+
+	if err := cb.check(&http.Request{}, jt, nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
+
+func createCertificateBoundToken(thumbprint string) *jwt.Token {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["cnf"] = map[string]interface{}{"x5t#S256": thumbprint}
+	return jt
+}
+
+func createTestCertificate(t *testing.T) *x509.Certificate {
+	k, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal("Failure while generating the test key.", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	if err != nil {
+		t.Fatal("Failure while creating the test certificate.", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("Failure while parsing the test certificate.", err)
+	}
+
+	return cert
+}
@@ -0,0 +1,16 @@
+/*
+Package chimw adapts the openid middlewares to the chi router (https://github.com/go-chi/chi),
+enforcing policies by the route pattern matched by chi, i.e.: "/admin/{id}", rather than by the path
+of the request:
+
+	r := chi.NewRouter()
+	r.Use(chimw.Middleware(configuration,
+	    chimw.Protect("/admin/{id}", openid.RequireScope("admin")),
+	    chimw.Observe(func(r *http.Request, pattern string, authenticated bool) {
+	        authentications.WithLabelValues(pattern, strconv.FormatBool(authenticated)).Inc()
+	    })))
+	r.Get("/admin/{id}", adminHandler)
+
+The authenticated user is retrieved from the request context with openid.UserFromContext.
+*/
+package chimw
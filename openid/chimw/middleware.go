@@ -0,0 +1,102 @@
+package chimw
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/pachapman/openid2go/openid"
+)
+
+// ObserverFunc is called with the route pattern matched by each request, an empty string when
+// none is, and whether the request was authenticated, i.e.: to label metrics by route.
+type ObserverFunc func(r *http.Request, pattern string, authenticated bool)
+
+// Option configures the Middleware.
+type Option func(*middleware)
+
+type middleware struct {
+	conf     *openid.Configuration
+	routes   map[string]*openid.Configuration
+	observer ObserverFunc
+}
+
+// Protect option requires the authenticated users of the requests matching the route pattern, as
+// registered with chi, to satisfy all the given policies. Their errors are handled by the
+// ErrorHandlerFunc of the configuration. Protecting a pattern again replaces its policies.
+func Protect(pattern string, policies ...openid.Policy) Option {
+	return func(m *middleware) {
+		m.routes[pattern] = m.conf.Route(openid.RoutePolicies(policies...))
+	}
+}
+
+// Observe option registers the function called with the outcome of the authentication of
+// each request.
+func Observe(o ObserverFunc) Option {
+	return func(m *middleware) {
+		m.observer = o
+	}
+}
+
+// Middleware returns a chi middleware authenticating the requests with conf, as openid.New, and
+// enforcing the policies of the route pattern they match, see Protect. It can be registered with
+// the Use and With methods of chi routers at any level, as the pattern is resolved before chi
+// routes the request.
+func Middleware(conf *openid.Configuration, options ...Option) func(next http.Handler) http.Handler {
+	m := &middleware{conf: conf, routes: make(map[string]*openid.Configuration)}
+	for _, option := range options {
+		option(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := RoutePattern(r)
+
+			c, ok := m.routes[pattern]
+			if !ok {
+				c = m.conf
+			}
+
+			observed := false
+			openid.AuthenticateUserContext(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The ErrorHandlerFunc can continue after a failed authentication.
+				observed = true
+				if m.observer != nil {
+					_, authenticated := openid.UserFromContext(r.Context())
+					m.observer(r, pattern, authenticated)
+				}
+
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(w, r)
+
+			if !observed && m.observer != nil {
+				m.observer(r, pattern, false)
+			}
+		})
+	}
+}
+
+// RoutePattern returns the route pattern of chi matching the request, i.e.: "/users/{id}", also in
+// the middlewares registered with Use, which run before chi routes the request and completes its
+// pattern. It returns an empty string when no route matches.
+func RoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := rctx.RoutePath
+	if path == "" {
+		path = r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+
+	return strings.TrimSuffix(rctx.RoutePattern(), "/*") + tctx.RoutePattern()
+}
@@ -0,0 +1,89 @@
+package chimw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/oidctest"
+)
+
+func Test_Middleware(t *testing.T) {
+	op, err := oidctest.NewProvider(oidctest.NewClock(time.Now()))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer op.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{op.Provider("app")}, nil
+	}))
+
+	type observation struct {
+		pattern       string
+		authenticated bool
+	}
+
+	var observed []observation
+	r := chi.NewRouter()
+	r.Use(Middleware(c,
+		Protect("/admin/{id}", openid.RequireScope("admin")),
+		Observe(func(r *http.Request, pattern string, authenticated bool) {
+			observed = append(observed, observation{pattern, authenticated})
+		})))
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		if _, authenticated := openid.UserFromContext(r.Context()); !authenticated {
+			t.Error("Expected the user in the request context.")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	r.Get("/admin/{id}", ok)
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/{id}", ok)
+	})
+
+	tests := []struct {
+		path     string
+		scope    string
+		token    bool
+		status   int
+		observed observation
+	}{
+		{"/admin/1", "admin", true, http.StatusNoContent, observation{"/admin/{id}", true}},
+		{"/admin/1", "read", true, http.StatusForbidden, observation{"/admin/{id}", false}},
+		{"/users/1", "read", true, http.StatusNoContent, observation{"/users/{id}", true}},
+		{"/users/1", "", false, http.StatusBadRequest, observation{"/users/{id}", false}},
+	}
+
+	for _, test := range tests {
+		observed = nil
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.token {
+			ts, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "scope": test.scope}, time.Hour)
+			req.Header.Set("Authorization", "Bearer "+ts)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("Expected the status %v for %v, but got %v", test.status, test.path, w.Code)
+		}
+
+		if len(observed) != 1 || observed[0] != test.observed {
+			t.Errorf("Expected the observation %+v for %v, but got %+v", test.observed, test.path, observed)
+		}
+	}
+}
+
+func Test_RoutePattern_WhenNotRouted(t *testing.T) {
+	if p := RoutePattern(httptest.NewRequest(http.MethodGet, "/", nil)); p != "" {
+		t.Error("Expected no pattern without chi, but got", p)
+	}
+}
@@ -0,0 +1,136 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned instead of calling a provider whose circuit is open.
+var errCircuitOpen = errors.New("the circuit of the provider is open")
+
+// ProviderCircuitBreaker option stops calling the hosts of a provider, for its discovery document,
+// signing keys and introspection endpoint, after failures consecutive calls failed with a network
+// error or a 5xx status. The calls then fail immediately, instead of waiting for the provider to
+// time out, until openDuration elapsed. A single call is then let through: the circuit closes if
+// it succeeds and opens again otherwise. Cached keys are used while the circuit is open, including
+// the stale ones kept with the StaleKeys option.
+func ProviderCircuitBreaker(failures int, openDuration time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if failures <= 0 || openDuration <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidCircuitBreaker,
+				Message: fmt.Sprintf("The failures %v and open duration %v must be positive.", failures, openDuration),
+			}
+		}
+
+		cb := &circuitBreaker{failures: failures, openDuration: openDuration, now: time.Now, circuits: make(map[string]*circuit)}
+		sksp := c.idTokenValidator().
+			keyGetter.(*signingKeyProvider).
+			keySetGetter.(*signingKeySetProvider)
+		sksp.configGetter.(*httpConfigurationProvider).conditional.breaker = cb
+		sksp.jwksGetter.(*httpJwksProvider).conditional.breaker = cb
+		c.introspector.breaker = cb
+		return nil
+	}
+}
+
+// circuitBreaker tracks the failures of the calls to each host. A nil circuitBreaker always
+// makes the calls.
+type circuitBreaker struct {
+	failures     int
+	openDuration time.Duration
+	now          func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// do calls call unless the circuit of the host of rawurl is open, in which case errCircuitOpen
+// is returned, and records its outcome. The failures of calls whose request r was canceled are
+// not recorded.
+func (cb *circuitBreaker) do(r *http.Request, rawurl string, call func() (*http.Response, error)) (*http.Response, error) {
+	if cb == nil {
+		return call()
+	}
+
+	host := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		host = u.Host
+	}
+
+	if !cb.allow(host) {
+		return nil, errCircuitOpen
+	}
+
+	resp, err := call()
+	if err != nil && r != nil && r.Context().Err() != nil {
+		cb.release(host)
+		return resp, err
+	}
+
+	cb.record(host, !retryable(resp, err))
+	return resp, err
+}
+
+// allow returns true when the circuit of the host is closed, or when it is the first call since
+// the open duration elapsed.
+func (cb *circuitBreaker) allow(host string) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok || c.failures < cb.failures {
+		return true
+	}
+
+	if c.probing || cb.now().Before(c.openUntil) {
+		return false
+	}
+
+	c.probing = true
+	return true
+}
+
+// release lets the next call through when the probe of the host was canceled.
+func (cb *circuitBreaker) release(host string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c, ok := cb.circuits[host]; ok {
+		c.probing = false
+	}
+}
+
+// record closes the circuit of the host after a success, and opens it once the failures
+// reach the threshold, or when a probe failed.
+func (cb *circuitBreaker) record(host string, success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if success {
+		delete(cb.circuits, host)
+		return
+	}
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[host] = c
+	}
+
+	c.failures++
+	c.probing = false
+	if c.failures >= cb.failures {
+		c.openUntil = cb.now().Add(cb.openDuration)
+	}
+}
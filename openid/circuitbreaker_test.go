@@ -0,0 +1,123 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCircuitBreaker(failures int) (*circuitBreaker, *time.Time) {
+	now := time.Now()
+	return &circuitBreaker{failures: failures, openDuration: time.Minute, now: func() time.Time { return now }, circuits: make(map[string]*circuit)}, &now
+}
+
+func expectCircuitCall(t *testing.T, cb *circuitBreaker, rawurl string, result interface{}, called bool) {
+	c := false
+	_, err := cb.do(nil, rawurl, func() (*http.Response, error) {
+		c = true
+		if err, ok := result.(error); ok {
+			return nil, err
+		}
+
+		return response(result.(int)), nil
+	})
+
+	if c != called {
+		t.Errorf("Expected the call to %v to be made %v, but got %v", rawurl, called, c)
+	}
+
+	if !called && err != errCircuitOpen {
+		t.Error("Expected the circuit open error, but got", err)
+	}
+}
+
+func Test_circuitBreaker_do_WhenNil(t *testing.T) {
+	var cb *circuitBreaker
+
+	expectCircuitCall(t, cb, "https://issuer/jwks", errors.New("unreachable"), true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", errors.New("unreachable"), true)
+}
+
+func Test_circuitBreaker_do_OpensAfterFailures(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(2)
+
+	expectCircuitCall(t, cb, "https://issuer/.well-known/openid-configuration", errors.New("unreachable"), true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusServiceUnavailable, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, false)
+	expectCircuitCall(t, cb, "https://other/jwks", http.StatusOK, true)
+}
+
+func Test_circuitBreaker_do_ResetsOnSuccess(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(2)
+
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusInternalServerError, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusNotFound, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusInternalServerError, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, true)
+}
+
+func Test_circuitBreaker_do_ProbesAfterOpenDuration(t *testing.T) {
+	cb, now := newTestCircuitBreaker(1)
+
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusInternalServerError, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, false)
+
+	*now = now.Add(time.Minute)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusInternalServerError, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, false)
+
+	*now = now.Add(time.Minute)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, true)
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, true)
+}
+
+func Test_circuitBreaker_do_IgnoresCanceledRequests(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	cb.do(r, "https://issuer/jwks", func() (*http.Response, error) { return nil, ctx.Err() })
+
+	expectCircuitCall(t, cb, "https://issuer/jwks", http.StatusOK, true)
+}
+
+func Test_ProviderCircuitBreaker_WhenInvalid(t *testing.T) {
+	_, err := NewConfiguration(ProviderCircuitBreaker(0, time.Second))
+	expectSetupError(t, err, SetupErrorInvalidCircuitBreaker)
+
+	_, err = NewConfiguration(ProviderCircuitBreaker(1, 0))
+	expectSetupError(t, err, SetupErrorInvalidCircuitBreaker)
+}
+
+func Test_ProviderCircuitBreaker_FailsFast(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	ti, _ := NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ProviderCircuitBreaker(1, time.Minute))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	for i := 0; i < 3; i++ {
+		_, _, err := c.validate(nil, ts)
+		if ve, ok := err.(*ValidationError); !ok || ve.HTTPStatus != http.StatusBadGateway {
+			t.Error("Expected a validation error with status 502, but got", err)
+		}
+	}
+
+	if requests != 1 {
+		t.Error("Expected a single request to the provider, but got", requests)
+	}
+}
@@ -0,0 +1,463 @@
+package openid
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ClaimAssertion option compiles the expression expr and rejects the tokens whose claims do not
+// satisfy it with status 403/Forbidden. The expression is meant to be loaded from the service
+// configuration, so access rules can change without changing code:
+//
+//	email_verified == true && ('admin' in groups || realm_access.roles contains 'ops')
+//
+// The syntax supports:
+//
+//	claims           referenced by name; dots access members of object claims, i.e.: address.country.
+//	                 A claim missing from the token is null.
+//	literals         'strings' or "strings", numbers, true, false and null.
+//	comparison       ==, !=, <, <=, > and >= between numbers or strings.
+//	membership       a in b and b contains a are true when the array b contains a, or when the
+//	                 string b contains the word a, i.e.: 'read' in scope.
+//	logic            &&, ||, ! and parentheses.
+//
+// A claim used as a condition on its own must be true. An error is returned when the expression
+// does not compile.
+func ClaimAssertion(expr string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		n, err := compileClaimAssertion(expr)
+		if err != nil {
+			return &SetupError{
+				Code:    SetupErrorInvalidClaimAssertion,
+				Message: fmt.Sprintf("The claim assertion %v does not compile. %v", expr, err),
+			}
+		}
+
+		c.tokenCheckers = append(c.tokenCheckers, &claimAssertionChecker{expr, n})
+		return nil
+	}
+}
+
+type claimAssertionChecker struct {
+	expr string
+	node assertionNode
+}
+
+func (ac *claimAssertionChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	if v, _ := ac.node.eval(t.Claims.(jwt.MapClaims)).(bool); v {
+		return nil
+	}
+
+	return &ValidationError{
+		Code:       ValidationErrorClaimAssertionFailed,
+		Message:    fmt.Sprintf("The token claims do not satisfy the assertion %v.", ac.expr),
+		HTTPStatus: http.StatusForbidden,
+	}
+}
+
+// assertionNode is a node of a compiled claim assertion.
+type assertionNode interface {
+	eval(claims map[string]interface{}) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(claims map[string]interface{}) interface{} {
+	return n.value
+}
+
+type claimNode struct {
+	path []string
+}
+
+func (n *claimNode) eval(claims map[string]interface{}) interface{} {
+	var v interface{} = claims
+	for _, m := range n.path {
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		v = o[m]
+	}
+
+	return normalizeClaimValue(v)
+}
+
+type notNode struct {
+	operand assertionNode
+}
+
+func (n *notNode) eval(claims map[string]interface{}) interface{} {
+	return !isTrue(n.operand.eval(claims))
+}
+
+type binaryNode struct {
+	op          string
+	left, right assertionNode
+}
+
+func (n *binaryNode) eval(claims map[string]interface{}) interface{} {
+	switch n.op {
+	case "&&":
+		return isTrue(n.left.eval(claims)) && isTrue(n.right.eval(claims))
+	case "||":
+		return isTrue(n.left.eval(claims)) || isTrue(n.right.eval(claims))
+	}
+
+	l, r := n.left.eval(claims), n.right.eval(claims)
+
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(l, r)
+	case "!=":
+		return !reflect.DeepEqual(l, r)
+	case "in":
+		return containsValue(r, l)
+	case "contains":
+		return containsValue(l, r)
+	}
+
+	c, ok := compareValues(l, r)
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func isTrue(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+// normalizeClaimValue converts the numbers of the claims to float64, the type of
+// the number literals, so they can be compared.
+func normalizeClaimValue(v interface{}) interface{} {
+	switch nv := v.(type) {
+	case json.Number:
+		f, _ := nv.Float64()
+		return f
+	case int:
+		return float64(nv)
+	case int64:
+		return float64(nv)
+	}
+
+	return v
+}
+
+func containsValue(set interface{}, v interface{}) bool {
+	switch s := set.(type) {
+	case string:
+		vs, ok := v.(string)
+		return ok && containsString(claimStrings(s), vs)
+	case []interface{}:
+		for _, e := range s {
+			if reflect.DeepEqual(normalizeClaimValue(e), v) {
+				return true
+			}
+		}
+	case []string:
+		vs, ok := v.(string)
+		return ok && containsString(s, vs)
+	}
+
+	return false
+}
+
+func compareValues(l interface{}, r interface{}) (int, bool) {
+	switch lv := l.(type) {
+	case float64:
+		if rv, ok := r.(float64); ok {
+			switch {
+			case lv < rv:
+				return -1, true
+			case lv > rv:
+				return 1, true
+			}
+
+			return 0, true
+		}
+	case string:
+		if rv, ok := r.(string); ok {
+			return strings.Compare(lv, rv), true
+		}
+	}
+
+	return 0, false
+}
+
+// compileClaimAssertion parses the expression into a tree of nodes with a recursive descent
+// parser following the grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = primary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" | "contains" ) primary ]
+//	primary = "(" or ")" | literal | claim
+func compileClaimAssertion(expr string) (assertionNode, error) {
+	tokens, err := lexClaimAssertion(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &assertionParser{tokens: tokens}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != assertionEOF {
+		return nil, fmt.Errorf("Unexpected %v at position %v.", t.text, t.pos)
+	}
+
+	return n, nil
+}
+
+type assertionTokenKind int
+
+const (
+	assertionEOF assertionTokenKind = iota
+	assertionOperator
+	assertionIdentifier
+	assertionString
+	assertionNumber
+)
+
+type assertionToken struct {
+	kind  assertionTokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+var assertionOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func lexClaimAssertion(expr string) ([]assertionToken, error) {
+	var tokens []assertionToken
+
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '\'' || c == '"':
+			end := strings.IndexRune(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated string at position %v.", i)
+			}
+
+			tokens = append(tokens, assertionToken{assertionString, expr[i : i+end+2], expr[i+1 : i+end+1], i})
+			i += end + 2
+			continue
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(expr) && (unicode.IsDigit(rune(expr[j])) || expr[j] == '.') {
+				j++
+			}
+
+			f, err := strconv.ParseFloat(expr[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid number %v at position %v.", expr[i:j], i)
+			}
+
+			tokens = append(tokens, assertionToken{assertionNumber, expr[i:j], f, i})
+			i = j
+			continue
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || expr[j] == '.' || expr[j] == '-' ||
+				unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+
+			tokens = append(tokens, assertionToken{assertionIdentifier, expr[i:j], nil, i})
+			i = j
+			continue
+		}
+
+		op := ""
+		for _, o := range assertionOperators {
+			if strings.HasPrefix(expr[i:], o) {
+				op = o
+				break
+			}
+		}
+
+		if op == "" {
+			return nil, fmt.Errorf("Unexpected character %q at position %v.", c, i)
+		}
+
+		tokens = append(tokens, assertionToken{assertionOperator, op, nil, i})
+		i += len(op)
+	}
+
+	return append(tokens, assertionToken{assertionEOF, "end of expression", nil, len(expr)}), nil
+}
+
+type assertionParser struct {
+	tokens []assertionToken
+	pos    int
+}
+
+func (p *assertionParser) peek() assertionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *assertionParser) next() assertionToken {
+	t := p.tokens[p.pos]
+	if t.kind != assertionEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// accept consumes the next token when it is one of the operators ops, including
+// the keyword operators in and contains.
+func (p *assertionParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != assertionOperator && t.kind != assertionIdentifier {
+		return "", false
+	}
+
+	for _, o := range ops {
+		if t.text == o {
+			p.next()
+			return o, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *assertionParser) parseOr() (assertionNode, error) {
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return n, nil
+		}
+
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		n = &binaryNode{"||", n, r}
+	}
+}
+
+func (p *assertionParser) parseAnd() (assertionNode, error) {
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return n, nil
+		}
+
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		n = &binaryNode{"&&", n, r}
+	}
+}
+
+func (p *assertionParser) parseUnary() (assertionNode, error) {
+	if _, ok := p.accept("!"); ok {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notNode{n}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *assertionParser) parseCompare() (assertionNode, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in", "contains")
+	if !ok {
+		return n, nil
+	}
+
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{op, n, r}, nil
+}
+
+func (p *assertionParser) parsePrimary() (assertionNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case assertionString, assertionNumber:
+		return &literalNode{t.value}, nil
+	case assertionIdentifier:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		case "in", "contains":
+			return nil, fmt.Errorf("Unexpected %v at position %v.", t.text, t.pos)
+		}
+
+		return &claimNode{strings.Split(t.text, ".")}, nil
+	case assertionOperator:
+		if t.text == "(" {
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("Expected ) at position %v.", p.peek().pos)
+			}
+
+			return n, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unexpected %v at position %v.", t.text, t.pos)
+}
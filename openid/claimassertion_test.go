@@ -0,0 +1,77 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func Test_ClaimAssertion_WhenInvalid(t *testing.T) {
+	for _, expr := range []string{"", "groups ==", "(a == 1", "a == 1)", "'open", "a @ b", "in groups", "a == == b", "1.2.3 == a"} {
+		_, err := NewConfiguration(ClaimAssertion(expr))
+
+		expectSetupError(t, err, SetupErrorInvalidClaimAssertion)
+	}
+}
+
+func Test_ClaimAssertion(t *testing.T) {
+	claims := jwt.MapClaims{
+		"email_verified": true,
+		"groups":         []interface{}{"users", "admin"},
+		"scope":          "read write",
+		"age":            float64(42),
+		"name":           "alice",
+		"address":        map[string]interface{}{"country": "PT"},
+		"realm_access":   map[string]interface{}{"roles": []interface{}{"ops"}},
+	}
+
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"email_verified == true && 'admin' in groups", true},
+		{"email_verified", true},
+		{"!email_verified", false},
+		{"'admin' in groups && 'owner' in groups", false},
+		{"'owner' in groups || 'users' in groups", true},
+		{"'write' in scope", true},
+		{"'wri' in scope", false},
+		{"groups contains \"users\"", true},
+		{"realm_access.roles contains 'ops'", true},
+		{"address.country == 'PT' && address.city == null", true},
+		{"age >= 18 && age < 65", true},
+		{"age > 42 || age <= 41", false},
+		{"name > 'aaron' && name != 'bob'", true},
+		{"age > 'a'", false},
+		{"missing", false},
+		{"missing.member == 1", false},
+		{"!(email_verified && 'admin' in groups)", false},
+		{"42 in groups", false},
+		{"groups == 'admin'", false},
+		{"groups != address", true},
+		{"address == address", true},
+		{"groups in groups", false},
+	}
+
+	for _, test := range tests {
+		n, err := compileClaimAssertion(test.expr)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", test.expr, err)
+		}
+
+		ac := &claimAssertionChecker{test.expr, n}
+		err = ac.check(httptest.NewRequest(http.MethodGet, "/", nil), &jwt.Token{Claims: claims}, &Provider{})
+
+		if test.ok {
+			if err != nil {
+				t.Error("An error was returned but not expected", test.expr, err)
+			}
+
+			continue
+		}
+
+		expectValidationError(t, err, ValidationErrorClaimAssertionFailed, http.StatusForbidden, nil)
+	}
+}
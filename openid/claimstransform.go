@@ -0,0 +1,136 @@
+package openid
+
+import (
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ClaimsTransformerFunc transforms the claims of a validated token issued by the provider p,
+// which may be nil, before the User is created from them, see TransformClaims. It modifies the
+// claims in place, but not the values they contain, as they are shared with the token.
+type ClaimsTransformerFunc func(claims map[string]interface{}, p *Provider) error
+
+// TransformClaims option registers the transformers applied to the claims of the tokens before the
+// User is created from them, so the handlers see the same claims regardless of the provider:
+//
+//	openid.TransformClaims(
+//	    openid.RenameClaim("preferred_username", "username"),
+//	    openid.FlattenClaim("realm_access.roles", "roles"),
+//	    openid.MapClaimValues("groups", "roles", map[string][]string{"admins": {"admin"}}),
+//	)
+//
+// The transformers are applied in the order they were registered, and the first error rejects the
+// request and is handled by the ErrorHandlerFunc; return a *ValidationError to choose its status.
+// The Issuer, ID, Groups and Roles of the User are read from the transformed claims, and the
+// RolesFunc of the provider receives them. The claims of the token itself, i.e.: those forwarded
+// by Authenticate and the IdentityHeaders, are left unchanged.
+func TransformClaims(transformers ...ClaimsTransformerFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.claimsTransformers = append(c.claimsTransformers, transformers...)
+		return nil
+	}
+}
+
+// transformClaims returns a copy of the token t whose claims were transformed by the
+// transformers of the configuration, or t when there are none.
+func (c *Configuration) transformClaims(t *jwt.Token, p *Provider) (*jwt.Token, error) {
+	if len(c.claimsTransformers) == 0 || t == nil {
+		return t, nil
+	}
+
+	claims := make(jwt.MapClaims)
+	for n, v := range t.Claims.(jwt.MapClaims) {
+		claims[n] = v
+	}
+
+	for _, ct := range c.claimsTransformers {
+		if err := ct(claims, p); err != nil {
+			return nil, err
+		}
+	}
+
+	tt := *t
+	tt.Claims = claims
+	return &tt, nil
+}
+
+// RenameClaim returns a ClaimsTransformerFunc renaming the claim from to the name to, replacing the
+// claim named to, i.e.: to read the username of every provider from the same claim.
+func RenameClaim(from string, to string) ClaimsTransformerFunc {
+	return func(claims map[string]interface{}, p *Provider) error {
+		if v, ok := claims[from]; ok {
+			delete(claims, from)
+			claims[to] = v
+		}
+
+		return nil
+	}
+}
+
+// FlattenClaim returns a ClaimsTransformerFunc copying the nested claim at the dotted path to the
+// top level claim name, i.e.: FlattenClaim("realm_access.roles", "roles") for Keycloak tokens.
+func FlattenClaim(path string, name string) ClaimsTransformerFunc {
+	members := strings.Split(path, ".")
+	return func(claims map[string]interface{}, p *Provider) error {
+		var v interface{} = claims
+		for _, m := range members {
+			o, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+
+			if v, ok = o[m]; !ok {
+				return nil
+			}
+		}
+
+		claims[name] = v
+		return nil
+	}
+}
+
+// MapClaimValues returns a ClaimsTransformerFunc adding to the claim to the values mapped from the
+// values of the claim from, i.e.: to derive the roles of the users from their groups. The claim to
+// becomes an array of strings keeping its former values, and values are not repeated.
+func MapClaimValues(from string, to string, mapping map[string][]string) ClaimsTransformerFunc {
+	return func(claims map[string]interface{}, p *Provider) error {
+		values := append([]string(nil), claimStrings(claims[to])...)
+		added := false
+
+		for _, fv := range claimStrings(claims[from]) {
+			for _, tv := range mapping[fv] {
+				if !containsString(values, tv) {
+					values = append(values, tv)
+					added = true
+				}
+			}
+		}
+
+		if added {
+			tvs := make([]interface{}, len(values))
+			for i, v := range values {
+				tvs[i] = v
+			}
+
+			claims[to] = tvs
+		}
+
+		return nil
+	}
+}
+
+// StaticClaims returns a ClaimsTransformerFunc adding the given claims to the claims that do not
+// contain them, i.e.: to set the tenant of the users of a provider dedicated to it. The claims of
+// the token take precedence.
+func StaticClaims(static map[string]interface{}) ClaimsTransformerFunc {
+	return func(claims map[string]interface{}, p *Provider) error {
+		for n, v := range static {
+			if _, ok := claims[n]; !ok {
+				claims[n] = v
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClaimsTransformers(t *testing.T) {
+	tests := []struct {
+		transformer ClaimsTransformerFunc
+		claims      map[string]interface{}
+		expected    map[string]interface{}
+	}{
+		{
+			RenameClaim("preferred_username", "username"),
+			map[string]interface{}{"preferred_username": "user1", "username": "other"},
+			map[string]interface{}{"username": "user1"},
+		},
+		{
+			RenameClaim("preferred_username", "username"),
+			map[string]interface{}{"username": "other"},
+			map[string]interface{}{"username": "other"},
+		},
+		{
+			FlattenClaim("realm_access.roles", "roles"),
+			map[string]interface{}{"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}}},
+			map[string]interface{}{"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}}, "roles": []interface{}{"admin"}},
+		},
+		{
+			FlattenClaim("realm_access.roles", "roles"),
+			map[string]interface{}{"realm_access": "invalid"},
+			map[string]interface{}{"realm_access": "invalid"},
+		},
+		{
+			MapClaimValues("groups", "roles", map[string][]string{"admins": {"admin", "user"}, "users": {"user"}}),
+			map[string]interface{}{"groups": []interface{}{"admins", "users", "others"}, "roles": "reader"},
+			map[string]interface{}{"groups": []interface{}{"admins", "users", "others"}, "roles": []interface{}{"reader", "admin", "user"}},
+		},
+		{
+			MapClaimValues("groups", "roles", map[string][]string{"admins": {"admin"}}),
+			map[string]interface{}{"groups": []interface{}{"users"}},
+			map[string]interface{}{"groups": []interface{}{"users"}},
+		},
+		{
+			StaticClaims(map[string]interface{}{"tenant": "contoso", "sub": "other"}),
+			map[string]interface{}{"sub": "user1"},
+			map[string]interface{}{"sub": "user1", "tenant": "contoso"},
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.transformer(test.claims, nil); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if !reflect.DeepEqual(test.claims, test.expected) {
+			t.Errorf("Test %v expected the claims %v, but got %v", i, test.expected, test.claims)
+		}
+	}
+}
+
+func TestTransformClaims(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	var providerIssuer string
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}, RolesFunc: RolesClaim("roles")}}, nil
+	}), TransformClaims(
+		FlattenClaim("realm_access.groups", "groups"),
+		MapClaimValues("groups", "roles", map[string][]string{"admins": {"admin"}}),
+		func(claims map[string]interface{}, p *Provider) error {
+			providerIssuer = p.Issuer
+			return nil
+		},
+	), TransformClaims(RenameClaim("preferred_username", "username")))
+
+	ts, _ := ti.Issue(map[string]interface{}{
+		"sub":                "user1",
+		"aud":                "app",
+		"preferred_username": "user.one",
+		"realm_access":       map[string]interface{}{"groups": []interface{}{"admins"}},
+	}, time.Hour)
+
+	u, err := NewValidator(c).Validate(context.Background(), ts)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if !reflect.DeepEqual(u.Roles, []string{"admin"}) || u.Claims["username"] != "user.one" || u.Claims["preferred_username"] != nil {
+		t.Error("Expected the user built from the transformed claims, but got", u)
+	}
+
+	if providerIssuer != s.URL {
+		t.Error("Expected the transformers to receive the provider of the token, but got", providerIssuer)
+	}
+
+	c, _ = NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), TransformClaims(func(claims map[string]interface{}, p *Provider) error {
+		return &ValidationError{Code: ValidationErrorInvalidClaims, Message: "invalid claims", HTTPStatus: http.StatusForbidden}
+	}))
+
+	_, err = NewValidator(c).Validate(context.Background(), ts)
+	expectValidationError(t, err, ValidationErrorInvalidClaims, http.StatusForbidden, nil)
+
+	c, _ = NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), TransformClaims(func(claims map[string]interface{}, p *Provider) error {
+		return errors.New("transformation failed")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+
+	w := httptest.NewRecorder()
+	AuthenticateUser(c, func(u *User, w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the request to be rejected")
+	}).ServeHTTP(w, r)
+
+	if w.Code < http.StatusBadRequest {
+		t.Error("Expected the transformation error to reject the request, but got", w.Code)
+	}
+}
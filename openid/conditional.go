@@ -0,0 +1,110 @@
+package openid
+
+import (
+	"net/http"
+	"sync"
+)
+
+// headerHTTPGetter is implemented by the httpGetters able to send arbitrary request headers,
+// i.e.: the validators of conditional requests.
+type headerHTTPGetter interface {
+	getWithHeader(r *http.Request, url string, h http.Header) (*http.Response, error)
+}
+
+// conditionalCache keeps the last document decoded from each URL along with the validators of
+// its response, ETag and Last-Modified, so the next request for the URL can be conditional and
+// an unchanged document answered with 304/Not Modified is not transferred nor decoded again.
+// The entries are stored in the cache, see ProviderCache, keyed by the URL. The requests failing
+// with transient errors are retried according to the retry policy, see ProviderRetries, unless
+// the circuit breaker is open, see ProviderCircuitBreaker. The zero value is ready to use, storing
+// the entries in a memory cache and making a single attempt.
+type conditionalCache struct {
+	mu      sync.Mutex
+	cache   Cache
+	retry   *retryPolicy
+	breaker *circuitBreaker
+}
+
+// conditionalCacheKeyPrefix prefixes the URLs of the documents in the cache.
+const conditionalCacheKeyPrefix = "document "
+
+type conditionalEntry struct {
+	etag         string
+	lastModified string
+	value        interface{}
+}
+
+// get GETs the document at url, sending the authorization when not empty. The request is
+// conditional when the getter supports it and a document from url is cached, in which case
+// the cached document is returned when the response has status 304/Not Modified.
+func (cc *conditionalCache) get(g httpGetter, r *http.Request, url string, authorization string) (*http.Response, interface{}, error) {
+	hg, ok := g.(headerHTTPGetter)
+	if !ok {
+		resp, err := cc.retry.do(r, func() (*http.Response, error) {
+			return cc.breaker.do(r, url, func() (*http.Response, error) {
+				if authorization == "" {
+					return g.get(r, url)
+				}
+
+				return getAuthorized(g, r, url, authorization)
+			})
+		})
+		return resp, nil, err
+	}
+
+	h := http.Header{}
+	if authorization != "" {
+		h.Set("Authorization", authorization)
+	}
+
+	e, _ := cc.store().Get(conditionalCacheKeyPrefix + url)
+	if e, ok := e.(*conditionalEntry); ok {
+		if e.etag != "" {
+			h.Set("If-None-Match", e.etag)
+		}
+
+		if e.lastModified != "" {
+			h.Set("If-Modified-Since", e.lastModified)
+		}
+	}
+
+	resp, err := cc.retry.do(r, func() (*http.Response, error) {
+		return cc.breaker.do(r, url, func() (*http.Response, error) {
+			return hg.getWithHeader(r, url, h)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if e, ok := e.(*conditionalEntry); ok && resp.StatusCode == http.StatusNotModified {
+		return resp, e.value, nil
+	}
+
+	return resp, nil, nil
+}
+
+// set caches the document v decoded from the response to a request for url, provided the
+// response has validators.
+func (cc *conditionalCache) set(url string, resp *http.Response, v interface{}) {
+	e := &conditionalEntry{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified"), value: v}
+
+	if e.etag == "" && e.lastModified == "" {
+		cc.store().Delete(conditionalCacheKeyPrefix + url)
+		return
+	}
+
+	cc.store().Set(conditionalCacheKeyPrefix+url, e, 0)
+}
+
+// store returns the cache of the entries, creating a memory cache when none is set.
+func (cc *conditionalCache) store() Cache {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.cache == nil {
+		cc.cache = NewMemoryCache(0)
+	}
+
+	return cc.cache
+}
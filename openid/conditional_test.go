@@ -0,0 +1,104 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_httpJwksProvider_Get_SendsConditionalRequests(t *testing.T) {
+	calls, notModified := 0, 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"keys": [{"kty": "oct", "kid": "kid1", "k": "c2VjcmV0"}]}`))
+	}))
+	defer s.Close()
+
+	jp := newHTTPJwksProvider(defaultHTTPGetter{}, &jsonJwksDecoder{})
+
+	for i := 0; i < 3; i++ {
+		jwks, err := jp.get(nil, s.URL, nil, false)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "kid1" {
+			t.Error("Expected the key kid1, but got", jwks.Keys)
+		}
+	}
+
+	if calls != 3 || notModified != 2 {
+		t.Error("Expected 2 conditional requests out of 3, but got", notModified, calls)
+	}
+}
+
+func Test_httpConfigurationProvider_Get_SendsConditionalRequests(t *testing.T) {
+	notModified := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lm := "Wed, 01 Jan 2020 00:00:00 GMT"
+		if r.Header.Get("If-Modified-Since") == lm {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Last-Modified", lm)
+		w.Write([]byte(`{"issuer": "https://issuer", "jwks_uri": "https://issuer/jwks"}`))
+	}))
+	defer s.Close()
+
+	cp := newHTTPConfigurationProvider(defaultHTTPGetter{}, &jsonConfigurationDecoder{})
+
+	for i := 0; i < 2; i++ {
+		c, err := cp.get(nil, s.URL)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if c.JwksURI != "https://issuer/jwks" {
+			t.Error("Expected the jwks_uri https://issuer/jwks, but got", c.JwksURI)
+		}
+	}
+
+	if notModified != 1 {
+		t.Error("Expected 1 conditional request, but got", notModified)
+	}
+}
+
+func Test_conditionalCache_Get_WhenGetterCannotSendHeaders(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Error("Unexpected conditional request")
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer s.Close()
+
+	var cc conditionalCache
+	g := HTTPGetFunc(func(r *http.Request, url string) (*http.Response, error) {
+		return http.Get(url)
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, cached, err := cc.get(g, nil, s.URL, "")
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		resp.Body.Close()
+		cc.set(s.URL, resp, "document")
+
+		if cached != nil {
+			t.Error("No document should be returned from the cache, but got", cached)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package openid
 
 type configuration struct {
-	Issuer  string `json:"issuer"`
-	JwksURI string `json:"jwks_uri"`
+	Issuer                string `json:"issuer"`
+	JwksURI               string `json:"jwks_uri"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
 }
@@ -2,13 +2,24 @@ package openid
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 const wellKnownOpenIDConfiguration = "/.well-known/openid-configuration"
 
+// wellKnownAuthorizationServer is the well-known path of the OAuth 2.0 authorization server
+// metadata, see RFC 8414.
+const wellKnownAuthorizationServer = "/.well-known/oauth-authorization-server"
+
+// errDocumentNotFound is returned when a discovery document is answered with 404/Not Found.
+var errDocumentNotFound = errors.New("The document was not found.")
+
+// configurationGetter returns the configuration of a provider from the discovery document at url.
 type configurationGetter interface {
 	get(r *http.Request, url string) (configuration, error)
 }
@@ -25,43 +36,156 @@ func (f HTTPGetFunc) get(r *http.Request, url string) (*http.Response, error) {
 	return f(r, url)
 }
 
+// authorizedHTTPGetter is implemented by the httpGetters able to send an Authorization header.
+type authorizedHTTPGetter interface {
+	getAuthorized(r *http.Request, url string, authorization string) (*http.Response, error)
+}
+
+// formPoster is implemented by the httpGetters able to post forms, i.e.: to the introspection endpoint.
+type formPoster interface {
+	postForm(r *http.Request, url string, authorization string, form url.Values) (*http.Response, error)
+}
+
+// defaultHTTPGetter is the httpGetter used when no HTTPGetFunc is registered.
+// It uses the client registered with the HTTPClient option, or http.DefaultClient, and the context
+// of the request parameter, when not nil, so the calls to the providers are canceled with the request.
+type defaultHTTPGetter struct {
+	client *http.Client
+}
+
+func (g defaultHTTPGetter) do(req *http.Request) (*http.Response, error) {
+	if g.client == nil {
+		return http.DefaultClient.Do(req)
+	}
+
+	return g.client.Do(req)
+}
+
+func (g defaultHTTPGetter) get(r *http.Request, url string) (*http.Response, error) {
+	return g.getWithHeader(r, url, nil)
+}
+
+func (g defaultHTTPGetter) postForm(r *http.Request, url string, authorization string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		req = req.WithContext(r.Context())
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return g.do(req)
+}
+
+func (g defaultHTTPGetter) getAuthorized(r *http.Request, url string, authorization string) (*http.Response, error) {
+	return g.getWithHeader(r, url, http.Header{"Authorization": {authorization}})
+}
+
+func (g defaultHTTPGetter) getWithHeader(r *http.Request, url string, h http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		req = req.WithContext(r.Context())
+	}
+
+	for k, v := range h {
+		req.Header[k] = v
+	}
+
+	return g.do(req)
+}
+
 type httpConfigurationProvider struct {
-	getter  httpGetter
-	decoder configurationDecoder
+	getter      httpGetter
+	decoder     configurationDecoder
+	conditional conditionalCache
+}
+
+func newHTTPConfigurationProvider(gc httpGetter, dc configurationDecoder) *httpConfigurationProvider {
+	return &httpConfigurationProvider{getter: gc, decoder: dc}
+}
+
+// discoveryURL returns the URL of the discovery document of the provider, its DiscoveryURL
+// or the well-known path under its issuer.
+func discoveryURL(p *Provider) string {
+	if p.DiscoveryURL != "" {
+		return p.DiscoveryURL
+	}
+
+	return issuerURL(p.Issuer) + wellKnownOpenIDConfiguration
 }
 
-func newHTTPConfigurationProvider(gc HTTPGetFunc, dc configurationDecoder) *httpConfigurationProvider {
-	return &httpConfigurationProvider{gc, dc}
+// authorizationServerMetadataURL returns the URL of the authorization server metadata of the
+// issuer, with the well-known path inserted between its host and path as required by RFC 8414.
+func authorizationServerMetadataURL(iss string) string {
+	u, err := url.Parse(issuerURL(iss))
+	if err != nil {
+		return issuerURL(iss) + wellKnownAuthorizationServer
+	}
+
+	u.Path = wellKnownAuthorizationServer + u.Path
+	u.RawPath = ""
+	return u.String()
 }
 
-func (httpProv *httpConfigurationProvider) get(r *http.Request, issuer string) (configuration, error) {
-	// Workaround for tokens issued by google
-	if issuer == "accounts.google.com" {
-		issuer = "https://" + issuer
+// getProviderConfiguration returns the configuration of the provider from its discovery document.
+// Providers without a DiscoveryURL whose OpenID discovery document is not found fall back to their
+// OAuth 2.0 authorization server metadata, so plain OAuth 2.0 servers issuing JWTs can be used.
+func getProviderConfiguration(cg configurationGetter, r *http.Request, p *Provider) (configuration, error) {
+	conf, err := cg.get(r, discoveryURL(p))
+	if ve, ok := err.(*ValidationError); ok && ve.Err == errDocumentNotFound && p.DiscoveryURL == "" {
+		return cg.get(r, authorizationServerMetadataURL(p.Issuer))
 	}
-	configurationURI := issuer + wellKnownOpenIDConfiguration
+
+	return conf, err
+}
+
+func (httpProv *httpConfigurationProvider) get(r *http.Request, configurationURI string) (configuration, error) {
 	var config configuration
-	resp, err := httpProv.getter.get(r, configurationURI)
+	resp, cached, err := httpProv.conditional.get(httpProv.getter, r, configurationURI, "")
 	if err != nil {
 		return config, &ValidationError{
 			Code:       ValidationErrorGetOpenIdConfigurationFailure,
 			Message:    fmt.Sprintf("Failure while contacting the configuration endpoint %v.", configurationURI),
 			Err:        err,
-			HTTPStatus: http.StatusUnauthorized,
+			HTTPStatus: http.StatusBadGateway,
 		}
 	}
 
 	defer resp.Body.Close()
 
+	if cached != nil {
+		return cached.(configuration), nil
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return config, &ValidationError{
+			Code:       ValidationErrorGetOpenIdConfigurationFailure,
+			Message:    fmt.Sprintf("The configuration endpoint %v was not found.", configurationURI),
+			Err:        errDocumentNotFound,
+			HTTPStatus: http.StatusBadGateway,
+		}
+	}
+
 	if config, err = httpProv.decoder.decode(resp.Body); err != nil {
 		return config, &ValidationError{
 			Code:       ValidationErrorDecodeOpenIdConfigurationFailure,
 			Message:    fmt.Sprintf("Failure while decoding the configuration retrived from endpoint %v.", configurationURI),
 			Err:        err,
-			HTTPStatus: http.StatusUnauthorized,
+			HTTPStatus: http.StatusBadGateway,
 		}
 	}
 
+	httpProv.conditional.set(configurationURI, resp, config)
 	return config, nil
 }
 
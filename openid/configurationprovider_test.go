@@ -29,7 +29,7 @@ func TestConfigurationProvider_Get_UsesCorrectUrlAndRequest(t *testing.T) {
 	configSuffix := "/.well-known/openid-configuration"
 	httpGetter.On("get", req, issuer+configSuffix).Return(nil, errors.New("Read configuration error"))
 
-	_, e := configurationProvider.get(req, issuer)
+	_, e := configurationProvider.get(req, discoveryURL(&Provider{Issuer: issuer}))
 
 	if e == nil {
 		t.Error("An error was expected but not returned")
@@ -47,7 +47,7 @@ func TestConfigurationProvider_Get_WhenGetReturnsError(t *testing.T) {
 
 	_, e := configurationProvider.get(nil, "issuer")
 
-	expectValidationError(t, e, ValidationErrorGetOpenIdConfigurationFailure, http.StatusUnauthorized, readError)
+	expectValidationError(t, e, ValidationErrorGetOpenIdConfigurationFailure, http.StatusBadGateway, readError)
 
 	httpGetter.AssertExpectations(t)
 }
@@ -55,7 +55,7 @@ func TestConfigurationProvider_Get_WhenGetReturnsError(t *testing.T) {
 func TestConfigurationProvider_Get_WhenGetSucceeds(t *testing.T) {
 	httpGetter := &mockHTTPGetter{}
 	configDecoder := &mockConfigurationDecoder{}
-	configurationProvider := httpConfigurationProvider{httpGetter, configDecoder}
+	configurationProvider := httpConfigurationProvider{getter: httpGetter, decoder: configDecoder}
 
 	respBody := "openid configuration"
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
@@ -77,7 +77,7 @@ func TestConfigurationProvider_Get_WhenDecodeResponseReturnsError(t *testing.T)
 	httpGetter := &mockHTTPGetter{}
 	configDecoder := &mockConfigurationDecoder{}
 
-	configurationProvider := httpConfigurationProvider{httpGetter, configDecoder}
+	configurationProvider := httpConfigurationProvider{getter: httpGetter, decoder: configDecoder}
 	decodeError := errors.New("Decode configuration error")
 	respBody := "openid configuration"
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
@@ -86,7 +86,7 @@ func TestConfigurationProvider_Get_WhenDecodeResponseReturnsError(t *testing.T)
 	configDecoder.On("decode", mock.MatchedBy(ioReaderMatcher(t, respBody))).Return(configuration{}, decodeError)
 	_, e := configurationProvider.get(nil, mock.Anything)
 
-	expectValidationError(t, e, ValidationErrorDecodeOpenIdConfigurationFailure, http.StatusUnauthorized, decodeError)
+	expectValidationError(t, e, ValidationErrorDecodeOpenIdConfigurationFailure, http.StatusBadGateway, decodeError)
 
 	httpGetter.AssertExpectations(t)
 	configDecoder.AssertExpectations(t)
@@ -96,8 +96,8 @@ func TestConfigurationProvider_Get_WhenDecodeResponseSucceeds(t *testing.T) {
 	httpGetter := &mockHTTPGetter{}
 	configDecoder := &mockConfigurationDecoder{}
 
-	configurationProvider := httpConfigurationProvider{httpGetter, configDecoder}
-	config := configuration{"testissuer", "https://testissuer/jwk"}
+	configurationProvider := httpConfigurationProvider{getter: httpGetter, decoder: configDecoder}
+	config := configuration{Issuer: "testissuer", JwksURI: "https://testissuer/jwk"}
 	respBody := "openid configuration"
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
 	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(resp, nil)
@@ -149,3 +149,93 @@ func ioReaderMatcher(t *testing.T, expectedContent string) func(r io.Reader) boo
 		return res
 	}
 }
+
+func Test_discoveryURL(t *testing.T) {
+	tests := []struct {
+		p   Provider
+		url string
+	}{
+		{Provider{Issuer: "https://issuer/"}, "https://issuer/.well-known/openid-configuration"},
+		{Provider{Issuer: "issuer"}, "https://issuer/.well-known/openid-configuration"},
+		{Provider{Issuer: "https://issuer", DiscoveryURL: "https://issuer/.well-known/oauth-authorization-server"}, "https://issuer/.well-known/oauth-authorization-server"},
+	}
+
+	for _, test := range tests {
+		if u := discoveryURL(&test.p); u != test.url {
+			t.Errorf("Expected the discovery URL %v for %+v, but got %v", test.url, test.p, u)
+		}
+	}
+}
+
+func TestConfigurationProvider_Get_WhenNotFound(t *testing.T) {
+	httpGetter := &mockHTTPGetter{}
+	configDecoder := &mockConfigurationDecoder{}
+	configurationProvider := httpConfigurationProvider{getter: httpGetter, decoder: configDecoder}
+
+	resp := &http.Response{StatusCode: http.StatusNotFound, Body: testBody{bytes.NewBufferString("not found")}}
+	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(resp, nil)
+
+	_, e := configurationProvider.get(nil, mock.Anything)
+
+	expectValidationError(t, e, ValidationErrorGetOpenIdConfigurationFailure, http.StatusBadGateway, errDocumentNotFound)
+
+	httpGetter.AssertExpectations(t)
+	configDecoder.AssertExpectations(t)
+}
+
+func Test_authorizationServerMetadataURL(t *testing.T) {
+	tests := []struct {
+		iss string
+		url string
+	}{
+		{"https://issuer", "https://issuer/.well-known/oauth-authorization-server"},
+		{"https://issuer/", "https://issuer/.well-known/oauth-authorization-server"},
+		{"issuer", "https://issuer/.well-known/oauth-authorization-server"},
+		{"https://issuer/tenant1", "https://issuer/.well-known/oauth-authorization-server/tenant1"},
+	}
+
+	for _, test := range tests {
+		if u := authorizationServerMetadataURL(test.iss); u != test.url {
+			t.Errorf("Expected the metadata URL %v for %v, but got %v", test.url, test.iss, u)
+		}
+	}
+}
+
+func Test_getProviderConfiguration_WhenDiscoveryDocumentNotFound(t *testing.T) {
+	cg := &mockConfigurationGetter{}
+	notFound := &ValidationError{Code: ValidationErrorGetOpenIdConfigurationFailure, Err: errDocumentNotFound, HTTPStatus: http.StatusBadGateway}
+	config := configuration{Issuer: "https://issuer", JwksURI: "https://issuer/jwks"}
+
+	cg.On("get", (*http.Request)(nil), "https://issuer/.well-known/openid-configuration").Return(configuration{}, notFound)
+	cg.On("get", (*http.Request)(nil), "https://issuer/.well-known/oauth-authorization-server").Return(config, nil)
+
+	rc, e := getProviderConfiguration(cg, nil, &Provider{Issuer: "https://issuer"})
+
+	assert.Nil(t, e)
+	assert.Equal(t, config, rc)
+	cg.AssertExpectations(t)
+}
+
+func Test_getProviderConfiguration_WhenDiscoveryURLNotFound(t *testing.T) {
+	cg := &mockConfigurationGetter{}
+	notFound := &ValidationError{Code: ValidationErrorGetOpenIdConfigurationFailure, Err: errDocumentNotFound, HTTPStatus: http.StatusBadGateway}
+
+	cg.On("get", (*http.Request)(nil), "https://issuer/custom").Return(configuration{}, notFound)
+
+	_, e := getProviderConfiguration(cg, nil, &Provider{Issuer: "https://issuer", DiscoveryURL: "https://issuer/custom"})
+
+	assert.Equal(t, notFound, e)
+	cg.AssertExpectations(t)
+}
+
+func Test_getProviderConfiguration_WhenDiscoveryFails(t *testing.T) {
+	cg := &mockConfigurationGetter{}
+	ve := &ValidationError{Code: ValidationErrorGetOpenIdConfigurationFailure, Err: errors.New("unavailable"), HTTPStatus: http.StatusBadGateway}
+
+	cg.On("get", (*http.Request)(nil), "https://issuer/.well-known/openid-configuration").Return(configuration{}, ve)
+
+	_, e := getProviderConfiguration(cg, nil, &Provider{Issuer: "https://issuer"})
+
+	assert.Equal(t, ve, e)
+	cg.AssertExpectations(t)
+}
@@ -0,0 +1,52 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid/internal/ctxkeys"
+)
+
+// withToken returns a shallow copy of r whose context carries the raw validated token and
+// the provider that issued it. Use the openidctx package to retrieve them.
+func withToken(r *http.Request, t *jwt.Token, p *Provider) *http.Request {
+	ctx := context.WithValue(r.Context(), ctxkeys.RawToken, t.Raw)
+	ctx = context.WithValue(ctx, ctxkeys.Provider, p)
+	return r.WithContext(ctx)
+}
+
+// tokenProvider returns the provider stored in the context of r by withToken, or nil.
+func tokenProvider(r *http.Request) *Provider {
+	if r == nil {
+		return nil
+	}
+
+	p, _ := r.Context().Value(ctxkeys.Provider).(*Provider)
+	return p
+}
+
+// withUser returns a shallow copy of r whose context carries the authenticated user.
+func withUser(r *http.Request, u *User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ctxkeys.User, u))
+}
+
+// UserFromContext returns the authenticated user stored in ctx by the AuthenticateUserContext and
+// AuthenticateUser middlewares, and by the ServeMux, along with the claims of its token:
+//
+//	func meHandler(w http.ResponseWriter, r *http.Request) {
+//	    u, ok := openid.UserFromContext(r.Context())
+//	    ...
+//	}
+//
+// It returns false when ctx carries no user, i.e.: when the ErrorHandlerFunc chose to continue
+// after a failed authentication.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(ctxkeys.User).(*User)
+	return u, ok && u != nil
+}
+
+// withClaims returns a shallow copy of r whose context carries the typed claims of the token.
+func withClaims(r *http.Request, claims interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ctxkeys.Claims, claims))
+}
@@ -0,0 +1,39 @@
+package openid
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// CredentialsFunc represents the function used to provide the credentials sent to a provider
+// endpoint that requires its callers to be authenticated. It uses the request being authenticated (r)
+// to return the value of the Authorization header, i.e.: 'Bearer [token]'.
+// Implementations that obtain short-lived tokens are responsible for caching and renewing them.
+type CredentialsFunc func(r *http.Request) (authorization string, err error)
+
+// BearerCredentials returns a CredentialsFunc that always provides the given bearer token.
+func BearerCredentials(token string) CredentialsFunc {
+	return func(r *http.Request) (string, error) {
+		return "Bearer " + token, nil
+	}
+}
+
+// BasicCredentials returns a CredentialsFunc that provides the given username and password
+// using the HTTP Basic authentication scheme.
+func BasicCredentials(username string, password string) CredentialsFunc {
+	return func(r *http.Request) (string, error) {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	}
+}
+
+var errCredentialsNotSupported = errors.New("the registered HTTPGetFunc cannot send credentials")
+
+func getAuthorized(g httpGetter, r *http.Request, url string, authorization string) (*http.Response, error) {
+	ag, ok := g.(authorizedHTTPGetter)
+	if !ok {
+		return nil, errCredentialsNotSupported
+	}
+
+	return ag.getAuthorized(r, url, authorization)
+}
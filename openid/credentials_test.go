@@ -0,0 +1,29 @@
+package openid
+
+import (
+	"testing"
+)
+
+func Test_BearerCredentials(t *testing.T) {
+	a, err := BearerCredentials("token")(nil)
+
+	if err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if a != "Bearer token" {
+		t.Error("Expected 'Bearer token', but got", a)
+	}
+}
+
+func Test_BasicCredentials(t *testing.T) {
+	a, err := BasicCredentials("Aladdin", "open sesame")(nil)
+
+	if err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if a != "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==" {
+		t.Error("Expected 'Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==', but got", a)
+	}
+}
@@ -0,0 +1,42 @@
+package openid
+
+// Deprecation describes the use of a deprecated option, reported to the DeprecationHandlerFunc
+// registered with the DeprecationHook option. Deprecated options keep working until removed in a
+// later major version, so applications can move to their replacement incrementally.
+type Deprecation struct {
+	// Name is the name of the deprecated option, i.e.: HTTPGetter.
+	Name string
+	// Replacement is the name of the option replacing it, if any.
+	Replacement string
+	// Message explains how to move to the replacement.
+	Message string
+}
+
+// DeprecationHandlerFunc is a function that receives the uses of deprecated options, i.e.: to log them.
+type DeprecationHandlerFunc func(Deprecation)
+
+// DeprecationHook option registers the function receiving the uses of deprecated options by the
+// configuration. They are reported once all the options are applied by NewConfiguration, regardless
+// of the position of this option, each use once.
+func DeprecationHook(h DeprecationHandlerFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.deprecationHandler = h
+		return nil
+	}
+}
+
+// deprecated records the use of a deprecated option, to be reported by reportDeprecations.
+// Deprecated options call it before applying themselves as they always did.
+func (c *Configuration) deprecated(d Deprecation) {
+	c.deprecations = append(c.deprecations, d)
+}
+
+func (c *Configuration) reportDeprecations() {
+	if c.deprecationHandler != nil {
+		for _, d := range c.deprecations {
+			c.deprecationHandler(d)
+		}
+	}
+
+	c.deprecations = nil
+}
@@ -0,0 +1,56 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+)
+
+// The options of the original API must keep their signatures so existing applications compile.
+var (
+	_ func(*Configuration) error = ProvidersGetter(GetProvidersFunc(nil))
+	_ func(*Configuration) error = HTTPGetter(HTTPGetFunc(nil))
+	_ func(*Configuration) error = ErrorHandler(ErrorHandlerFunc(nil))
+)
+
+func Test_DeprecationHook_ReportsDeprecationsAfterOptions(t *testing.T) {
+	d := Deprecation{Name: "Old", Replacement: "New", Message: "Use New instead."}
+	old := func(c *Configuration) error {
+		c.deprecated(d)
+		return nil
+	}
+
+	var reported []Deprecation
+	hook := DeprecationHook(func(rd Deprecation) {
+		reported = append(reported, rd)
+	})
+
+	if _, err := NewConfiguration(old, hook); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(reported) != 1 || reported[0] != d {
+		t.Error("Expected the deprecation", d, "to be reported once, but got", reported)
+	}
+}
+
+func Test_NewConfiguration_WithOriginalOptions(t *testing.T) {
+	var reported []Deprecation
+
+	c, err := NewConfiguration(
+		ProvidersGetter(func() ([]Provider, error) { return nil, nil }),
+		HTTPGetter(func(r *http.Request, url string) (*http.Response, error) { return nil, nil }),
+		ErrorHandler(func(e error, w http.ResponseWriter, r *http.Request) bool { return true }),
+		DeprecationHook(func(d Deprecation) { reported = append(reported, d) }))
+
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if c.idTokenValidator().provGetter == nil || c.errorHandler == nil {
+		t.Error("Expected the options to be applied.")
+	}
+
+	if len(reported) != 1 || reported[0].Name != "HTTPGetter" || reported[0].Replacement != "HTTPClient" {
+		t.Error("Expected the deprecation of HTTPGetter, but got", reported)
+	}
+}
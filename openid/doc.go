@@ -20,12 +20,15 @@ of them you will need an instance of the Configuration type, to create that you
        func ErrorHandler(eh ErrorHandlerFunc) func(*Configuration) error
        func ProvidersGetter(pg GetProvidersFunc) func(*Configuration) error
        func HTTPGetter(hg HTTPGetFunc) func(*Configuration) error
+       func HTTPClient(hc *http.Client) func(*Configuration) error
+       func CertificateBoundTokens(pc PeerCertificateFunc) func(*Configuration) error
 
        // extension points:
 
        type ErrorHandlerFunc func(error, http.ResponseWriter, *http.Request) bool
        type GetProvidersFunc func() ([]Provider, error)
        type HTTPGetFunc func(r *http.Request, url string) (*http.Response, error)
+       type PeerCertificateFunc func(r *http.Request) (*x509.Certificate, error)
 
 The Example below demonstrates these elements working together.
 
@@ -80,7 +83,9 @@ Error Handling
 The default behavior of the Authenticate and AuthenticateUser middlewares upon error conditions is:
 the execution pipeline is stopped (the next handler will not be executed), the response will contain
 status 400 when a token is not found and 401 when it is invalid, and the response will also contain the
-error message.
+error message. Failures of the provider, i.e.: its discovery endpoint is down, are returned with status 502
+and failures of the service itself with status 500. The Source method of ValidationError tells them apart
+from the errors caused by the client.
 This behavior can be changed by implementing a function of type ErrorHandlerFunc and registering it
 using ErrorHandler with the Configuration.
 
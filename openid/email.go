@@ -0,0 +1,75 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const emailClaimName = "email"
+const emailVerifiedClaimName = "email_verified"
+
+// VerifiedEmail option rejects tokens that do not contain an 'email' claim verified by the
+// provider, according to the 'email_verified' claim. When domains are provided the domain of
+// the email must also match one of them, ignoring case.
+// Tokens are rejected with the error codes ValidationErrorEmailNotVerified and
+// ValidationErrorEmailDomainNotAllowed, so an ErrorHandlerFunc can show a tailored message.
+func VerifiedEmail(domains ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &emailChecker{domains})
+		return nil
+	}
+}
+
+type emailChecker struct {
+	domains []string
+}
+
+func (ec *emailChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	claims := t.Claims.(jwt.MapClaims)
+	email, _ := claims[emailClaimName].(string)
+
+	if email == "" || !isEmailVerified(claims[emailVerifiedClaimName]) {
+		return &ValidationError{
+			Code:       ValidationErrorEmailNotVerified,
+			Message:    "The token does not contain a verified email.",
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+
+	if len(ec.domains) == 0 {
+		return nil
+	}
+
+	d := ""
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		d = email[i+1:]
+	}
+
+	for _, ad := range ec.domains {
+		if d != "" && strings.EqualFold(d, ad) {
+			return nil
+		}
+	}
+
+	return &ValidationError{
+		Code:       ValidationErrorEmailDomainNotAllowed,
+		Message:    fmt.Sprintf("The email domain %v is not allowed.", d),
+		HTTPStatus: http.StatusForbidden,
+	}
+}
+
+// isEmailVerified returns true when the 'email_verified' claim is true. Some providers
+// send the claim as a string, so "true" is accepted as well.
+func isEmailVerified(v interface{}) bool {
+	switch ev := v.(type) {
+	case bool:
+		return ev
+	case string:
+		return strings.EqualFold(ev, "true")
+	}
+
+	return false
+}
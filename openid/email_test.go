@@ -0,0 +1,55 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestEmailChecker_Check(t *testing.T) {
+	tests := []struct {
+		domains []string
+		claims  jwt.MapClaims
+		code    ValidationErrorCode
+		valid   bool
+	}{
+		{nil, jwt.MapClaims{"email": "user@example.com", "email_verified": true}, 0, true},
+		{nil, jwt.MapClaims{"email": "user@example.com", "email_verified": "true"}, 0, true},
+		{nil, jwt.MapClaims{"email": "user@example.com", "email_verified": false}, ValidationErrorEmailNotVerified, false},
+		{nil, jwt.MapClaims{"email": "user@example.com", "email_verified": "false"}, ValidationErrorEmailNotVerified, false},
+		{nil, jwt.MapClaims{"email": "user@example.com"}, ValidationErrorEmailNotVerified, false},
+		{nil, jwt.MapClaims{"email_verified": true}, ValidationErrorEmailNotVerified, false},
+		{[]string{"example.com"}, jwt.MapClaims{"email": "user@Example.com", "email_verified": true}, 0, true},
+		{[]string{"other.com", "example.com"}, jwt.MapClaims{"email": "user@example.com", "email_verified": true}, 0, true},
+		{[]string{"example.com"}, jwt.MapClaims{"email": "user@sub.example.com", "email_verified": true}, ValidationErrorEmailDomainNotAllowed, false},
+		{[]string{"example.com"}, jwt.MapClaims{"email": "user@evil.com", "email_verified": true}, ValidationErrorEmailDomainNotAllowed, false},
+		{[]string{"example.com"}, jwt.MapClaims{"email": "example.com", "email_verified": true}, ValidationErrorEmailDomainNotAllowed, false},
+	}
+
+	for i, test := range tests {
+		ec := &emailChecker{test.domains}
+		e := ec.check(nil, &jwt.Token{Claims: test.claims}, nil)
+
+		if test.valid {
+			if e != nil {
+				t.Error("Test", i, "an error was returned but not expected", e)
+			}
+			continue
+		}
+
+		expectValidationError(t, e, test.code, http.StatusForbidden, nil)
+	}
+}
+
+func TestVerifiedEmail_RegistersChecker(t *testing.T) {
+	c, _ := NewConfiguration(VerifiedEmail("example.com"))
+
+	if len(c.tokenCheckers) != 1 {
+		t.Fatal("Expected 1 token checker, but got", len(c.tokenCheckers))
+	}
+
+	if ec := c.tokenCheckers[0].(*emailChecker); len(ec.domains) != 1 || ec.domains[0] != "example.com" {
+		t.Error("Expected the domains [example.com], but got", ec.domains)
+	}
+}
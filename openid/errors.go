@@ -16,6 +16,25 @@ const (
 	SetupErrorInvalidIssuer           SetupErrorCode = iota // Invalid issuer provided during setup.
 	SetupErrorInvalidClientIDs                              // Invalid client id collection provided during setup.
 	SetupErrorEmptyProviderCollection                       // Empty collection of providers provided during setup.
+	SetupErrorRevocationStoreNotFound                       // No revocation store registered with the configuration.
+	SetupErrorInvalidTrustedProxy                           // Invalid trusted proxy address provided during setup.
+	SetupErrorTenantValidatorNotFound                       // Provider with an issuer template missing the TenantValidator.
+	SetupErrorUnsupportedKeyAlgorithm                       // Unsupported algorithm provided to generate a key.
+	SetupErrorInvalidClaimAssertion                         // Claim assertion that does not compile provided during setup.
+	SetupErrorInvalidTypedClaims                            // Value that is not a pointer to a struct provided for the typed claims.
+	SetupErrorInvalidCacheBounds                            // Minimum cache lifetime greater than the maximum provided during setup.
+	SetupErrorInvalidRefreshInterval                        // Non positive background refresh interval provided during setup.
+	SetupErrorSigningKeyNotFound                            // Key set without a RSA signing key provided to the token issuer.
+	SetupErrorInvalidJwks                                   // Invalid or empty jwk set provided for a provider.
+	SetupErrorInvalidCertificates                           // Invalid CA certificates or public key pins provided during setup.
+	SetupErrorInvalidRetries                                // Non positive retries or backoff provided during setup.
+	SetupErrorInvalidCircuitBreaker                         // Non positive failures or open duration provided during setup.
+	SetupErrorInvalidProvidersFile                          // Providers file that cannot be decoded provided during setup.
+	SetupErrorIssuerMismatch                                // Discovery document with an issuer different from the provider's.
+	SetupErrorInvalidValidationPolicy                       // Invalid provider validation policy provided during setup.
+	SetupErrorInvalidSkipPattern                            // Invalid pattern of the requests skipped by the middlewares.
+	SetupErrorPoliciesNotFound                              // Provider with the policy placeholder missing the Policies.
+	SetupErrorUnsupportedTransport                          // HTTP client whose transport cannot be configured for TLS.
 )
 
 // ValidationErrorCode is the type of error code that can
@@ -48,8 +67,85 @@ const (
 	ValidationErrorSubjectNotFound                                               // Token missing the 'sub' claim.
 	ValidationErrorIdTokenEmpty                                                  // Empty ID token.
 	ValidationErrorEmptyProviders                                                // Empty collection of providers.
+	ValidationErrorCertificateBindingNotFound                                    // Token missing the 'cnf' claim with a certificate thumbprint.
+	ValidationErrorClientCertificateNotFound                                     // Client certificate not presented with the request.
+	ValidationErrorCertificateBindingMismatch                                    // Token not bound to the presented client certificate.
+	ValidationErrorTokenLimitExceeded                                            // Token exceeds the configured size or structure limits.
+	ValidationErrorGetJwksCredentialsFailure                                     // Failure while retrieving the credentials for the jwk endpoint.
+	ValidationErrorTokenIDNotFound                                               // Token missing the 'jti' claim.
+	ValidationErrorExpirationNotFound                                            // Token missing the 'exp' claim.
+	ValidationErrorTokenReplayed                                                 // Token identifier already used.
+	ValidationErrorReplayStoreFailure                                            // Failure while recording the token identifier.
+	ValidationErrorIntrospectionEndpointNotFound                                 // Provider configuration missing the introspection endpoint.
+	ValidationErrorGetIntrospectionCredentialsFailure                            // Failure while retrieving the credentials for the introspection endpoint.
+	ValidationErrorIntrospectionFailure                                          // Failure while calling the introspection endpoint.
+	ValidationErrorDecodeIntrospectionFailure                                    // Failure while decoding the introspection response.
+	ValidationErrorTokenInactive                                                 // Token reported inactive by the introspection endpoint.
+	ValidationErrorIssuedAtNotFound                                              // Token missing the 'iat' claim.
+	ValidationErrorTokenLifetimeExceeded                                         // Token lifetime longer than the maximum accepted.
+	ValidationErrorTokenAgeExceeded                                              // Token issued longer ago than the maximum accepted.
+	ValidationErrorTokenRevoked                                                  // Token belongs to a session or subject revoked after it was issued.
+	ValidationErrorRevocationStoreFailure                                        // Failure while reading or recording a revocation.
+	ValidationErrorInvalidLogoutToken                                            // Logout token missing required content or with forbidden claims.
+	ValidationErrorEmailNotVerified                                              // Token email missing or not verified.
+	ValidationErrorEmailDomainNotAllowed                                         // Token email domain not allowed.
+	ValidationErrorHostedDomainNotAllowed                                        // Token hosted domain not allowed by the provider.
+	ValidationErrorInsufficientScope                                             // Token missing a scope required by the route.
+	ValidationErrorInsecureTransport                                             // Token sent over a request not protected by TLS.
+	ValidationErrorTenantNotAllowed                                              // Token tenant rejected by the provider TenantValidator.
+	ValidationErrorGrantTypeNotAllowed                                           // Token obtained through a grant type removed by OAuth 2.1.
+	ValidationErrorPolicyDenied                                                  // Request denied by the policy hook.
+	ValidationErrorPolicyFailure                                                 // Failure while evaluating the policy hook.
+	ValidationErrorClaimAssertionFailed                                          // Token claims not satisfying a claim assertion.
+	ValidationErrorInvalidClaims                                                 // Token claims missing or not matching the typed claims.
+	ValidationErrorWebhookDenied                                                 // Request denied by the verification webhook.
+	ValidationErrorWebhookFailure                                                // Failure while calling the verification webhook.
+	ValidationErrorAuthorizationEndpointNotFound                                 // Provider configuration missing the authorization endpoint.
+	ValidationErrorTokenUseNotAllowed                                            // Token 'token_use' claim not allowed by the provider.
+	ValidationErrorAlgorithmNotAllowed                                           // Token signing algorithm not allowed by the provider.
+	ValidationErrorTokenPolicyNotFound                                           // Token missing the 'tfp' or 'acr' claim naming its Azure AD B2C policy.
+	ValidationErrorInsufficientRole                                              // User missing a role required by the route.
+	ValidationErrorInsufficientGroup                                             // User not member of a group required by the route.
+	ValidationErrorGroupsResolutionFailure                                       // Failure while resolving the groups of a user whose token omits them.
+	ValidationErrorSubjectChanged                                                // Token refreshing a connection issued to another user.
+	ValidationErrorPathValueMismatch                                             // Request path value not matching the claim required by the route.
+	ValidationErrorUserIDNotFound                                                // Token missing the claim identifying the user, named by the provider UserIDClaim.
 )
 
+// ErrorSource identifies the party responsible for a validation error.
+type ErrorSource uint32
+
+// Error source constants.
+const (
+	ErrorSourceClient   ErrorSource = iota // The request or its token is defective, i.e.: expired token.
+	ErrorSourceProvider                    // The provider failed, i.e.: discovery endpoint down or malformed jwk set.
+	ErrorSourceService                     // The service failed, i.e.: misconfiguration or store failure.
+)
+
+// errorSources contains the source of the validation errors not caused by the client.
+var errorSources = map[ValidationErrorCode]ErrorSource{
+	ValidationErrorGetOpenIdConfigurationFailure:      ErrorSourceProvider,
+	ValidationErrorDecodeOpenIdConfigurationFailure:   ErrorSourceProvider,
+	ValidationErrorGetJwksFailure:                     ErrorSourceProvider,
+	ValidationErrorDecodeJwksFailure:                  ErrorSourceProvider,
+	ValidationErrorEmptyJwk:                           ErrorSourceProvider,
+	ValidationErrorEmptyJwkKey:                        ErrorSourceProvider,
+	ValidationErrorIntrospectionEndpointNotFound:      ErrorSourceProvider,
+	ValidationErrorAuthorizationEndpointNotFound:      ErrorSourceProvider,
+	ValidationErrorIntrospectionFailure:               ErrorSourceProvider,
+	ValidationErrorDecodeIntrospectionFailure:         ErrorSourceProvider,
+	ValidationErrorGroupsResolutionFailure:            ErrorSourceProvider,
+	ValidationErrorJwtValidationUnknownFailure:        ErrorSourceService,
+	ValidationErrorMarshallingKey:                     ErrorSourceService,
+	ValidationErrorEmptyProviders:                     ErrorSourceService,
+	ValidationErrorGetJwksCredentialsFailure:          ErrorSourceService,
+	ValidationErrorGetIntrospectionCredentialsFailure: ErrorSourceService,
+	ValidationErrorReplayStoreFailure:                 ErrorSourceService,
+	ValidationErrorRevocationStoreFailure:             ErrorSourceService,
+	ValidationErrorPolicyFailure:                      ErrorSourceService,
+	ValidationErrorWebhookFailure:                     ErrorSourceService,
+}
+
 const setupErrorMessagePrefix string = "Setup Error."
 const validationErrorMessagePrefix string = "Validation Error."
 
@@ -84,13 +180,22 @@ type ValidationError struct {
 // should be executed when an error is found or true if the execution should be stopped.
 type ErrorHandlerFunc func(error, http.ResponseWriter, *http.Request) bool
 
+// Source returns the party responsible for the error. Provider failures are returned with HTTP
+// status 502/Bad Gateway and service failures with 500/Internal Server Error, while the errors
+// caused by the client are returned with 4xx statuses.
+func (ve ValidationError) Source() ErrorSource {
+	return errorSources[ve.Code]
+}
+
 // Error returns a formatted string containing the error Message.
 func (ve ValidationError) Error() string {
 	return fmt.Sprintf("Validation error. %v", ve.Message)
 }
 
-// jwtErrorToOpenIDError converts errors of the type *jwt.ValidationError returned during token validation into errors of type *ValidationError
-func jwtErrorToOpenIDError(e error) *ValidationError {
+// jwtErrorToOpenIDError converts errors of the type *jwt.ValidationError returned during token
+// validation into errors of type *ValidationError. The errors returned by the KeyFunc are
+// returned unchanged.
+func jwtErrorToOpenIDError(e error) error {
 	if jwtError, ok := e.(*jwt.ValidationError); ok {
 		if (jwtError.Errors & (jwt.ValidationErrorNotValidYet | jwt.ValidationErrorExpired | jwt.ValidationErrorSignatureInvalid)) != 0 {
 			return &ValidationError{
@@ -109,8 +214,13 @@ func jwtErrorToOpenIDError(e error) *ValidationError {
 		}
 
 		if (jwtError.Errors & jwt.ValidationErrorUnverifiable) != 0 {
-			// TODO: improve this once https://github.com/dgrijalva/jwt-go/issues/108 is resolved.
-			// Currently jwt.Parse does not surface errors returned by the KeyFunc.
+			// Surface the errors returned by the KeyFunc, i.e.: failures to retrieve the signing keys,
+			// so they are not mistaken for token defects.
+			switch jwtError.Inner.(type) {
+			case *ValidationError, *SetupError:
+				return jwtError.Inner
+			}
+
 			return &ValidationError{
 				Code:       ValidationErrorJwtValidationFailure,
 				Message:    jwtError.Error(),
@@ -130,7 +240,7 @@ func validationErrorToHTTPStatus(e error, rw http.ResponseWriter, req *http.Requ
 		http.Error(rw, verr.Message, verr.HTTPStatus)
 	} else {
 		rw.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(rw, e.Error())
+		fmt.Fprint(rw, e.Error())
 	}
 
 	return true
@@ -0,0 +1,48 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestValidationError_Source(t *testing.T) {
+	tests := []struct {
+		code   ValidationErrorCode
+		source ErrorSource
+	}{
+		{ValidationErrorJwtValidationFailure, ErrorSourceClient},
+		{ValidationErrorAudienceNotFound, ErrorSourceClient},
+		{ValidationErrorKidNotFound, ErrorSourceClient},
+		{ValidationErrorGetOpenIdConfigurationFailure, ErrorSourceProvider},
+		{ValidationErrorDecodeJwksFailure, ErrorSourceProvider},
+		{ValidationErrorIntrospectionFailure, ErrorSourceProvider},
+		{ValidationErrorReplayStoreFailure, ErrorSourceService},
+		{ValidationErrorGetJwksCredentialsFailure, ErrorSourceService},
+	}
+
+	for _, test := range tests {
+		if s := (ValidationError{Code: test.code}).Source(); s != test.source {
+			t.Error("Expected the source", test.source, "for the code", test.code, "but got", s)
+		}
+	}
+}
+
+func TestJwtErrorToOpenIDError_SurfacesKeyFuncErrors(t *testing.T) {
+	ve := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	se := &SetupError{Code: SetupErrorEmptyProviderCollection}
+
+	if e := jwtErrorToOpenIDError(&jwt.ValidationError{Inner: ve, Errors: jwt.ValidationErrorUnverifiable}); e != ve {
+		t.Error("Expected the KeyFunc error", ve, "but got", e)
+	}
+
+	if e := jwtErrorToOpenIDError(&jwt.ValidationError{Inner: se, Errors: jwt.ValidationErrorUnverifiable}); e != se {
+		t.Error("Expected the KeyFunc error", se, "but got", e)
+	}
+
+	e := jwtErrorToOpenIDError(&jwt.ValidationError{Inner: errors.New("key error"), Errors: jwt.ValidationErrorUnverifiable})
+
+	expectValidationError(t, e, ValidationErrorJwtValidationFailure, http.StatusUnauthorized, nil)
+}
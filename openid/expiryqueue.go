@@ -0,0 +1,42 @@
+package openid
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryQueue orders the keys of the entries of the memory stores by expiration, so the expired
+// entries are removed without scanning the stores. A key stored again keeps its former expiration
+// in the queue, the remove function of expire compares it with the current one of the entry.
+type expiryQueue []expiryQueueItem
+
+type expiryQueueItem struct {
+	key string
+	exp time.Time
+}
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].exp.Before(q[j].exp) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiryQueueItem)) }
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// add queues the key expiring at exp.
+func (q *expiryQueue) add(key string, exp time.Time) {
+	heap.Push(q, expiryQueueItem{key, exp})
+}
+
+// expire dequeues the keys expired at now and calls remove with each of them and the expiration
+// it was queued with.
+func (q *expiryQueue) expire(now time.Time, remove func(key string, exp time.Time)) {
+	for q.Len() > 0 && !(*q)[0].exp.After(now) {
+		it := heap.Pop(q).(expiryQueueItem)
+		remove(it.key, it.exp)
+	}
+}
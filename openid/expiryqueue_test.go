@@ -0,0 +1,28 @@
+package openid
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_expiryQueue_expire(t *testing.T) {
+	now := time.Now()
+	var q expiryQueue
+	q.add("key3", now.Add(3*time.Second))
+	q.add("key1", now.Add(time.Second))
+	q.add("key2", now.Add(2*time.Second))
+
+	var removed []string
+	remove := func(key string, exp time.Time) { removed = append(removed, key) }
+
+	q.expire(now, remove)
+	if len(removed) != 0 {
+		t.Error("Expected no key to be expired, but got", removed)
+	}
+
+	q.expire(now.Add(2*time.Second), remove)
+	if !reflect.DeepEqual(removed, []string{"key1", "key2"}) || q.Len() != 1 {
+		t.Error("Expected the keys key1 and key2 to be expired in order, but got", removed)
+	}
+}
@@ -0,0 +1,20 @@
+/*
+Package extauthz implements the external authorization service of Envoy
+(https://www.envoyproxy.io/docs/envoy/latest/api-v3/service/auth/v3/external_auth.proto) with the
+openid package, so Envoy and Istio deployments delegate the validation of the tokens to a small Go
+service:
+
+	conf, _ := openid.NewConfiguration(openid.EnvProviders(), openid.IdentityHeaders())
+
+	s := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(s, extauthz.NewServer(conf))
+
+	l, _ := net.Listen("tcp", ":9001")
+	s.Serve(l)
+
+The requests checked by Envoy are authenticated by the middlewares of the configuration, so its
+options apply, i.e.: SkipPaths, RoutePolicies or the Authorize policies of a custom handler. The
+identity headers set by the IdentityHeaders option are added to the requests forwarded by Envoy,
+and the copies sent by the clients are removed.
+*/
+package extauthz
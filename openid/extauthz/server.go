@@ -0,0 +1,212 @@
+package extauthz
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/pachapman/openid2go/openid"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+// identityHeaders are the headers set by the openid.IdentityHeaders option.
+var identityHeaders = []string{
+	openid.UserSubjectHeaderName,
+	openid.UserIssuerHeaderName,
+	openid.UserClaimsHeaderName,
+}
+
+// Server is the Envoy AuthorizationServer authenticating the checked requests.
+type Server struct {
+	authv3.UnimplementedAuthorizationServer
+
+	handler http.Handler
+}
+
+// NewServer returns a Server authenticating the checked requests with conf, as
+// openid.AuthenticateUserContext.
+func NewServer(conf *openid.Configuration) *Server {
+	return NewServerWithMiddleware(func(next http.Handler) http.Handler {
+		return openid.AuthenticateUserContext(conf, next)
+	})
+}
+
+// NewServerWithMiddleware returns a Server allowing the checked requests forwarded to the next
+// handler by the middleware mw, i.e.: openid.New or a chain of the authorization middlewares of
+// the openid package. The responses written by mw are returned to Envoy as denied responses.
+func NewServerWithMiddleware(mw func(next http.Handler) http.Handler) *Server {
+	return &Server{handler: mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed, ok := r.Context().Value(allowedKey{}).(**http.Request); ok {
+			*allowed = r
+		}
+	}))}
+}
+
+// allowedKey is the context key of the request allowed by the middlewares. It is set in the context
+// of the checked request rather than in its checkWriter, as the middlewares can wrap the writer.
+type allowedKey struct{}
+
+// Check authenticates the request described by req and returns the response allowing or
+// denying it.
+func (s *Server) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	r, err := newRequest(ctx, req)
+	if err != nil {
+		return denied(http.StatusBadRequest, nil, err.Error()), nil
+	}
+
+	var allowed *http.Request
+	w := &checkWriter{header: http.Header{}}
+	s.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), allowedKey{}, &allowed)))
+
+	if allowed == nil {
+		if w.status < http.StatusBadRequest {
+			w.status = http.StatusForbidden
+		}
+
+		return denied(w.status, w.header, w.body.String()), nil
+	}
+
+	ok := &authv3.OkHttpResponse{HeadersToRemove: removedHeaders(req)}
+	for _, n := range identityHeaders {
+		if v := allowed.Header.Get(n); v != "" {
+			ok.Headers = append(ok.Headers, headerValue(n, v))
+		}
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: ok},
+	}, nil
+}
+
+// removedHeaders returns the names of the identity headers to remove from the checked request,
+// including those of its headers only differing from them by underscores instead of dashes.
+func removedHeaders(req *authv3.CheckRequest) []string {
+	hr := req.GetAttributes().GetRequest().GetHttp()
+	names := make([]string, 0, len(identityHeaders))
+	names = append(names, identityHeaders...)
+
+	for n := range hr.GetHeaders() {
+		if strings.Contains(n, "_") && isIdentityHeader(n) {
+			names = append(names, n)
+		}
+	}
+
+	for _, h := range hr.GetHeaderMap().GetHeaders() {
+		if n := h.GetKey(); strings.Contains(n, "_") && isIdentityHeader(n) {
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+// isIdentityHeader returns true when the header name n is the name of an identity header once its
+// underscores are replaced with dashes, regardless of the case.
+func isIdentityHeader(n string) bool {
+	n = strings.Replace(n, "_", "-", -1)
+	for _, ih := range identityHeaders {
+		if strings.EqualFold(n, ih) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newRequest returns the HTTP request described by the attributes of req.
+func newRequest(ctx context.Context, req *authv3.CheckRequest) (*http.Request, error) {
+	hr := req.GetAttributes().GetRequest().GetHttp()
+
+	scheme := hr.GetScheme()
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	method := hr.GetMethod()
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	r, err := http.NewRequest(method, scheme+"://"+hr.GetHost()+hr.GetPath(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for n, v := range hr.GetHeaders() {
+		r.Header.Set(n, v)
+	}
+
+	for _, h := range hr.GetHeaderMap().GetHeaders() {
+		v := h.GetValue()
+		if v == "" {
+			v = string(h.GetRawValue())
+		}
+
+		r.Header.Add(h.GetKey(), v)
+	}
+
+	if strings.EqualFold(scheme, "https") {
+		r.TLS = &tls.ConnectionState{}
+	}
+
+	return r.WithContext(ctx), nil
+}
+
+// denied returns the response denying a request with the status, headers and body written by
+// the middlewares.
+func denied(code int, header http.Header, body string) *authv3.CheckResponse {
+	rc := codes.PermissionDenied
+	if code == http.StatusUnauthorized || code == http.StatusBadRequest {
+		rc = codes.Unauthenticated
+	}
+
+	dr := &authv3.DeniedHttpResponse{Status: &typev3.HttpStatus{Code: typev3.StatusCode(code)}, Body: body}
+	for n, vs := range header {
+		for _, v := range vs {
+			dr.Headers = append(dr.Headers, headerValue(n, v))
+		}
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &status.Status{Code: int32(rc), Message: strings.TrimSpace(body)},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: dr},
+	}
+}
+
+func headerValue(n string, v string) *corev3.HeaderValueOption {
+	return &corev3.HeaderValueOption{
+		Header:       &corev3.HeaderValue{Key: n, Value: v},
+		AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+	}
+}
+
+// checkWriter records the response written by the middlewares for a checked request.
+type checkWriter struct {
+	header http.Header
+	status int
+	body   strings.Builder
+}
+
+func (w *checkWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *checkWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(b)
+}
+
+func (w *checkWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
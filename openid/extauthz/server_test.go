@@ -0,0 +1,133 @@
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/oidctest"
+	"google.golang.org/grpc/codes"
+)
+
+func checkRequest(path string, headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{Request: &authv3.AttributeContext_Request{
+		Http: &authv3.AttributeContext_HttpRequest{Method: http.MethodGet, Scheme: "https", Host: "api.example.com", Path: path, Headers: headers},
+	}}}
+}
+
+func Test_Server_Check(t *testing.T) {
+	op, err := oidctest.NewProvider(oidctest.NewClock(time.Now()))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer op.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{op.Provider("app")}, nil
+	}), openid.IdentityHeaders(), openid.SkipPaths("/healthz"), openid.RequireTLS())
+
+	s := NewServer(c)
+	ts, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	other, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "other"}, time.Hour)
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+		code    codes.Code
+		status  int
+		subject string
+		removed int
+	}{
+		{"/api?q=1", map[string]string{"authorization": "Bearer " + ts, "x-user-subject": "forged"}, codes.OK, 0, "user1", 3},
+		{"/api", map[string]string{"authorization": "Bearer " + other}, codes.Unauthenticated, http.StatusUnauthorized, "", 0},
+		{"/api", nil, codes.Unauthenticated, http.StatusBadRequest, "", 0},
+		{"/healthz", nil, codes.OK, 0, "", 3},
+		{"/healthz", map[string]string{"x_user_subject": "forged", "x_tenant": "t1"}, codes.OK, 0, "", 4},
+	}
+
+	for _, test := range tests {
+		resp, err := s.Check(context.Background(), checkRequest(test.path, test.headers))
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if codes.Code(resp.GetStatus().GetCode()) != test.code {
+			t.Errorf("Expected the code %v for %v, but got %v", test.code, test.path, resp.GetStatus())
+		}
+
+		if test.code != codes.OK {
+			if st := resp.GetDeniedResponse().GetStatus().GetCode(); int(st) != test.status {
+				t.Errorf("Expected the status %v for %v, but got %v", test.status, test.path, st)
+			}
+			continue
+		}
+
+		ok := resp.GetOkResponse()
+		if len(ok.GetHeadersToRemove()) != test.removed {
+			t.Error("Expected the identity headers to be removed, but got", ok.GetHeadersToRemove())
+		}
+
+		var subject string
+		for _, h := range ok.GetHeaders() {
+			if h.GetHeader().GetKey() == openid.UserSubjectHeaderName {
+				subject = h.GetHeader().GetValue()
+			}
+		}
+
+		if subject != test.subject {
+			t.Errorf("Expected the subject %q for %v, but got %q", test.subject, test.path, subject)
+		}
+	}
+}
+
+func Test_NewServerWithMiddleware(t *testing.T) {
+	s := NewServerWithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Tenant") != "t1" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "tenant not allowed", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	resp, _ := s.Check(context.Background(), checkRequest("/", map[string]string{"x-tenant": "t2"}))
+	dr := resp.GetDeniedResponse()
+	if codes.Code(resp.GetStatus().GetCode()) != codes.PermissionDenied || dr.GetStatus().GetCode() != http.StatusForbidden || dr.GetBody() != "tenant not allowed\n" {
+		t.Error("Expected the response of the middleware to be denied, but got", resp)
+	}
+
+	found := false
+	for _, h := range dr.GetHeaders() {
+		found = found || (h.GetHeader().GetKey() == "Www-Authenticate" && h.GetHeader().GetValue() == "Bearer")
+	}
+
+	if !found {
+		t.Error("Expected the headers of the middleware in the denied response, but got", dr.GetHeaders())
+	}
+
+	resp, _ = s.Check(context.Background(), checkRequest("/", map[string]string{"x-tenant": "t1"}))
+	if resp.GetOkResponse() == nil {
+		t.Error("Expected the request to be allowed, but got", resp)
+	}
+
+	s = NewServerWithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&wrappedWriter{w}, r)
+		})
+	})
+
+	resp, _ = s.Check(context.Background(), checkRequest("/", nil))
+	if resp.GetOkResponse() == nil {
+		t.Error("Expected the request to be allowed by a middleware wrapping the writer, but got", resp)
+	}
+}
+
+type wrappedWriter struct {
+	http.ResponseWriter
+}
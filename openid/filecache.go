@@ -0,0 +1,147 @@
+package openid
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewFileProviderCache returns a Cache, to be registered with the ProviderCache option, keeping
+// the discovery documents and signing keys in memory and persisting them to the file at path
+// whenever they change. The documents persisted by a previous run are restored, so a service
+// restarting while a provider is unavailable keeps validating tokens with the last retrieved
+// keys, as long as they have not expired, see JwksCaching and StaleKeys:
+//
+//	pc, err := openid.NewFileProviderCache("/var/cache/myservice/openid.json")
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	                                openid.ProviderCache(pc))
+//
+// A missing file is created on the first change. The error of reading or decoding an existing file
+// is returned, failures to write it are ignored, the documents remain cached in memory.
+func NewFileProviderCache(path string) (Cache, error) {
+	c := &fileProviderCache{path: path, entries: make(map[string]*fileCacheEntry), now: time.Now}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// fileCacheEntry is a value of the cache along with its JSON representation, see encodeCacheValue.
+type fileCacheEntry struct {
+	Value      json.RawMessage `json:"value"`
+	Expiration time.Time       `json:"expiration,omitempty"`
+	value      interface{}
+}
+
+type fileProviderCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*fileCacheEntry
+	now     func() time.Time
+}
+
+func (c *fileProviderCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || c.expired(e) {
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+func (c *fileProviderCache) Set(key string, v interface{}, ttl time.Duration) {
+	data, ok := encodeCacheValue(v)
+	if !ok {
+		return
+	}
+
+	e := &fileCacheEntry{Value: data, value: v}
+	if ttl > 0 {
+		e.Expiration = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = e
+	c.save()
+}
+
+func (c *fileProviderCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.save()
+	}
+}
+
+func (c *fileProviderCache) expired(e *fileCacheEntry) bool {
+	return !e.Expiration.IsZero() && !c.now().Before(e.Expiration)
+}
+
+// load restores the entries persisted to the file, skipping the expired ones and those
+// that cannot be decoded.
+func (c *fileProviderCache) load() error {
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var entries map[string]*fileCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	for k, e := range entries {
+		if e == nil || c.expired(e) {
+			continue
+		}
+
+		v, ok := decodeCacheValue(e.Value)
+		if !ok {
+			continue
+		}
+
+		e.value = v
+		c.entries[k] = e
+	}
+
+	return nil
+}
+
+// save persists the entries, not yet expired, to a temporary file renamed to the file, so the
+// file is never partially written.
+func (c *fileProviderCache) save() {
+	entries := make(map[string]*fileCacheEntry, len(c.entries))
+	for k, e := range c.entries {
+		if !c.expired(e) {
+			entries[k] = e
+		}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+	}
+}
@@ -0,0 +1,92 @@
+package openid
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createFileCachePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "openid")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	return filepath.Join(dir, "cache.json"), func() { os.RemoveAll(dir) }
+}
+
+func Test_NewFileProviderCache_RestoresPersistedEntries(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	c, err := NewFileProviderCache(path)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	pk := createPublicKey(t)
+	c.Set("keys", &cachedSigningKeys{keys: []signingKey{{keyID: "kid1", key: pk}}, expiry: time.Now().Add(time.Hour)}, time.Hour)
+	c.Set("conf", &conditionalEntry{etag: `"v1"`, value: configuration{Issuer: "https://issuer"}}, 0)
+	c.Set("expired", &cachedSigningKeys{}, time.Hour)
+	c.(*fileProviderCache).entries["expired"].Expiration = time.Now().Add(-time.Second)
+	c.Set("deleted", &cachedSigningKeys{}, 0)
+	c.Delete("deleted")
+
+	rc, err := NewFileProviderCache(path)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if v, ok := rc.Get("keys"); !ok || keyString(v.(*cachedSigningKeys).keys[0].key) != keyString(pk) {
+		t.Error("Expected the signing keys to be restored, but got", v)
+	}
+
+	if v, ok := rc.Get("conf"); !ok || v.(*conditionalEntry).value.(configuration).Issuer != "https://issuer" {
+		t.Error("Expected the configuration to be restored, but got", v)
+	}
+
+	for _, k := range []string{"expired", "deleted"} {
+		if v, ok := rc.Get(k); ok {
+			t.Error("Expected no entry", k, "but got", v)
+		}
+	}
+}
+
+func Test_NewFileProviderCache_WhenFileInvalid(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	ioutil.WriteFile(path, []byte("not json"), 0600)
+
+	if _, err := NewFileProviderCache(path); err == nil {
+		t.Error("An error was expected but not returned.")
+	}
+}
+
+func Test_NewFileProviderCache_ValidatesAfterRestartWithoutProvider(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	pc, _ := NewFileProviderCache(path)
+	c, _ := NewConfiguration(ProviderCache(pc))
+	kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	kg := &mockSigningKeySetGetter{}
+	kp.keySetGetter = kg
+	pk := createPublicKey(t)
+	kg.On("get", (*http.Request)(nil), &Provider{Issuer: "issuer"}).Return([]signingKey{{keyID: "kid1", key: pk}}, time.Hour, nil).Once()
+
+	expectKey(t, kp, "issuer", "kid1", keyString(pk))
+
+	// The restarted service finds the keys without contacting the provider.
+	pc, _ = NewFileProviderCache(path)
+	c, _ = NewConfiguration(ProviderCache(pc))
+	rkp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	rkp.keySetGetter = &mockSigningKeySetGetter{}
+
+	expectKey(t, rkp, "issuer", "kid1", keyString(pk))
+
+	kg.AssertExpectations(t)
+}
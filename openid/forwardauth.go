@@ -0,0 +1,97 @@
+package openid
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// The headers describing the original request sent by Traefik to the ForwardAuth endpoint.
+const (
+	forwardedMethodHeaderName = "X-Forwarded-Method"
+	forwardedHostHeaderName   = "X-Forwarded-Host"
+	forwardedURIHeaderName    = "X-Forwarded-Uri"
+)
+
+// ForwardAuthHandler returns the handler implementing the ForwardAuth contract of Traefik, so the
+// gateway authenticates the requests before forwarding them to the services:
+//
+//	http.Handle("/auth", openid.ForwardAuthHandler(configuration))
+//
+// configured in Traefik with the identity headers as authResponseHeaders:
+//
+//	forwardAuth:
+//	  address: http://openid-auth:8080/auth
+//	  authResponseHeaders: [X-User-Subject, X-User-Issuer, X-User-Claims]
+//
+// The original request is rebuilt from the X-Forwarded-Method, X-Forwarded-Host and
+// X-Forwarded-Uri headers, so the SkipPaths and SkipPreflight options of the configuration apply
+// to it, and RequireTLS reads its protocol from the X-Forwarded-Proto header when Traefik is a
+// trusted proxy. The handler responds with status 200/OK and the identity headers of the user, see
+// IdentityHeaders, when it is authenticated, and with the response of the ErrorHandlerFunc
+// otherwise. Without ErrorHandler option, the requests without valid token are rejected with
+// status 401/Unauthorized and the authorization errors with status 403/Forbidden.
+func ForwardAuthHandler(conf *Configuration) http.Handler {
+	return gatewayHandler(conf, http.StatusOK, func(r *http.Request) *http.Request {
+		return originalRequest(r, forwardedMethodHeaderName, forwardedHostHeaderName, forwardedURIHeaderName)
+	})
+}
+
+// gatewayHandler returns the handler authenticating the original requests rebuilt by original
+// for a gateway, responding with the status and the identity headers of the user when the request
+// is authenticated, and with the response of the ErrorHandlerFunc of conf otherwise.
+func gatewayHandler(conf *Configuration, status int, original func(r *http.Request) *http.Request) http.Handler {
+	if conf.errorHandler == nil {
+		conf = conf.Route(RouteErrorHandler(forwardAuthErrorHandler))
+	}
+
+	h := AuthenticateUserContext(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := UserFromContext(r.Context()); ok {
+			setIdentityHeaders(w.Header(), u.Claims)
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, original(r))
+	})
+}
+
+// originalRequest returns the original request described by the headers of r with the given names.
+func originalRequest(r *http.Request, methodHeader string, hostHeader string, uriHeader string) *http.Request {
+	fr := r.WithContext(r.Context())
+	fr.Header = r.Header.Clone()
+
+	if m := r.Header.Get(methodHeader); m != "" {
+		fr.Method = m
+	}
+
+	if h := r.Header.Get(hostHeader); h != "" {
+		fr.Host = h
+	}
+
+	if uri := r.Header.Get(uriHeader); uri != "" {
+		if u, err := url.ParseRequestURI(uri); err == nil {
+			fr.URL = u
+			fr.RequestURI = uri
+		}
+	}
+
+	return fr
+}
+
+// forwardAuthErrorHandler responds to the errors of the gateway endpoints, rejecting the
+// requests whose token is missing or malformed with status 401/Unauthorized rather than
+// 400/Bad Request, as expected by the gateways.
+func forwardAuthErrorHandler(e error, w http.ResponseWriter, r *http.Request) bool {
+	if ve, ok := e.(*ValidationError); ok && ve.HTTPStatus == http.StatusBadRequest {
+		w.Header().Set(wwwAuthenticateHeaderName, "Bearer")
+		ue := *ve
+		ue.HTTPStatus = http.StatusUnauthorized
+		e = &ue
+	} else if ok && ve.HTTPStatus == http.StatusUnauthorized {
+		w.Header().Set(wwwAuthenticateHeaderName, "Bearer")
+	}
+
+	return validationErrorToHTTPStatus(e, w, r)
+}
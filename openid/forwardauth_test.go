@@ -0,0 +1,62 @@
+package openid
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ForwardAuthHandler(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), SkipPaths("GET /public/*"))
+
+	h := ForwardAuthHandler(c)
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	tests := []struct {
+		method  string
+		uri     string
+		token   string
+		status  int
+		subject string
+	}{
+		{http.MethodGet, "/api/orders?page=2", ts, http.StatusOK, "user1"},
+		{http.MethodGet, "/api/orders", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/api/orders", "invalid", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/public/index.html", "", http.StatusOK, ""},
+		{http.MethodPost, "/public/form", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/auth", nil)
+		r.Header.Set("X-Forwarded-Method", test.method)
+		r.Header.Set("X-Forwarded-Host", "api.example.com")
+		r.Header.Set("X-Forwarded-Uri", test.uri)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status || w.Header().Get(UserSubjectHeaderName) != test.subject {
+			t.Errorf("Expected the status %v and subject %q for %v %v, but got %v and %q", test.status, test.subject, test.method, test.uri, w.Code, w.Header().Get(UserSubjectHeaderName))
+		}
+
+		if test.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Error("Expected the Bearer challenge, but got", w.Header().Get("WWW-Authenticate"))
+		}
+
+		if test.subject != "" {
+			if _, err := base64.StdEncoding.DecodeString(w.Header().Get(UserClaimsHeaderName)); err != nil {
+				t.Error("Expected the encoded claims, but got", err)
+			}
+		}
+	}
+}
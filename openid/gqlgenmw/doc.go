@@ -0,0 +1,22 @@
+/*
+Package gqlgenmw integrates the openid middlewares with GraphQL servers generated by gqlgen
+(https://github.com/99designs/gqlgen). The Middleware authenticates the requests to the GraphQL
+endpoint and stores the user in their context, and HasScope and HasRole check it from the
+resolvers and directives:
+
+	srv := handler.NewDefaultServer(generated.NewExecutableSchema(c))
+	http.Handle("/query", gqlgenmw.Middleware(configuration)(srv))
+
+A directive such as `directive @hasScope(scope: String!) on FIELD_DEFINITION` is then implemented
+with:
+
+	c.Directives.HasScope = func(ctx context.Context, obj interface{}, next graphql.Resolver, scope string) (interface{}, error) {
+	    if err := gqlgenmw.HasScope(ctx, scope); err != nil {
+	        return nil, err
+	    }
+	    return next(ctx)
+	}
+
+The package does not import gqlgen, so it does not constrain its version.
+*/
+package gqlgenmw
@@ -0,0 +1,57 @@
+package gqlgenmw
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+// Middleware returns the middleware authenticating the requests to the GraphQL endpoint, as
+// openid.AuthenticateOptional. The requests without token are served without user, so a schema
+// can mix public and protected fields, the latter rejected by HasScope and HasRole. The requests
+// with an invalid token are handled by the ErrorHandlerFunc of conf. The request is stored in the
+// context along with the user, for the policies checked by Check.
+func Middleware(conf *openid.Configuration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return openid.AuthenticateOptional(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, r)))
+		}))
+	}
+}
+
+// requestKey is the context key of the request to the GraphQL endpoint stored by the Middleware.
+type requestKey struct{}
+
+// Check returns the error of the first of the policies not satisfied by the user stored in
+// ctx by the Middleware. The policies receive a nil user when ctx has no user, and the request
+// to the GraphQL endpoint stored in ctx by the Middleware. When ctx has no request, they receive
+// an empty request, so the policies depending on the request deny it.
+func Check(ctx context.Context, policies ...openid.Policy) error {
+	u, _ := openid.UserFromContext(ctx)
+	r, ok := ctx.Value(requestKey{}).(*http.Request)
+	if !ok {
+		r = (&http.Request{Method: http.MethodPost, URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+	}
+
+	for _, p := range policies {
+		if err := p(u, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HasScope returns an error when the token of the user stored in ctx does not grant all the
+// given scopes, as openid.RequireScope, or when ctx has no user.
+func HasScope(ctx context.Context, scopes ...string) error {
+	return Check(ctx, openid.RequireScope(scopes...))
+}
+
+// HasRole returns an error when the user stored in ctx does not have all the given roles, as
+// openid.RequireRole, or when ctx has no user.
+func HasRole(ctx context.Context, roles ...string) error {
+	return Check(ctx, openid.RequireRole(roles...))
+}
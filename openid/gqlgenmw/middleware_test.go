@@ -0,0 +1,98 @@
+package gqlgenmw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/oidctest"
+	"github.com/pachapman/openid2go/openid/openidctx"
+)
+
+func Test_Middleware(t *testing.T) {
+	op, err := oidctest.NewProvider(oidctest.NewClock(time.Now()))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer op.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{op.Provider("app")}, nil
+	}))
+
+	var scopeErr, tenantErr error
+	called := false
+	h := Middleware(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		scopeErr = HasScope(r.Context(), "read")
+		tenantErr = Check(r.Context(), func(u *openid.User, r *http.Request) error {
+			if r.Header.Get("X-Tenant") != "t1" {
+				return errors.New("tenant not allowed")
+			}
+			return nil
+		})
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query", nil))
+
+	if !called || scopeErr == nil {
+		t.Error("Expected the request without token to be served without user, but got", called, scopeErr)
+	}
+
+	ts, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "scope": "read"}, time.Hour)
+	r := httptest.NewRequest(http.MethodPost, "/query", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if scopeErr != nil {
+		t.Error("Expected the scope of the token to be granted, but got", scopeErr)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/query", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+	r.Header.Set("X-Tenant", "t1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if tenantErr != nil {
+		t.Error("Expected the policy to receive the request, but got", tenantErr)
+	}
+
+	if err := Check(context.Background(), func(u *openid.User, r *http.Request) error {
+		if r.Header.Get("X-Tenant") != "t1" {
+			return errors.New("tenant not allowed")
+		}
+		return nil
+	}); err == nil {
+		t.Error("Expected the policy to deny the context without request")
+	}
+
+	called = false
+	r = httptest.NewRequest(http.MethodPost, "/query", nil)
+	r.Header.Set("Authorization", "Bearer invalid")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if called || w.Code < http.StatusBadRequest {
+		t.Error("Expected the request with an invalid token to be rejected, but got", w.Code)
+	}
+}
+
+func Test_HasRole(t *testing.T) {
+	ctx := openidctx.WithUser(context.Background(), &openid.User{ID: "user1", Roles: []string{"admin", "editor"}})
+
+	if err := HasRole(ctx, "admin", "editor"); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if err := HasRole(ctx, "admin", "owner"); err == nil {
+		t.Error("An error was expected but not returned")
+	}
+
+	if err := HasRole(context.Background(), "admin"); err == nil {
+		t.Error("An error was expected without user but not returned")
+	}
+}
@@ -0,0 +1,259 @@
+package openid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// The claims of the Azure AD tokens signaling that the groups of the user were omitted because
+// they exceed the limit of the token, see hasGroupsOverage.
+const (
+	claimNamesClaimName = "_claim_names"
+	hasGroupsClaimName  = "hasgroups"
+	groupsClaimName     = "groups"
+	objectIDClaimName   = "oid"
+)
+
+// graphGroupsURL is the Microsoft Graph endpoint returning the groups of the user with the
+// object ID set as its single placeholder.
+const graphGroupsURL = "https://graph.microsoft.com/v1.0/users/%v/getMemberGroups"
+
+// GroupsResolverFunc returns the groups of the user u authenticated by the request r when its token
+// omits them, see GroupsResolver.
+type GroupsResolverFunc func(r *http.Request, u *User) ([]string, error)
+
+// GroupsResolver option registers the function resolving the groups of the users whose token
+// omits them, used by RequireGroups and RequireAnyGroup. Azure AD omits the 'groups' claim of the
+// users member of more groups than fit in a token, the groups overage, and signals it with the
+// '_claim_names' or 'hasgroups' claims; the groups are then retrieved from Microsoft Graph, i.e.:
+// with GraphGroups. The resolved groups replace the Groups of the User. The groups of the other
+// tokens are read from the claim named by the GroupsClaim of their provider, "groups" for Azure AD.
+func GroupsResolver(gr GroupsResolverFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.groupsResolver = gr
+		return nil
+	}
+}
+
+// GraphGroups returns a GroupsResolverFunc retrieving the IDs of the groups of the user, named by
+// the 'oid' claim of its token, from the getMemberGroups function of Microsoft Graph. The requests
+// are sent with the client hc, http.DefaultClient when nil, and the Authorization header provided
+// by credentials, a token of the service granted the GroupMember.Read.All permission. The groups
+// are cached for each user until the expiration of its token.
+func GraphGroups(credentials CredentialsFunc, hc *http.Client) GroupsResolverFunc {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	gg := newGraphGroups(graphGroupsURL, credentials, hc)
+	return gg.resolve
+}
+
+type graphGroups struct {
+	url         string
+	credentials CredentialsFunc
+	client      *http.Client
+
+	mu       sync.Mutex
+	groups   map[string]graphGroupsEntry
+	expiries expiryQueue
+	now      func() time.Time
+}
+
+// graphGroupsEntry contains the groups of a user cached until the expiration of its token.
+type graphGroupsEntry struct {
+	groups []string
+	exp    time.Time
+}
+
+func newGraphGroups(url string, credentials CredentialsFunc, hc *http.Client) *graphGroups {
+	return &graphGroups{
+		url:         url,
+		credentials: credentials,
+		client:      hc,
+		groups:      make(map[string]graphGroupsEntry),
+		now:         time.Now,
+	}
+}
+
+func (gg *graphGroups) resolve(r *http.Request, u *User) ([]string, error) {
+	oid, _ := u.Claims[objectIDClaimName].(string)
+	if oid == "" {
+		return nil, fmt.Errorf("The token of the user %v does not contain the '%v' claim.", u.ID, objectIDClaimName)
+	}
+
+	key := u.Issuer + " " + oid
+	if groups, ok := gg.cached(key); ok {
+		return groups, nil
+	}
+
+	groups, err := gg.retrieve(r, oid)
+	if err != nil {
+		return nil, err
+	}
+
+	if exp, ok := u.GetTime("exp"); ok {
+		gg.cache(key, groups, exp)
+	}
+
+	return groups, nil
+}
+
+// cached returns the groups cached for the key, unless they expired.
+func (gg *graphGroups) cached(key string) ([]string, bool) {
+	gg.mu.Lock()
+	defer gg.mu.Unlock()
+
+	e, ok := gg.groups[key]
+	if !ok || !gg.now().Before(e.exp) {
+		return nil, false
+	}
+
+	return e.groups, true
+}
+
+// cache stores the groups for the key until exp, removing the expired groups.
+func (gg *graphGroups) cache(key string, groups []string, exp time.Time) {
+	gg.mu.Lock()
+	defer gg.mu.Unlock()
+
+	gg.expiries.expire(gg.now(), func(k string, exp time.Time) {
+		if e, ok := gg.groups[k]; ok && e.exp.Equal(exp) {
+			delete(gg.groups, k)
+		}
+	})
+
+	if !gg.now().Before(exp) {
+		return
+	}
+
+	gg.groups[key] = graphGroupsEntry{groups: groups, exp: exp}
+	gg.expiries.add(key, exp)
+}
+
+// retrieve returns the groups of the user with the object ID oid from Microsoft Graph.
+func (gg *graphGroups) retrieve(r *http.Request, oid string) ([]string, error) {
+
+	authorization, err := gg.credentials(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(gg.url, url.PathEscape(oid)), bytes.NewReader([]byte(`{"securityEnabledOnly":false}`)))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(r.Context())
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := gg.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Microsoft Graph returned status %v.", resp.StatusCode)
+	}
+
+	var groups struct {
+		Value []string `json:"value"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&groups)
+	return groups.Value, err
+}
+
+// hasGroupsOverage returns true when the claims signal that the groups of the user were omitted.
+func hasGroupsOverage(claims map[string]interface{}) bool {
+	if names, ok := claims[claimNamesClaimName].(map[string]interface{}); ok {
+		if _, ok := names[groupsClaimName]; ok {
+			return true
+		}
+	}
+
+	hg, _ := claims[hasGroupsClaimName].(bool)
+	return hg
+}
+
+// userGroups returns the groups of the user u, resolved with the GroupsResolverFunc of c when its
+// token omits them.
+func (c *Configuration) userGroups(r *http.Request, u *User) ([]string, error) {
+	if u == nil {
+		return nil, nil
+	}
+
+	if c.groupsResolver == nil || !hasGroupsOverage(u.Claims) {
+		return u.Groups, nil
+	}
+
+	groups, err := c.groupsResolver(r, u)
+	if err != nil {
+		return nil, &ValidationError{
+			Code:       ValidationErrorGroupsResolutionFailure,
+			Message:    fmt.Sprintf("Failure while resolving the groups of the user %v.", u.ID),
+			Err:        err,
+			HTTPStatus: http.StatusBadGateway,
+		}
+	}
+
+	u.Groups = groups
+	return groups, nil
+}
+
+// RequireGroups middleware rejects the requests whose user is not member of all the given groups
+// with status 403/Forbidden. The groups are read from the claim named by the GroupsClaim of the
+// provider, or resolved with the GroupsResolver option when the token omits them. As RequireScopes,
+// it must be registered after AuthenticateUserContext, New or AuthenticateOptional, and the errors
+// are handled by the ErrorHandlerFunc of conf, with the error code ValidationErrorInsufficientGroup.
+func RequireGroups(conf *Configuration, groups ...string) func(next http.Handler) http.Handler {
+	return groupsMiddleware(conf, groups, true)
+}
+
+// RequireAnyGroup middleware rejects the requests whose user is not member of any of the given
+// groups, and is otherwise registered as RequireGroups.
+func RequireAnyGroup(conf *Configuration, groups ...string) func(next http.Handler) http.Handler {
+	return groupsMiddleware(conf, groups, false)
+}
+
+func groupsMiddleware(conf *Configuration, groups []string, all bool) func(next http.Handler) http.Handler {
+	return authorizationMiddleware(conf, "", func(r *http.Request, u *User) error {
+		ug, err := conf.userGroups(r, u)
+		if err != nil {
+			return err
+		}
+
+		for _, g := range groups {
+			member := containsString(ug, g)
+			if member && !all {
+				return nil
+			}
+
+			if !member && all {
+				return &ValidationError{
+					Code:       ValidationErrorInsufficientGroup,
+					Message:    fmt.Sprintf("The user is not member of the group %v.", g),
+					HTTPStatus: http.StatusForbidden,
+				}
+			}
+		}
+
+		if all {
+			return nil
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorInsufficientGroup,
+			Message:    fmt.Sprintf("The user is not member of any of the groups %v.", groups),
+			HTTPStatus: http.StatusForbidden,
+		}
+	})
+}
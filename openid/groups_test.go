@@ -0,0 +1,136 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_RequireGroups(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	resolved := 0
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}, GroupsClaim: "groups"}}, nil
+	}), GroupsResolver(func(r *http.Request, u *User) ([]string, error) {
+		resolved++
+		if u.ID == "broken" {
+			return nil, errors.New("Graph unavailable")
+		}
+
+		return []string{"admins", "editors"}, nil
+	}))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	all := New(c)(RequireGroups(c, "admins", "editors")(ok))
+	oneOf := New(c)(RequireAnyGroup(c, "admins", "editors")(ok))
+	overage := map[string]interface{}{"groups": "src1"}
+
+	tests := []struct {
+		h        http.Handler
+		claims   map[string]interface{}
+		status   int
+		resolved int
+	}{
+		{all, map[string]interface{}{"groups": []interface{}{"admins", "editors"}}, http.StatusNoContent, 0},
+		{all, map[string]interface{}{"groups": []interface{}{"admins"}}, http.StatusForbidden, 0},
+		{oneOf, map[string]interface{}{"groups": []interface{}{"editors"}}, http.StatusNoContent, 0},
+		{oneOf, map[string]interface{}{}, http.StatusForbidden, 0},
+		{all, map[string]interface{}{"_claim_names": overage}, http.StatusNoContent, 1},
+		{oneOf, map[string]interface{}{"hasgroups": true}, http.StatusNoContent, 1},
+		{all, map[string]interface{}{"sub": "broken", "hasgroups": true}, http.StatusBadGateway, 1},
+	}
+
+	for _, test := range tests {
+		resolved = 0
+		claims := map[string]interface{}{"sub": "user1", "aud": "app"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		test.h.ServeHTTP(w, r)
+
+		if w.Code != test.status || resolved != test.resolved {
+			t.Errorf("Expected the status %v with %v resolutions for the claims %v, but got %v and %v", test.status, test.resolved, test.claims, w.Code, resolved)
+		}
+	}
+}
+
+func Test_GraphGroups(t *testing.T) {
+	var path, authorization string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, authorization = r.URL.Path, r.Header.Get("Authorization")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Write([]byte(`{"value":["g1","g2"]}`))
+	}))
+	defer s.Close()
+
+	gg := newGraphGroups(s.URL+"/users/%v/getMemberGroups", BearerCredentials("graph"), http.DefaultClient)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	groups, err := gg.resolve(r, &User{ID: "user1", Claims: map[string]interface{}{"oid": "oid1"}})
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if !reflect.DeepEqual(groups, []string{"g1", "g2"}) || path != "/users/oid1/getMemberGroups" || authorization != "Bearer graph" {
+		t.Error("Expected the groups of the user oid1 retrieved with the credentials, but got", groups, path, authorization)
+	}
+
+	if _, err := gg.resolve(r, &User{ID: "user1", Claims: map[string]interface{}{}}); err == nil {
+		t.Error("An error was expected without the 'oid' claim but not returned")
+	}
+}
+
+func Test_GraphGroups_Cache(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"value":["g1"]}`))
+	}))
+	defer s.Close()
+
+	now := time.Unix(1700000000, 0)
+	gg := newGraphGroups(s.URL+"/users/%v/getMemberGroups", BearerCredentials("graph"), http.DefaultClient)
+	gg.now = func() time.Time { return now }
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	u := &User{Issuer: "https://issuer", ID: "user1", Claims: map[string]interface{}{"oid": "oid1", "exp": float64(now.Unix() + 60)}}
+	other := &User{Issuer: "https://issuer", ID: "user2", Claims: map[string]interface{}{"oid": "oid2"}}
+
+	for _, test := range []struct {
+		u        *User
+		after    time.Duration
+		requests int
+	}{
+		{u, 0, 1},
+		{u, 30 * time.Second, 1},
+		{other, 0, 2},
+		{other, 0, 3},
+		{u, 60 * time.Second, 4},
+	} {
+		now = now.Add(test.after)
+		if _, err := gg.resolve(r, test.u); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if requests != test.requests {
+			t.Errorf("Expected %v requests to Microsoft Graph for the user %v, but got %v", test.requests, test.u.ID, requests)
+		}
+	}
+}
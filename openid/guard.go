@@ -0,0 +1,69 @@
+package openid
+
+import (
+	"net/http"
+)
+
+// Guard composes the authentication of a Configuration with authorization requirements, see
+// Configuration.Protect. Each method returns a new Guard, so a Guard can be shared as the base of
+// others.
+type Guard struct {
+	conf     *Configuration
+	options  []RouteOption
+	policies []Policy
+}
+
+// Protect returns a Guard building the handlers authenticating the requests with c and enforcing
+// the requirements added to it, without nesting middlewares:
+//
+//	h := conf.Protect().RequireScope("read:orders").RequireRole("admin").Handler(ordersHandler)
+//
+// The requirements are checked in the order they were added, after the checks of c, and their
+// errors are handled by the ErrorHandlerFunc of c.
+func (c *Configuration) Protect() *Guard {
+	return &Guard{conf: c}
+}
+
+// Require returns a Guard also requiring the authenticated user to satisfy the given policies.
+func (g *Guard) Require(policies ...Policy) *Guard {
+	ng := *g
+	ng.policies = append(append([]Policy(nil), g.policies...), policies...)
+	return &ng
+}
+
+// RequireScope returns a Guard also requiring the token to grant all the given scopes, as the
+// RequireScope policy.
+func (g *Guard) RequireScope(scopes ...string) *Guard {
+	return g.Require(RequireScope(scopes...))
+}
+
+// RequireRole returns a Guard also requiring the user to have all the given roles, as the
+// RequireRole policy.
+func (g *Guard) RequireRole(roles ...string) *Guard {
+	return g.Require(RequireRole(roles...))
+}
+
+// With returns a Guard also applying the given route options, i.e.: RouteAudiences or
+// RouteErrorHandler, see Configuration.Route.
+func (g *Guard) With(options ...RouteOption) *Guard {
+	ng := *g
+	ng.options = append(append([]RouteOption(nil), g.options...), options...)
+	return &ng
+}
+
+// Handler returns the handler authenticating the requests and enforcing the requirements of the
+// Guard before forwarding them to h, as AuthenticateUserContext.
+func (g *Guard) Handler(h http.Handler) http.Handler {
+	options := g.options
+	if len(g.policies) > 0 {
+		options = append(append([]RouteOption(nil), options...), RoutePolicies(g.policies...))
+	}
+
+	return AuthenticateUserContext(g.conf.Route(options...), h)
+}
+
+// HandlerFunc returns the handler authenticating the requests and enforcing the requirements of
+// the Guard before forwarding them to f, as Handler.
+func (g *Guard) HandlerFunc(f func(http.ResponseWriter, *http.Request)) http.Handler {
+	return g.Handler(http.HandlerFunc(f))
+}
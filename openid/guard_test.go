@@ -0,0 +1,65 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Configuration_Protect(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	roles := func(claims map[string]interface{}) []string { return claimStrings(claims["roles"]) }
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app", "admin"}, RolesFunc: roles}}, nil
+	}))
+
+	base := c.Protect().RequireScope("read:orders")
+	admin := base.RequireRole("admin").With(RouteAudiences("admin"))
+
+	tests := []struct {
+		guard  *Guard
+		claims map[string]interface{}
+		status int
+	}{
+		{base, map[string]interface{}{"aud": "app", "scope": "read:orders"}, http.StatusNoContent},
+		{base, map[string]interface{}{"aud": "app"}, http.StatusForbidden},
+		{admin, map[string]interface{}{"aud": "app", "scope": "read:orders", "roles": "admin"}, http.StatusForbidden},
+		{admin, map[string]interface{}{"aud": "admin", "scope": "read:orders"}, http.StatusForbidden},
+		{admin, map[string]interface{}{"aud": "admin", "scope": "read:orders", "roles": []interface{}{"admin"}}, http.StatusNoContent},
+		{c.Protect(), map[string]interface{}{"aud": "other"}, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		var u *User
+		h := test.guard.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, _ = UserFromContext(r.Context())
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		claims := map[string]interface{}{"sub": "user1"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Expected the status %v for the claims %v, but got %v", test.status, test.claims, w.Code)
+		}
+
+		if test.status == http.StatusNoContent && (u == nil || u.ID != "user1") {
+			t.Error("Expected the user user1 in the request context, but got", u)
+		}
+	}
+
+	if len(base.policies) != 1 || len(base.options) != 0 {
+		t.Error("Expected the derived guards not to change their base, but got", base)
+	}
+}
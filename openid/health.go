@@ -0,0 +1,214 @@
+package openid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCacheTTL is the duration the report of HealthHandler is served from before probing the
+// providers again.
+const healthCacheTTL = 10 * time.Second
+
+// unreachableEndpoint is the error reported by HealthHandler for the endpoints not reached.
+const unreachableEndpoint = "unreachable"
+
+// ProviderHealth reports whether the discovery document and signing keys of a provider can be
+// retrieved, along with the state of its cached signing keys, see Health.
+type ProviderHealth struct {
+	Issuer    string          `json:"issuer"`
+	Discovery *EndpointHealth `json:"discovery,omitempty"`
+	Jwks      *EndpointHealth `json:"jwks,omitempty"`
+	Keys      KeysHealth      `json:"keys"`
+}
+
+// EndpointHealth reports whether the document at URL was retrieved, or the error that occurred.
+type EndpointHealth struct {
+	URL       string `json:"url,omitempty"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// KeysHealth describes the signing keys of a provider in the cache. Fresh is false when the keys
+// are not cached, have expired or were flushed, in which case they are retrieved again on demand.
+type KeysHealth struct {
+	Cached  bool      `json:"cached"`
+	Count   int       `json:"count"`
+	Expires time.Time `json:"expires,omitempty"`
+	Fresh   bool      `json:"fresh"`
+}
+
+// Healthy returns true when the endpoints of the provider were reached.
+func (ph ProviderHealth) Healthy() bool {
+	return (ph.Discovery == nil || ph.Discovery.Reachable) && (ph.Jwks == nil || ph.Jwks.Reachable)
+}
+
+// Health retrieves the discovery document and signing keys of every provider returned by the
+// GetProvidersFunc and reports whether they were reached, along with the state of the cached keys.
+// The cached keys are not replaced. The endpoints of the providers registered with Keys are not
+// reported, and the providers registered with issuer templates are skipped, as by Warmup.
+// The HTTPGetFunc and JwksCredentials receive a request carrying ctx, which bounds the retrievals.
+func (c *Configuration) Health(ctx context.Context) ([]ProviderHealth, error) {
+	tv := c.idTokenValidator()
+	if tv.provGetter == nil {
+		return nil, nil
+	}
+
+	provs, err := tv.provGetter.get()
+	if err != nil {
+		return nil, err
+	}
+
+	r := newBackgroundRequest(ctx)
+	kp := tv.keyGetter.(*signingKeyProvider)
+	sksp, _ := kp.keySetGetter.(*signingKeySetProvider)
+
+	var health []ProviderHealth
+	for i := range provs {
+		p := &provs[i]
+		if p.isTemplate() {
+			continue
+		}
+
+		ph := ProviderHealth{Issuer: p.Issuer, Keys: kp.keysHealth(p.Issuer)}
+		if sksp != nil && len(p.Keys) == 0 {
+			ph.Discovery, ph.Jwks = sksp.probe(r, p)
+		}
+
+		health = append(health, ph)
+	}
+
+	return health, nil
+}
+
+// HealthHandler returns an http.Handler serving the report of Health as JSON, i.e.: to be
+// registered at /healthz. The status is 200/OK when the endpoints of all the providers were
+// reached and 503/Service Unavailable otherwise, or when the providers cannot be retrieved.
+// The report is cached for 10 seconds, so the requests cannot make the providers be probed at
+// will, and the errors of the endpoints are reported as "unreachable" without their details.
+func (c *Configuration) HealthHandler() http.Handler {
+	hc := &healthCache{conf: c, now: time.Now}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		health, err := hc.health(r.Context())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		status := http.StatusOK
+		for _, ph := range health {
+			if !ph.Healthy() {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		body, err := json.Marshal(map[string]interface{}{"providers": health})
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// healthCache caches the report of Health served by HealthHandler for healthCacheTTL.
+type healthCache struct {
+	conf *Configuration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	report  []ProviderHealth
+	err     error
+	expires time.Time
+}
+
+// health returns the cached report, probing the providers again once it expired. The report
+// is not cached when ctx is done before the probes complete.
+func (hc *healthCache) health(ctx context.Context) ([]ProviderHealth, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.now().Before(hc.expires) {
+		return hc.report, hc.err
+	}
+
+	health, err := hc.conf.Health(ctx)
+	for i := range health {
+		health[i].Discovery = genericEndpointHealth(health[i].Discovery)
+		health[i].Jwks = genericEndpointHealth(health[i].Jwks)
+	}
+
+	if ctx.Err() == nil {
+		hc.report, hc.err, hc.expires = health, err, hc.now().Add(healthCacheTTL)
+	}
+
+	return health, err
+}
+
+// genericEndpointHealth returns the health of the endpoint with a generic error.
+func genericEndpointHealth(eh *EndpointHealth) *EndpointHealth {
+	if eh == nil || eh.Error == "" {
+		return eh
+	}
+
+	geh := *eh
+	geh.Error = unreachableEndpoint
+	return &geh
+}
+
+// probe retrieves the discovery document and the signing keys of the provider. The signing keys
+// are not reported when the discovery document cannot be retrieved.
+func (signProv *signingKeySetProvider) probe(r *http.Request, p *Provider) (*EndpointHealth, *EndpointHealth) {
+	var discovery *EndpointHealth
+	u := p.CertificatesURL
+	if u == "" {
+		discovery = &EndpointHealth{URL: discoveryURL(p)}
+		conf, err := getProviderConfiguration(signProv.configGetter, r, p)
+		if err != nil {
+			discovery.Error = err.Error()
+			return discovery, nil
+		}
+
+		discovery.Reachable = true
+		u = conf.JwksURI
+	}
+
+	jwks := &EndpointHealth{URL: u}
+	if _, err := signProv.jwksGetter.get(r, u, p.JwksCredentials, p.CertificatesURL != ""); err != nil {
+		jwks.Error = err.Error()
+		return discovery, jwks
+	}
+
+	jwks.Reachable = true
+	return discovery, jwks
+}
+
+// keysHealth returns the state of the signing keys of the issuer in the cache.
+func (s *signingKeyProvider) keysHealth(issuer string) KeysHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ck, ok := s.cachedKeys(issuer)
+	if !ok {
+		return KeysHealth{}
+	}
+
+	return KeysHealth{
+		Cached:  true,
+		Count:   len(ck.keys),
+		Expires: ck.expiry,
+		Fresh:   !ck.flushed && s.now().Before(ck.expiry),
+	}
+}
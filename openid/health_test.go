@@ -0,0 +1,140 @@
+package openid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createHealthConfiguration(t *testing.T, provs ...Provider) (*TokenIssuer, *httptest.Server, *Configuration) {
+	var ti *TokenIssuer
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	provs = append([]Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, provs...)
+	c, err := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return provs, nil
+	}))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	return ti, s, c
+}
+
+func Test_Configuration_Health(t *testing.T) {
+	ti, s, c := createHealthConfiguration(t,
+		Provider{Issuer: "http://127.0.0.1:1", ClientIDs: []string{"app"}},
+		Provider{Issuer: "https://login/{tenant}/v2.0", ClientIDs: []string{"app"}, TenantValidator: func(string) error { return nil }})
+	defer s.Close()
+
+	if err := c.RefreshKeys(context.Background(), s.URL); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	health, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(health) != 2 {
+		t.Fatal("Expected the health of 2 providers, but got", health)
+	}
+
+	ph := health[0]
+	if !ph.Healthy() || ph.Discovery.URL != s.URL+wellKnownOpenIDConfiguration || ph.Jwks.URL != s.URL+WellKnownJwksPath {
+		t.Errorf("Expected the endpoints of %v to be reachable, but got %+v %+v", s.URL, ph.Discovery, ph.Jwks)
+	}
+
+	if !ph.Keys.Cached || !ph.Keys.Fresh || ph.Keys.Count != len(ti.keys.Public().Keys) || !ph.Keys.Expires.After(time.Now()) {
+		t.Errorf("Expected fresh cached keys, but got %+v", ph.Keys)
+	}
+
+	ph = health[1]
+	if ph.Healthy() || ph.Discovery.Reachable || ph.Discovery.Error == "" || ph.Jwks != nil {
+		t.Errorf("Expected the discovery document of %v to be unreachable, but got %+v %+v", ph.Issuer, ph.Discovery, ph.Jwks)
+	}
+
+	if ph.Keys.Cached || ph.Keys.Fresh {
+		t.Errorf("Expected no cached keys, but got %+v", ph.Keys)
+	}
+}
+
+func Test_Configuration_HealthHandler(t *testing.T) {
+	_, s, c := createHealthConfiguration(t)
+	defer s.Close()
+
+	w := httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected status 200 with a JSON body, but got %v %v", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var body struct {
+		Providers []ProviderHealth `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Providers) != 1 || !body.Providers[0].Healthy() {
+		t.Errorf("Expected a healthy provider, but got %v %v", w.Body.String(), err)
+	}
+
+	s.Close()
+
+	w = httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if w.Code != http.StatusServiceUnavailable || strings.Contains(w.Body.String(), "connect") || !strings.Contains(w.Body.String(), unreachableEndpoint) {
+		t.Error("Expected status 503 with a generic error once the provider is down, but got", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("Expected status 405, but got", w.Code)
+	}
+}
+
+func Test_Configuration_HealthHandler_WhenProvidersFail(t *testing.T) {
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return nil, &SetupError{Code: SetupErrorEmptyProviderCollection, Message: "No providers."}
+	}))
+
+	w := httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if w.Code != http.StatusServiceUnavailable || strings.Contains(w.Body.String(), "No providers.") {
+		t.Error("Expected status 503 without the error, but got", w.Code, w.Body.String())
+	}
+}
+
+func Test_healthCache(t *testing.T) {
+	_, s, c := createHealthConfiguration(t)
+	defer s.Close()
+
+	now := time.Now()
+	hc := &healthCache{conf: c, now: func() time.Time { return now }}
+
+	if health, err := hc.health(context.Background()); err != nil || !health[0].Healthy() {
+		t.Fatal("Expected a healthy provider, but got", health, err)
+	}
+
+	s.Close()
+
+	if health, _ := hc.health(context.Background()); !health[0].Healthy() {
+		t.Error("Expected the cached report, but got", health)
+	}
+
+	now = now.Add(healthCacheTTL)
+	if health, _ := hc.health(context.Background()); health[0].Healthy() || health[0].Discovery.Error != unreachableEndpoint {
+		t.Errorf("Expected the provider to be probed again once the report expired, but got %+v", health[0].Discovery)
+	}
+}
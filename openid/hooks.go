@@ -0,0 +1,53 @@
+package openid
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// BeforeValidationFunc is called with the request r and its raw token t before the token is
+// validated, see BeforeValidation.
+type BeforeValidationFunc func(r *http.Request, t string) error
+
+// AfterValidationFunc is called with the request r and its validated token t, see AfterValidation.
+type AfterValidationFunc func(r *http.Request, t *jwt.Token)
+
+// BeforeValidation option registers a function called with every token extracted from the
+// requests before it is validated, i.e.: to reject the tokens of a custom denylist without
+// retrieving the signing keys. The error returned by the function rejects the request and is handled
+// by the ErrorHandlerFunc; return a *ValidationError to choose its status, i.e.: 401/Unauthorized.
+// The functions are called in the order they were registered, and the first error stops the calls.
+func BeforeValidation(f BeforeValidationFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.beforeValidation = append(c.beforeValidation, f)
+		return nil
+	}
+}
+
+// AfterValidation option registers a function called with every token once it was validated and
+// passed the checks of the configuration, i.e.: to record an audit trail or to enrich the claims of
+// the token before the User is created from them. The functions are called in the order they were
+// registered.
+func AfterValidation(f AfterValidationFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.afterValidation = append(c.afterValidation, f)
+		return nil
+	}
+}
+
+func (c *Configuration) runBeforeValidation(r *http.Request, t string) error {
+	for _, f := range c.beforeValidation {
+		if err := f(r, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Configuration) runAfterValidation(r *http.Request, t *jwt.Token) {
+	for _, f := range c.afterValidation {
+		f(r, t)
+	}
+}
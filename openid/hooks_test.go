@@ -0,0 +1,61 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func Test_BeforeAndAfterValidation(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	denied, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	allowed, _ := ti.Issue(map[string]interface{}{"sub": "user2", "aud": "app"}, time.Hour)
+
+	var calls []string
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), BeforeValidation(func(r *http.Request, ts string) error {
+		calls = append(calls, "before")
+		if ts == denied {
+			return &ValidationError{Code: ValidationErrorTokenRevoked, Message: "Denied.", HTTPStatus: http.StatusUnauthorized}
+		}
+		return nil
+	}), AfterValidation(func(r *http.Request, jt *jwt.Token) {
+		calls = append(calls, "after")
+		jt.Claims.(jwt.MapClaims)["tier"] = "gold"
+	}))
+
+	var u *User
+	h := AuthenticateUserContext(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ = UserFromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+denied)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized || u != nil || len(calls) != 1 {
+		t.Error("Expected the denied token to be rejected before validation, but got", w.Code, calls)
+	}
+
+	calls = nil
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+allowed)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if u == nil || u.Claims["tier"] != "gold" || len(calls) != 2 || calls[1] != "after" {
+		t.Error("Expected the enriched user after the hooks, but got", u, calls)
+	}
+
+	calls = nil
+	if _, err := NewValidator(c).Validate(context.Background(), denied); err == nil || len(calls) != 1 {
+		t.Error("Expected the hooks to apply to the Validator, but got", err, calls)
+	}
+}
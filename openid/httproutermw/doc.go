@@ -0,0 +1,16 @@
+/*
+Package httproutermw adapts the openid middlewares to the httprouter router
+(https://github.com/julienschmidt/httprouter), forwarding the route parameters to the handlers,
+so the core openid package does not depend on httprouter:
+
+	router := httprouter.New()
+	router.GET("/users/:id", httproutermw.AuthenticateUser(configuration,
+	    func(u *openid.User, w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	        ...
+	    }))
+
+The middlewares behave as openid.Authenticate and openid.AuthenticateUser, the raw token, its
+provider and the authenticated user are also stored in the request context, see the openidctx
+package.
+*/
+package httproutermw
@@ -0,0 +1,34 @@
+package httproutermw
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pachapman/openid2go/openid"
+)
+
+// The UserHandler represents a handler to be registered by the middleware AuthenticateUser.
+// It is similar to the openid.UserHandler, with the additional parameters of the route matched
+// by httprouter.
+type UserHandler func(*openid.User, http.ResponseWriter, *http.Request, httprouter.Params)
+
+// Authenticate middleware performs the validation of the OIDC ID Token as openid.Authenticate,
+// forwarding the route parameters to the next handler(h) when the validation is successful.
+func Authenticate(conf *openid.Configuration, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		openid.Authenticate(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h(w, r, params)
+		})).ServeHTTP(w, r)
+	}
+}
+
+// AuthenticateUser middleware performs the validation of the OIDC ID Token as
+// openid.AuthenticateUser, forwarding the authenticated user's information and the route
+// parameters to the next handler(h) when the validation is successful.
+func AuthenticateUser(conf *openid.Configuration, h UserHandler) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		openid.AuthenticateUser(conf, func(u *openid.User, w http.ResponseWriter, r *http.Request) {
+			h(u, w, r, params)
+		}).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,55 @@
+package httproutermw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/oidctest"
+)
+
+func Test_Middlewares_ForwardParams(t *testing.T) {
+	op, err := oidctest.NewProvider(oidctest.NewClock(time.Now()))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer op.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{op.Provider("app")}, nil
+	}))
+
+	var id, uid, sub string
+	router := httprouter.New()
+	router.GET("/items/:id", Authenticate(c, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id = ps.ByName("id")
+	}))
+	router.GET("/users/:id", AuthenticateUser(c, func(u *openid.User, w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		uid, sub = ps.ByName("id"), u.ID
+	}))
+
+	ts, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	for _, path := range []string{"/items/1", "/users/2"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if id != "1" {
+		t.Error("Expected the param 1 forwarded by Authenticate, but got", id)
+	}
+
+	if uid != "2" || sub != "user1" {
+		t.Error("Expected the param 2 and the user user1 forwarded by AuthenticateUser, but got", uid, sub)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/3", nil))
+
+	if w.Code != http.StatusBadRequest || uid != "2" {
+		t.Error("Expected the request without token to be rejected, but got", w.Code)
+	}
+}
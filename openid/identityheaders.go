@@ -0,0 +1,78 @@
+package openid
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// The headers set by the IdentityHeaders option.
+const (
+	UserSubjectHeaderName = "X-User-Subject"
+	UserIssuerHeaderName  = "X-User-Issuer"
+	UserClaimsHeaderName  = "X-User-Claims"
+)
+
+// IdentityHeaders option sets the identity of the authenticated user as headers of the requests
+// forwarded to the next handler, so the services behind a reverse proxy built with the middlewares,
+// i.e.: with httputil.ReverseProxy, receive it without validating the token again:
+//
+//	X-User-Subject: the 'sub' claim of the token.
+//	X-User-Issuer:  the 'iss' claim of the token.
+//	X-User-Claims:  the claims of the token as JSON, encoded with standard base64.
+//
+// The headers sent by the clients are removed from every request handled by the middlewares,
+// including the requests skipped or whose authentication failed, so the services can trust them.
+// The headers whose names only differ by underscores instead of dashes, i.e.: X_User_Subject, are
+// removed as well, as some servers and frameworks treat them alike. The control characters of the
+// subject and issuer are removed.
+func IdentityHeaders() func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.identityHeaders = true
+		return nil
+	}
+}
+
+// stripIdentityHeaders removes the identity headers sent by the client from r, see
+// isIdentityHeader.
+func stripIdentityHeaders(r *http.Request) {
+	for n := range r.Header {
+		if isIdentityHeader(n) {
+			delete(r.Header, n)
+		}
+	}
+}
+
+// isIdentityHeader returns true when the header name n is the name of an identity header once its
+// underscores are replaced with dashes, regardless of the case.
+func isIdentityHeader(n string) bool {
+	n = strings.Replace(n, "_", "-", -1)
+	return strings.EqualFold(n, UserSubjectHeaderName) || strings.EqualFold(n, UserIssuerHeaderName) ||
+		strings.EqualFold(n, UserClaimsHeaderName)
+}
+
+// setIdentityHeaders sets the identity headers of the user with the claims in h.
+func setIdentityHeaders(h http.Header, claims map[string]interface{}) {
+	if sub, ok := claims[subjectClaimName].(string); ok {
+		h.Set(UserSubjectHeaderName, sanitizeHeaderValue(sub))
+	}
+
+	if iss, ok := claims[issuerClaimName].(string); ok {
+		h.Set(UserIssuerHeaderName, sanitizeHeaderValue(iss))
+	}
+
+	if data, err := json.Marshal(claims); err == nil {
+		h.Set(UserClaimsHeaderName, base64.StdEncoding.EncodeToString(data))
+	}
+}
+
+// sanitizeHeaderValue removes the control characters of v, so it cannot inject headers.
+func sanitizeHeaderValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, v)
+}
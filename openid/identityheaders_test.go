@@ -0,0 +1,66 @@
+package openid
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_IdentityHeaders(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), IdentityHeaders(), SkipPaths("/public"))
+
+	var forwarded http.Header
+	h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1\r\nX-Admin: true", "aud": "app", "email": "user1@example.com"}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+	r.Header.Set("X-User-Claims", "forged")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if sub := forwarded.Get(UserSubjectHeaderName); sub != "user1X-Admin: true" {
+		t.Error("Expected the sanitized subject, but got", sub)
+	}
+
+	if iss := forwarded.Get(UserIssuerHeaderName); iss != s.URL {
+		t.Error("Expected the issuer", s.URL, "but got", iss)
+	}
+
+	var claims map[string]interface{}
+	data, err := base64.StdEncoding.DecodeString(forwarded.Get(UserClaimsHeaderName))
+	if err == nil {
+		err = json.Unmarshal(data, &claims)
+	}
+
+	if err != nil || claims["email"] != "user1@example.com" {
+		t.Error("Expected the encoded claims of the token, but got", claims, err)
+	}
+
+	forwarded = nil
+	r = httptest.NewRequest(http.MethodGet, "/public", nil)
+	r.Header.Set(UserSubjectHeaderName, "admin")
+	r.Header.Set(UserIssuerHeaderName, s.URL)
+	r.Header["X_User_Subject"] = []string{"admin"}
+	r.Header["x_user_claims"] = []string{"forged"}
+	r.Header.Set("X-User-Name", "user1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if forwarded == nil || forwarded.Get(UserSubjectHeaderName) != "" || forwarded.Get(UserIssuerHeaderName) != "" ||
+		forwarded["X_User_Subject"] != nil || forwarded["x_user_claims"] != nil {
+		t.Error("Expected the headers of the client to be removed, but got", forwarded)
+	}
+
+	if forwarded.Get("X-User-Name") != "user1" {
+		t.Error("Expected the other headers of the client to be kept, but got", forwarded)
+	}
+}
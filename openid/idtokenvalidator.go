@@ -1,9 +1,9 @@
 package openid
 
 import (
-	"crypto/rsa"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 )
@@ -12,9 +12,13 @@ const issuerClaimName = "iss"
 const audiencesClaimName = "aud"
 const subjectClaimName = "sub"
 const keyIDJwtHeaderName = "kid"
+const x5tJwtHeaderName = "x5t"
+const x5tS256JwtHeaderName = "x5t#S256"
+const hostedDomainClaimName = "hd"
+const tokenUseClaimName = "token_use"
 
 type jwtTokenValidator interface {
-	validate(r *http.Request, t string) (jt *jwt.Token, err error)
+	validate(r *http.Request, t string) (jt *jwt.Token, p *Provider, err error)
 }
 
 type jwtParser interface {
@@ -27,33 +31,30 @@ func (p jwtParserFunc) parse(token string, keyFunc jwt.Keyfunc) (*jwt.Token, err
 	return p(token, keyFunc)
 }
 
-type pemToRSAPublicKeyParser interface {
-	parse(key []byte) (*rsa.PublicKey, error)
-}
-
-type defaultPemToRSAPublicKeyParser struct {
-}
-
-func (p *defaultPemToRSAPublicKeyParser) parse(key []byte) (*rsa.PublicKey, error) {
-	return jwt.ParseRSAPublicKeyFromPEM(key)
-}
-
-// type pemToRSAPublicKeyParserFunc func(key []byte) (*rsa.PublicKey, error)
-
+// idTokenValidator validates the signature, issuer, audiences and subject of a JWT. When
+// subjectOptional is true tokens without the 'sub' claim are accepted, i.e.: logout tokens.
 type idTokenValidator struct {
-	provGetter providersGetter
-	jwtParser  jwtParser
-	keyGetter  signingKeyGetter
-	rsaParser  pemToRSAPublicKeyParser
+	provGetter      providersGetter
+	jwtParser       jwtParser
+	keyGetter       signingKeyGetter
+	subjectOptional bool
+	issuers         *issuerEquivalents
+	raceProviders   bool
+	selector        ProvidersSelectorFunc
 }
 
-func newIDTokenValidator(pg GetProvidersFunc, jp jwtParser, kg signingKeyGetter, kp pemToRSAPublicKeyParser) *idTokenValidator {
-	return &idTokenValidator{pg, jp, kg, kp}
+func newIDTokenValidator(pg GetProvidersFunc, jp jwtParser, kg signingKeyGetter) *idTokenValidator {
+	return &idTokenValidator{provGetter: pg, jwtParser: jp, keyGetter: kg, issuers: newIssuerEquivalents()}
 }
 
-func (tv *idTokenValidator) validate(r *http.Request, t string) (*jwt.Token, error) {
-	jt, err := tv.jwtParser.parse(t, func(tok *jwt.Token) (interface{}, error) {
-		return tv.getSigningKey(r, tok)
+func (tv *idTokenValidator) validate(r *http.Request, t string) (*jwt.Token, *Provider, error) {
+	var p *Provider
+	jt, err := tv.jwtParser.parse(t, func(tok *jwt.Token) (key interface{}, err error) {
+		key, p, err = tv.getSigningKey(r, tok)
+		if err == nil {
+			err = validateAlgorithm(tok, p)
+		}
+		return key, err
 	})
 	if err != nil {
 
@@ -61,71 +62,141 @@ func (tv *idTokenValidator) validate(r *http.Request, t string) (*jwt.Token, err
 			// If the signing key did not match it may be because the in memory key is outdated.
 			// Renew the cached signing key.
 			if (verr.Errors & jwt.ValidationErrorSignatureInvalid) != 0 {
-				jt, err = tv.jwtParser.parse(t, func(tok *jwt.Token) (interface{}, error) {
-					return tv.renewAndGetSigningKey(r, tok)
+				jt, err = tv.jwtParser.parse(t, func(tok *jwt.Token) (key interface{}, err error) {
+					key, p, err = tv.renewAndGetSigningKey(r, tok)
+					if err == nil {
+						err = validateAlgorithm(tok, p)
+					}
+					return key, err
 				})
 			}
 		}
 	}
 
+	if err != nil && toleratedTimeError(jt, p, err) {
+		jt.Valid = true
+		err = nil
+	}
+
 	if err != nil {
-		return nil, jwtErrorToOpenIDError(err)
+		return nil, nil, jwtErrorToOpenIDError(err)
+	}
+
+	if err := validateHostedDomain(jt, p); err != nil {
+		return nil, nil, err
 	}
 
-	return jt, nil
+	if err := validateRequiredClaims(jt, p); err != nil {
+		return nil, nil, err
+	}
+
+	return jt, p, nil
 }
 
-func (tv *idTokenValidator) renewAndGetSigningKey(r *http.Request, jt *jwt.Token) (interface{}, error) {
-	// Issuer is already validated when 'getSigningKey was called.
-	iss := jt.Claims.(jwt.MapClaims)[issuerClaimName].(string)
+func (tv *idTokenValidator) renewAndGetSigningKey(r *http.Request, jt *jwt.Token) (interface{}, *Provider, error) {
+	if tv.raceProviders && !hasIssuer(jt) {
+		return tv.raceSigningKey(r, jt)
+	}
 
-	err := tv.keyGetter.flushCachedSigningKeys(iss)
+	p, aud, err := tv.getProvider(r, jt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	kid := getTokenKid(jt)
-
-	var key []byte
-	if key, err = tv.keyGetter.getSigningKey(r, iss, kid); err == nil {
-		return tv.rsaParser.parse(key)
+	err = tv.keyGetter.flushCachedSigningKeys(p.keysIssuer())
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil, err
+	key, err := tv.getProviderSigningKey(r, p, aud, jt)
+	return key, p, err
 }
 
-func (tv *idTokenValidator) getSigningKey(r *http.Request, jt *jwt.Token) (interface{}, error) {
-	provs, err := tv.provGetter.get()
+func (tv *idTokenValidator) getSigningKey(r *http.Request, jt *jwt.Token) (interface{}, *Provider, error) {
+	if tv.raceProviders && !hasIssuer(jt) {
+		return tv.raceSigningKey(r, jt)
+	}
+
+	p, aud, err := tv.getProvider(r, jt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err := providers(provs).validate(); err != nil {
-		return nil, err
+	key, err := tv.getProviderSigningKey(r, p, aud, jt)
+	return key, p, err
+}
+
+func (tv *idTokenValidator) getProviderSigningKey(r *http.Request, p *Provider, aud string, jt *jwt.Token) (interface{}, error) {
+	ks := keySelector{kid: getTokenKid(jt), audience: aud}
+	ks.x5t, _ = jt.Header[x5tJwtHeaderName].(string)
+	ks.x5tS256, _ = jt.Header[x5tS256JwtHeaderName].(string)
+
+	key, err := tv.keyGetter.getSigningKey(r, p, ks)
+	if kc, ok := key.(keyCandidates); ok && err == nil {
+		return kc.verifying(jt), nil
 	}
 
-	p, err := validateIssuer(jt, provs)
+	return key, err
+}
+
+// verifying returns the candidate verifying the signature of the token jt. The keys of another
+// algorithm family than the one of the token fail the verification. The first candidate is
+// returned when none verifies the signature, so the token is rejected as invalidly signed.
+func (kc keyCandidates) verifying(jt *jwt.Token) interface{} {
+	for _, k := range kc {
+		if verifySignature(jt, k) == nil {
+			return k
+		}
+	}
+
+	return kc[0]
+}
+
+// getProvider returns the registered provider that issued the token jt, along with the token
+// audience matching one of the provider client IDs, after validating the token issuer, audiences
+// and subject. Only the providers selected for the request r are accepted.
+func (tv *idTokenValidator) getProvider(r *http.Request, jt *jwt.Token) (*Provider, string, error) {
+	provs, err := tv.requestProviders(r)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	_, err = validateAudiences(jt, p)
+	p, err := validateIssuer(jt, provs, tv.issuers)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	_, err = validateSubject(jt)
+
+	return tv.checkProvider(jt, p)
+}
+
+// checkProvider validates the audiences, token use and subject of the token jt against the
+// provider p that issued it, or the provider of its Azure AD B2C policy, returned along with the
+// token audience matching one of the provider client IDs.
+func (tv *idTokenValidator) checkProvider(jt *jwt.Token, p *Provider) (*Provider, string, error) {
+	p, err := policyProvider(jt, p)
+	if err != nil {
+		return nil, "", err
+	}
+
+	aud, err := validateAudiences(jt, p)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	kid := getTokenKid(jt)
+	if err := validateTokenUse(jt, p); err != nil {
+		return nil, "", err
+	}
 
-	var key []byte
-	if key, err = tv.keyGetter.getSigningKey(r, p.Issuer, kid); err == nil {
-		return tv.rsaParser.parse(key)
+	if tv.subjectOptional {
+		return p, aud, nil
 	}
 
-	return nil, err
+	_, err = validateSubject(jt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return p, aud, nil
 }
 
 func getTokenKid(jt *jwt.Token) string {
@@ -133,7 +204,7 @@ func getTokenKid(jt *jwt.Token) string {
 	return kid
 }
 
-func validateIssuer(jt *jwt.Token, ps []Provider) (*Provider, error) {
+func validateIssuer(jt *jwt.Token, ps []Provider, ie *issuerEquivalents) (*Provider, error) {
 	issuerClaim := getIssuer(jt)
 	var ti string
 
@@ -155,16 +226,21 @@ func validateIssuer(jt *jwt.Token, ps []Provider) (*Provider, error) {
 		}
 	}
 
-	// Workaround for tokens issued by google
-	gi := ti
-	if gi == "accounts.google.com" {
-		gi = "https://" + gi
-	}
+	if p, tenant := ie.find(ti, ps); p != nil {
+		if tenant == "" {
+			return p, nil
+		}
 
-	for _, p := range ps {
-		if ti == p.Issuer || gi == p.Issuer {
-			return &p, nil
+		if err := p.TenantValidator(tenant); err != nil {
+			return nil, &ValidationError{
+				Code:       ValidationErrorTenantNotAllowed,
+				Message:    fmt.Sprintf("The tenant %v is not allowed.", tenant),
+				Err:        err,
+				HTTPStatus: http.StatusUnauthorized,
+			}
 		}
+
+		return p, nil
 	}
 
 	return nil, &ValidationError{
@@ -200,12 +276,30 @@ func validateSubject(jt *jwt.Token) (string, error) {
 }
 
 func validateAudiences(jt *jwt.Token, p *Provider) (string, error) {
+	if _, ok := jt.Claims.(jwt.MapClaims)[audiencesClaimName]; !ok && p.ClientIDClaim != "" {
+		return validateClientID(jt, p)
+	}
+
+	if p.audienceMode() == AudienceIgnored {
+		aud, _ := getAudiences(jt)
+		if len(aud) > 0 {
+			ta, _ := aud[0].(string)
+			return ta, nil
+		}
+
+		return "", nil
+	}
+
 	audiencesClaim, err := getAudiences(jt)
 
 	if err != nil {
 		return "", err
 	}
 
+	if p.audienceMode() == AudienceAll {
+		return validateAllAudiences(audiencesClaim, p)
+	}
+
 	for _, aud := range p.ClientIDs {
 		for _, audienceClaim := range audiencesClaim {
 			ta, ok := audienceClaim.(string)
@@ -239,6 +333,93 @@ func validateAudiences(jt *jwt.Token, p *Provider) (string, error) {
 	}
 }
 
+// validateAllAudiences validates that all the audiences of the token are client IDs of the provider
+// and returns the first one.
+func validateAllAudiences(audiencesClaim []interface{}, p *Provider) (string, error) {
+	for _, a := range audiencesClaim {
+		if ta, ok := a.(string); !ok || !containsString(p.ClientIDs, ta) {
+			return "", &ValidationError{
+				Code:       ValidationErrorAudienceNotFound,
+				Message:    fmt.Sprintf("The provider %v does not have a client id matching all the token audiences %+v", p.Issuer, audiencesClaim),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	if len(audiencesClaim) == 0 {
+		return "", &ValidationError{
+			Code:       ValidationErrorInvalidAudience,
+			Message:    "The token 'aud' claim was not found or was empty.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return audiencesClaim[0].(string), nil
+}
+
+// validateClientID validates the client ID claim, named by the ClientIDClaim of the provider,
+// of the token without audiences and returns it as the token audience.
+func validateClientID(jt *jwt.Token, p *Provider) (string, error) {
+	cid, _ := jt.Claims.(jwt.MapClaims)[p.ClientIDClaim].(string)
+	if cid == "" {
+		return "", &ValidationError{
+			Code:       ValidationErrorInvalidAudience,
+			Message:    fmt.Sprintf("The token has no 'aud' claim and its '%v' claim was not found or was empty.", p.ClientIDClaim),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	if !containsString(p.ClientIDs, cid) {
+		return "", &ValidationError{
+			Code:       ValidationErrorAudienceNotFound,
+			Message:    fmt.Sprintf("The provider %v does not have a client id matching the token client id %v", p.Issuer, cid),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return cid, nil
+}
+
+// validateTokenUse validates the 'token_use' claim of the token when the provider restricts
+// the token uses.
+func validateTokenUse(jt *jwt.Token, p *Provider) error {
+	if len(p.TokenUses) == 0 {
+		return nil
+	}
+
+	tu, _ := jt.Claims.(jwt.MapClaims)[tokenUseClaimName].(string)
+	if tu != "" && containsString(p.TokenUses, tu) {
+		return nil
+	}
+
+	return &ValidationError{
+		Code:       ValidationErrorTokenUseNotAllowed,
+		Message:    fmt.Sprintf("The token use '%v' is not allowed by the provider %v.", tu, p.Issuer),
+		HTTPStatus: http.StatusUnauthorized,
+	}
+}
+
+// validateHostedDomain validates the 'hd' claim of the token when the provider restricts
+// the hosted domains.
+func validateHostedDomain(jt *jwt.Token, p *Provider) error {
+	if p == nil || len(p.HostedDomains) == 0 {
+		return nil
+	}
+
+	hd, _ := jt.Claims.(jwt.MapClaims)[hostedDomainClaimName].(string)
+	for _, d := range p.HostedDomains {
+		if hd != "" && strings.EqualFold(hd, d) {
+			return nil
+		}
+	}
+
+	return &ValidationError{
+		Code:       ValidationErrorHostedDomainNotAllowed,
+		Message:    fmt.Sprintf("The token hosted domain '%v' is not allowed by the provider %v.", hd, p.Issuer),
+		HTTPStatus: http.StatusForbidden,
+	}
+}
+
 func getAudiences(t *jwt.Token) ([]interface{}, error) {
 	audiencesClaim := t.Claims.(jwt.MapClaims)[audiencesClaimName]
 	if aud, ok := audiencesClaim.(string); ok {
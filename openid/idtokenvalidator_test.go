@@ -9,15 +9,16 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/mock"
+	"gopkg.in/square/go-jose.v2"
 )
 
 func Test_getSigningKey_WhenGetProvidersReturnsError(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	ee := errors.New("Error getting providers")
 	pm.On("get").Return(nil, ee)
 
-	sk, err := tv.getSigningKey(nil, nil)
+	sk, _, err := tv.getSigningKey(nil, nil)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -35,28 +36,28 @@ func Test_getSigningKey_WhenGetProvidersReturnsError(t *testing.T) {
 }
 
 func Test_getSigningKey_WhenGetProvidersReturnsEmptyCollection(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return(nil, nil).Once()
 
 	pm.On("get").Return([]Provider{}, nil).Once()
 
-	_, err := tv.getSigningKey(nil, nil)
+	_, _, err := tv.getSigningKey(nil, nil)
 	expectSetupError(t, err, SetupErrorEmptyProviderCollection)
 
-	_, err = tv.getSigningKey(nil, nil)
+	_, _, err = tv.getSigningKey(nil, nil)
 	expectSetupError(t, err, SetupErrorEmptyProviderCollection)
 
 	pm.AssertExpectations(t)
 }
 
 func Test_getSigningKey_UsingTokenWithInvalidIssuerType(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
 
 	jt := jwt.New(jwt.SigningMethodRS256)
 	jt.Claims.(jwt.MapClaims)["iss"] = 0 // The expected issuer type is string, not int.
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -67,7 +68,7 @@ func Test_getSigningKey_UsingTokenWithInvalidIssuerType(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithEmptyIssuer(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil).Once()
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil).Once()
@@ -75,7 +76,7 @@ func Test_getSigningKey_UsingTokenWithEmptyIssuer(t *testing.T) {
 	jt := jwt.New(jwt.SigningMethodRS256)
 
 	// The token has no 'iss' claim
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -85,7 +86,7 @@ func Test_getSigningKey_UsingTokenWithEmptyIssuer(t *testing.T) {
 
 	// The token has '' as 'iss' claim
 	jt.Claims.(jwt.MapClaims)["iss"] = ""
-	sk, err = tv.getSigningKey(nil, jt)
+	sk, _, err = tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -97,7 +98,7 @@ func Test_getSigningKey_UsingTokenWithEmptyIssuer(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithUnknownIssuer(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
 
@@ -105,7 +106,7 @@ func Test_getSigningKey_UsingTokenWithUnknownIssuer(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "http://unknown"
 
 	// The token has no 'iss' claim
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -116,7 +117,7 @@ func Test_getSigningKey_UsingTokenWithUnknownIssuer(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithInvalidAudienceType(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
 
@@ -124,7 +125,7 @@ func Test_getSigningKey_UsingTokenWithInvalidAudienceType(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
 	jt.Claims.(jwt.MapClaims)["aud"] = 0 // Expected 'aud' type is string
 
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -135,7 +136,7 @@ func Test_getSigningKey_UsingTokenWithInvalidAudienceType(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithInvalidAudience(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil).Once()
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil).Once()
@@ -144,7 +145,7 @@ func Test_getSigningKey_UsingTokenWithInvalidAudience(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
 
 	// No audience claim
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -154,7 +155,7 @@ func Test_getSigningKey_UsingTokenWithInvalidAudience(t *testing.T) {
 
 	// Empty audience claim.
 	jt.Claims.(jwt.MapClaims)["aud"] = ""
-	sk, err = tv.getSigningKey(nil, jt)
+	sk, _, err = tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -165,7 +166,7 @@ func Test_getSigningKey_UsingTokenWithInvalidAudience(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithUnknownAudience(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1", "client2"}}}, nil)
 
@@ -173,7 +174,7 @@ func Test_getSigningKey_UsingTokenWithUnknownAudience(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
 	jt.Claims.(jwt.MapClaims)["aud"] = "client3" // unknown audience
 
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -184,7 +185,7 @@ func Test_getSigningKey_UsingTokenWithUnknownAudience(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithUnknownMultipleAudiences(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1", "client2"}}}, nil)
 
@@ -192,7 +193,7 @@ func Test_getSigningKey_UsingTokenWithUnknownMultipleAudiences(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
 	jt.Claims.(jwt.MapClaims)["aud"] = []interface{}{"client3", "client4"} // unknown audiences
 
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -203,7 +204,7 @@ func Test_getSigningKey_UsingTokenWithUnknownMultipleAudiences(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingTokenWithInvalidSubjectType(t *testing.T) {
-	pm, _, _, _, tv := createIDTokenValidator(t)
+	pm, _, _, tv := createIDTokenValidator(t)
 
 	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
 
@@ -211,7 +212,7 @@ func Test_getSigningKey_UsingTokenWithInvalidSubjectType(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
 	jt.Claims.(jwt.MapClaims)["aud"] = "client"
 	jt.Claims.(jwt.MapClaims)["sub"] = 0 // The expected 'sub' claim type is string
-	sk, err := tv.getSigningKey(nil, jt)
+	sk, _, err := tv.getSigningKey(nil, jt)
 
 	if sk != nil {
 		t.Error("The returned signing key should be nil.")
@@ -222,14 +223,14 @@ func Test_getSigningKey_UsingTokenWithInvalidSubjectType(t *testing.T) {
 }
 
 func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterReturnsError(t *testing.T) {
-	pm, _, sm, _, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	iss := "https://issuer"
 	keyID := "kid"
 	ee := &ValidationError{Code: ValidationErrorIssuerNotFound, HTTPStatus: http.StatusUnauthorized}
 
-	sm.On("getSigningKey", req, iss, keyID).Return(nil, ee)
+	sm.On("getSigningKey", req, &Provider{Issuer: iss, ClientIDs: []string{"client"}}, keySelector{kid: keyID, audience: "client"}).Return(nil, ee)
 	pm.On("get").Return([]Provider{{Issuer: iss, ClientIDs: []string{"client"}}}, nil)
 
 	jt := jwt.New(jwt.SigningMethodRS256)
@@ -238,7 +239,7 @@ func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterReturnsError(t *test
 	jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
 	jt.Header["kid"] = keyID
 
-	_, err := tv.getSigningKey(req, jt)
+	_, _, err := tv.getSigningKey(req, jt)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, nil)
 	pm.AssertExpectations(t)
@@ -246,17 +247,15 @@ func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterReturnsError(t *test
 }
 
 func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterSucceeds(t *testing.T) {
-	pm, _, sm, kp, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	iss := "https://issuer"
 	keyID := "kid"
-	esk := "signingKey"
 	pk := &rsa.PublicKey{N: nil, E: 345}
 
-	sm.On("getSigningKey", req, iss, keyID).Return([]byte(esk), nil)
+	sm.On("getSigningKey", req, &Provider{Issuer: iss, ClientIDs: []string{"client"}}, keySelector{kid: keyID, audience: "client"}).Return(pk, nil)
 	pm.On("get").Return([]Provider{{Issuer: iss, ClientIDs: []string{"client"}}}, nil)
-	kp.On("parse", []byte(esk)).Return(pk, nil)
 
 	jt := jwt.New(jwt.SigningMethodRS256)
 	jt.Claims.(jwt.MapClaims)["iss"] = iss
@@ -264,7 +263,7 @@ func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterSucceeds(t *testing.
 	jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
 	jt.Header["kid"] = keyID
 
-	rsk, err := tv.getSigningKey(req, jt)
+	rsk, _, err := tv.getSigningKey(req, jt)
 
 	if err != nil {
 		t.Error("An error was returned but not expected.", err)
@@ -274,26 +273,23 @@ func Test_getSigningKey_UsingValidToken_WhenSigningKeyGetterSucceeds(t *testing.
 
 	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
-	kp.AssertExpectations(t)
 }
 
 func Test_getSigningKey_UsingValidToken_WithoutKeyIdentifier_WhenSigningKeyGetterSucceeds(t *testing.T) {
-	pm, _, sm, kp, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	iss := "https://issuer"
 	keyID := ""
-	esk := "signingKey"
 	pk := &rsa.PublicKey{N: nil, E: 345}
-	sm.On("getSigningKey", (*http.Request)(nil), iss, keyID).Return([]byte(esk), nil)
+	sm.On("getSigningKey", (*http.Request)(nil), &Provider{Issuer: iss, ClientIDs: []string{"client"}}, keySelector{kid: keyID, audience: "client"}).Return(pk, nil)
 	pm.On("get").Return([]Provider{{Issuer: iss, ClientIDs: []string{"client"}}}, nil)
-	kp.On("parse", []byte(esk)).Return(pk, nil)
 
 	jt := jwt.New(jwt.SigningMethodRS256)
 	jt.Claims.(jwt.MapClaims)["iss"] = iss
 	jt.Claims.(jwt.MapClaims)["aud"] = "client"
 	jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
 
-	rsk, err := tv.getSigningKey(nil, jt)
+	rsk, _, err := tv.getSigningKey(nil, jt)
 
 	if err != nil {
 		t.Error("An error was returned but not expected.", err)
@@ -304,20 +300,17 @@ func Test_getSigningKey_UsingValidToken_WithoutKeyIdentifier_WhenSigningKeyGette
 	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
 	sm.AssertExpectations(t)
-	kp.AssertExpectations(t)
 }
 
 func Test_getSigningKey_UsingValidTokenWithMultipleAudiences(t *testing.T) {
-	pm, _, sm, kp, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	iss := "https://issuer"
 	keyID := "kid"
-	esk := "signingKey"
 	pk := &rsa.PublicKey{N: nil, E: 345}
 
-	sm.On("getSigningKey", (*http.Request)(nil), iss, keyID).Return([]byte(esk), nil)
+	sm.On("getSigningKey", (*http.Request)(nil), &Provider{Issuer: iss, ClientIDs: []string{"client"}}, keySelector{kid: keyID, audience: "client"}).Return(pk, nil)
 	pm.On("get").Return([]Provider{{Issuer: iss, ClientIDs: []string{"client"}}}, nil)
-	kp.On("parse", []byte(esk)).Return(pk, nil)
 
 	jt := jwt.New(jwt.SigningMethodRS256)
 	jt.Claims.(jwt.MapClaims)["iss"] = iss
@@ -325,7 +318,7 @@ func Test_getSigningKey_UsingValidTokenWithMultipleAudiences(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
 	jt.Header["kid"] = keyID
 
-	rsk, err := tv.getSigningKey(nil, jt)
+	rsk, _, err := tv.getSigningKey(nil, jt)
 
 	if err != nil {
 		t.Error("An error was returned but not expected.", err)
@@ -335,76 +328,89 @@ func Test_getSigningKey_UsingValidTokenWithMultipleAudiences(t *testing.T) {
 
 	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
-	kp.AssertExpectations(t)
+}
+
+func Test_renewAndGetSigningKey_WhenGetProvidersReturnsError(t *testing.T) {
+	pm, _, sm, tv := createIDTokenValidator(t)
+
+	ee := errors.New("Error getting providers")
+	pm.On("get").Return(nil, ee)
+
+	_, _, err := tv.renewAndGetSigningKey(nil, jwt.New(jwt.SigningMethodRS256))
+
+	if err != ee {
+		t.Error("Expected error", ee, ", but got", err)
+	}
+
+	pm.AssertExpectations(t)
+	sm.AssertExpectations(t)
 }
 
 func Test_renewAndGetSigningKey_UsingValidToken_WhenFlushCachedSigningKeysReturnsError(t *testing.T) {
-	_, _, sm, _, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	ee := &ValidationError{Code: ValidationErrorIssuerNotFound, HTTPStatus: http.StatusUnauthorized}
-	sm.On("flushCachedSigningKeys", mock.Anything).Return(ee)
+	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
+	sm.On("flushCachedSigningKeys", "https://issuer").Return(ee)
 
-	jt := jwt.New(jwt.SigningMethodRS256)
-	jt.Claims.(jwt.MapClaims)["iss"] = ""
+	jt := createValidatedToken("https://issuer", "client", "")
 
-	_, err := tv.renewAndGetSigningKey(nil, jt)
+	_, _, err := tv.renewAndGetSigningKey(nil, jt)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, nil)
 
+	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
 }
 
 func Test_renewAndGetSigningKey_UsingValidToken_WhenGetSigningKeyReturnsError(t *testing.T) {
-	_, _, sm, _, tv := createIDTokenValidator(t)
+	pm, _, sm, tv := createIDTokenValidator(t)
 
 	ee := &ValidationError{Code: ValidationErrorIssuerNotFound, HTTPStatus: http.StatusUnauthorized}
+	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
 	sm.On("getSigningKey", (*http.Request)(nil), mock.Anything, mock.Anything).Return(nil, ee)
 	sm.On("flushCachedSigningKeys", mock.Anything).Return(nil)
 
-	jt := jwt.New(jwt.SigningMethodRS256)
-	jt.Claims.(jwt.MapClaims)["iss"] = ""
-	jt.Header["kid"] = ""
+	jt := createValidatedToken("https://issuer", "client", "")
 
-	_, err := tv.renewAndGetSigningKey(nil, jt)
+	_, _, err := tv.renewAndGetSigningKey(nil, jt)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, nil)
 
+	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
 }
 
 func Test_renewAndGetSigningKey_UsingValidToken_WhenGetSigningKeySucceeds(t *testing.T) {
-	_, _, sm, kp, tv := createIDTokenValidator(t)
-	esk := "signingKey"
+	pm, _, sm, tv := createIDTokenValidator(t)
 	pk := &rsa.PublicKey{N: nil, E: 365}
 
-	sm.On("getSigningKey", (*http.Request)(nil), mock.Anything, mock.Anything).Return([]byte(esk), nil)
-	sm.On("flushCachedSigningKeys", mock.Anything).Return(nil)
-	kp.On("parse", []byte(esk)).Return(pk, nil)
+	pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil)
+	sm.On("getSigningKey", (*http.Request)(nil), &Provider{Issuer: "https://issuer", ClientIDs: []string{"client"}}, keySelector{kid: "kid", audience: "client"}).Return(pk, nil)
+	sm.On("flushCachedSigningKeys", "https://issuer").Return(nil)
 
-	jt := jwt.New(jwt.SigningMethodRS256)
-	jt.Claims.(jwt.MapClaims)["iss"] = ""
-	jt.Header["kid"] = ""
+	jt := createValidatedToken("https://issuer", "client", "kid")
 
-	rsk, err := tv.renewAndGetSigningKey(nil, jt)
+	rsk, _, err := tv.renewAndGetSigningKey(nil, jt)
 
 	if err != nil {
 		t.Error("An error was returned but not expected.", err)
 	}
 
 	expectSigningKey(t, rsk, jt, pk)
+	pm.AssertExpectations(t)
 	sm.AssertExpectations(t)
-	kp.AssertExpectations(t)
 }
 
 func Test_validate_WhenParserReturnsErrorFirstTime(t *testing.T) {
-	_, jm, _, _, tv := createIDTokenValidator(t)
+	_, jm, _, tv := createIDTokenValidator(t)
 
 	je := &jwt.ValidationError{Errors: jwt.ValidationErrorNotValidYet}
 	ee := &ValidationError{Code: ValidationErrorJwtValidationFailure, HTTPStatus: http.StatusUnauthorized}
 
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(nil, je)
 
-	_, err := tv.validate(nil, mock.Anything)
+	_, _, err := tv.validate(nil, mock.Anything)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, ee.Err)
 
@@ -412,13 +418,13 @@ func Test_validate_WhenParserReturnsErrorFirstTime(t *testing.T) {
 }
 
 func Test_validate_WhenParserSuceedsFirstTime(t *testing.T) {
-	_, jm, _, _, tv := createIDTokenValidator(t)
+	_, jm, _, tv := createIDTokenValidator(t)
 
 	jt := &jwt.Token{}
 
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(jt, nil)
 
-	rjt, err := tv.validate(nil, mock.Anything)
+	rjt, _, err := tv.validate(nil, mock.Anything)
 
 	if err != nil {
 		t.Error("Unexpected error was returned.", err)
@@ -432,7 +438,7 @@ func Test_validate_WhenParserSuceedsFirstTime(t *testing.T) {
 }
 
 func Test_validate_WhenParserReturnsErrorSecondTime(t *testing.T) {
-	_, jm, _, _, tv := createIDTokenValidator(t)
+	_, jm, _, tv := createIDTokenValidator(t)
 
 	jfe := &jwt.ValidationError{Errors: jwt.ValidationErrorSignatureInvalid}
 	je := &jwt.ValidationError{Errors: jwt.ValidationErrorMalformed}
@@ -441,7 +447,7 @@ func Test_validate_WhenParserReturnsErrorSecondTime(t *testing.T) {
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(nil, jfe).Once()
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(nil, je).Once()
 
-	_, err := tv.validate(nil, mock.Anything)
+	_, _, err := tv.validate(nil, mock.Anything)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, ee.Err)
 
@@ -449,7 +455,7 @@ func Test_validate_WhenParserReturnsErrorSecondTime(t *testing.T) {
 }
 
 func Test_validate_WhenParserReturnsSignatureInvalidErrorSecondTime(t *testing.T) {
-	_, jm, _, _, tv := createIDTokenValidator(t)
+	_, jm, _, tv := createIDTokenValidator(t)
 
 	je := &jwt.ValidationError{Errors: jwt.ValidationErrorSignatureInvalid}
 	ee := &ValidationError{Code: ValidationErrorJwtValidationFailure, HTTPStatus: http.StatusUnauthorized}
@@ -457,7 +463,7 @@ func Test_validate_WhenParserReturnsSignatureInvalidErrorSecondTime(t *testing.T
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(nil, je).Once()
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(nil, je).Once()
 
-	_, err := tv.validate(nil, mock.Anything)
+	_, _, err := tv.validate(nil, mock.Anything)
 
 	expectValidationError(t, err, ee.Code, ee.HTTPStatus, ee.Err)
 
@@ -465,7 +471,7 @@ func Test_validate_WhenParserReturnsSignatureInvalidErrorSecondTime(t *testing.T
 }
 
 func Test_validate_WhenParserSuceedsSecondTime(t *testing.T) {
-	_, jm, _, _, tv := createIDTokenValidator(t)
+	_, jm, _, tv := createIDTokenValidator(t)
 
 	jfe := &jwt.ValidationError{Errors: jwt.ValidationErrorSignatureInvalid}
 
@@ -474,7 +480,7 @@ func Test_validate_WhenParserSuceedsSecondTime(t *testing.T) {
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(jt, jfe).Once()
 	jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(jt, nil).Once()
 
-	rjt, err := tv.validate(nil, mock.Anything)
+	rjt, _, err := tv.validate(nil, mock.Anything)
 	if err != nil {
 		t.Error("Unexpected error was returned.", err)
 	}
@@ -486,6 +492,89 @@ func Test_validate_WhenParserSuceedsSecondTime(t *testing.T) {
 	jm.AssertExpectations(t)
 }
 
+func Test_validate_HostedDomain(t *testing.T) {
+	tests := []struct {
+		hd      interface{}
+		allowed bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com", true},
+		{"other.com", false},
+		{"", false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		pm, jm, sm, tv := createIDTokenValidator(t)
+		pm.On("get").Return([]Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}, HostedDomains: []string{"example.com"}}}, nil)
+		sm.On("getSigningKey", mock.Anything, mock.Anything, mock.Anything).Return(&rsa.PublicKey{}, nil)
+
+		jt := createValidatedToken("https://issuer", "client", "kid")
+		if test.hd != nil {
+			jt.Claims.(jwt.MapClaims)["hd"] = test.hd
+		}
+
+		jm.On("parse", mock.Anything, mock.AnythingOfType("jwt.Keyfunc")).Return(func(s string, kf jwt.Keyfunc) *jwt.Token {
+			kf(jt)
+			return jt
+		}, nil)
+
+		_, p, err := tv.validate(nil, mock.Anything)
+
+		if !test.allowed {
+			expectValidationError(t, err, ValidationErrorHostedDomainNotAllowed, http.StatusForbidden, nil)
+			continue
+		}
+
+		if err != nil {
+			t.Error("Unexpected error was returned.", err)
+		}
+
+		if p == nil || p.Issuer != "https://issuer" {
+			t.Error("Expected the provider https://issuer, but got", p)
+		}
+	}
+}
+
+func Test_getProvider_CognitoTokenUses(t *testing.T) {
+	tests := []struct {
+		claims map[string]interface{}
+		code   ValidationErrorCode
+		aud    string
+	}{
+		{map[string]interface{}{"aud": "client1", "token_use": "id"}, 0, "client1"},
+		{map[string]interface{}{"client_id": "client1", "token_use": "access"}, 0, "client1"},
+		{map[string]interface{}{"client_id": "client2", "token_use": "access"}, ValidationErrorAudienceNotFound, ""},
+		{map[string]interface{}{"token_use": "access"}, ValidationErrorInvalidAudience, ""},
+		{map[string]interface{}{"aud": "client2", "client_id": "client1", "token_use": "id"}, ValidationErrorAudienceNotFound, ""},
+		{map[string]interface{}{"client_id": "client1", "token_use": "refresh"}, ValidationErrorTokenUseNotAllowed, ""},
+		{map[string]interface{}{"client_id": "client1"}, ValidationErrorTokenUseNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		pm, _, _, tv := createIDTokenValidator(t)
+		pm.On("get").Return([]Provider{CognitoProvider("us-east-1", "us-east-1_pool1", "client1")}, nil)
+
+		jt := jwt.New(jwt.SigningMethodRS256)
+		jt.Claims.(jwt.MapClaims)["iss"] = "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_pool1"
+		jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
+		for k, v := range test.claims {
+			jt.Claims.(jwt.MapClaims)[k] = v
+		}
+
+		_, aud, err := tv.getProvider(nil, jt)
+
+		if test.aud == "" {
+			expectValidationError(t, err, test.code, http.StatusUnauthorized, nil)
+			continue
+		}
+
+		if err != nil || aud != test.aud {
+			t.Errorf("Expected the audience %v, but got %v %v", test.aud, aud, err)
+		}
+	}
+}
+
 func expectSigningKey(t *testing.T, rsk interface{}, jt *jwt.Token, esk *rsa.PublicKey) {
 
 	if rsk == nil {
@@ -501,10 +590,52 @@ func expectSigningKey(t *testing.T, rsk interface{}, jt *jwt.Token, esk *rsa.Pub
 	}
 }
 
-func createIDTokenValidator(t *testing.T) (*mockProvidersGetter, *mockJwtParser, *mockSigningKeyGetter, *mockPemToRSAPublicKeyParser, *idTokenValidator) {
+func createValidatedToken(iss string, aud string, kid string) *jwt.Token {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["iss"] = iss
+	jt.Claims.(jwt.MapClaims)["aud"] = aud
+	jt.Claims.(jwt.MapClaims)["sub"] = "subject1"
+	jt.Header["kid"] = kid
+	return jt
+}
+
+func createIDTokenValidator(t *testing.T) (*mockProvidersGetter, *mockJwtParser, *mockSigningKeyGetter, *idTokenValidator) {
 	pm := &mockProvidersGetter{}
 	jm := &mockJwtParser{}
 	sm := &mockSigningKeyGetter{}
-	kp := &mockPemToRSAPublicKeyParser{}
-	return pm, jm, sm, kp, &idTokenValidator{pm, jm, sm, kp}
+	return pm, jm, sm, &idTokenValidator{provGetter: pm, jwtParser: jm, keyGetter: sm, issuers: newIssuerEquivalents()}
+}
+
+func Test_validate_WhenKeysAndTokenHaveNoKid(t *testing.T) {
+	var keys []jose.JSONWebKey
+	for _, alg := range []string{"ES256", "RS256", "RS256"} {
+		k, _ := GenerateKey(alg)
+		k.KeyID = ""
+		keys = append(keys, k)
+	}
+
+	var pubs []jose.JSONWebKey
+	for _, k := range keys {
+		pubs = append(pubs, k.Public())
+	}
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}, Keys: pubs}}, nil
+	}))
+
+	for i, k := range keys {
+		method := jwt.GetSigningMethod(k.Algorithm)
+		ts, _ := jwt.NewWithClaims(method, jwt.MapClaims{"iss": "https://issuer", "aud": "client", "sub": "subject1"}).SignedString(k.Key)
+
+		if _, _, err := c.validate(nil, ts); err != nil {
+			t.Error("An error was returned but not expected for the key", i, err)
+		}
+	}
+
+	other, _ := GenerateKey("RS256")
+	ts, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": "https://issuer", "aud": "client", "sub": "subject1"}).SignedString(other.Key)
+
+	if _, _, err := c.validate(nil, ts); err == nil {
+		t.Error("An error was expected for a token signed by an unknown key but not returned")
+	}
 }
@@ -0,0 +1,13 @@
+// Package ctxkeys defines the keys of the request context values shared by the openid
+// package, which stores them, and the openidctx package, which retrieves them.
+package ctxkeys
+
+type key int
+
+// Keys of the values stored in the request context by the openid middlewares.
+const (
+	User     key = iota // *openid.User of the authenticated user.
+	RawToken            // Raw token string sent with the request.
+	Provider            // *openid.Provider that issued the token.
+	Claims              // Pointer to the typed claims struct of the token.
+)
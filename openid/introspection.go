@@ -0,0 +1,157 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const introspectionActiveMember = "active"
+
+var errPostNotSupported = errors.New("the registered HTTPGetFunc cannot post requests")
+
+type tokenIntrospector interface {
+	introspect(r *http.Request, p *Provider, token string) (map[string]interface{}, error)
+}
+
+// httpIntrospector calls the token introspection endpoint of a provider as described
+// by https://tools.ietf.org/html/rfc7662.
+type httpIntrospector struct {
+	configGetter configurationGetter
+	getter       httpGetter
+	breaker      *circuitBreaker
+
+	mu        sync.RWMutex
+	endpoints map[string]string
+}
+
+func newHTTPIntrospector(cg configurationGetter, g httpGetter) *httpIntrospector {
+	return &httpIntrospector{configGetter: cg, getter: g, endpoints: make(map[string]string)}
+}
+
+// IntrospectionCheck option enables the verification of every validated token against the introspection
+// endpoint published by the provider that issued it, see https://tools.ietf.org/html/rfc7662.
+// Tokens reported as inactive, i.e.: revoked before their expiration, are rejected.
+// The call to the introspection endpoint is authenticated with the provider IntrospectionCredentials.
+func IntrospectionCheck() func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &introspectionChecker{c.introspector})
+		return nil
+	}
+}
+
+type introspectionChecker struct {
+	introspector tokenIntrospector
+}
+
+func (ic *introspectionChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	if isIntrospectedToken(t) {
+		// Opaque tokens were already validated through introspection.
+		return nil
+	}
+
+	_, err := ic.introspector.introspect(r, p, t.Raw)
+	return err
+}
+
+func (i *httpIntrospector) introspect(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+	endpoint, err := i.getEndpoint(r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var a string
+	if p.IntrospectionCredentials != nil {
+		if a, err = p.IntrospectionCredentials(r); err != nil {
+			return nil, &ValidationError{
+				Code:       ValidationErrorGetIntrospectionCredentialsFailure,
+				Message:    fmt.Sprintf("Failure while retrieving the credentials for the introspection endpoint %v.", endpoint),
+				Err:        err,
+				HTTPStatus: http.StatusInternalServerError,
+			}
+		}
+	}
+
+	fp, ok := i.getter.(formPoster)
+	if !ok {
+		return nil, introspectionError(endpoint, errPostNotSupported)
+	}
+
+	resp, err := i.breaker.do(r, endpoint, func() (*http.Response, error) {
+		return fp.postForm(r, endpoint, a, url.Values{"token": {token}})
+	})
+	if err != nil {
+		return nil, introspectionError(endpoint, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, introspectionError(endpoint, fmt.Errorf("unexpected status %v", resp.StatusCode))
+	}
+
+	var ir map[string]interface{}
+	if err := jsonDecodeResponse(resp.Body, &ir); err != nil {
+		return nil, &ValidationError{
+			Code:       ValidationErrorDecodeIntrospectionFailure,
+			Message:    fmt.Sprintf("Failure while decoding the response of the introspection endpoint %v.", endpoint),
+			Err:        err,
+			HTTPStatus: http.StatusBadGateway,
+		}
+	}
+
+	if active, _ := ir[introspectionActiveMember].(bool); !active {
+		return nil, &ValidationError{
+			Code:       ValidationErrorTokenInactive,
+			Message:    "The token is not active.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return ir, nil
+}
+
+// getEndpoint returns the introspection endpoint of the provider, retrieving its
+// configuration only the first time.
+func (i *httpIntrospector) getEndpoint(r *http.Request, p *Provider) (string, error) {
+	iss := p.Issuer
+	i.mu.RLock()
+	e, ok := i.endpoints[iss]
+	i.mu.RUnlock()
+
+	if ok {
+		return e, nil
+	}
+
+	conf, err := getProviderConfiguration(i.configGetter, r, p)
+	if err != nil {
+		return "", err
+	}
+
+	if conf.IntrospectionEndpoint == "" {
+		return "", &ValidationError{
+			Code:       ValidationErrorIntrospectionEndpointNotFound,
+			Message:    fmt.Sprintf("The configuration of the issuer %v does not contain an introspection endpoint.", iss),
+			HTTPStatus: http.StatusBadGateway,
+		}
+	}
+
+	i.mu.Lock()
+	i.endpoints[iss] = conf.IntrospectionEndpoint
+	i.mu.Unlock()
+
+	return conf.IntrospectionEndpoint, nil
+}
+
+func introspectionError(endpoint string, err error) error {
+	return &ValidationError{
+		Code:       ValidationErrorIntrospectionFailure,
+		Message:    fmt.Sprintf("Failure while contacting the introspection endpoint %v.", endpoint),
+		Err:        err,
+		HTTPStatus: http.StatusBadGateway,
+	}
+}
@@ -0,0 +1,160 @@
+package openid
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultIssuerEquivalents contains the known issuers that differ from the issuer
+// published by their provider.
+var defaultIssuerEquivalents = map[string]string{
+	// Google issues tokens whose 'iss' claim misses the scheme.
+	"accounts.google.com": "https://accounts.google.com",
+}
+
+// issuerEquivalents maps the issuers found in tokens to the equivalent issuer of a provider.
+type issuerEquivalents struct {
+	equivalents map[string]string
+}
+
+func newIssuerEquivalents() *issuerEquivalents {
+	e := &issuerEquivalents{make(map[string]string, len(defaultIssuerEquivalents))}
+	e.add(defaultIssuerEquivalents)
+	return e
+}
+
+// IssuerEquivalents option registers issuers found in tokens that must be accepted as the
+// issuer of a registered provider. The keys of eq are the token issuers and the values the
+// issuer of the provider they are equivalent to. The table extends the known equivalences,
+// i.e.: 'accounts.google.com' for 'https://accounts.google.com'.
+// Issuers are compared ignoring the case of the scheme and host and a trailing slash.
+func IssuerEquivalents(eq map[string]string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		for ti, pi := range eq {
+			if err := validateProviderIssuer(pi); err != nil {
+				return err
+			}
+
+			if err := validateProviderIssuer(ti); err != nil {
+				return err
+			}
+		}
+
+		c.idTokenValidator().issuers.add(eq)
+		return nil
+	}
+}
+
+func (e *issuerEquivalents) add(eq map[string]string) {
+	for ti, pi := range eq {
+		e.equivalents[normalizeIssuer(ti)] = normalizeIssuer(pi)
+	}
+}
+
+// find returns the provider that issued tokens with the issuer ti, either its issuer, an
+// equivalent issuer or one of its aliases. When the provider issuer is a template the returned
+// provider is a copy whose issuer is ti, along with the tenant extracted from ti.
+func (e *issuerEquivalents) find(ti string, ps []Provider) (*Provider, string) {
+	ni := normalizeIssuer(ti)
+	ei, hasEquivalent := e.equivalents[ni]
+
+	for _, p := range ps {
+		if it, ok := parseIssuerTemplate(p.Issuer); ok {
+			if tenant, ok := it.match(ni); ok {
+				p.Issuer = ni
+				return &p, tenant
+			}
+
+			continue
+		}
+
+		pi := normalizeIssuer(p.Issuer)
+		if pi == ni || (hasEquivalent && pi == ei) {
+			return &p, ""
+		}
+
+		for _, a := range p.IssuerAliases {
+			if normalizeIssuer(a) == ni {
+				return &p, ""
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+// issuerTemplate is an issuer containing a placeholder for the tenant, i.e.:
+// https://login.microsoftonline.com/{tenantid}/v2.0.
+type issuerTemplate struct {
+	prefix string
+	suffix string
+}
+
+// tenantPlaceholder replaces the placeholder of issuer templates while normalizing them.
+const tenantPlaceholder = "openid2go-tenant"
+
+// parseIssuerTemplate returns the template of the issuer, or false when the issuer
+// does not contain exactly one placeholder.
+func parseIssuerTemplate(iss string) (issuerTemplate, bool) {
+	s := strings.Index(iss, "{")
+	e := strings.Index(iss, "}")
+
+	if s < 0 || e < s || strings.ContainsAny(iss[e+1:], "{}") {
+		return issuerTemplate{}, false
+	}
+
+	ni := normalizeIssuer(iss[:s] + tenantPlaceholder + iss[e+1:])
+	ps := strings.SplitN(ni, tenantPlaceholder, 2)
+
+	if len(ps) != 2 {
+		return issuerTemplate{}, false
+	}
+
+	return issuerTemplate{ps[0], ps[1]}, true
+}
+
+// match returns the tenant of the normalized issuer ni, or false when ni does
+// not match the template.
+func (it issuerTemplate) match(ni string) (string, bool) {
+	if len(ni) <= len(it.prefix)+len(it.suffix) || !strings.HasPrefix(ni, it.prefix) || !strings.HasSuffix(ni, it.suffix) {
+		return "", false
+	}
+
+	tenant := ni[len(it.prefix) : len(ni)-len(it.suffix)]
+	if strings.ContainsAny(tenant, "/?#@:") {
+		return "", false
+	}
+
+	return tenant, true
+}
+
+// isIssuerTemplate returns true when the issuer contains a placeholder.
+func isIssuerTemplate(iss string) bool {
+	return strings.ContainsAny(iss, "{}")
+}
+
+// normalizeIssuer returns the issuer with the scheme and host in lower case and
+// without a trailing slash.
+func normalizeIssuer(iss string) string {
+	iss = strings.TrimSuffix(iss, "/")
+
+	u, err := url.Parse(iss)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return iss
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// issuerURL returns the URL of the issuer, adding the https scheme to issuers missing it.
+func issuerURL(iss string) string {
+	iss = strings.TrimSuffix(iss, "/")
+
+	if !strings.Contains(iss, "://") {
+		iss = "https://" + iss
+	}
+
+	return iss
+}
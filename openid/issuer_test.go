@@ -0,0 +1,211 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestNormalizeIssuer(t *testing.T) {
+	tests := []struct {
+		iss      string
+		expected string
+	}{
+		{"https://issuer", "https://issuer"},
+		{"https://issuer/", "https://issuer"},
+		{"HTTPS://Issuer.Example.com/Tenant/", "https://issuer.example.com/Tenant"},
+		{"accounts.google.com", "accounts.google.com"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if n := normalizeIssuer(test.iss); n != test.expected {
+			t.Errorf("Expected the issuer %v to be normalized to %v, but got %v", test.iss, test.expected, n)
+		}
+	}
+}
+
+func TestIssuerEquivalents_Find(t *testing.T) {
+	ps := []Provider{
+		{Issuer: "https://accounts.google.com"},
+		{Issuer: "https://issuer.example.com/tenant/"},
+		{Issuer: "https://new.example.com"},
+		{Issuer: "https://tenant.auth0.com/", IssuerAliases: []string{"https://login.example.com/", "https://proxy.example.com/auth"}},
+	}
+
+	ie := newIssuerEquivalents()
+	ie.add(map[string]string{"https://old.example.com": "https://new.example.com/"})
+
+	tests := []struct {
+		iss      string
+		expected string
+	}{
+		{"https://accounts.google.com", "https://accounts.google.com"},
+		{"accounts.google.com", "https://accounts.google.com"},
+		{"https://issuer.example.com/tenant", "https://issuer.example.com/tenant/"},
+		{"HTTPS://ISSUER.example.com/tenant/", "https://issuer.example.com/tenant/"},
+		{"https://issuer.example.com/TENANT", ""},
+		{"https://old.example.com", "https://new.example.com"},
+		{"https://unknown.example.com", ""},
+		{"https://LOGIN.example.com", "https://tenant.auth0.com/"},
+		{"https://proxy.example.com/auth/", "https://tenant.auth0.com/"},
+		{"https://proxy.example.com", ""},
+	}
+
+	for _, test := range tests {
+		p, _ := ie.find(test.iss, ps)
+
+		if test.expected == "" {
+			if p != nil {
+				t.Error("Expected no provider for", test.iss, "but got", p.Issuer)
+			}
+			continue
+		}
+
+		if p == nil || p.Issuer != test.expected {
+			t.Error("Expected the provider", test.expected, "for", test.iss, "but got", p)
+		}
+	}
+}
+
+func TestIssuerEquivalents_Option(t *testing.T) {
+	c, e := NewConfiguration(IssuerEquivalents(map[string]string{"https://old": "https://new"}))
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if p, _ := c.idTokenValidator().issuers.find("https://old", []Provider{{Issuer: "https://new"}}); p == nil {
+		t.Error("Expected the equivalent issuer to be registered")
+	}
+
+	_, e = NewConfiguration(IssuerEquivalents(map[string]string{"https://old": ""}))
+
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+}
+
+func TestIssuerURL(t *testing.T) {
+	tests := []struct {
+		iss      string
+		expected string
+	}{
+		{"https://issuer", "https://issuer"},
+		{"https://issuer/", "https://issuer"},
+		{"accounts.google.com", "https://accounts.google.com"},
+	}
+
+	for _, test := range tests {
+		if u := issuerURL(test.iss); u != test.expected {
+			t.Errorf("Expected the URL %v for the issuer %v, but got %v", test.expected, test.iss, u)
+		}
+	}
+}
+
+func TestIssuerEquivalents_Find_IssuerTemplate(t *testing.T) {
+	ps := []Provider{
+		{Issuer: "https://login.microsoftonline.com/{tenantid}/v2.0"},
+		{Issuer: "https://{tenant}.b2clogin.com/tfp/policy/"},
+	}
+
+	tests := []struct {
+		iss    string
+		issuer string
+		tenant string
+	}{
+		{"https://login.microsoftonline.com/9188040d-6c67-4c5b-b112-36a304b66dad/v2.0", "https://login.microsoftonline.com/9188040d-6c67-4c5b-b112-36a304b66dad/v2.0", "9188040d-6c67-4c5b-b112-36a304b66dad"},
+		{"https://login.microsoftonline.com/tenant1/v2.0/", "https://login.microsoftonline.com/tenant1/v2.0", "tenant1"},
+		{"https://contoso.b2clogin.com/tfp/policy", "https://contoso.b2clogin.com/tfp/policy", "contoso"},
+		{"https://login.microsoftonline.com//v2.0", "", ""},
+		{"https://login.microsoftonline.com/a/b/v2.0", "", ""},
+		{"https://login.microsoftonline.com/tenant1/v1.0", "", ""},
+		{"https://evil.com/login.microsoftonline.com/tenant1/v2.0", "", ""},
+	}
+
+	ie := newIssuerEquivalents()
+	for _, test := range tests {
+		p, tenant := ie.find(test.iss, ps)
+
+		if test.issuer == "" {
+			if p != nil {
+				t.Error("Expected no provider for", test.iss, "but got", p.Issuer)
+			}
+			continue
+		}
+
+		if p == nil || p.Issuer != test.issuer {
+			t.Error("Expected the provider", test.issuer, "for", test.iss, "but got", p)
+		}
+
+		if tenant != test.tenant {
+			t.Error("Expected the tenant", test.tenant, "for", test.iss, "but got", tenant)
+		}
+	}
+
+	if ps[0].Issuer != "https://login.microsoftonline.com/{tenantid}/v2.0" {
+		t.Error("The registered provider should not be modified, but got", ps[0].Issuer)
+	}
+}
+
+func TestValidateIssuer_IssuerTemplate(t *testing.T) {
+	var validated string
+	ps := []Provider{{Issuer: "https://login.microsoftonline.com/{tenantid}/v2.0", TenantValidator: func(tenant string) error {
+		validated = tenant
+		if tenant != "allowed" {
+			return errors.New("Tenant not allowed")
+		}
+		return nil
+	}}}
+
+	jt := &jwt.Token{Claims: jwt.MapClaims{"iss": "https://login.microsoftonline.com/allowed/v2.0"}}
+	p, e := validateIssuer(jt, ps, newIssuerEquivalents())
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if validated != "allowed" || p.Issuer != "https://login.microsoftonline.com/allowed/v2.0" {
+		t.Error("Expected the tenant 'allowed' to be validated, but got", validated, p.Issuer)
+	}
+
+	jt = &jwt.Token{Claims: jwt.MapClaims{"iss": "https://login.microsoftonline.com/other/v2.0"}}
+	_, e = validateIssuer(jt, ps, newIssuerEquivalents())
+
+	if ve, ok := e.(*ValidationError); !ok || ve.Code != ValidationErrorTenantNotAllowed || ve.HTTPStatus != http.StatusUnauthorized {
+		t.Error("Expected error code", ValidationErrorTenantNotAllowed, "but got", e)
+	}
+}
+
+func TestProvider_Validate_IssuerTemplate(t *testing.T) {
+	tv := func(tenant string) error { return nil }
+
+	if e := (Provider{Issuer: "https://issuer/{tenant}", ClientIDs: []string{"client"}, TenantValidator: tv}).Validate(); e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	e := Provider{Issuer: "https://issuer/{tenant}", ClientIDs: []string{"client"}}.Validate()
+	expectSetupError(t, e, SetupErrorTenantValidatorNotFound)
+
+	e = Provider{Issuer: "https://issuer/{tenant}/{policy}", ClientIDs: []string{"client"}, TenantValidator: tv}.Validate()
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+
+	e = Provider{Issuer: "https://issuer/}tenant{", ClientIDs: []string{"client"}, TenantValidator: tv}.Validate()
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+}
+
+func TestProvider_Validate_IssuerAliases(t *testing.T) {
+	if e := (Provider{Issuer: "https://issuer", IssuerAliases: []string{"https://alias"}, ClientIDs: []string{"client"}}).Validate(); e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	e := Provider{Issuer: "https://issuer", IssuerAliases: []string{""}, ClientIDs: []string{"client"}}.Validate()
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+
+	e = Provider{Issuer: "https://issuer", IssuerAliases: []string{"https://alias/{tenant}"}, ClientIDs: []string{"client"}}.Validate()
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+
+	tv := func(tenant string) error { return nil }
+	e = Provider{Issuer: "https://issuer/{tenant}", IssuerAliases: []string{"https://alias"}, ClientIDs: []string{"client"}, TenantValidator: tv}.Validate()
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+}
@@ -0,0 +1,97 @@
+package openid
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// JwksFile holds the keys of a jwk set file, i.e.: distributed by a configuration management
+// tool, reloading them when the file changes so rotated keys are used without restarting.
+// Register them as the Keys of the Provider returned by the GetProvidersFunc:
+//
+//	jf, err := openid.NewJwksFile("/etc/myservice/jwks.json", time.Minute)
+//
+//	func myGetProviders() ([]openid.Provider, error) {
+//	    return []openid.Provider{{Issuer: "https://internal.issuer", ClientIDs: []string{"app"}, Keys: jf.Keys()}}, nil
+//	}
+//
+// It is safe for concurrent use.
+type JwksFile struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	keys    []jose.JSONWebKey
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// NewJwksFile returns a JwksFile with the keys of the file at path, checked for changes at most
+// once every interval. The error of reading or parsing the file is returned, see ParseJwks.
+func NewJwksFile(path string, interval time.Duration) (*JwksFile, error) {
+	jf := &JwksFile{path: path, interval: interval, now: time.Now}
+	if err := jf.load(); err != nil {
+		return nil, err
+	}
+
+	return jf, nil
+}
+
+// Keys returns the keys of the file, reloading them first when the interval elapsed since the
+// last check and the modification time or size of the file changed. The previous keys are kept
+// when the file can no longer be read or parsed.
+func (jf *JwksFile) Keys() []jose.JSONWebKey {
+	jf.mu.Lock()
+	defer jf.mu.Unlock()
+
+	if jf.now().Sub(jf.checked) >= jf.interval {
+		jf.reload()
+	}
+
+	return jf.keys
+}
+
+func (jf *JwksFile) load() error {
+	jf.mu.Lock()
+	defer jf.mu.Unlock()
+
+	fi, err := os.Stat(jf.path)
+	if err != nil {
+		return err
+	}
+
+	return jf.read(fi)
+}
+
+// reload reads the file when it changed since the last time it was read.
+func (jf *JwksFile) reload() {
+	jf.checked = jf.now()
+
+	fi, err := os.Stat(jf.path)
+	if err != nil || (fi.ModTime().Equal(jf.modTime) && fi.Size() == jf.size) {
+		return
+	}
+
+	jf.read(fi)
+}
+
+func (jf *JwksFile) read(fi os.FileInfo) error {
+	data, err := ioutil.ReadFile(jf.path)
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseJwks(data)
+	if err != nil {
+		return err
+	}
+
+	jf.keys, jf.modTime, jf.size, jf.checked = keys, fi.ModTime(), fi.Size(), jf.now()
+	return nil
+}
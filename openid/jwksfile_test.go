@@ -0,0 +1,61 @@
+package openid
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_JwksFile_ReloadsChangedFile(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	ioutil.WriteFile(path, []byte(`{"keys":[{"kty":"oct","kid":"kid1","k":"c2VjcmV0"}]}`), 0600)
+
+	jf, err := NewJwksFile(path, time.Minute)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	now := time.Now()
+	jf.now = func() time.Time { return now }
+
+	expectJwksFileKey(t, jf, "kid1")
+
+	ioutil.WriteFile(path, []byte(`{"keys":[{"kty":"oct","kid":"kid2","k":"c2VjcmV0"}]}`), 0600)
+	os.Chtimes(path, now.Add(time.Second), now.Add(time.Second))
+
+	// The file is not checked again before the interval elapses.
+	expectJwksFileKey(t, jf, "kid1")
+
+	now = now.Add(time.Minute)
+	expectJwksFileKey(t, jf, "kid2")
+
+	// The previous keys are kept when the file becomes invalid.
+	ioutil.WriteFile(path, []byte(`{"keys":[]}`), 0600)
+	os.Chtimes(path, now.Add(2*time.Second), now.Add(2*time.Second))
+
+	now = now.Add(time.Minute)
+	expectJwksFileKey(t, jf, "kid2")
+}
+
+func Test_NewJwksFile_WhenFileInvalid(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	if _, err := NewJwksFile(path, time.Minute); !os.IsNotExist(err) {
+		t.Error("Expected a not exist error, but got", err)
+	}
+
+	ioutil.WriteFile(path, []byte(`not json`), 0600)
+	_, err := NewJwksFile(path, time.Minute)
+
+	expectSetupError(t, err, SetupErrorInvalidJwks)
+}
+
+func expectJwksFileKey(t *testing.T, jf *JwksFile, kid string) {
+	if keys := jf.Keys(); len(keys) != 1 || keys[0].KeyID != kid {
+		t.Error("Expected the key", kid, "but got", keys)
+	}
+}
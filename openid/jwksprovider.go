@@ -1,64 +1,229 @@
 package openid
 
 import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	jose "gopkg.in/square/go-jose.v2"
 )
 
+// unknownLifetime is the lifetime of responses that advertise none.
+const unknownLifetime time.Duration = -1
+
+// jsonWebKeySet represents a jwk set whose keys preserve all the members
+// published by the provider, including non-standard ones. The lifetime is the time
+// the set can be cached according to the headers of the response.
+type jsonWebKeySet struct {
+	Keys     []jsonWebKey `json:"keys"`
+	lifetime time.Duration
+}
+
+// jsonWebKey represents a jwk along with the raw values of its members.
+type jsonWebKey struct {
+	jose.JSONWebKey
+	members map[string]interface{}
+}
+
+func (k *jsonWebKey) UnmarshalJSON(data []byte) error {
+	if err := k.JSONWebKey.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &k.members)
+}
+
+// MarshalJSON serializes the key with all the members it was decoded from, so it can be
+// decoded again, i.e.: from a SharedCache.
+func (k jsonWebKey) MarshalJSON() ([]byte, error) {
+	if k.members != nil {
+		return json.Marshal(k.members)
+	}
+
+	return k.JSONWebKey.MarshalJSON()
+}
+
+// jwksGetter returns the jwk set at the url, or the jwk set of the certificates at the url when
+// certificates is true, see certificatesJwks.
 type jwksGetter interface {
-	get(r *http.Request, url string) (jose.JSONWebKeySet, error)
+	get(r *http.Request, url string, cf CredentialsFunc, certificates bool) (jsonWebKeySet, error)
 }
 
 type jwksDecoder interface {
-	decode(io.Reader) (jose.JSONWebKeySet, error)
+	decode(r io.Reader, certificates bool) (jsonWebKeySet, error)
 }
 
 type httpJwksProvider struct {
-	getter  httpGetter
-	decoder jwksDecoder
+	getter      httpGetter
+	decoder     jwksDecoder
+	conditional conditionalCache
 }
 
-func newHTTPJwksProvider(gf HTTPGetFunc, d jwksDecoder) *httpJwksProvider {
-	return &httpJwksProvider{gf, d}
+func newHTTPJwksProvider(g httpGetter, d jwksDecoder) *httpJwksProvider {
+	return &httpJwksProvider{getter: g, decoder: d}
 }
 
-func (httpProv *httpJwksProvider) get(r *http.Request, url string) (jose.JSONWebKeySet, error) {
+func (httpProv *httpJwksProvider) get(r *http.Request, url string, cf CredentialsFunc, certificates bool) (jsonWebKeySet, error) {
+
+	var jwks jsonWebKeySet
+	var a string
+	var err error
 
-	var jwks jose.JSONWebKeySet
-	resp, err := httpProv.getter.get(r, url)
+	if cf != nil {
+		if a, err = cf(r); err != nil {
+			return jwks, &ValidationError{
+				Code:       ValidationErrorGetJwksCredentialsFailure,
+				Message:    fmt.Sprintf("Failure while retrieving the credentials for the jwk endpoint %v.", url),
+				Err:        err,
+				HTTPStatus: http.StatusInternalServerError,
+			}
+		}
+	}
 
+	resp, cached, err := httpProv.conditional.get(httpProv.getter, r, url, a)
 	if err != nil {
 		return jwks, &ValidationError{
 			Code:       ValidationErrorGetJwksFailure,
 			Message:    fmt.Sprintf("Failure while contacting the jwk endpoint %v.", url),
 			Err:        err,
-			HTTPStatus: http.StatusUnauthorized,
+			HTTPStatus: http.StatusBadGateway,
 		}
 	}
 
 	defer resp.Body.Close()
 
-	if jwks, err = httpProv.decoder.decode(resp.Body); err != nil {
+	if cached != nil {
+		jwks = cached.(jsonWebKeySet)
+		jwks.lifetime = responseLifetime(resp.Header, time.Now())
+		return jwks, nil
+	}
+
+	if jwks, err = httpProv.decoder.decode(resp.Body, certificates); err != nil {
 		return jwks, &ValidationError{
 			Code:       ValidationErrorDecodeJwksFailure,
 			Message:    fmt.Sprintf("Failure while decoding the jwk retrieved from the  endpoint %v.", url),
 			Err:        err,
-			HTTPStatus: http.StatusUnauthorized,
+			HTTPStatus: http.StatusBadGateway,
 		}
 	}
 
+	httpProv.conditional.set(url, resp, jwks)
+
+	jwks.lifetime = responseLifetime(resp.Header, time.Now())
 	return jwks, nil
 }
 
+// responseLifetime returns the time a response can be cached according to its Cache-Control
+// header, or its Expires header when Cache-Control has no max-age. It returns unknownLifetime
+// when neither header is present.
+func responseLifetime(h http.Header, now time.Time) time.Duration {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		for _, d := range strings.Split(cc, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+
+			if d == "no-store" || d == "no-cache" {
+				return 0
+			}
+
+			if strings.HasPrefix(d, "max-age=") {
+				secs, err := strconv.ParseInt(strings.Trim(d[len("max-age="):], "\""), 10, 64)
+				if err != nil || secs < 0 {
+					return 0
+				}
+
+				age, _ := strconv.ParseInt(h.Get("Age"), 10, 64)
+				if age >= secs {
+					return 0
+				}
+
+				return time.Duration(secs-age) * time.Second
+			}
+		}
+	}
+
+	if e := h.Get("Expires"); e != "" {
+		exp, err := http.ParseTime(e)
+		if err != nil {
+			// Invalid dates, i.e.: "0", represent a time in the past.
+			return 0
+		}
+
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			now = d
+		}
+
+		if exp.Before(now) {
+			return 0
+		}
+
+		return exp.Sub(now)
+	}
+
+	return unknownLifetime
+}
+
 type jsonJwksDecoder struct {
 }
 
-func (d *jsonJwksDecoder) decode(r io.Reader) (jose.JSONWebKeySet, error) {
-	var jwks jose.JSONWebKeySet
-	err := jsonDecodeResponse(r, &jwks)
+// decode decodes the jwk set or, when certificates is true, the JSON object of key IDs to PEM
+// encoded X.509 certificates published by the providers with a CertificatesURL, see
+// certificatesJwks.
+func (d *jsonJwksDecoder) decode(r io.Reader, certificates bool) (jsonWebKeySet, error) {
+	var jwks jsonWebKeySet
+	if certificates {
+		var certs map[string]json.RawMessage
+		if err := jsonDecodeResponse(r, &certs); err != nil {
+			return jwks, err
+		}
 
+		return certificatesJwks(certs)
+	}
+
+	err := jsonDecodeResponse(r, &jwks)
 	return jwks, err
 }
+
+// certificatesJwks returns the jwk set of the PEM encoded X.509 certificates by key ID, whose keys
+// are the public keys of the certificates, along with the certificates.
+func certificatesJwks(certs map[string]json.RawMessage) (jsonWebKeySet, error) {
+	var jwks jsonWebKeySet
+
+	kids := make([]string, 0, len(certs))
+	for kid := range certs {
+		kids = append(kids, kid)
+	}
+
+	sort.Strings(kids)
+	for _, kid := range kids {
+		var data string
+		if err := json.Unmarshal(certs[kid], &data); err != nil {
+			return jwks, err
+		}
+
+		b, _ := pem.Decode([]byte(data))
+		if b == nil || b.Type != "CERTIFICATE" {
+			return jwks, fmt.Errorf("The value of the key %v is not a PEM encoded certificate.", kid)
+		}
+
+		cert, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return jwks, err
+		}
+
+		jwks.Keys = append(jwks.Keys, jsonWebKey{JSONWebKey: jose.JSONWebKey{
+			Key:          cert.PublicKey,
+			KeyID:        kid,
+			Use:          "sig",
+			Certificates: []*x509.Certificate{cert},
+		}})
+	}
+
+	return jwks, nil
+}
@@ -2,10 +2,17 @@ package openid
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"gopkg.in/square/go-jose.v2"
@@ -20,7 +27,7 @@ func TestJwksProvider_Get_UsesCorrectUrl(t *testing.T) {
 
 	httpGetter.On("get", req, url).Return(nil, errors.New("Read configuration error"))
 
-	_, e := jwksProvider.get(req, url)
+	_, e := jwksProvider.get(req, url, nil, false)
 
 	if e == nil {
 		t.Error("An error was expected but not returned")
@@ -36,9 +43,9 @@ func TestJwksProvider_Get_WhenGetReturnsError(t *testing.T) {
 	readError := errors.New("Read jwks error")
 	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(nil, readError)
 
-	_, e := jwksProvider.get(nil, mock.Anything)
+	_, e := jwksProvider.get(nil, mock.Anything, nil, false)
 
-	expectValidationError(t, e, ValidationErrorGetJwksFailure, http.StatusUnauthorized, readError)
+	expectValidationError(t, e, ValidationErrorGetJwksFailure, http.StatusBadGateway, readError)
 
 	httpGetter.AssertExpectations(t)
 }
@@ -46,14 +53,14 @@ func TestJwksProvider_Get_WhenGetReturnsError(t *testing.T) {
 func TestJwksProvider_Get_WhenGetSucceeds(t *testing.T) {
 	httpGetter := &mockHTTPGetter{}
 	jwksDecoder := &mockJwksDecoder{}
-	jwksProvider := httpJwksProvider{httpGetter, jwksDecoder}
+	jwksProvider := httpJwksProvider{getter: httpGetter, decoder: jwksDecoder}
 
 	respBody := "jwk set"
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
 	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(resp, nil)
-	jwksDecoder.On("decode", mock.MatchedBy(ioReaderMatcher(t, respBody))).Return(jose.JSONWebKeySet{}, nil)
+	jwksDecoder.On("decode", mock.MatchedBy(ioReaderMatcher(t, respBody)), false).Return(jsonWebKeySet{}, nil)
 
-	_, e := jwksProvider.get(nil, mock.Anything)
+	_, e := jwksProvider.get(nil, mock.Anything, nil, false)
 
 	if e != nil {
 		t.Error("An error was returned but not expected", e)
@@ -67,16 +74,16 @@ func TestJwksProvider_Get_WhenDecodeResponseReturnsError(t *testing.T) {
 	httpGetter := &mockHTTPGetter{}
 	jwksDecoder := &mockJwksDecoder{}
 
-	jwksProvider := httpJwksProvider{httpGetter, jwksDecoder}
+	jwksProvider := httpJwksProvider{getter: httpGetter, decoder: jwksDecoder}
 	decodeError := errors.New("Decode jwks error")
 	respBody := "jwk set."
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
 	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(resp, nil)
-	jwksDecoder.On("decode", mock.Anything).Return(jose.JSONWebKeySet{}, decodeError)
+	jwksDecoder.On("decode", mock.Anything, false).Return(jsonWebKeySet{}, decodeError)
 
-	_, e := jwksProvider.get(nil, mock.Anything)
+	_, e := jwksProvider.get(nil, mock.Anything, nil, false)
 
-	expectValidationError(t, e, ValidationErrorDecodeJwksFailure, http.StatusUnauthorized, decodeError)
+	expectValidationError(t, e, ValidationErrorDecodeJwksFailure, http.StatusBadGateway, decodeError)
 
 	httpGetter.AssertExpectations(t)
 	jwksDecoder.AssertExpectations(t)
@@ -86,18 +93,18 @@ func TestJwksProvider_Get_WhenDecodeResponseSucceeds(t *testing.T) {
 	httpGetter := &mockHTTPGetter{}
 	jwksDecoder := &mockJwksDecoder{}
 
-	jwksProvider := httpJwksProvider{httpGetter, jwksDecoder}
-	keys := []jose.JSONWebKey{
-		{Key: "key1", Certificates: nil, KeyID: "keyid1", Algorithm: "algo1", Use: "use1"},
-		{Key: "key2", Certificates: nil, KeyID: "keyid2", Algorithm: "algo2", Use: "use2"},
+	jwksProvider := httpJwksProvider{getter: httpGetter, decoder: jwksDecoder}
+	keys := []jsonWebKey{
+		{JSONWebKey: jose.JSONWebKey{Key: "key1", Certificates: nil, KeyID: "keyid1", Algorithm: "algo1", Use: "use1"}},
+		{JSONWebKey: jose.JSONWebKey{Key: "key2", Certificates: nil, KeyID: "keyid2", Algorithm: "algo2", Use: "use2"}},
 	}
-	jwks := jose.JSONWebKeySet{Keys: keys}
+	jwks := jsonWebKeySet{Keys: keys}
 	respBody := "jwk set"
 	resp := &http.Response{Body: testBody{bytes.NewBufferString(respBody)}}
 	httpGetter.On("get", (*http.Request)(nil), mock.Anything).Return(resp, nil)
-	jwksDecoder.On("decode", mock.Anything).Return(jwks, nil)
+	jwksDecoder.On("decode", mock.Anything, false).Return(jwks, nil)
 
-	rj, e := jwksProvider.get(nil, mock.Anything)
+	rj, e := jwksProvider.get(nil, mock.Anything, nil, false)
 
 	if e != nil {
 		t.Error("An error was returned but not expected", e)
@@ -126,3 +133,135 @@ func TestJwksProvider_Get_WhenDecodeResponseSucceeds(t *testing.T) {
 	httpGetter.AssertExpectations(t)
 	jwksDecoder.AssertExpectations(t)
 }
+
+func TestJwksProvider_Get_WhenCredentialsReturnError(t *testing.T) {
+	jwksProvider := httpJwksProvider{getter: defaultHTTPGetter{}}
+
+	ce := errors.New("Credentials error")
+	_, e := jwksProvider.get(nil, "https://jwks", func(r *http.Request) (string, error) {
+		return "", ce
+	}, false)
+
+	expectValidationError(t, e, ValidationErrorGetJwksCredentialsFailure, http.StatusInternalServerError, ce)
+}
+
+func TestJwksProvider_Get_WhenGetterCannotSendCredentials(t *testing.T) {
+	httpGetter := &mockHTTPGetter{}
+	jwksProvider := httpJwksProvider{getter: httpGetter}
+
+	_, e := jwksProvider.get(nil, "https://jwks", BearerCredentials("token"), false)
+
+	expectValidationError(t, e, ValidationErrorGetJwksFailure, http.StatusBadGateway, errCredentialsNotSupported)
+
+	httpGetter.AssertExpectations(t)
+}
+
+func TestJwksProvider_Get_SendsCredentials(t *testing.T) {
+	var authorization string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer s.Close()
+
+	jwksProvider := newHTTPJwksProvider(defaultHTTPGetter{}, &jsonJwksDecoder{})
+
+	_, e := jwksProvider.get(nil, s.URL, BearerCredentials("token"), false)
+
+	if e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	if authorization != "Bearer token" {
+		t.Error("Expected the Authorization header 'Bearer token', but got", authorization)
+	}
+}
+
+func TestJsonJwksDecoder_Decode_PreservesMembers(t *testing.T) {
+	d := &jsonJwksDecoder{}
+	body := `{"keys":[{"kty":"oct","kid":"kid1","k":"c2VjcmV0","client_id":["client1","client2"]}]}`
+
+	jwks, e := d.decode(bytes.NewBufferString(body), false)
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if len(jwks.Keys) != 1 {
+		t.Fatal("Expected 1 key, but got", len(jwks.Keys))
+	}
+
+	k := jwks.Keys[0]
+	if k.KeyID != "kid1" {
+		t.Error("Expected key ID kid1, but got", k.KeyID)
+	}
+
+	if cs := claimStrings(k.members["client_id"]); len(cs) != 2 || cs[0] != "client1" || cs[1] != "client2" {
+		t.Error("Expected the client_id member [client1 client2], but got", k.members["client_id"])
+	}
+}
+
+func TestJsonJwksDecoder_Decode_Certificates(t *testing.T) {
+	k, _ := rsa.GenerateKey(rand.Reader, 2048)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	body, _ := json.Marshal(map[string]string{
+		"kid1": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	})
+
+	d := &jsonJwksDecoder{}
+	jwks, e := d.decode(bytes.NewBuffer(body), true)
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "kid1" || len(jwks.Keys[0].Certificates) != 1 {
+		t.Fatal("Expected the key of the certificate kid1, but got", jwks.Keys)
+	}
+
+	if pk, ok := jwks.Keys[0].Key.(*rsa.PublicKey); !ok || pk.N.Cmp(k.PublicKey.N) != 0 {
+		t.Error("Expected the public key of the certificate, but got", jwks.Keys[0].Key)
+	}
+
+	if _, e := d.decode(bytes.NewBufferString(`{"kid1":"not a certificate"}`), true); e == nil {
+		t.Error("Expected an error decoding an invalid certificate.")
+	}
+
+	if jwks, _ := d.decode(bytes.NewBuffer(body), false); len(jwks.Keys) != 0 {
+		t.Error("Expected no keys decoding certificates as a jwk set, but got", jwks.Keys)
+	}
+}
+
+func Test_responseLifetime(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		headers  map[string]string
+		lifetime time.Duration
+	}{
+		{map[string]string{}, unknownLifetime},
+		{map[string]string{"Cache-Control": "public, max-age=3600"}, time.Hour},
+		{map[string]string{"Cache-Control": "max-age=3600", "Age": "600"}, 50 * time.Minute},
+		{map[string]string{"Cache-Control": "max-age=60", "Age": "600"}, 0},
+		{map[string]string{"Cache-Control": "no-cache"}, 0},
+		{map[string]string{"Cache-Control": "No-Store, max-age=3600"}, 0},
+		{map[string]string{"Cache-Control": "max-age=abc"}, 0},
+		{map[string]string{"Cache-Control": "max-age=60", "Expires": "Wed, 01 Jan 2020 02:00:00 GMT"}, time.Minute},
+		{map[string]string{"Cache-Control": "public", "Expires": "Wed, 01 Jan 2020 02:00:00 GMT"}, 2 * time.Hour},
+		{map[string]string{"Expires": "Wed, 01 Jan 2020 02:00:00 GMT", "Date": "Wed, 01 Jan 2020 01:30:00 GMT"}, 30 * time.Minute},
+		{map[string]string{"Expires": "Tue, 31 Dec 2019 23:00:00 GMT"}, 0},
+		{map[string]string{"Expires": "0"}, 0},
+	}
+
+	for _, test := range tests {
+		h := http.Header{}
+		for k, v := range test.headers {
+			h.Set(k, v)
+		}
+
+		if l := responseLifetime(h, now); l != test.lifetime {
+			t.Errorf("Headers %v: expected the lifetime %v, but got %v.", test.headers, test.lifetime, l)
+		}
+	}
+}
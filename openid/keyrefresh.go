@@ -0,0 +1,169 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BackgroundKeyRefresh option retrieves the discovery document and signing keys of every
+// provider returned by the GetProvidersFunc every interval, in a goroutine started by
+// Configuration.Start, so the validation of tokens does not wait for the providers. While it runs
+// the cached keys are used beyond their lifetime until they are retrieved again, only tokens signed
+// by keys not yet cached still wait for the provider. The onError, when not nil, receives the
+// errors of the refreshes, the previously retrieved keys are kept when a refresh fails.
+// Providers registered with issuer templates are not refreshed. The refreshes are not made on
+// behalf of a request, so the HTTPGetFunc and the JwksCredentials receive a request carrying a
+// context canceled by Configuration.Stop, which does not wait for an unresponsive provider.
+func BackgroundKeyRefresh(interval time.Duration, onError func(error)) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if interval <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidRefreshInterval,
+				Message: fmt.Sprintf("The background refresh interval %v must be positive.", interval),
+			}
+		}
+
+		tv := c.idTokenValidator()
+		c.refresher = &keyRefresher{tv: tv, keys: tv.keyGetter.(*signingKeyProvider), interval: interval, onError: onError}
+		return nil
+	}
+}
+
+// Start starts the background refresh of the configuration registered with the BackgroundKeyRefresh
+// option. The first refresh starts immediately. Start does nothing when the option was not used or
+// the refresh is already running.
+func (c *Configuration) Start() {
+	if c.refresher != nil {
+		c.refresher.start()
+	}
+}
+
+// Stop stops the background refresh started by Start and waits for the refresh in progress,
+// if any, to complete.
+func (c *Configuration) Stop() {
+	if c.refresher != nil {
+		c.refresher.stop()
+	}
+}
+
+type keyRefresher struct {
+	tv       *idTokenValidator
+	keys     *signingKeyProvider
+	interval time.Duration
+	onError  func(error)
+
+	mu   sync.Mutex
+	quit chan struct{}
+	done chan struct{}
+}
+
+func (kr *keyRefresher) start() {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.quit != nil {
+		return
+	}
+
+	kr.quit, kr.done = make(chan struct{}), make(chan struct{})
+	kr.setKeepExpired(true)
+
+	go kr.run(kr.quit, kr.done)
+}
+
+func (kr *keyRefresher) stop() {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.quit == nil {
+		return
+	}
+
+	close(kr.quit)
+	<-kr.done
+	kr.quit, kr.done = nil, nil
+	kr.setKeepExpired(false)
+}
+
+func (kr *keyRefresher) setKeepExpired(v bool) {
+	kr.keys.mu.Lock()
+	kr.keys.keepExpired = v
+	kr.keys.mu.Unlock()
+}
+
+func (kr *keyRefresher) run(quit chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	t := time.NewTicker(kr.interval)
+	defer t.Stop()
+
+	for {
+		kr.refresh(ctx)
+
+		select {
+		case <-quit:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// refresh retrieves the signing keys of all the providers, on behalf of a request carrying ctx.
+func (kr *keyRefresher) refresh(ctx context.Context) {
+	if kr.tv.provGetter == nil {
+		return
+	}
+
+	provs, err := kr.tv.provGetter.get()
+	if err != nil {
+		kr.report(err)
+		return
+	}
+
+	r := newBackgroundRequest(ctx)
+	for i := range provs {
+		if provs[i].isTemplate() || ctx.Err() != nil {
+			continue
+		}
+
+		if err := kr.keys.refreshSigningKeys(r, &provs[i]); err != nil {
+			kr.report(err)
+		}
+	}
+}
+
+// backgroundKey is the context key marking the requests returned by newBackgroundRequest.
+type backgroundKey struct{}
+
+// newBackgroundRequest returns the request carrying ctx on behalf of which the retrievals that are
+// not triggered by a request, i.e.: the background refreshes, are made.
+func newBackgroundRequest(ctx context.Context) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	return r.WithContext(context.WithValue(ctx, backgroundKey{}, true))
+}
+
+// isBackgroundRequest returns true when r was returned by newBackgroundRequest.
+func isBackgroundRequest(r *http.Request) bool {
+	b, _ := r.Context().Value(backgroundKey{}).(bool)
+	return b
+}
+
+func (kr *keyRefresher) report(err error) {
+	if kr.onError != nil {
+		kr.onError(err)
+	}
+}
@@ -0,0 +1,127 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func createKeyRefreshConfiguration(t *testing.T, pg GetProvidersFunc, onError func(error)) (*mockSigningKeySetGetter, *signingKeyProvider, *Configuration) {
+	kg, kp := createSigningKeyProvider(t)
+	c := &Configuration{tokenValidator: &idTokenValidator{provGetter: pg, keyGetter: kp}}
+
+	if err := BackgroundKeyRefresh(time.Hour, onError)(c); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	return kg, kp, c
+}
+
+func Test_BackgroundKeyRefresh_WhenIntervalInvalid(t *testing.T) {
+	_, err := NewConfiguration(BackgroundKeyRefresh(0, nil))
+
+	expectSetupError(t, err, SetupErrorInvalidRefreshInterval)
+}
+
+func Test_Configuration_Start_RefreshesProviderKeys(t *testing.T) {
+	pg := GetProvidersFunc(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer"}, {Issuer: "https://login/{tenant}/v2.0"}}, nil
+	})
+
+	var errs []error
+	kg, kp, c := createKeyRefreshConfiguration(t, pg, func(e error) { errs = append(errs, e) })
+	kg.On("get", mock.AnythingOfType("*http.Request"), &Provider{Issuer: "https://issuer"}).Return([]signingKey{{keyID: "kid1", key: []byte("key")}}, time.Duration(0), nil).Once()
+
+	now := time.Now()
+	kp.now = func() time.Time { return now }
+
+	c.Start()
+	c.Start()
+	c.Stop()
+	c.Stop()
+
+	// The keys expire once the time of the cache moves past their lifetime.
+	now = now.Add(defaultMinJwksCacheTTL)
+
+	if len(errs) != 0 {
+		t.Error("No errors were expected, but got", errs)
+	}
+
+	kg.AssertExpectations(t)
+
+	kp.keepExpired = true
+	if k := kp.cachedKey("https://issuer", keySelector{kid: "kid1"}); keyString(k) != "key" {
+		t.Error("Expected the refreshed key to be served after it expired, but got", k)
+	}
+
+	kp.keepExpired = false
+	if k := kp.cachedKey("https://issuer", keySelector{kid: "kid1"}); k != nil {
+		t.Error("Expected the expired key not to be served once the refresh stopped, but got", k)
+	}
+}
+
+func Test_keyRefresher_Refresh_ReportsErrors(t *testing.T) {
+	pe := errors.New("Providers error")
+	pg := GetProvidersFunc(func() ([]Provider, error) {
+		return nil, pe
+	})
+
+	var errs []error
+	_, _, c := createKeyRefreshConfiguration(t, pg, func(e error) { errs = append(errs, e) })
+
+	c.refresher.refresh(context.Background())
+
+	if len(errs) != 1 || errs[0] != pe {
+		t.Error("Expected the providers error to be reported, but got", errs)
+	}
+
+	ke := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	kg, kp, c := createKeyRefreshConfiguration(t, GetProvidersFunc(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer"}}, nil
+	}), func(e error) { errs = append(errs, e) })
+	cacheKeys(kp, "https://issuer", []signingKey{{keyID: "kid1", key: []byte("key")}})
+	kg.On("get", mock.AnythingOfType("*http.Request"), mock.Anything).Return(nil, time.Duration(0), ke)
+
+	c.refresher.refresh(context.Background())
+
+	if len(errs) != 2 || errs[1] != ke {
+		t.Error("Expected the jwks error to be reported, but got", errs)
+	}
+
+	if k := kp.cachedKey("https://issuer", keySelector{kid: "kid1"}); keyString(k) != "key" {
+		t.Error("Expected the previous keys to be kept, but got", k)
+	}
+}
+
+func Test_Configuration_Stop_CancelsRefreshInProgress(t *testing.T) {
+	kg, _, c := createKeyRefreshConfiguration(t, func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer"}}, nil
+	}, nil)
+
+	started := make(chan struct{})
+	kg.On("get", mock.AnythingOfType("*http.Request"), mock.Anything).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(*http.Request).Context().Done()
+	}).Return(nil, time.Duration(0), context.Canceled).Once()
+
+	c.Start()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Stop to cancel the refresh in progress.")
+	}
+
+	kg.AssertExpectations(t)
+}
@@ -0,0 +1,98 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// KeyRotationFunc receives the issuer whose signing keys were retrieved again and changed, along
+// with the identifiers of its keys before and after, sorted. The keys are identified by their kid,
+// or by the thumbprint of their certificate when they have none.
+type KeyRotationFunc func(issuer string, previous []string, current []string)
+
+// KeyRotationHook option registers the function notified when the signing keys retrieved from a
+// provider differ from the ones retrieved before, i.e.: to log and alert on key rotations. The
+// first retrieval of the keys of a provider is not reported. The function is called synchronously
+// by the retrieval, on behalf of a request or of the background refresh, and must not block.
+func KeyRotationHook(h KeyRotationFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+		kp.rotationHook = h
+		return nil
+	}
+}
+
+// RefreshKeys retrieves the discovery document and signing keys of the provider registered with
+// the issuer, replacing the cached keys, i.e.: for operators to pick up a rotation announced by the
+// provider. The HTTPGetFunc and JwksCredentials receive a request carrying ctx. Issuers matching
+// issuer templates can be refreshed; a ValidationError with ValidationErrorIssuerNotFound is
+// returned when no provider is registered with the issuer.
+func (c *Configuration) RefreshKeys(ctx context.Context, issuer string) error {
+	tv := c.idTokenValidator()
+
+	var provs []Provider
+	if tv.provGetter != nil {
+		var err error
+		if provs, err = tv.provGetter.get(); err != nil {
+			return err
+		}
+	}
+
+	p, _ := tv.issuers.find(issuer, provs)
+	if p == nil {
+		return &ValidationError{
+			Code:       ValidationErrorIssuerNotFound,
+			Message:    fmt.Sprintf("No provider was registered with issuer: %v", issuer),
+			HTTPStatus: http.StatusNotFound,
+		}
+	}
+
+	return tv.keyGetter.(*signingKeyProvider).refreshSigningKeys(newBackgroundRequest(ctx), p)
+}
+
+// keyIdentifiers returns the sorted identifiers of the keys, see KeyRotationFunc.
+func keyIdentifiers(keys []signingKey) []string {
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		switch {
+		case k.keyID != "":
+			ids = append(ids, k.keyID)
+		case k.x5tS256 != "":
+			ids = append(ids, k.x5tS256)
+		case k.x5t != "":
+			ids = append(ids, k.x5t)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// rotated records the identifiers of the keys retrieved for the issuer and returns the identifiers
+// retrieved before when they differ. It must be called with the lock held.
+func (s *signingKeyProvider) rotated(issuer string, ids []string) ([]string, bool) {
+	if s.rotationHook == nil {
+		return nil, false
+	}
+
+	if s.keyIDs == nil {
+		s.keyIDs = make(map[string][]string)
+	}
+
+	previous, known := s.keyIDs[issuer]
+	s.keyIDs[issuer] = ids
+
+	if !known || len(previous) != len(ids) {
+		return previous, known
+	}
+
+	for i := range ids {
+		if previous[i] != ids[i] {
+			return previous, true
+		}
+	}
+
+	return nil, false
+}
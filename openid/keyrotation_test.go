@@ -0,0 +1,81 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type rotation struct {
+	issuer   string
+	previous []string
+	current  []string
+}
+
+func createKeyRotationConfiguration(t *testing.T, provs ...Provider) (*mockSigningKeySetGetter, *Configuration, *[]rotation) {
+	kg, kp := createSigningKeyProvider(t)
+	c := &Configuration{tokenValidator: &idTokenValidator{keyGetter: kp, issuers: newIssuerEquivalents(), provGetter: GetProvidersFunc(func() ([]Provider, error) {
+		return provs, nil
+	})}}
+
+	var rotations []rotation
+	KeyRotationHook(func(issuer string, previous []string, current []string) {
+		rotations = append(rotations, rotation{issuer, previous, current})
+	})(c)
+
+	return kg, c, &rotations
+}
+
+func Test_Configuration_RefreshKeys_ReportsRotations(t *testing.T) {
+	kg, c, rotations := createKeyRotationConfiguration(t, Provider{Issuer: "https://issuer"})
+
+	ctx := context.WithValue(context.Background(), "key", "value")
+	withCtx := mock.MatchedBy(func(r *http.Request) bool { return r.Context().Value("key") == "value" })
+	kg.On("get", withCtx, &Provider{Issuer: "https://issuer"}).Return([]signingKey{{keyID: "kid2", key: []byte("key2")}, {keyID: "kid1", key: []byte("key1")}}, time.Duration(0), nil).Once()
+	kg.On("get", withCtx, &Provider{Issuer: "https://issuer"}).Return([]signingKey{{keyID: "kid1", key: []byte("key1")}, {keyID: "kid2", key: []byte("key2")}}, time.Duration(0), nil).Once()
+	kg.On("get", withCtx, &Provider{Issuer: "https://issuer"}).Return([]signingKey{{keyID: "kid3", key: []byte("key3")}, {x5t: "t4", key: []byte("key4")}}, time.Duration(0), nil).Once()
+
+	for i := 0; i < 3; i++ {
+		if err := c.RefreshKeys(ctx, "https://issuer"); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+	}
+
+	if len(*rotations) != 1 {
+		t.Fatal("Expected a single rotation, but got", *rotations)
+	}
+
+	r := (*rotations)[0]
+	if r.issuer != "https://issuer" || len(r.previous) != 2 || r.previous[0] != "kid1" || r.previous[1] != "kid2" ||
+		len(r.current) != 2 || r.current[0] != "kid3" || r.current[1] != "t4" {
+		t.Errorf("Expected the rotation from [kid1 kid2] to [kid3 t4], but got %+v", r)
+	}
+
+	kg.AssertExpectations(t)
+}
+
+func Test_Configuration_RefreshKeys_WhenIssuerMatchesTemplate(t *testing.T) {
+	kg, c, _ := createKeyRotationConfiguration(t, Provider{Issuer: "https://login/{tenant}/v2.0", TenantValidator: func(string) error { return nil }})
+
+	kg.On("get", mock.Anything, mock.MatchedBy(func(p *Provider) bool { return p.Issuer == "https://login/tenant1/v2.0" })).Return([]signingKey{{keyID: "kid1", key: []byte("key1")}}, time.Duration(0), nil).Once()
+
+	if err := c.RefreshKeys(context.Background(), "https://login/tenant1/v2.0"); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	if k := kp.cachedKey("https://login/tenant1/v2.0", keySelector{kid: "kid1"}); keyString(k) != "key1" {
+		t.Error("Expected the keys of the tenant to be cached, but got", k)
+	}
+}
+
+func Test_Configuration_RefreshKeys_WhenIssuerNotFound(t *testing.T) {
+	_, c, _ := createKeyRotationConfiguration(t, Provider{Issuer: "https://issuer"})
+
+	err := c.RefreshKeys(context.Background(), "https://other")
+
+	expectValidationError(t, err, ValidationErrorIssuerNotFound, http.StatusNotFound, nil)
+}
@@ -0,0 +1,67 @@
+package kvproviders
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulWait is the maximum time a blocking query waits for the key to change.
+const consulWait = 5 * time.Minute
+
+// NewConsulSource returns a Source with the providers of the key of the Consul KV store at addr,
+// i.e.: http://localhost:8500, watched with blocking queries. The client must not time out
+// before the blocking queries, which wait up to 5 minutes. The error of retrieving or parsing
+// the current value of the key is returned.
+func NewConsulSource(client *http.Client, addr string, key string) (*Source, error) {
+	return newSource(consulFetch(client, addr, key), defaultRetryInterval)
+}
+
+func consulFetch(client *http.Client, addr string, key string) fetchFunc {
+	u := strings.TrimSuffix(addr, "/") + "/v1/kv/" + strings.TrimPrefix(key, "/")
+
+	return func(ctx context.Context, index uint64) ([]byte, uint64, error) {
+		q := url.Values{"raw": {"true"}}
+		if index > 0 {
+			q.Set("index", strconv.FormatUint(index, 10))
+			q.Set("wait", consulWait.String())
+		}
+
+		req, err := http.NewRequest(http.MethodGet, u+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, 0, fmt.Errorf("Consul responded to the request for the key %v with the status %v.", key, resp.StatusCode)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Consul responded to the request for the key %v without a valid index.", key)
+		}
+
+		// The index must be greater than zero for the queries to block.
+		if next == 0 {
+			next = 1
+		}
+
+		return data, next, nil
+	}
+}
@@ -0,0 +1,158 @@
+package kvproviders
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is a key of a configuration store whose value is modified at increasing indexes.
+type fakeStore struct {
+	mu      sync.Mutex
+	value   string
+	index   uint64
+	changed chan struct{}
+}
+
+func newFakeStore(value string) *fakeStore {
+	return &fakeStore{value: value, index: 10, changed: make(chan struct{})}
+}
+
+func (s *fakeStore) set(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.value = value
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// wait returns the value once its index is greater than index, or false when ctx is done.
+func (s *fakeStore) wait(ctx context.Context, index uint64) (string, uint64, bool) {
+	for {
+		s.mu.Lock()
+		value, current, changed := s.value, s.index, s.changed
+		s.mu.Unlock()
+
+		if current > index {
+			return value, current, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", 0, false
+		case <-changed:
+		}
+	}
+}
+
+func newFakeConsul(t *testing.T, s *fakeStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/myservice/providers" || r.URL.Query().Get("raw") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		if index > 0 && r.URL.Query().Get("wait") == "" {
+			t.Error("Expected a blocking query with a wait.")
+		}
+
+		value, current, ok := s.wait(r.Context(), index)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(current, 10))
+		w.Write([]byte(value))
+	}))
+}
+
+func expectIssuer(t *testing.T, src *Source, issuer string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		provs, _ := src.Providers()
+		if len(provs) == 1 && provs[0].Issuer == issuer {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the provider %v, but got %v", issuer, provs)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+const (
+	providers1 = `{"providers":[{"issuer":"https://issuer1","client_ids":["client"]}]}`
+	providers2 = `{"providers":[{"issuer":"https://issuer2","client_ids":["client"]}]}`
+)
+
+func Test_NewConsulSource_WatchesKey(t *testing.T) {
+	store := newFakeStore(providers1)
+	server := newFakeConsul(t, store)
+	defer server.Close()
+
+	src, err := NewConsulSource(http.DefaultClient, server.URL+"/", "/myservice/providers")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer src.Stop()
+
+	expectIssuer(t, src, "https://issuer1")
+
+	store.set(providers2)
+	expectIssuer(t, src, "https://issuer2")
+
+	// The previous providers are kept when the value is invalid.
+	store.set(`{"providers":[]}`)
+	store.set(`{"providers":`)
+	time.Sleep(50 * time.Millisecond)
+	expectIssuer(t, src, "https://issuer2")
+
+	store.set(providers1)
+	expectIssuer(t, src, "https://issuer1")
+}
+
+func Test_NewConsulSource_WhenKeyInvalid(t *testing.T) {
+	server := newFakeConsul(t, newFakeStore(`{"providers":[]}`))
+	defer server.Close()
+
+	if _, err := NewConsulSource(http.DefaultClient, server.URL, "myservice/other"); err == nil {
+		t.Error("Expected an error for a missing key.")
+	}
+
+	if _, err := NewConsulSource(http.DefaultClient, server.URL, "myservice/providers"); err == nil {
+		t.Error("Expected an error for a value without providers.")
+	}
+}
+
+func Test_Source_Stop(t *testing.T) {
+	server := newFakeConsul(t, newFakeStore(providers1))
+	defer server.Close()
+
+	src, err := NewConsulSource(http.DefaultClient, server.URL, "myservice/providers")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		src.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Stop to cancel the blocking query.")
+	}
+
+	expectIssuer(t, src, "https://issuer1")
+}
@@ -0,0 +1,18 @@
+/*Package kvproviders loads the providers of the openid package from a key of a configuration
+store, Consul or etcd, and watches the key so the changes are applied to every service instance
+without restarting them. The value of the key is a JSON document in the format of
+openid.ProvidersFile:
+
+	{"providers": [{"issuer": "https://accounts.google.com", "client_ids": ["client1"]}]}
+
+The stores are reached through their HTTP APIs:
+
+	s, err := kvproviders.NewConsulSource(http.DefaultClient, "http://localhost:8500", "myservice/providers")
+	if err != nil {
+	    log.Fatal(err)
+	}
+	defer s.Stop()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(s.Providers))
+*/
+package kvproviders
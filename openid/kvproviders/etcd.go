@@ -0,0 +1,145 @@
+package kvproviders
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewEtcdSource returns a Source with the providers of the key of the etcd v3 store at addr,
+// i.e.: http://localhost:2379, watched through the JSON gateway of its API. The client must not
+// time out while the key is watched. The error of retrieving or parsing the current value of the
+// key is returned.
+func NewEtcdSource(client *http.Client, addr string, key string) (*Source, error) {
+	return newSource(etcdFetch(client, addr, key), defaultRetryInterval)
+}
+
+// etcdKeyValue is a key value returned by the etcd JSON gateway, which encodes the 64 bits
+// integers as strings and the bytes as base64.
+type etcdKeyValue struct {
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Canceled        bool   `json:"canceled"`
+		CompactRevision string `json:"compact_revision"`
+		Events          []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func etcdFetch(client *http.Client, addr string, key string) fetchFunc {
+	addr = strings.TrimSuffix(addr, "/")
+	k := base64.StdEncoding.EncodeToString([]byte(key))
+
+	post := func(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(http.MethodPost, addr+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("etcd responded to the request for the key %v with the status %v.", key, resp.StatusCode)
+		}
+
+		return resp, nil
+	}
+
+	// get returns the current value of the key along with the revision of the store.
+	get := func(ctx context.Context) ([]byte, uint64, error) {
+		resp, err := post(ctx, "/v3/kv/range", map[string]string{"key": k})
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+
+		var rr etcdRangeResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+			return nil, 0, err
+		}
+
+		if len(rr.Kvs) == 0 {
+			return nil, 0, fmt.Errorf("The key %v was not found in etcd.", key)
+		}
+
+		rev, err := strconv.ParseUint(rr.Header.Revision, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("etcd responded to the request for the key %v without a valid revision.", key)
+		}
+
+		return rr.Kvs[0].Value, rev, nil
+	}
+
+	return func(ctx context.Context, index uint64) ([]byte, uint64, error) {
+		if index == 0 {
+			return get(ctx)
+		}
+
+		resp, err := post(ctx, "/v3/watch", map[string]interface{}{
+			"create_request": map[string]interface{}{"key": k, "start_revision": strconv.FormatUint(index+1, 10)},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+
+		// The deletions of the key are ignored, the providers of its last value are kept.
+		d := json.NewDecoder(resp.Body)
+		for {
+			var wr etcdWatchResponse
+			if err := d.Decode(&wr); err != nil {
+				return nil, 0, err
+			}
+
+			if wr.Error != nil {
+				return nil, 0, fmt.Errorf("etcd failed to watch the key %v: %v", key, wr.Error.Message)
+			}
+
+			// The revisions were compacted, the watch restarts from the current value.
+			if wr.Result.Canceled || wr.Result.CompactRevision != "" && wr.Result.CompactRevision != "0" {
+				return get(ctx)
+			}
+
+			for i := len(wr.Result.Events) - 1; i >= 0; i-- {
+				e := wr.Result.Events[i]
+				if e.Type == "DELETE" {
+					continue
+				}
+
+				rev, err := strconv.ParseUint(e.Kv.ModRevision, 10, 64)
+				if err != nil {
+					return nil, 0, fmt.Errorf("etcd responded to the watch of the key %v without a valid revision.", key)
+				}
+
+				return e.Kv.Value, rev, nil
+			}
+		}
+	}
+}
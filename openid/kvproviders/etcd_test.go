@@ -0,0 +1,97 @@
+package kvproviders
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newFakeEtcd(t *testing.T, s *fakeStore) *httptest.Server {
+	key := base64.StdEncoding.EncodeToString([]byte("myservice/providers"))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			var k string
+			json.Unmarshal(body["key"], &k)
+
+			value, current, _ := s.wait(r.Context(), 0)
+			resp := map[string]interface{}{"header": map[string]string{"revision": strconv.FormatUint(current, 10)}}
+			if k == key {
+				resp["kvs"] = []map[string]interface{}{{"value": []byte(value), "mod_revision": strconv.FormatUint(current, 10)}}
+			}
+
+			json.NewEncoder(w).Encode(resp)
+		case "/v3/watch":
+			var cr struct {
+				Key           string `json:"key"`
+				StartRevision string `json:"start_revision"`
+			}
+			json.Unmarshal(body["create_request"], &cr)
+
+			start, _ := strconv.ParseUint(cr.StartRevision, 10, 64)
+			if cr.Key != key || start == 0 {
+				t.Errorf("Expected a watch of the key from a revision, but got %+v", cr)
+			}
+
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+			w.(http.Flusher).Flush()
+
+			value, current, ok := s.wait(r.Context(), start-1)
+			if !ok {
+				return
+			}
+
+			event := map[string]interface{}{"kv": map[string]interface{}{"value": []byte(value), "mod_revision": strconv.FormatUint(current, 10)}}
+			if value == "" {
+				event["type"] = "DELETE"
+			}
+
+			enc.Encode(map[string]interface{}{"result": map[string]interface{}{"events": []interface{}{event}}})
+			w.(http.Flusher).Flush()
+
+			// The stream stays open until the client cancels the watch.
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func Test_NewEtcdSource_WatchesKey(t *testing.T) {
+	store := newFakeStore(providers1)
+	server := newFakeEtcd(t, store)
+	defer server.Close()
+
+	src, err := NewEtcdSource(http.DefaultClient, server.URL, "myservice/providers")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer src.Stop()
+
+	expectIssuer(t, src, "https://issuer1")
+
+	store.set(providers2)
+	expectIssuer(t, src, "https://issuer2")
+
+	// The providers are kept when the key is deleted.
+	store.set("")
+	store.set(providers1)
+	expectIssuer(t, src, "https://issuer1")
+}
+
+func Test_NewEtcdSource_WhenKeyNotFound(t *testing.T) {
+	server := newFakeEtcd(t, newFakeStore(providers1))
+	defer server.Close()
+
+	if _, err := NewEtcdSource(http.DefaultClient, server.URL, "myservice/other"); err == nil {
+		t.Error("Expected an error for a missing key.")
+	}
+}
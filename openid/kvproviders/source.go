@@ -0,0 +1,104 @@
+package kvproviders
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+// defaultRetryInterval is the time waited before watching a key again after a failure.
+const defaultRetryInterval = 5 * time.Second
+
+// fetchFunc returns the value of the key once its modification index is greater than index,
+// blocking until it is or ctx is done, along with the new index. An index of zero returns
+// the current value immediately.
+type fetchFunc func(ctx context.Context, index uint64) ([]byte, uint64, error)
+
+// Source holds the providers of a key of a configuration store, replaced all at once whenever
+// the key changes. Register its Providers method as the openid.GetProvidersFunc. It is safe for
+// concurrent use.
+type Source struct {
+	fetch fetchFunc
+	retry time.Duration
+
+	mu    sync.RWMutex
+	provs []openid.Provider
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newSource returns a Source with the providers of the current value of the key, watching it
+// until Stop is called. The error of retrieving or parsing the current value is returned.
+func newSource(fetch fetchFunc, retry time.Duration) (*Source, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Source{fetch: fetch, retry: retry, cancel: cancel, done: make(chan struct{})}
+
+	data, index, err := fetch(ctx, 0)
+	if err == nil {
+		s.provs, err = openid.ParseProviders(data)
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.watch(ctx, index)
+	return s, nil
+}
+
+// Providers returns the providers of the current value of the key.
+func (s *Source) Providers() ([]openid.Provider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.provs, nil
+}
+
+// Stop stops watching the key. The providers of its last value are still returned.
+func (s *Source) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// watch replaces the providers whenever the key changes, until ctx is done. The previous
+// providers are kept when the new value cannot be parsed, or the key cannot be retrieved, in
+// which case it is watched again after the retry interval.
+func (s *Source) watch(ctx context.Context, index uint64) {
+	defer close(s.done)
+
+	for {
+		data, next, err := s.fetch(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.retry):
+			}
+
+			continue
+		}
+
+		if next != index {
+			if provs, err := openid.ParseProviders(data); err == nil {
+				s.mu.Lock()
+				s.provs = provs
+				s.mu.Unlock()
+			}
+		}
+
+		// An index going backwards, i.e.: after the store was restored, restarts the watch.
+		if next < index {
+			next = 0
+		}
+
+		index = next
+	}
+}
@@ -0,0 +1,161 @@
+package lambdaauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pachapman/openid2go/openid"
+)
+
+// ErrUnauthorized is returned by the authorizers when the token is missing or invalid, which API
+// Gateway responds to with status 401/Unauthorized.
+var ErrUnauthorized = errors.New("Unauthorized")
+
+const (
+	policyVersion = "2012-10-17"
+	invokeAction  = "execute-api:Invoke"
+)
+
+// Authorizer validates the tokens of the API Gateway authorizer events and enforces policies on
+// their users.
+type Authorizer struct {
+	validator *openid.Validator
+	policies  []openid.Policy
+}
+
+// NewAuthorizer returns an Authorizer validating the tokens with conf and requiring their users to
+// satisfy all the given policies. The requests of the users denied by a policy are rejected by API
+// Gateway with status 403/Forbidden. The policies receive a nil request for the TOKEN authorizers,
+// and the request described by the event otherwise.
+func NewAuthorizer(conf *openid.Configuration, policies ...openid.Policy) *Authorizer {
+	return &Authorizer{validator: openid.NewValidator(conf), policies: policies}
+}
+
+// Token handles the event of a TOKEN authorizer, whose token is the value of the Authorization
+// header, with or without the Bearer scheme. As the policies only depend on the token, which API
+// Gateway caches the response for, the policy of the response allows or denies the invocation of
+// all the methods of the stage of the API.
+func (a *Authorizer) Token(ctx context.Context, e events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	return a.authorize(ctx, e.AuthorizationToken, stageArn(e.MethodArn), nil)
+}
+
+// Request handles the event of a REQUEST authorizer, reading the token from its Authorization
+// header, as Token. As the policies can depend on the request, the policy of the response only
+// allows or denies the invocation of the method of the event, its MethodArn.
+func (a *Authorizer) Request(ctx context.Context, e events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	r := newRequest(ctx, e.HTTPMethod, e.Path, e.Headers)
+	return a.authorize(ctx, r.Header.Get("Authorization"), e.MethodArn, r)
+}
+
+// HTTPAPI handles the event of an authorizer of an HTTP API using the simple response format,
+// reading the token from its Authorization header.
+func (a *Authorizer) HTTPAPI(ctx context.Context, e events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
+	r := newRequest(ctx, e.RequestContext.HTTP.Method, e.RawPath, e.Headers)
+	u, err := a.validate(ctx, r.Header.Get("Authorization"), r)
+	if err == ErrUnauthorized {
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{}, err
+	}
+
+	if err != nil {
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{IsAuthorized: false}, nil
+	}
+
+	return events.APIGatewayV2CustomAuthorizerSimpleResponse{IsAuthorized: true, Context: userContext(u)}, nil
+}
+
+// authorize returns the response allowing or denying the invocation of the resource by the user
+// authenticated by the token t.
+func (a *Authorizer) authorize(ctx context.Context, t string, resource string, r *http.Request) (events.APIGatewayCustomAuthorizerResponse, error) {
+	u, err := a.validate(ctx, t, r)
+	if err == ErrUnauthorized {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	effect := "Allow"
+	resp := events.APIGatewayCustomAuthorizerResponse{PrincipalID: u.ID, Context: userContext(u)}
+	if err != nil {
+		effect = "Deny"
+		resp.Context = nil
+	}
+
+	resp.PolicyDocument = events.APIGatewayCustomAuthorizerPolicy{
+		Version: policyVersion,
+		Statement: []events.IAMPolicyStatement{{
+			Action:   []string{invokeAction},
+			Effect:   effect,
+			Resource: []string{resource},
+		}},
+	}
+
+	return resp, nil
+}
+
+// validate returns the user authenticated by the token t, and ErrUnauthorized when the token is
+// missing or invalid. The error of the first policy denying the user is returned along with it.
+func (a *Authorizer) validate(ctx context.Context, t string, r *http.Request) (*openid.User, error) {
+	t = strings.TrimSpace(t)
+	if len(t) > 7 && strings.EqualFold(t[:7], "Bearer ") {
+		t = strings.TrimSpace(t[7:])
+	}
+
+	if t == "" {
+		return nil, ErrUnauthorized
+	}
+
+	u, err := a.validator.Validate(ctx, t)
+	if err != nil {
+		if ve, ok := err.(*openid.ValidationError); ok && ve.HTTPStatus == http.StatusForbidden {
+			return &openid.User{}, err
+		}
+
+		return nil, ErrUnauthorized
+	}
+
+	for _, p := range a.policies {
+		if err := p(u, r); err != nil {
+			return u, err
+		}
+	}
+
+	return u, nil
+}
+
+// stageArn returns the ARN of all the methods of the stage of the method ARN, i.e.:
+// arn:aws:execute-api:us-east-1:123456789012:api/prod/* for
+// arn:aws:execute-api:us-east-1:123456789012:api/prod/GET/orders.
+func stageArn(methodArn string) string {
+	parts := strings.SplitN(methodArn, "/", 3)
+	if len(parts) < 2 {
+		return methodArn
+	}
+
+	return parts[0] + "/" + parts[1] + "/*"
+}
+
+// userContext returns the context of the response authorizing the user u.
+func userContext(u *openid.User) map[string]interface{} {
+	c := map[string]interface{}{"sub": u.ID, "iss": u.Issuer}
+	if data, err := json.Marshal(u.Claims); err == nil {
+		c["claims"] = string(data)
+	}
+
+	return c
+}
+
+func newRequest(ctx context.Context, method string, path string, headers map[string]string) *http.Request {
+	r, err := http.NewRequest(method, "/", nil)
+	if err != nil {
+		r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	}
+
+	r.URL.Path = path
+	for n, v := range headers {
+		r.Header.Set(n, v)
+	}
+
+	return r.WithContext(ctx)
+}
@@ -0,0 +1,80 @@
+package lambdaauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/oidctest"
+)
+
+const methodArn = "arn:aws:execute-api:us-east-1:123456789012:api/prod/GET/orders/1"
+
+func Test_Authorizer(t *testing.T) {
+	op, err := oidctest.NewProvider(oidctest.NewClock(time.Now()))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer op.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{op.Provider("app")}, nil
+	}))
+
+	a := NewAuthorizer(c, openid.RequireScope("orders:read"))
+	read, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "scope": "orders:read"}, time.Hour)
+	none, _ := op.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	tests := []struct {
+		token  string
+		effect string
+		err    error
+	}{
+		{"Bearer " + read, "Allow", nil},
+		{read, "Allow", nil},
+		{"Bearer " + none, "Deny", nil},
+		{"Bearer invalid", "", ErrUnauthorized},
+		{"", "", ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		resp, err := a.Token(context.Background(), events.APIGatewayCustomAuthorizerRequest{Type: "TOKEN", AuthorizationToken: test.token, MethodArn: methodArn})
+		if err != test.err {
+			t.Errorf("Expected the error %v for the token %q, but got %v", test.err, test.token, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		st := resp.PolicyDocument.Statement
+		if len(st) != 1 || st[0].Effect != test.effect || st[0].Resource[0] != "arn:aws:execute-api:us-east-1:123456789012:api/prod/*" {
+			t.Errorf("Expected the effect %v on the stage for the token %q, but got %+v", test.effect, test.token, resp.PolicyDocument)
+		}
+
+		if test.effect == "Allow" && (resp.PrincipalID != "user1" || resp.Context["sub"] != "user1" || resp.Context["claims"] == nil) {
+			t.Error("Expected the user in the response, but got", resp)
+		}
+	}
+
+	resp, err := a.Request(context.Background(), events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		MethodArn: methodArn, HTTPMethod: "GET", Path: "/orders/1", Headers: map[string]string{"authorization": "Bearer " + read},
+	})
+	if err != nil || resp.PolicyDocument.Statement[0].Effect != "Allow" || resp.PolicyDocument.Statement[0].Resource[0] != methodArn {
+		t.Error("Expected the REQUEST authorizer to allow the method for the token, but got", resp, err)
+	}
+
+	hr, err := a.HTTPAPI(context.Background(), events.APIGatewayV2CustomAuthorizerV2Request{Headers: map[string]string{"authorization": "Bearer " + none}})
+	if err != nil || hr.IsAuthorized {
+		t.Error("Expected the HTTP API authorizer to deny the token, but got", hr, err)
+	}
+}
+
+func Test_stageArn(t *testing.T) {
+	if a := stageArn("arn:aws:execute-api:us-east-1:1:api"); a != "arn:aws:execute-api:us-east-1:1:api" {
+		t.Error("Expected the malformed ARN unchanged, but got", a)
+	}
+}
@@ -0,0 +1,20 @@
+/*
+Package lambdaauth adapts the openid package to the Lambda authorizers of AWS API Gateway, so the
+providers of a Configuration guard Lambda based APIs:
+
+	conf, _ := openid.NewConfiguration(openid.EnvProviders())
+	a := lambdaauth.NewAuthorizer(conf, openid.RequireScope("orders:read"))
+
+	lambda.Start(a.Token)
+
+Token handles the events of the TOKEN authorizers of REST APIs, Request the events of their
+REQUEST authorizers and HTTPAPI the events of the authorizers of HTTP APIs using the simple
+response format. The tokens are validated as by openid.Validator, and the subject, issuer and
+claims of the user are returned in the context of the response, available to the integrations as
+$context.authorizer.sub, $context.authorizer.iss and $context.authorizer.claims.
+
+The policy returned by Token applies to all the methods of the stage of the API, so the policies
+given to NewAuthorizer must only depend on the token for the TOKEN authorizers; the policy returned
+by Request only applies to the invoked method, as the policies can then depend on the request.
+*/
+package lambdaauth
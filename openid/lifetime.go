@@ -0,0 +1,82 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const issuedAtClaimName = "iat"
+
+// MaxTokenLifetime option rejects tokens whose lifetime, the time between the 'iat' and 'exp'
+// claims, is longer than d regardless of the token not being expired yet. This protects the service
+// from issuers misconfigured to mint long lived tokens. Once enabled every token must contain
+// the 'iat' and 'exp' claims.
+func MaxTokenLifetime(d time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &lifetimeChecker{maxLifetime: d, now: time.Now})
+		return nil
+	}
+}
+
+// MaxTokenAge option rejects tokens issued, according to the 'iat' claim, longer than d ago
+// regardless of the token not being expired yet. Once enabled every token must contain the 'iat' claim.
+func MaxTokenAge(d time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &lifetimeChecker{maxAge: d, now: time.Now})
+		return nil
+	}
+}
+
+// lifetimeChecker enforces the maximum lifetime and age of a token. A zero value disables
+// the respective limit.
+type lifetimeChecker struct {
+	maxLifetime time.Duration
+	maxAge      time.Duration
+	now         func() time.Time
+}
+
+func (lc *lifetimeChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	claims := t.Claims.(jwt.MapClaims)
+	iat, ok := getTimeClaim(claims, issuedAtClaimName)
+
+	if !ok {
+		return &ValidationError{
+			Code:       ValidationErrorIssuedAtNotFound,
+			Message:    "The token 'iat' claim was not found or was not a number.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	if lc.maxLifetime > 0 {
+		exp, ok := getTimeClaim(claims, expirationClaimName)
+
+		if !ok {
+			return &ValidationError{
+				Code:       ValidationErrorExpirationNotFound,
+				Message:    "The token 'exp' claim was not found or was not a number.",
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+
+		if exp.Sub(iat) > lc.maxLifetime {
+			return &ValidationError{
+				Code:       ValidationErrorTokenLifetimeExceeded,
+				Message:    fmt.Sprintf("The token lifetime exceeds the maximum of %v.", lc.maxLifetime),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	if lc.maxAge > 0 && lc.now().Sub(iat) > lc.maxAge {
+		return &ValidationError{
+			Code:       ValidationErrorTokenAgeExceeded,
+			Message:    fmt.Sprintf("The token was issued more than %v ago.", lc.maxAge),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestLifetimeChecker_Check_WhenIssuedAtMissing(t *testing.T) {
+	lc := &lifetimeChecker{maxAge: time.Hour, now: time.Now}
+
+	e := lc.check(nil, &jwt.Token{Claims: jwt.MapClaims{}}, nil)
+
+	expectValidationError(t, e, ValidationErrorIssuedAtNotFound, http.StatusUnauthorized, nil)
+}
+
+func TestLifetimeChecker_Check_WhenExpirationMissing(t *testing.T) {
+	lc := &lifetimeChecker{maxLifetime: time.Hour, now: time.Now}
+
+	e := lc.check(nil, createLifetimeToken(time.Now(), nil), nil)
+
+	expectValidationError(t, e, ValidationErrorExpirationNotFound, http.StatusUnauthorized, nil)
+}
+
+func TestLifetimeChecker_Check_Lifetime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		exp      time.Time
+		exceeded bool
+	}{
+		{now.Add(time.Hour), false},
+		{now.Add(time.Hour + time.Second), true},
+		{now.Add(7 * 24 * time.Hour), true},
+	}
+
+	lc := &lifetimeChecker{maxLifetime: time.Hour, now: time.Now}
+	for _, test := range tests {
+		exp := test.exp
+		e := lc.check(nil, createLifetimeToken(now, &exp), nil)
+
+		if test.exceeded {
+			expectValidationError(t, e, ValidationErrorTokenLifetimeExceeded, http.StatusUnauthorized, nil)
+		} else if e != nil {
+			t.Error("An error was returned but not expected", e)
+		}
+	}
+}
+
+func TestLifetimeChecker_Check_Age(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	tests := []struct {
+		iat      time.Time
+		exceeded bool
+	}{
+		{now.Add(-time.Minute), false},
+		{now.Add(-time.Hour), false},
+		{now.Add(-time.Hour - time.Second), true},
+	}
+
+	lc := &lifetimeChecker{maxAge: time.Hour, now: func() time.Time { return now }}
+	for _, test := range tests {
+		exp := now.Add(24 * time.Hour)
+		e := lc.check(nil, createLifetimeToken(test.iat, &exp), nil)
+
+		if test.exceeded {
+			expectValidationError(t, e, ValidationErrorTokenAgeExceeded, http.StatusUnauthorized, nil)
+		} else if e != nil {
+			t.Error("An error was returned but not expected", e)
+		}
+	}
+}
+
+func TestMaxTokenLifetime_RegistersChecker(t *testing.T) {
+	c, e := NewConfiguration(MaxTokenLifetime(time.Hour), MaxTokenAge(time.Minute))
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if len(c.tokenCheckers) != 2 {
+		t.Fatal("Expected 2 token checkers, but got", len(c.tokenCheckers))
+	}
+
+	if lc := c.tokenCheckers[0].(*lifetimeChecker); lc.maxLifetime != time.Hour || lc.maxAge != 0 {
+		t.Error("Expected a maximum lifetime of 1h, but got", lc.maxLifetime, lc.maxAge)
+	}
+
+	if lc := c.tokenCheckers[1].(*lifetimeChecker); lc.maxAge != time.Minute || lc.maxLifetime != 0 {
+		t.Error("Expected a maximum age of 1m, but got", lc.maxAge, lc.maxLifetime)
+	}
+}
+
+func createLifetimeToken(iat time.Time, exp *time.Time) *jwt.Token {
+	claims := jwt.MapClaims{issuedAtClaimName: float64(iat.Unix())}
+	if exp != nil {
+		claims[expirationClaimName] = float64(exp.Unix())
+	}
+
+	return &jwt.Token{Claims: claims}
+}
@@ -0,0 +1,101 @@
+package openid
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const logoutTokenFormName = "logout_token"
+const eventsClaimName = "events"
+const nonceClaimName = "nonce"
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// BackChannelLogoutHandler returns the handler receiving the logout tokens sent by the providers
+// as described by https://openid.net/specs/openid-connect-backchannel-1_0.html.
+// The logout token is validated the same way as the tokens accepted by the middlewares, then the
+// session identified by its 'sid' claim and all the sessions of the subject of its 'sub' claim are
+// recorded in the store registered with the TokenRevocation option. The middlewares then reject
+// the tokens of those sessions issued before the logout.
+// The conf must have been created with the TokenRevocation option.
+func BackChannelLogoutHandler(conf *Configuration) (http.Handler, error) {
+	if conf.revocation == nil {
+		return nil, &SetupError{
+			Code:    SetupErrorRevocationStoreNotFound,
+			Message: "The back-channel logout handler requires the TokenRevocation option.",
+		}
+	}
+
+	// Logout tokens identify either a session or a subject, so the subject is optional.
+	lv := *conf.idTokenValidator()
+	lv.subjectOptional = true
+
+	return &backChannelLogoutHandler{validator: &lv, revocation: conf.revocation}, nil
+}
+
+type backChannelLogoutHandler struct {
+	validator  jwtTokenValidator
+	revocation *revocationChecker
+}
+
+func (h *backChannelLogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	lt := r.PostFormValue(logoutTokenFormName)
+	if lt == "" {
+		http.Error(w, "The request does not contain a logout token.", http.StatusBadRequest)
+		return
+	}
+
+	t, _, err := h.validator.validate(r, lt)
+	if err == nil {
+		err = validateLogoutToken(t)
+	}
+
+	if err == nil {
+		err = h.revocation.revoke(t.Claims.(jwt.MapClaims))
+	}
+
+	if err != nil {
+		status := http.StatusBadRequest
+		if ve, ok := err.(*ValidationError); ok && ve.HTTPStatus >= http.StatusInternalServerError {
+			status = ve.HTTPStatus
+		}
+
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// validateLogoutToken validates the claims specific to logout tokens.
+func validateLogoutToken(t *jwt.Token) error {
+	claims := t.Claims.(jwt.MapClaims)
+
+	if len(revocationKeys(claims)) == 0 {
+		return logoutTokenError("The logout token does not contain a 'sid' or 'sub' claim.")
+	}
+
+	events, _ := claims[eventsClaimName].(map[string]interface{})
+	if _, ok := events[backChannelLogoutEvent]; !ok {
+		return logoutTokenError("The logout token 'events' claim does not contain the back-channel logout event.")
+	}
+
+	if _, ok := claims[nonceClaimName]; ok {
+		return logoutTokenError("The logout token must not contain a 'nonce' claim.")
+	}
+
+	return nil
+}
+
+func logoutTokenError(m string) error {
+	return &ValidationError{
+		Code:       ValidationErrorInvalidLogoutToken,
+		Message:    m,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
@@ -0,0 +1,120 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBackChannelLogoutHandler_WhenRevocationNotConfigured(t *testing.T) {
+	c, _ := NewConfiguration()
+
+	_, e := BackChannelLogoutHandler(c)
+
+	expectSetupError(t, e, SetupErrorRevocationStoreNotFound)
+}
+
+func TestBackChannelLogoutHandler_AllowsMissingSubject(t *testing.T) {
+	c, _ := NewConfiguration(TokenRevocation(NewMemoryRevocationStore(), time.Hour))
+
+	h, e := BackChannelLogoutHandler(c)
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if !h.(*backChannelLogoutHandler).validator.(*idTokenValidator).subjectOptional {
+		t.Error("The logout token validator should accept tokens without subject")
+	}
+
+	if c.idTokenValidator().subjectOptional {
+		t.Error("The configuration validator should still require the subject")
+	}
+}
+
+func TestBackChannelLogoutHandler_ServeHTTP(t *testing.T) {
+	event := map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}}
+	tests := []struct {
+		method string
+		claims jwt.MapClaims
+		status int
+	}{
+		{http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, nil, http.StatusBadRequest},
+		{http.MethodPost, jwt.MapClaims{"iss": "https://issuer", "sid": "session1", "events": event}, http.StatusOK},
+		{http.MethodPost, jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "events": event}, http.StatusOK},
+		{http.MethodPost, jwt.MapClaims{"iss": "https://issuer", "events": event}, http.StatusBadRequest},
+		{http.MethodPost, jwt.MapClaims{"iss": "https://issuer", "sid": "session1"}, http.StatusBadRequest},
+		{http.MethodPost, jwt.MapClaims{"iss": "https://issuer", "sid": "session1", "events": event, "nonce": "n"}, http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		vm := &mockJwtTokenValidator{}
+		h := &backChannelLogoutHandler{vm, &revocationChecker{store: NewMemoryRevocationStore(), retention: time.Hour, now: time.Now}}
+		form := url.Values{}
+		if test.claims != nil {
+			form.Set(logoutTokenFormName, "logout token")
+			vm.On("validate", mock.Anything, "logout token").Return(&jwt.Token{Claims: test.claims}, &Provider{}, nil)
+		}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "/logout", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Error("Test", i, "expected status", test.status, "but got", w.Code)
+		}
+
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Error("Test", i, "expected the Cache-Control header no-store, but got", w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestBackChannelLogoutHandler_ServeHTTP_WhenValidationFails(t *testing.T) {
+	vm := &mockJwtTokenValidator{}
+	h := &backChannelLogoutHandler{vm, &revocationChecker{store: NewMemoryRevocationStore(), now: time.Now}}
+	vm.On("validate", mock.Anything, "logout token").Return(nil, nil, &ValidationError{Code: ValidationErrorJwtValidationFailure, HTTPStatus: http.StatusUnauthorized})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader("logout_token=logout+token"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Error("Expected status", http.StatusBadRequest, "but got", w.Code)
+	}
+}
+
+func TestBackChannelLogoutHandler_ServeHTTP_RevokesSession(t *testing.T) {
+	vm := &mockJwtTokenValidator{}
+	now := time.Unix(time.Now().Unix(), 0)
+	rc := &revocationChecker{store: NewMemoryRevocationStore(), retention: time.Hour, now: func() time.Time { return now }}
+	h := &backChannelLogoutHandler{vm, rc}
+	lc := jwt.MapClaims{"iss": "https://issuer", "sid": "session1", "events": map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}}}
+	vm.On("validate", mock.Anything, "logout token").Return(&jwt.Token{Claims: lc}, &Provider{}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader("logout_token=logout+token"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected status", http.StatusOK, "but got", w.Code)
+	}
+
+	at := jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1", "iat": float64(now.Add(-time.Minute).Unix())}
+	e := rc.check(nil, &jwt.Token{Claims: at}, nil)
+
+	expectValidationError(t, e, ValidationErrorTokenRevoked, http.StatusUnauthorized, nil)
+}
@@ -1,18 +1,42 @@
 package openid
 
 import (
+	"crypto/tls"
 	"net/http"
 
 	"github.com/dgrijalva/jwt-go"
-	"github.com/julienschmidt/httprouter"
 )
 
 // The Configuration contains the entities needed to perform ID token validation.
 // This type should be instantiated at the application startup time.
 type Configuration struct {
 	tokenValidator jwtTokenValidator
+	introspector   *httpIntrospector
 	idTokenGetter  GetIDTokenFunc
 	errorHandler   ErrorHandlerFunc
+	tokenCheckers  []tokenChecker
+	tokenLimits    *tokenLimits
+	revocation     *revocationChecker
+	transport      *transportPolicy
+	cache          *tokenCache
+	typedClaims    *typedClaims
+	configGetter   configurationGetter
+	refresher      *keyRefresher
+	providerTLS    *tls.Config
+	httpClient     *http.Client
+	skip           SkipFunc
+	skipPreflight  bool
+	groupsResolver GroupsResolverFunc
+
+	beforeValidation []BeforeValidationFunc
+	afterValidation  []AfterValidationFunc
+	identityHeaders  bool
+	redactedClaims   []string
+
+	claimsTransformers []ClaimsTransformerFunc
+
+	deprecationHandler DeprecationHandlerFunc
+	deprecations       []Deprecation
 }
 
 type option func(*Configuration) error
@@ -23,11 +47,13 @@ type option func(*Configuration) error
 // returns an error then NewConfiguration will return a nil configuration and that error.
 func NewConfiguration(options ...option) (*Configuration, error) {
 	m := new(Configuration)
-	cp := newHTTPConfigurationProvider(defaultHTTPGet, &jsonConfigurationDecoder{})
-	jp := newHTTPJwksProvider(defaultHTTPGet, &jsonJwksDecoder{})
-	ksp := newSigningKeySetProvider(cp, jp, &pemPublicKeyEncoder{})
+	cp := newHTTPConfigurationProvider(defaultHTTPGetter{}, &jsonConfigurationDecoder{})
+	jp := newHTTPJwksProvider(defaultHTTPGetter{}, &jsonJwksDecoder{})
+	ksp := newSigningKeySetProvider(cp, jp)
 	kp := newSigningKeyProvider(ksp)
-	m.tokenValidator = newIDTokenValidator(nil, jwtParserFunc(jwt.Parse), kp, &defaultPemToRSAPublicKeyParser{})
+	m.tokenValidator = newIDTokenValidator(nil, jwtParserFunc(jwt.Parse), kp)
+	m.introspector = newHTTPIntrospector(cp, defaultHTTPGetter{})
+	m.configGetter = cp
 
 	for _, option := range options {
 		err := option(m)
@@ -37,14 +63,28 @@ func NewConfiguration(options ...option) (*Configuration, error) {
 		}
 	}
 
+	tv := m.idTokenValidator()
+	tv.provGetter = newProviderRegistry(tv.provGetter)
+
+	m.reportDeprecations()
 	return m, nil
 }
 
+// idTokenValidator returns the validator of JWTs, unwrapping the validator of opaque tokens
+// registered by the OpaqueTokens option.
+func (c *Configuration) idTokenValidator() *idTokenValidator {
+	if ov, ok := c.tokenValidator.(*opaqueTokenValidator); ok {
+		return ov.jwtValidator.(*idTokenValidator)
+	}
+
+	return c.tokenValidator.(*idTokenValidator)
+}
+
 // ProvidersGetter option registers the function responsible for returning the
 // providers containing the valid issuer and client IDs used to validate the ID Token.
 func ProvidersGetter(pg GetProvidersFunc) func(*Configuration) error {
 	return func(c *Configuration) error {
-		c.tokenValidator.(*idTokenValidator).provGetter = pg
+		c.idTokenValidator().provGetter = pg
 		return nil
 	}
 }
@@ -60,84 +100,151 @@ func ErrorHandler(eh ErrorHandlerFunc) func(*Configuration) error {
 }
 
 // HTTPGetFunc is a function that gets a URL based on a contextual request
-// and a target URL. The default behavior is the http.Get method, ignoring
-// the request parameter.
+// and a target URL. The default getter sends the requests with the context of the request
+// parameter, so the retrievals made on behalf of a request are canceled when its client
+// disconnects and honor its deadline. An HTTPGetFunc should do the same.
+// An HTTPGetFunc cannot send credentials, therefore it cannot be used with
+// providers configured with JwksCredentials, nor conditional requests, so the discovery
+// document and signing keys are always transferred in full. Prefer the HTTPClient option to
+// customize the transport.
 type HTTPGetFunc func(r *http.Request, url string) (*http.Response, error)
 
-var defaultHTTPGet = func(r *http.Request, url string) (*http.Response, error) {
-	return http.Get(url)
+// HTTPGetter option registers the function retrieving the discovery documents and signing keys
+// of the providers, and calling their introspection endpoints.
+//
+// Deprecated: use HTTPClient, which supports JwksCredentials and conditional requests. Its use is
+// reported to the DeprecationHook.
+func HTTPGetter(hg HTTPGetFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.deprecated(Deprecation{
+			Name:        "HTTPGetter",
+			Replacement: "HTTPClient",
+			Message:     "Replace the HTTPGetFunc with an http.Client whose Transport customizes the requests.",
+		})
+		c.httpClient, c.providerTLS = nil, nil
+		c.setHTTPGetter(hg)
+		return nil
+	}
 }
 
-// HTTPGetter option registers the function responsible for returning the
-// providers containing the valid issuer and client IDs used to validate the ID Token.
-func HTTPGetter(hg HTTPGetFunc) func(*Configuration) error {
+// HTTPClient option sets the client used to retrieve the discovery documents and signing keys,
+// and to call the introspection endpoints, so standard transports, proxies and instrumentation
+// wrappers can be used without registering an HTTPGetFunc. Unlike an HTTPGetFunc, the client
+// supports JwksCredentials and conditional requests. The http.DefaultClient is used by default.
+// HTTPClient replaces any HTTPGetFunc registered before it, and the other way around, as well as
+// the client configured by the ProviderTLSConfig, ProviderRootCAs and PinnedPublicKeys options.
+// These options registered after HTTPClient configure a copy of the client and of its transport,
+// which must then be an *http.Transport.
+func HTTPClient(hc *http.Client) func(*Configuration) error {
 	return func(c *Configuration) error {
-		sksp := c.tokenValidator.(*idTokenValidator).
-			keyGetter.(*signingKeyProvider).
-			keySetGetter.(*signingKeySetProvider)
-		sksp.configGetter.(*httpConfigurationProvider).getter = hg
-		sksp.jwksGetter.(*httpJwksProvider).getter = hg
+		c.httpClient, c.providerTLS = hc, nil
+		c.setHTTPGetter(defaultHTTPGetter{client: hc})
 		return nil
 	}
 }
 
+// setHTTPGetter sets the httpGetter used for all the calls to the providers.
+func (c *Configuration) setHTTPGetter(g httpGetter) {
+	sksp := c.idTokenValidator().
+		keyGetter.(*signingKeyProvider).
+		keySetGetter.(*signingKeySetProvider)
+	sksp.configGetter.(*httpConfigurationProvider).getter = g
+	sksp.jwksGetter.(*httpJwksProvider).getter = g
+	c.introspector.getter = g
+}
+
 // Authenticate middleware performs the validation of the OIDC ID Token.
 // If an error happens, i.e.: expired token, the next handler may or may not executed depending on the
 // provided ErrorHandlerFunc option. The default behavior, determined by validationErrorToHTTPStatus,
 // stops the execution and returns Unauthorized.
-// If the validation is successful then the next handler(h) will be executed.
+// If the validation is successful then the next handler(h) will be executed. The context of the
+// request forwarded to h carries the raw token and its provider, retrieved with the openidctx package.
 func Authenticate(conf *Configuration, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if _, halt := authenticate(conf, w, r); !halt {
-			h.ServeHTTP(w, r)
+		if ar, _, halt := authenticate(conf, w, r); !halt {
+			h.ServeHTTP(w, ar)
 		}
 	})
 }
 
-// Authenticate middleware performs the validation of the OIDC ID Token.
-// If an error happens, i.e.: expired token, the next handler may or may not execute depending on the
-// provided ErrorHandlerFunc option. The default behavior, determined by validationErrorToHTTPStatus,
-// stops the execution and returns Unauthorized.
-// If the validation is successful then the next handler(h) will be executed.
-func AuthenticateWithParams(conf *Configuration, h httprouter.Handle) httprouter.Handle {
-	return httprouter.Handle(func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-		if _, halt := authenticate(conf, w, r); !halt {
-			h(w, r, params)
-		}
-	})
+// New returns the standard middleware constructor of the configuration, so the authentication
+// composes with chi, alice, negroni and other middleware stacks:
+//
+//	r := chi.NewRouter()
+//	r.Use(openid.New(configuration))
+//
+// The middleware behaves as AuthenticateUserContext, the authenticated user is retrieved from
+// the request context with UserFromContext.
+func New(conf *Configuration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return AuthenticateUserContext(conf, next)
+	}
 }
 
-// AuthenticateUser middleware performs the validation of the OIDC ID Token and
-// forwards the authenticated user's information to the next handler in the pipeline.
+// AuthenticateUserContext middleware performs the validation of the OIDC ID Token and forwards
+// the authenticated user's information to the next handler in the pipeline through the request
+// context, so standard http.Handler chains and frameworks that only pass contexts can retrieve it
+// with UserFromContext. The claims of the token are the Claims of the user.
 // If an error happens, i.e.: expired token, the next handler may or may not executed depending on the
 // provided ErrorHandlerFunc option. The default behavior, determined by validationErrorToHTTPStatus,
 // stops the execution and returns Unauthorized.
-// If the validation is successful then the next handler(h) will be executed and will
-// receive the authenticated user information.
-func AuthenticateUser(conf *Configuration, h UserHandler) http.Handler {
+func AuthenticateUserContext(conf *Configuration, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if u, halt := authenticateUser(conf, w, r); !halt {
-			h(u, w, r)
+		if ar, _, halt := authenticateUser(conf, w, r); !halt {
+			h.ServeHTTP(w, ar)
 		}
 	})
 }
 
+// AuthenticateOptional middleware authenticates the requests carrying a token as
+// AuthenticateUserContext, and forwards the requests without token to the next handler(h)
+// without user, i.e.: for endpoints serving a public response to anonymous users and a
+// personalized one to authenticated users. A request is anonymous when the GetIDTokenFunc returns
+// a ValidationError with the code ValidationErrorAuthorizationHeaderNotFound, as the default one does
+// for requests without Authorization header. Malformed, expired or otherwise invalid tokens are
+// handled by the ErrorHandlerFunc option, and stop the execution by default.
+func AuthenticateOptional(conf *Configuration, h http.Handler) http.Handler {
+	eh := conf.errorHandler
+	if eh == nil {
+		eh = validationErrorToHTTPStatus
+	}
+
+	oc := conf.Route(RouteErrorHandler(func(e error, w http.ResponseWriter, r *http.Request) bool {
+		if ve, ok := e.(*ValidationError); ok && ve.Code == ValidationErrorAuthorizationHeaderNotFound {
+			return false
+		}
+
+		return eh(e, w, r)
+	}))
+
+	return AuthenticateUserContext(oc, h)
+}
+
 // AuthenticateUser middleware performs the validation of the OIDC ID Token and
 // forwards the authenticated user's information to the next handler in the pipeline.
 // If an error happens, i.e.: expired token, the next handler may or may not executed depending on the
 // provided ErrorHandlerFunc option. The default behavior, determined by validationErrorToHTTPStatus,
 // stops the execution and returns Unauthorized.
 // If the validation is successful then the next handler(h) will be executed and will
-// receive the authenticated user information.
-func AuthenticateUserWithParams(conf *Configuration, h UserHandlerWithParams) httprouter.Handle {
-	return httprouter.Handle(func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-		if u, halt := authenticateUser(conf, w, r); !halt {
-			h(u, w, r, params)
+// receive the authenticated user information, which is also stored in the request context
+// along with the raw token and its provider, see the openidctx package.
+func AuthenticateUser(conf *Configuration, h UserHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ar, u, halt := authenticateUser(conf, w, r); !halt {
+			h(u, w, ar)
 		}
 	})
 }
 
-func authenticate(c *Configuration, rw http.ResponseWriter, req *http.Request) (t *jwt.Token, halt bool) {
+func authenticate(c *Configuration, rw http.ResponseWriter, req *http.Request) (ar *http.Request, t *jwt.Token, halt bool) {
+	if c.identityHeaders {
+		stripIdentityHeaders(req)
+	}
+
+	if (c.skipPreflight && isPreflight(req)) || (c.skip != nil && c.skip(req)) {
+		return req, nil, false
+	}
+
 	var tg GetIDTokenFunc
 	if c.idTokenGetter == nil {
 		tg = getIDTokenAuthorizationHeader
@@ -152,22 +259,59 @@ func authenticate(c *Configuration, rw http.ResponseWriter, req *http.Request) (
 		eh = c.errorHandler
 	}
 
+	if c.transport != nil {
+		if err := c.transport.enforce(rw, req); err != nil {
+			return req, nil, eh(err, rw, req)
+		}
+	}
+
 	ts, err := tg(req)
 
 	if err != nil {
-		return nil, eh(err, rw, req)
+		return req, nil, eh(err, rw, req)
+	}
+
+	if c.tokenLimits != nil {
+		if err := c.tokenLimits.check(ts); err != nil {
+			return req, nil, eh(err, rw, req)
+		}
 	}
 
-	vt, err := c.tokenValidator.validate(req, ts)
+	if err := c.runBeforeValidation(req, ts); err != nil {
+		return req, nil, eh(err, rw, req)
+	}
+
+	vt, p, err := c.validate(req, ts)
 
 	if err != nil {
-		return nil, eh(err, rw, req)
+		return req, nil, eh(err, rw, req)
+	}
+
+	if err := checkToken(c.tokenCheckers, req, vt, p); err != nil {
+		return req, nil, eh(err, rw, req)
+	}
+
+	c.runAfterValidation(req, vt)
+
+	ar = withToken(req, vt, p)
+
+	if c.identityHeaders {
+		setIdentityHeaders(ar.Header, vt.Claims.(jwt.MapClaims))
 	}
 
-	return vt, false
+	if c.typedClaims != nil {
+		claims, err := c.typedClaims.decode(vt)
+		if err != nil {
+			return req, nil, eh(err, rw, req)
+		}
+
+		ar = withClaims(ar, claims)
+	}
+
+	return ar, vt, false
 }
 
-func authenticateUser(c *Configuration, rw http.ResponseWriter, req *http.Request) (u *User, halt bool) {
+func authenticateUser(c *Configuration, rw http.ResponseWriter, req *http.Request) (ar *http.Request, u *User, halt bool) {
 	var vt *jwt.Token
 
 	var eh ErrorHandlerFunc
@@ -177,17 +321,22 @@ func authenticateUser(c *Configuration, rw http.ResponseWriter, req *http.Reques
 		eh = c.errorHandler
 	}
 
-	if t, halt := authenticate(c, rw, req); !halt {
-		vt = t
+	if r, t, halt := authenticate(c, rw, req); !halt {
+		ar, vt = r, t
 	} else {
-		return nil, halt
+		return req, nil, halt
+	}
+
+	// The ErrorHandlerFunc chose to continue after a failed authentication.
+	if vt == nil {
+		return ar, nil, false
 	}
 
-	u, err := newUser(vt)
+	u, err := c.newUser(vt, tokenProvider(ar))
 
 	if err != nil {
-		return nil, eh(err, rw, req)
+		return req, nil, eh(err, rw, req)
 	}
 
-	return u, false
+	return withUser(ar, u), u, false
 }
@@ -1,12 +1,16 @@
 package openid
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid/internal/ctxkeys"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -15,7 +19,7 @@ const idToken string = "IDTOKEN"
 func Test_authenticateUser_WhenGetIDTokenReturnsError_WhenErrorHandlerContinues(t *testing.T) {
 	_, c := createConfiguration(t, errorHandlerContinue, getIDTokenReturnsError)
 
-	u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
+	_, u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
 
 	if u != nil {
 		t.Errorf("The returned user should be nil, but was %+v.", u)
@@ -29,7 +33,7 @@ func Test_authenticateUser_WhenGetIDTokenReturnsError_WhenErrorHandlerContinues(
 func Test_authenticateUser_WhenGetIDTokenReturnsError_WhenErrorHandlerHalts(t *testing.T) {
 	_, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsError)
 
-	u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
+	_, u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
 
 	if u != nil {
 		t.Errorf("The returned user should be nil, but was %+v.", u)
@@ -42,9 +46,9 @@ func Test_authenticateUser_WhenGetIDTokenReturnsError_WhenErrorHandlerHalts(t *t
 
 func Test_authenticateUser_WhenValidateReturnsError_WhenErrorHandlerHalts(t *testing.T) {
 	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
-	vm.On("validate", mock.Anything, idToken).Return(nil, errors.New("Error while validating the token"))
+	vm.On("validate", mock.Anything, idToken).Return(nil, nil, errors.New("Error while validating the token"))
 
-	u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
+	_, u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
 
 	if u != nil {
 		t.Errorf("The returned user should be nil, but was %+v.", u)
@@ -66,9 +70,12 @@ func Test_authenticateUser_WhenValidateSucceeds(t *testing.T) {
 	jt.Claims.(jwt.MapClaims)["iss"] = iss
 	jt.Claims.(jwt.MapClaims)["sub"] = sub
 
-	vm.On("validate", mock.Anything, idToken).Return(jt, nil)
+	jt.Raw = idToken
+	p := &Provider{Issuer: iss}
 
-	u, halt := authenticateUser(c, httptest.NewRecorder(), nil)
+	vm.On("validate", mock.Anything, idToken).Return(jt, p, nil)
+
+	ar, u, halt := authenticateUser(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
 
 	if halt {
 		t.Error("A successful authenticateUser call should not have returned halt with value true.")
@@ -90,6 +97,154 @@ func Test_authenticateUser_WhenValidateSucceeds(t *testing.T) {
 		t.Error("Expected number of user claims", len(jt.Claims.(jwt.MapClaims)), ", but got", len(u.Claims))
 	}
 
+	if cu := ar.Context().Value(ctxkeys.User); cu != u {
+		t.Error("Expected the user", u, "in the request context, but got", cu)
+	}
+
+	if ct := ar.Context().Value(ctxkeys.RawToken); ct != idToken {
+		t.Error("Expected the raw token", idToken, "in the request context, but got", ct)
+	}
+
+	if cp := ar.Context().Value(ctxkeys.Provider); cp != p {
+		t.Error("Expected the provider", p, "in the request context, but got", cp)
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func Test_AuthenticateUserContext(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
+	jt.Claims.(jwt.MapClaims)["sub"] = "SUB1"
+	jt.Claims.(jwt.MapClaims)["email"] = "user1@example.com"
+	jt.Raw = idToken
+
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{Issuer: "https://issuer"}, nil)
+
+	var u *User
+	AuthenticateUserContext(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ = UserFromContext(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if u == nil || u.ID != "SUB1" || u.Claims["email"] != "user1@example.com" {
+		t.Error("Expected the user SUB1 with its claims in the request context, but got", u)
+	}
+
+	if _, ok := UserFromContext(context.Background()); ok {
+		t.Error("Expected no user in an empty context.")
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func Test_New(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
+	jt.Claims.(jwt.MapClaims)["sub"] = "SUB1"
+	jt.Raw = idToken
+
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{Issuer: "https://issuer"}, nil)
+
+	var u *User
+	var mw func(http.Handler) http.Handler = New(c)
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ = UserFromContext(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if u == nil || u.ID != "SUB1" {
+		t.Error("Expected the user SUB1 in the request context, but got", u)
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func Test_AuthenticateOptional(t *testing.T) {
+	var ti *TokenIssuer
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	var called bool
+	var u *User
+	h := AuthenticateOptional(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		u, _ = UserFromContext(r.Context())
+	}))
+
+	valid, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	expired, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "exp": time.Now().Add(-time.Hour).Unix()}, time.Hour)
+
+	tests := []struct {
+		header string
+		called bool
+		user   bool
+	}{
+		{"", true, false},
+		{"Bearer " + valid, true, true},
+		{"Bearer " + expired, false, false},
+		{"Bearer", false, false},
+		{"Basic " + valid, false, false},
+	}
+
+	for _, test := range tests {
+		called, u = false, nil
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if called != test.called || (u != nil) != test.user {
+			t.Errorf("Expected called %v with user %v for the header %q, but got %v and %v", test.called, test.user, test.header, called, u)
+		}
+
+		if !test.called && w.Code < http.StatusBadRequest {
+			t.Errorf("Expected the request with the header %q to be rejected, but got %v", test.header, w.Code)
+		}
+	}
+}
+
+func Test_authenticateUser_SetsGroupsAndRolesOfProvider(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims = jwt.MapClaims{"iss": "https://issuer", "sub": "SUB1", "groups": []interface{}{"admins", "users"}}
+	jt.Raw = idToken
+
+	rf := func(claims map[string]interface{}) []string { return []string{"role1"} }
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{Issuer: "https://issuer", GroupsClaim: "groups", RolesFunc: rf}, nil).Once()
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{Issuer: "https://issuer"}, nil).Once()
+
+	_, u, _ := authenticateUser(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if u == nil || len(u.Groups) != 2 || u.Groups[0] != "admins" || u.Groups[1] != "users" {
+		t.Error("Expected the groups [admins users], but got", u)
+	}
+
+	if len(u.Roles) != 1 || u.Roles[0] != "role1" {
+		t.Error("Expected the roles of the RolesFunc, but got", u.Roles)
+	}
+
+	_, u, _ = authenticateUser(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if u == nil || u.Groups != nil || u.Roles != nil {
+		t.Error("Expected no groups nor roles without a groups claim and RolesFunc, but got", u)
+	}
+
 	vm.AssertExpectations(t)
 }
 
@@ -119,3 +274,105 @@ func errorHandlerHalt(e error, w http.ResponseWriter, r *http.Request) bool {
 func errorHandlerContinue(e error, w http.ResponseWriter, r *http.Request) bool {
 	return false
 }
+
+func Test_authenticate_WhenTokenCheckerReturnsError(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	jt := jwt.New(jwt.SigningMethodRS256)
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{}, nil)
+
+	ee := errors.New("Error while checking the token")
+	c.tokenCheckers = []tokenChecker{tokenCheckerFunc(func(r *http.Request, t *jwt.Token, p *Provider) error {
+		return ee
+	})}
+
+	_, rt, halt := authenticate(c, httptest.NewRecorder(), nil)
+
+	if rt != nil {
+		t.Errorf("The returned token should be nil, but was %+v.", rt)
+	}
+
+	if !halt {
+		t.Error("The authentication should have returned 'halt' true.")
+	}
+
+	vm.AssertExpectations(t)
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func Test_HTTPClient_RetrievesKeysWithClient(t *testing.T) {
+	var ti *TokenIssuer
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	tr := &countingTransport{}
+	c, err := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), HTTPClient(&http.Client{Transport: tr}))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	called := false
+	h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !called {
+		t.Error("Expected the token to be validated with the keys retrieved by the client.")
+	}
+
+	if n := atomic.LoadInt32(&tr.requests); n != 2 {
+		t.Error("Expected the configuration and the keys to be retrieved with the client, but got requests:", n)
+	}
+}
+
+func Test_validate_CancelsRetrievalWithRequest(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer s.Close()
+
+	ti, _ := NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	start := time.Now()
+	if _, _, err := c.validate(r, ts); err == nil {
+		t.Error("An error was expected but not returned")
+	}
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Error("Expected the retrieval to be canceled at the deadline of the request, but it took", d)
+	}
+}
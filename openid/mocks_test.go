@@ -6,9 +6,8 @@ import (
 	http "net/http"
 
 	mock "github.com/stretchr/testify/mock"
-	jose "gopkg.in/square/go-jose.v2"
 
-	rsa "crypto/rsa"
+	time "time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
@@ -32,22 +31,20 @@ func (_m *mockSigningKeyGetter) flushCachedSigningKeys(issuer string) error {
 	return r0
 }
 
-// getSigningKey provides a mock function with given fields: r, issuer, kid
-func (_m *mockSigningKeyGetter) getSigningKey(r *http.Request, issuer string, kid string) ([]byte, error) {
-	ret := _m.Called(r, issuer, kid)
+// getSigningKey provides a mock function with given fields: r, p, ks
+func (_m *mockSigningKeyGetter) getSigningKey(r *http.Request, p *Provider, ks keySelector) (interface{}, error) {
+	ret := _m.Called(r, p, ks)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(*http.Request, string, string) []byte); ok {
-		r0 = rf(r, issuer, kid)
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(*http.Request, *Provider, keySelector) interface{}); ok {
+		r0 = rf(r, p, ks)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
+		r0 = ret.Get(0)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*http.Request, string, string) error); ok {
-		r1 = rf(r, issuer, kid)
+	if rf, ok := ret.Get(1).(func(*http.Request, *Provider, keySelector) error); ok {
+		r1 = rf(r, p, ks)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -60,20 +57,20 @@ type mockJwksGetter struct {
 	mock.Mock
 }
 
-// getJwkSet provides a mock function with given fields: r, url
-func (_m *mockJwksGetter) get(r *http.Request, url string) (jose.JSONWebKeySet, error) {
-	ret := _m.Called(r, url)
+// get provides a mock function with given fields: r, url, cf, certificates
+func (_m *mockJwksGetter) get(r *http.Request, url string, cf CredentialsFunc, certificates bool) (jsonWebKeySet, error) {
+	ret := _m.Called(r, url, cf, certificates)
 
-	var r0 jose.JSONWebKeySet
-	if rf, ok := ret.Get(0).(func(*http.Request, string) jose.JSONWebKeySet); ok {
-		r0 = rf(r, url)
+	var r0 jsonWebKeySet
+	if rf, ok := ret.Get(0).(func(*http.Request, string, CredentialsFunc, bool) jsonWebKeySet); ok {
+		r0 = rf(r, url, cf, certificates)
 	} else {
-		r0 = ret.Get(0).(jose.JSONWebKeySet)
+		r0 = ret.Get(0).(jsonWebKeySet)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*http.Request, string) error); ok {
-		r1 = rf(r, url)
+	if rf, ok := ret.Get(1).(func(*http.Request, string, CredentialsFunc, bool) error); ok {
+		r1 = rf(r, url, cf, certificates)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -113,7 +110,7 @@ type mockJwtTokenValidator struct {
 }
 
 // validate provides a mock function with given fields: r, t
-func (_m *mockJwtTokenValidator) validate(r *http.Request, t string) (*jwt.Token, error) {
+func (_m *mockJwtTokenValidator) validate(r *http.Request, t string) (*jwt.Token, *Provider, error) {
 	ret := _m.Called(r, t)
 
 	var r0 *jwt.Token
@@ -125,14 +122,23 @@ func (_m *mockJwtTokenValidator) validate(r *http.Request, t string) (*jwt.Token
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(*http.Request, string) error); ok {
+	var r1 *Provider
+	if rf, ok := ret.Get(1).(func(*http.Request, string) *Provider); ok {
 		r1 = rf(r, t)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*Provider)
+		}
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(*http.Request, string) error); ok {
+		r2 = rf(r, t)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
 // mockJwtParser is an autogenerated mock type for the jwtParser type
@@ -222,47 +228,20 @@ type mockJwksDecoder struct {
 	mock.Mock
 }
 
-// decode provides a mock function with given fields: _a0
-func (_m *mockJwksDecoder) decode(_a0 io.Reader) (jose.JSONWebKeySet, error) {
-	ret := _m.Called(_a0)
-
-	var r0 jose.JSONWebKeySet
-	if rf, ok := ret.Get(0).(func(io.Reader) jose.JSONWebKeySet); ok {
-		r0 = rf(_a0)
-	} else {
-		r0 = ret.Get(0).(jose.JSONWebKeySet)
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(io.Reader) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-type mockPemEncoder struct {
-	mock.Mock
-}
-
-// encode provides a mock function with given fields: key
-func (_m *mockPemEncoder) encode(key interface{}) ([]byte, error) {
-	ret := _m.Called(key)
+// decode provides a mock function with given fields: _a0, _a1
+func (_m *mockJwksDecoder) decode(_a0 io.Reader, _a1 bool) (jsonWebKeySet, error) {
+	ret := _m.Called(_a0, _a1)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(interface{}) []byte); ok {
-		r0 = rf(key)
+	var r0 jsonWebKeySet
+	if rf, ok := ret.Get(0).(func(io.Reader, bool) jsonWebKeySet); ok {
+		r0 = rf(_a0, _a1)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
+		r0 = ret.Get(0).(jsonWebKeySet)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(interface{}) error); ok {
-		r1 = rf(key)
+	if rf, ok := ret.Get(1).(func(io.Reader, bool) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -298,58 +277,37 @@ func (_m *mockProvidersGetter) get() ([]Provider, error) {
 	return r0, r1
 }
 
-// mockPemToRSAPublicKeyParser is an autogenerated mock type for the pemToRSAPublicKeyParser type
-type mockPemToRSAPublicKeyParser struct {
-	mock.Mock
-}
-
-// parse provides a mock function with given fields: key
-func (_m *mockPemToRSAPublicKeyParser) parse(key []byte) (*rsa.PublicKey, error) {
-	ret := _m.Called(key)
-
-	var r0 *rsa.PublicKey
-	if rf, ok := ret.Get(0).(func([]byte) *rsa.PublicKey); ok {
-		r0 = rf(key)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*rsa.PublicKey)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func([]byte) error); ok {
-		r1 = rf(key)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
 // mockSigningKeySetGetter is an autogenerated mock type for the signingKeySetGetter type
 type mockSigningKeySetGetter struct {
 	mock.Mock
 }
 
-// get provides a mock function with given fields: r, issuer
-func (_m *mockSigningKeySetGetter) get(r *http.Request, issuer string) ([]signingKey, error) {
-	ret := _m.Called(r, issuer)
+// get provides a mock function with given fields: r, p
+func (_m *mockSigningKeySetGetter) get(r *http.Request, p *Provider) ([]signingKey, time.Duration, error) {
+	ret := _m.Called(r, p)
 
 	var r0 []signingKey
-	if rf, ok := ret.Get(0).(func(*http.Request, string) []signingKey); ok {
-		r0 = rf(r, issuer)
+	if rf, ok := ret.Get(0).(func(*http.Request, *Provider) []signingKey); ok {
+		r0 = rf(r, p)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]signingKey)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(*http.Request, string) error); ok {
-		r1 = rf(r, issuer)
+	var r1 time.Duration
+	if rf, ok := ret.Get(1).(func(*http.Request, *Provider) time.Duration); ok {
+		r1 = rf(r, p)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(time.Duration)
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(*http.Request, *Provider) error); ok {
+		r2 = rf(r, p)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
@@ -0,0 +1,45 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// grantTypeClaimNames contains the non-standard claims used by providers to
+// report the grant type through which a token was obtained, i.e.: 'gty' by Auth0.
+var grantTypeClaimNames = []string{"gty", "grant_type"}
+
+// oauth21RemovedGrantTypes contains the grant types removed by OAuth 2.1.
+var oauth21RemovedGrantTypes = []string{"password", "implicit"}
+
+// OAuth21 option enables the OAuth 2.1 compatibility mode, see
+// https://datatracker.ietf.org/doc/draft-ietf-oauth-v2-1/. Tokens obtained through the implicit
+// or the resource owner password credentials grants, both removed by OAuth 2.1, are rejected when
+// the provider reports the grant type in the token, through either the 'gty' or 'grant_type' claim.
+// Tokens that do not report their grant type are accepted.
+func OAuth21() func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, tokenCheckerFunc(checkOAuth21GrantType))
+		return nil
+	}
+}
+
+func checkOAuth21GrantType(r *http.Request, t *jwt.Token, p *Provider) error {
+	claims := t.Claims.(jwt.MapClaims)
+
+	for _, n := range grantTypeClaimNames {
+		for _, gt := range claimStrings(claims[n]) {
+			if containsString(oauth21RemovedGrantTypes, gt) {
+				return &ValidationError{
+					Code:       ValidationErrorGrantTypeNotAllowed,
+					Message:    fmt.Sprintf("Tokens obtained through the %v grant are not allowed.", gt),
+					HTTPStatus: http.StatusUnauthorized,
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestCheckOAuth21GrantType(t *testing.T) {
+	tests := []struct {
+		claims  jwt.MapClaims
+		allowed bool
+	}{
+		{jwt.MapClaims{}, true},
+		{jwt.MapClaims{"gty": "client-credentials"}, true},
+		{jwt.MapClaims{"grant_type": "authorization_code"}, true},
+		{jwt.MapClaims{"gty": "password"}, false},
+		{jwt.MapClaims{"gty": []interface{}{"refresh_token", "password"}}, false},
+		{jwt.MapClaims{"grant_type": "implicit"}, false},
+	}
+
+	for i, test := range tests {
+		e := checkOAuth21GrantType(nil, &jwt.Token{Claims: test.claims}, nil)
+
+		if test.allowed {
+			if e != nil {
+				t.Error("Test", i, "an error was returned but not expected", e)
+			}
+			continue
+		}
+
+		expectValidationError(t, e, ValidationErrorGrantTypeNotAllowed, http.StatusUnauthorized, nil)
+	}
+}
+
+func TestOAuth21_RegistersChecker(t *testing.T) {
+	c, _ := NewConfiguration(OAuth21())
+
+	if len(c.tokenCheckers) != 1 {
+		t.Error("Expected 1 token checker, but got", len(c.tokenCheckers))
+	}
+}
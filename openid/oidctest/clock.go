@@ -0,0 +1,43 @@
+package oidctest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a clock whose time only changes when set or advanced, so the tests relying on the
+// time are deterministic. Its Now method can be used wherever a func() time.Time is expected,
+// i.e.: jwt.TimeFunc. It is safe for concurrent use.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewClock returns a Clock set to the time t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+// Set sets the current time of the clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = t
+}
+
+// Advance moves the current time of the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
@@ -0,0 +1,27 @@
+package oidctest
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Clock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	if n := c.Now(); !n.Equal(start) {
+		t.Error("Expected the time", start, "but got", n)
+	}
+
+	c.Advance(time.Hour)
+
+	if n := c.Now(); !n.Equal(start.Add(time.Hour)) {
+		t.Error("Expected the time", start.Add(time.Hour), "but got", n)
+	}
+
+	c.Set(start)
+
+	if n := c.Now(); !n.Equal(start) {
+		t.Error("Expected the time", start, "but got", n)
+	}
+}
@@ -0,0 +1,20 @@
+/*Package oidctest provides an OpenID provider running on a local HTTP server and a controllable
+clock, so applications can test their use of the openid package, including its behavior when
+the provider is slow, failing or rotating its keys, without depending on a real provider.
+
+	clock := oidctest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	jwt.TimeFunc = clock.Now
+
+	p, _ := oidctest.NewProvider(clock)
+	defer p.Close()
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+	    return []openid.Provider{p.Provider("client1")}, nil
+	}))
+
+	token, _ := p.Issue(map[string]interface{}{"sub": "user1", "aud": "client1"}, time.Hour)
+
+	p.FailRequests(2)
+	clock.Advance(2 * time.Hour)
+*/
+package oidctest
@@ -0,0 +1,172 @@
+package oidctest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid"
+)
+
+// Provider is an OpenID provider serving its discovery document and signing keys from a local
+// HTTP server and issuing tokens signed with a RS256 key. Faults can be injected at any time
+// to simulate a slow or failing provider, see SetLatency, FailRequests and ServeMalformedJwks.
+// It is safe for concurrent use.
+type Provider struct {
+	clock   *Clock
+	keys    *openid.KeySet
+	server  *httptest.Server
+	handler http.Handler
+
+	mu        sync.Mutex
+	kid       string
+	latency   time.Duration
+	failures  int
+	malformed bool
+	requests  int
+}
+
+// NewProvider starts a Provider whose tokens are issued at the time of clock. The issuer is the
+// URL of the local server. Call Close to stop the server once the test completes.
+func NewProvider(clock *Clock) (*Provider, error) {
+	p := &Provider{clock: clock, keys: openid.NewKeySet()}
+	if err := p.RotateKeys(); err != nil {
+		return nil, err
+	}
+
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+
+	ti, err := openid.NewTokenIssuer(p.server.URL, p.keys)
+	if err != nil {
+		p.server.Close()
+		return nil, err
+	}
+
+	p.handler = ti.Handler()
+	return p, nil
+}
+
+// Issuer returns the issuer of the provider, the URL of its server.
+func (p *Provider) Issuer() string {
+	return p.server.URL
+}
+
+// Provider returns the openid.Provider to register with the configuration under test.
+func (p *Provider) Provider(clientIDs ...string) openid.Provider {
+	return openid.Provider{Issuer: p.Issuer(), ClientIDs: clientIDs}
+}
+
+// Close stops the server of the provider.
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+// Issue returns a token with the given claims signed with the current key of the provider. The
+// 'iss', 'iat' and 'exp' claims are set to the issuer, the time of the clock and the time ttl
+// later, unless present.
+func (p *Provider) Issue(claims map[string]interface{}, ttl time.Duration) (string, error) {
+	p.mu.Lock()
+	kid := p.kid
+	p.mu.Unlock()
+
+	k, _ := p.keys.Key(kid)
+
+	now := p.clock.Now()
+	tc := jwt.MapClaims{"iss": p.Issuer(), "iat": now.Unix(), "exp": now.Add(ttl).Unix()}
+	for n, v := range claims {
+		tc[n] = v
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, tc)
+	t.Header["kid"] = kid
+
+	return t.SignedString(k.Key)
+}
+
+// RotateKeys replaces the signing key of the provider with a new one. The tokens issued from
+// then on are signed with a key ID unknown to the validators that cached the previous keys,
+// and the tokens issued before can no longer be validated once the validators retrieve the new keys.
+func (p *Provider) RotateKeys() error {
+	k, err := openid.GenerateKey("RS256")
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys.Add(k)
+	p.keys.Remove(p.kid)
+	p.kid = k.KeyID
+
+	return nil
+}
+
+// SetLatency delays the responses of the provider by d, or until the request is canceled.
+// A latency of zero, the default, responds immediately.
+func (p *Provider) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.latency = d
+}
+
+// FailRequests responds to the next n requests with status 500/Internal Server Error. A negative n
+// fails all the requests until FailRequests is called again, a n of zero stops failing them.
+func (p *Provider) FailRequests(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failures = n
+}
+
+// ServeMalformedJwks responds to the requests for the signing keys with a body that is not a
+// JWK set while malformed is true.
+func (p *Provider) ServeMalformedJwks(malformed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.malformed = malformed
+}
+
+// Requests returns the number of requests received by the provider, including the failed ones.
+func (p *Provider) Requests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.requests
+}
+
+func (p *Provider) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests++
+	latency, malformed := p.latency, p.malformed
+	fail := p.failures != 0
+	if p.failures > 0 {
+		p.failures--
+	}
+	p.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if malformed && r.URL.Path == openid.WellKnownJwksPath {
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write([]byte(`{"keys":[{"kty":`))
+		return
+	}
+
+	p.handler.ServeHTTP(w, r)
+}
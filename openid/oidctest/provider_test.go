@@ -0,0 +1,133 @@
+package oidctest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid"
+)
+
+func newTestProvider(t *testing.T) (*Clock, *Provider, http.Handler) {
+	clock := NewClock(time.Now())
+	p, err := NewProvider(clock)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+		return []openid.Provider{p.Provider("client1")}, nil
+	}), openid.ErrorHandler(func(e error, w http.ResponseWriter, r *http.Request) bool {
+		if ve, ok := e.(*openid.ValidationError); ok {
+			w.WriteHeader(ve.HTTPStatus)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return true
+	}))
+
+	return clock, p, openid.Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func expectStatus(t *testing.T, h http.Handler, token string, status int) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != status {
+		t.Errorf("Expected the status %v, but got %v.", status, w.Code)
+	}
+}
+
+func issue(t *testing.T, p *Provider) string {
+	ts, err := p.Issue(map[string]interface{}{"sub": "user1", "aud": "client1"}, time.Hour)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	return ts
+}
+
+func Test_Provider_IssuesTokensAtClockTime(t *testing.T) {
+	clock, p, h := newTestProvider(t)
+	defer p.Close()
+
+	defer func(tf func() time.Time) { jwt.TimeFunc = tf }(jwt.TimeFunc)
+	jwt.TimeFunc = clock.Now
+
+	ts := issue(t, p)
+	expectStatus(t, h, ts, http.StatusOK)
+
+	clock.Advance(2 * time.Hour)
+	expectStatus(t, h, ts, http.StatusUnauthorized)
+}
+
+func Test_Provider_FailRequests(t *testing.T) {
+	_, p, h := newTestProvider(t)
+	defer p.Close()
+
+	ts := issue(t, p)
+
+	p.FailRequests(1)
+	expectStatus(t, h, ts, http.StatusBadGateway)
+
+	expectStatus(t, h, ts, http.StatusOK)
+
+	if n := p.Requests(); n != 3 {
+		t.Error("Expected 3 requests, the failed one, the configuration and the keys, but got", n)
+	}
+}
+
+func Test_Provider_ServeMalformedJwks(t *testing.T) {
+	_, p, h := newTestProvider(t)
+	defer p.Close()
+
+	ts := issue(t, p)
+
+	p.ServeMalformedJwks(true)
+	expectStatus(t, h, ts, http.StatusBadGateway)
+
+	p.ServeMalformedJwks(false)
+	expectStatus(t, h, ts, http.StatusOK)
+}
+
+func Test_Provider_RotateKeys(t *testing.T) {
+	_, p, h := newTestProvider(t)
+	defer p.Close()
+
+	expectStatus(t, h, issue(t, p), http.StatusOK)
+
+	if err := p.RotateKeys(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	n := p.Requests()
+	expectStatus(t, h, issue(t, p), http.StatusOK)
+
+	if p.Requests() == n {
+		t.Error("Expected the keys to be retrieved again after the rotation.")
+	}
+}
+
+func Test_Provider_SetLatency(t *testing.T) {
+	_, p, _ := newTestProvider(t)
+	defer p.Close()
+
+	p.SetLatency(50 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := http.Get(p.Issuer() + openid.WellKnownJwksPath)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	resp.Body.Close()
+
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Error("Expected the response to be delayed by 50ms, but got", d)
+	}
+}
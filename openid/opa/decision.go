@@ -0,0 +1,102 @@
+package opa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+// DecisionOption configures the PolicyHookFunc returned by Decision.
+type DecisionOption func(*decider)
+
+// HTTPClient option sets the client used to query OPA. The http.DefaultClient is used by default.
+func HTTPClient(c *http.Client) DecisionOption {
+	return func(d *decider) {
+		d.client = c
+	}
+}
+
+// Decision returns an openid.PolicyHookFunc that POSTs the policy input to the OPA Data API
+// document at url, i.e.: http://localhost:8181/v1/data/httpapi/authz/allow. The document must
+// evaluate to a boolean or to an object with a boolean member 'allow'. An undefined document denies
+// the request.
+func Decision(url string, options ...DecisionOption) openid.PolicyHookFunc {
+	d := &decider{url: url, client: http.DefaultClient}
+
+	for _, o := range options {
+		o(d)
+	}
+
+	return d.decide
+}
+
+type decider struct {
+	url    string
+	client *http.Client
+}
+
+type decisionRequest struct {
+	Input *openid.PolicyInput `json:"input"`
+}
+
+type decisionResponse struct {
+	Result interface{} `json:"result"`
+}
+
+func (d *decider) decide(r *http.Request, in *openid.PolicyInput) (bool, error) {
+	body, err := json.Marshal(&decisionRequest{in})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req = req.WithContext(r.Context())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("OPA returned status %v.", resp.StatusCode)
+	}
+
+	var dr decisionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+		return false, err
+	}
+
+	return Allowed(dr.Result)
+}
+
+// Allowed interprets the result of a policy evaluation as a decision. The result must be a
+// boolean or an object with a boolean member 'allow'. A nil result, from an undefined document,
+// is a deny.
+func Allowed(result interface{}) (bool, error) {
+	switch v := result.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case map[string]interface{}:
+		if a, ok := v["allow"].(bool); ok {
+			return a, nil
+		}
+
+		if _, ok := v["allow"]; !ok {
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("The policy result %v is not a decision.", result)
+}
@@ -0,0 +1,67 @@
+package opa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+func Test_Decision(t *testing.T) {
+	tests := []struct {
+		response string
+		status   int
+		allowed  bool
+		err      bool
+	}{
+		{`{"result": true}`, http.StatusOK, true, false},
+		{`{"result": false}`, http.StatusOK, false, false},
+		{`{"result": {"allow": true}}`, http.StatusOK, true, false},
+		{`{"result": {"allow": false}}`, http.StatusOK, false, false},
+		{`{}`, http.StatusOK, false, false},
+		{`{"result": "yes"}`, http.StatusOK, false, true},
+		{`{"result": {"allow": "yes"}}`, http.StatusOK, false, true},
+		{`not json`, http.StatusOK, false, true},
+		{`{"code": "internal_error"}`, http.StatusInternalServerError, false, true},
+	}
+
+	for _, test := range tests {
+		var input map[string]interface{}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var dr map[string]map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&dr)
+			input = dr["input"]
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.response))
+		}))
+
+		in := &openid.PolicyInput{Claims: map[string]interface{}{"sub": "user1"}, Method: http.MethodGet, Path: "/items"}
+		allowed, err := Decision(s.URL, HTTPClient(s.Client()))(httptest.NewRequest(http.MethodGet, "/items", nil), in)
+		s.Close()
+
+		if allowed != test.allowed {
+			t.Errorf("Response %v: expected allowed %v, but got %v.", test.response, test.allowed, allowed)
+		}
+
+		if (err != nil) != test.err {
+			t.Errorf("Response %v: expected error %v, but got %v.", test.response, test.err, err)
+		}
+
+		if input["method"] != http.MethodGet || input["path"] != "/items" || input["claims"].(map[string]interface{})["sub"] != "user1" {
+			t.Errorf("Response %v: unexpected policy input %v.", test.response, input)
+		}
+	}
+}
+
+func Test_Decision_WhenServerUnreachable(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+
+	allowed, err := Decision(s.URL)(httptest.NewRequest(http.MethodGet, "/", nil), &openid.PolicyInput{})
+
+	if allowed || err == nil {
+		t.Error("Expected a deny with an error, but got", allowed, err)
+	}
+}
@@ -0,0 +1,11 @@
+/*Package opa implements an openid.PolicyHookFunc deciding the requests with Open Policy Agent
+(https://www.openpolicyagent.org) through its REST Data API, so the authorization rules can be kept
+out of the service.
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+	                                openid.PolicyHook(opa.Decision("http://localhost:8181/v1/data/httpapi/authz/allow")))
+
+The openid.PolicyInput is sent as the policy input, i.e.: input.claims.sub, input.method and
+input.path. See the embedded package for evaluating Rego policies within the service instead.
+*/
+package opa
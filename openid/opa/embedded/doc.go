@@ -0,0 +1,18 @@
+/*Package embedded implements an openid.PolicyHookFunc evaluating Rego policies within the service,
+using the Open Policy Agent Go library, for services that should not depend on an OPA server.
+
+	const module = `
+	package httpapi.authz
+
+	default allow = false
+
+	allow {
+	    input.method == "GET"
+	    input.claims.groups[_] == "readers"
+	}`
+
+	h, err := embedded.Policy(ctx, "data.httpapi.authz.allow", map[string]string{"authz.rego": module})
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders), openid.PolicyHook(h))
+*/
+package embedded
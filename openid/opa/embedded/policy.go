@@ -0,0 +1,39 @@
+package embedded
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/opa"
+)
+
+// Policy compiles the Rego modules, keyed by file name, and returns an openid.PolicyHookFunc
+// evaluating the query with the openid.PolicyInput as input. The query must evaluate to a boolean
+// or to an object with a boolean member 'allow', as with opa.Decision. An error is returned when
+// the modules or the query do not compile.
+func Policy(ctx context.Context, query string, modules map[string]string) (openid.PolicyHookFunc, error) {
+	options := []func(*rego.Rego){rego.Query(query)}
+	for n, m := range modules {
+		options = append(options, rego.Module(n, m))
+	}
+
+	pq, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r *http.Request, in *openid.PolicyInput) (bool, error) {
+		rs, err := pq.Eval(r.Context(), rego.EvalInput(in))
+		if err != nil {
+			return false, err
+		}
+
+		if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+			return false, nil
+		}
+
+		return opa.Allowed(rs[0].Expressions[0].Value)
+	}, nil
+}
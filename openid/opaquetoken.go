@@ -0,0 +1,145 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OpaqueTokens option enables the validation of opaque (non-JWT) access tokens issued by the
+// provider with the given issuer. Tokens that are not JWTs are validated entirely through the
+// introspection endpoint of that provider, see https://tools.ietf.org/html/rfc7662, and the
+// members of the introspection response are used as the token claims, i.e.: to create the User
+// forwarded by AuthenticateUser. The provider must be returned by the ProvidersGetter and the
+// IntrospectionCredentials of the provider are used to authenticate with the endpoint.
+// JWTs keep being validated through their signature.
+func OpaqueTokens(issuer string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if err := validateProviderIssuer(issuer); err != nil {
+			return err
+		}
+
+		// The providers are read from the idTokenValidator at validation time, so the
+		// ProvidersGetter option can be used either before or after this option.
+		idv := c.idTokenValidator()
+		c.tokenValidator = &opaqueTokenValidator{
+			jwtValidator: idv,
+			provGetter: GetProvidersFunc(func() ([]Provider, error) {
+				return idv.provGetter.get()
+			}),
+			introspector: c.introspector,
+			issuers:      idv.issuers,
+			issuer:       issuer,
+			selector:     idv.selectProviders,
+		}
+		return nil
+	}
+}
+
+// opaqueTokenValidator validates opaque tokens through introspection and forwards
+// every other token to the jwtValidator.
+type opaqueTokenValidator struct {
+	jwtValidator jwtTokenValidator
+	provGetter   providersGetter
+	introspector tokenIntrospector
+	issuers      *issuerEquivalents
+	issuer       string
+
+	// selector returns the providers accepted for a request, see ProvidersSelector.
+	selector ProvidersSelectorFunc
+}
+
+func (v *opaqueTokenValidator) validate(r *http.Request, t string) (*jwt.Token, *Provider, error) {
+	if !isOpaqueToken(t) {
+		return v.jwtValidator.validate(r, t)
+	}
+
+	p, err := v.getProvider(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ir, err := v.introspector.introspect(r, p, t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims := jwt.MapClaims(ir)
+	if _, ok := claims[issuerClaimName]; !ok {
+		claims[issuerClaimName] = p.Issuer
+	}
+
+	jt := &jwt.Token{Raw: t, Header: map[string]interface{}{}, Claims: claims}
+
+	if _, err := validateIssuer(jt, []Provider{*p}, v.issuers); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := claims[audiencesClaimName]; ok {
+		if _, err := validateAudiences(jt, p); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if _, err := validateSubject(jt); err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateTimes(claims, p); err != nil {
+		return nil, nil, jwtErrorToOpenIDError(err)
+	}
+
+	if err := validateHostedDomain(jt, p); err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateRequiredClaims(jt, p); err != nil {
+		return nil, nil, err
+	}
+
+	jt.Valid = true
+	return jt, p, nil
+}
+
+func (v *opaqueTokenValidator) getProvider(r *http.Request) (*Provider, error) {
+	provs, err := v.provGetter.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return nil, err
+	}
+
+	if v.selector != nil {
+		if provs, err = v.selector(r, provs); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range provs {
+		if normalizeIssuer(p.Issuer) == normalizeIssuer(v.issuer) {
+			return &p, nil
+		}
+	}
+
+	return nil, &ValidationError{
+		Code:       ValidationErrorIssuerNotFound,
+		Message:    fmt.Sprintf("No provider was registered with issuer: %v", v.issuer),
+		HTTPStatus: http.StatusUnauthorized,
+	}
+}
+
+// isOpaqueToken returns true when the raw token does not have the three
+// segments of a JWT in the JWS compact serialization.
+func isOpaqueToken(t string) bool {
+	return strings.Count(t, ".") != 2
+}
+
+// isIntrospectedToken returns true when the validated token was created from an introspection
+// response rather than parsed from a JWT.
+func isIntrospectedToken(t *jwt.Token) bool {
+	return t.Method == nil
+}
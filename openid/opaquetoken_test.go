@@ -0,0 +1,214 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type tokenIntrospectorFunc func(r *http.Request, p *Provider, token string) (map[string]interface{}, error)
+
+func (f tokenIntrospectorFunc) introspect(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+	return f(r, p, token)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenTokenIsJwt(t *testing.T) {
+	jv := &mockJwtTokenValidator{}
+	v := createOpaqueTokenValidator(jv, nil)
+	jt := &jwt.Token{}
+	jv.On("validate", (*http.Request)(nil), "a.b.c").Return(jt, &Provider{}, nil)
+
+	rt, _, e := v.validate(nil, "a.b.c")
+
+	if e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	if rt != jt {
+		t.Error("Expected the token returned by the jwt validator, but got", rt)
+	}
+
+	jv.AssertExpectations(t)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenTokenIsOpaque(t *testing.T) {
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	var it string
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		it = token
+		return map[string]interface{}{"active": true, "sub": "user1", "aud": "client1", "exp": exp}, nil
+	})
+
+	jt, p, e := v.validate(nil, "opaque")
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if it != "opaque" {
+		t.Error("Expected the token 'opaque' to be introspected, but got", it)
+	}
+
+	if p == nil || p.Issuer != "https://issuer" {
+		t.Error("Expected the provider https://issuer, but got", p)
+	}
+
+	if !jt.Valid || jt.Raw != "opaque" {
+		t.Error("Expected a valid token with the raw value 'opaque', but got", jt)
+	}
+
+	u, e := newUser(jt, nil)
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if u.Issuer != "https://issuer" || u.ID != "user1" {
+		t.Error("Expected the user user1 from https://issuer, but got", u.ID, "from", u.Issuer)
+	}
+}
+
+func TestOpaqueTokenValidator_Validate_WhenIntrospectionReturnsError(t *testing.T) {
+	ie := &ValidationError{Code: ValidationErrorTokenInactive, HTTPStatus: http.StatusUnauthorized}
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return nil, ie
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	if e != ie {
+		t.Error("Expected error", ie, "but got", e)
+	}
+}
+
+func TestOpaqueTokenValidator_Validate_WhenTokenExpired(t *testing.T) {
+	exp := float64(time.Now().Add(-time.Hour).Unix())
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return map[string]interface{}{"active": true, "sub": "user1", "exp": exp}, nil
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorJwtValidationFailure, http.StatusUnauthorized, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenAudienceNotFound(t *testing.T) {
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return map[string]interface{}{"active": true, "sub": "user1", "aud": "client2"}, nil
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorAudienceNotFound, http.StatusUnauthorized, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenIssuerDoesNotMatch(t *testing.T) {
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return map[string]interface{}{"active": true, "sub": "user1", "iss": "https://other"}, nil
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorIssuerNotFound, http.StatusUnauthorized, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenSubjectMissing(t *testing.T) {
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return map[string]interface{}{"active": true}, nil
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorInvalidSubjectType, http.StatusUnauthorized, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenHostedDomainNotAllowed(t *testing.T) {
+	v := createOpaqueTokenValidator(nil, func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		return map[string]interface{}{"active": true, "sub": "user1", "hd": "other.com"}, nil
+	})
+	v.provGetter = GetProvidersFunc(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1"}, HostedDomains: []string{"example.com"}}}, nil
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorHostedDomainNotAllowed, http.StatusForbidden, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenProviderNotRegistered(t *testing.T) {
+	v := createOpaqueTokenValidator(nil, nil)
+	v.issuer = "https://other"
+
+	_, _, e := v.validate(nil, "opaque")
+
+	expectValidationError(t, e, ValidationErrorIssuerNotFound, http.StatusUnauthorized, nil)
+}
+
+func TestOpaqueTokenValidator_Validate_WhenProvidersGetterReturnsError(t *testing.T) {
+	pe := errors.New("Providers error")
+	v := createOpaqueTokenValidator(nil, nil)
+	v.provGetter = GetProvidersFunc(func() ([]Provider, error) {
+		return nil, pe
+	})
+
+	_, _, e := v.validate(nil, "opaque")
+
+	if e != pe {
+		t.Error("Expected error", pe, "but got", e)
+	}
+}
+
+func TestOpaqueTokens_UsesProvidersGetterRegisteredAfter(t *testing.T) {
+	c, e := NewConfiguration(OpaqueTokens("https://issuer"), ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1"}}}, nil
+	}))
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	v := c.tokenValidator.(*opaqueTokenValidator)
+	p, e := v.getProvider(nil)
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if p.Issuer != "https://issuer" {
+		t.Error("Expected the provider https://issuer, but got", p.Issuer)
+	}
+}
+
+func TestOpaqueTokens_WhenIssuerEmpty(t *testing.T) {
+	_, e := NewConfiguration(OpaqueTokens(""))
+
+	expectSetupError(t, e, SetupErrorInvalidIssuer)
+}
+
+func TestIntrospectionChecker_Check_SkipsIntrospectedTokens(t *testing.T) {
+	c := &introspectionChecker{tokenIntrospectorFunc(func(r *http.Request, p *Provider, token string) (map[string]interface{}, error) {
+		t.Error("The introspected token should not be introspected again")
+		return nil, nil
+	})}
+
+	e := c.check(nil, &jwt.Token{Raw: "opaque", Valid: true}, &Provider{Issuer: "https://issuer"})
+
+	if e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+}
+
+func createOpaqueTokenValidator(jv jwtTokenValidator, f tokenIntrospectorFunc) *opaqueTokenValidator {
+	return &opaqueTokenValidator{
+		jwtValidator: jv,
+		provGetter: GetProvidersFunc(func() ([]Provider, error) {
+			return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1"}}}, nil
+		}),
+		introspector: f,
+		issuers:      newIssuerEquivalents(),
+		issuer:       "https://issuer",
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Package openidctx provides access to the values stored in the request context by the
+middlewares of the openid package.
+
+The Authenticate middlewares store the raw token, the provider that issued it and the typed
+claims of the openid.TypedClaims option, the AuthenticateUser middlewares also store the
+authenticated user:
+
+	func meHandler(w http.ResponseWriter, r *http.Request) {
+		u, ok := openidctx.User(r.Context())
+		...
+	}
+
+The values are stored under unexported keys, so they cannot collide with the values
+stored by other packages.
+*/
+package openidctx
+
+import (
+	"context"
+
+	"github.com/pachapman/openid2go/openid"
+	"github.com/pachapman/openid2go/openid/internal/ctxkeys"
+)
+
+// User returns the authenticated user stored in ctx by the AuthenticateUser middlewares, as
+// openid.UserFromContext.
+func User(ctx context.Context) (*openid.User, bool) {
+	return openid.UserFromContext(ctx)
+}
+
+// RawToken returns the raw token validated by the middlewares.
+func RawToken(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(ctxkeys.RawToken).(string)
+	return t, ok
+}
+
+// Provider returns the provider that issued the token validated by the middlewares.
+func Provider(ctx context.Context) (*openid.Provider, bool) {
+	p, ok := ctx.Value(ctxkeys.Provider).(*openid.Provider)
+	return p, ok && p != nil
+}
+
+// Claims returns the typed claims of the token stored in ctx by the middlewares when the
+// configuration was created with the openid.TypedClaims option. The value is a pointer to the
+// struct type given to the option:
+//
+//	c, _ := openidctx.Claims(r.Context())
+//	claims := c.(*MyClaims)
+func Claims(ctx context.Context) (interface{}, bool) {
+	c := ctx.Value(ctxkeys.Claims)
+	return c, c != nil
+}
+
+// WithUser returns a copy of ctx carrying the user u, i.e.: to test handlers
+// that rely on the AuthenticateUser middlewares.
+func WithUser(ctx context.Context, u *openid.User) context.Context {
+	return context.WithValue(ctx, ctxkeys.User, u)
+}
+
+// WithRawToken returns a copy of ctx carrying the raw token t.
+func WithRawToken(ctx context.Context, t string) context.Context {
+	return context.WithValue(ctx, ctxkeys.RawToken, t)
+}
+
+// WithClaims returns a copy of ctx carrying the typed claims c.
+func WithClaims(ctx context.Context, c interface{}) context.Context {
+	return context.WithValue(ctx, ctxkeys.Claims, c)
+}
+
+// WithProvider returns a copy of ctx carrying the provider p.
+func WithProvider(ctx context.Context, p *openid.Provider) context.Context {
+	return context.WithValue(ctx, ctxkeys.Provider, p)
+}
@@ -0,0 +1,51 @@
+package openidctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pachapman/openid2go/openid"
+)
+
+func TestAccessors_WhenValuesPresent(t *testing.T) {
+	u := &openid.User{Issuer: "https://issuer", ID: "user1"}
+	p := &openid.Provider{Issuer: "https://issuer"}
+	c := &struct{ Sub string }{"user1"}
+	ctx := WithClaims(WithProvider(WithRawToken(WithUser(context.Background(), u), "token"), p), c)
+
+	if ru, ok := User(ctx); !ok || ru != u {
+		t.Error("Expected the user", u, "but got", ru, ok)
+	}
+
+	if rt, ok := RawToken(ctx); !ok || rt != "token" {
+		t.Error("Expected the raw token 'token', but got", rt, ok)
+	}
+
+	if rp, ok := Provider(ctx); !ok || rp != p {
+		t.Error("Expected the provider", p, "but got", rp, ok)
+	}
+
+	if rc, ok := Claims(ctx); !ok || rc != c {
+		t.Error("Expected the claims", c, "but got", rc, ok)
+	}
+}
+
+func TestAccessors_WhenValuesMissing(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &openid.User{})
+
+	if _, ok := User(ctx); ok {
+		t.Error("No user should be returned")
+	}
+
+	if _, ok := RawToken(ctx); ok {
+		t.Error("No raw token should be returned")
+	}
+
+	if _, ok := Provider(ctx); ok {
+		t.Error("No provider should be returned")
+	}
+
+	if _, ok := Claims(ctx); ok {
+		t.Error("No claims should be returned")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build go1.22
+// +build go1.22
+
+package openid
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequirePathValue returns a Policy requiring the path value of the wildcard, as matched by the
+// Go 1.22 and later http.ServeMux, to equal the claim of the user token, i.e.: to only serve the
+// resources of the user with RequirePathValue("id", "sub") on the pattern "GET /users/{id}/orders".
+// The policy must be enforced once the request is matched, by the handlers registered with a
+// ServeMux or with an http.ServeMux.
+func RequirePathValue(wildcard string, claim string) Policy {
+	return func(u *User, r *http.Request) error {
+		v := r.PathValue(wildcard)
+
+		if u != nil && v != "" {
+			if c, ok := u.Claims[claim].(string); ok && c == v {
+				return nil
+			}
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorPathValueMismatch,
+			Message:    fmt.Sprintf("The path value %v does not match the token claim '%v'.", wildcard, claim),
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build go1.22
+// +build go1.22
+
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePathValue(t *testing.T) {
+	p := RequirePathValue("id", "sub")
+	u := &User{ID: "user1", Claims: map[string]interface{}{"sub": "user1"}}
+
+	tests := []struct {
+		u     *User
+		value string
+		valid bool
+	}{
+		{u, "user1", true},
+		{u, "user2", false},
+		{u, "", false},
+		{nil, "user1", false},
+		{&User{Claims: map[string]interface{}{}}, "user1", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetPathValue("id", test.value)
+
+		err := p(test.u, r)
+		if test.valid {
+			if err != nil {
+				t.Errorf("Expected the path value %q to be allowed, but got %v", test.value, err)
+			}
+			continue
+		}
+
+		expectValidationError(t, err, ValidationErrorPathValueMismatch, http.StatusForbidden, nil)
+	}
+}
@@ -0,0 +1,80 @@
+package openid
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// PolicyInput contains the information about a request with a validated token provided
+// to the PolicyHookFunc. It is serialized as JSON by policy engines, i.e.: the opa package.
+//
+// The Claims contains the claims of the validated token and the Issuer the issuer of
+// its provider. The Method, Path and Headers are taken from the request.
+type PolicyInput struct {
+	Claims  map[string]interface{} `json:"claims"`
+	Issuer  string                 `json:"issuer"`
+	Method  string                 `json:"method"`
+	Path    string                 `json:"path"`
+	Headers map[string][]string    `json:"headers,omitempty"`
+}
+
+// PolicyHookFunc represents the function deciding whether a request with a validated token
+// is allowed. It returns false to deny the request and an error when the decision could not be made.
+type PolicyHookFunc func(r *http.Request, in *PolicyInput) (bool, error)
+
+// PolicyHook option registers the function h invoked with the claims of every validated token
+// and the request metadata. Requests denied by h are rejected with status 403/Forbidden and the
+// ones h fails to decide with status 500/Internal Server Error. See the opa package for a
+// PolicyHookFunc backed by Open Policy Agent.
+// The Authorization and Cookie headers are not provided to h.
+func PolicyHook(h PolicyHookFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &policyHookChecker{h})
+		return nil
+	}
+}
+
+type policyHookChecker struct {
+	hook PolicyHookFunc
+}
+
+func (pc *policyHookChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	allowed, err := pc.hook(r, newPolicyInput(r, t, p))
+
+	if err != nil {
+		return &ValidationError{
+			Code:       ValidationErrorPolicyFailure,
+			Message:    "Failure while evaluating the policy.",
+			Err:        err,
+			HTTPStatus: http.StatusInternalServerError,
+		}
+	}
+
+	if !allowed {
+		return &ValidationError{
+			Code:       ValidationErrorPolicyDenied,
+			Message:    "The request was denied by the policy.",
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
+
+func newPolicyInput(r *http.Request, t *jwt.Token, p *Provider) *PolicyInput {
+	in := &PolicyInput{Claims: t.Claims.(jwt.MapClaims), Method: r.Method, Path: r.URL.Path}
+
+	if p != nil {
+		in.Issuer = p.Issuer
+	}
+
+	in.Headers = make(map[string][]string, len(r.Header))
+	for k, v := range r.Header {
+		if k != "Authorization" && k != "Cookie" {
+			in.Headers[k] = v
+		}
+	}
+
+	return in
+}
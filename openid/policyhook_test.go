@@ -0,0 +1,66 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestPolicyHookChecker_Check_ProvidesInput(t *testing.T) {
+	var in *PolicyInput
+	pc := &policyHookChecker{func(r *http.Request, i *PolicyInput) (bool, error) {
+		in = i
+		return true, nil
+	}}
+
+	r := httptest.NewRequest(http.MethodDelete, "/items/1?force=true", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("X-Request-Id", "id1")
+
+	e := pc.check(r, &jwt.Token{Claims: jwt.MapClaims{"sub": "user1"}}, &Provider{Issuer: "https://issuer"})
+
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if in.Method != http.MethodDelete || in.Path != "/items/1" || in.Issuer != "https://issuer" || in.Claims["sub"] != "user1" {
+		t.Errorf("Unexpected policy input %+v", in)
+	}
+
+	if _, ok := in.Headers["Authorization"]; ok {
+		t.Error("The Authorization header should not be provided to the policy")
+	}
+
+	if _, ok := in.Headers["Cookie"]; ok {
+		t.Error("The Cookie header should not be provided to the policy")
+	}
+
+	if in.Headers["X-Request-Id"][0] != "id1" {
+		t.Error("Expected the X-Request-Id header id1, but got", in.Headers["X-Request-Id"])
+	}
+}
+
+func TestPolicyHookChecker_Check_WhenDenied(t *testing.T) {
+	pc := &policyHookChecker{func(r *http.Request, i *PolicyInput) (bool, error) {
+		return false, nil
+	}}
+
+	e := pc.check(httptest.NewRequest(http.MethodGet, "/", nil), &jwt.Token{Claims: jwt.MapClaims{}}, &Provider{})
+
+	expectValidationError(t, e, ValidationErrorPolicyDenied, http.StatusForbidden, nil)
+}
+
+func TestPolicyHookChecker_Check_WhenHookReturnsError(t *testing.T) {
+	he := errors.New("Policy error")
+	pc := &policyHookChecker{func(r *http.Request, i *PolicyInput) (bool, error) {
+		return true, he
+	}}
+
+	e := pc.check(httptest.NewRequest(http.MethodGet, "/", nil), &jwt.Token{Claims: jwt.MapClaims{}}, &Provider{})
+
+	expectValidationError(t, e, ValidationErrorPolicyFailure, http.StatusInternalServerError, he)
+}
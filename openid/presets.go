@@ -0,0 +1,241 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// googleIssuer is the issuer of the tokens issued by Google.
+const googleIssuer = "https://accounts.google.com"
+
+// The issuers of Azure AD, v2.0 and v1.0, and the tenant of the personal Microsoft accounts.
+const (
+	azureADIssuer          = "https://login.microsoftonline.com/%v/v2.0"
+	azureADV1Issuer        = "https://sts.windows.net/%v/"
+	azureADConsumersTenant = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+// GoogleProvider returns the Provider of Google Sign-In accepting the tokens issued to the given
+// client IDs, including the tokens whose 'iss' claim misses the scheme, as Google issues some:
+//
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(func() ([]openid.Provider, error) {
+//	    return []openid.Provider{openid.GoogleProvider("client1.apps.googleusercontent.com")}, nil
+//	}), openid.VerifiedEmail())
+//
+// Google recommends identifying users by their 'sub' claim, the User ID, rather than their email,
+// which must be verified before being trusted, see the VerifiedEmail option. Set the HostedDomains
+// of the provider to only accept users of certain Google Workspace domains.
+func GoogleProvider(clientIDs ...string) Provider {
+	return Provider{
+		Issuer:        googleIssuer,
+		IssuerAliases: []string{"accounts.google.com"},
+		ClientIDs:     clientIDs,
+	}
+}
+
+// AzureADProvider returns the Provider of the Azure AD (Microsoft Entra ID) tenant with the given ID,
+// a GUID, accepting the tokens issued to the given client IDs by its v2.0 endpoint and, for the
+// access tokens of APIs still using them, by its v1.0 endpoint:
+//
+//	openid.AzureADProvider("72f988bf-86f1-41af-91ab-2d7cd011db47", "client1")
+//
+// The multi-tenant endpoints are supported as tenant IDs: "common" accepts the users of any tenant
+// and personal Microsoft accounts, "organizations" the users of any tenant and "consumers" personal
+// Microsoft accounts only. The signing keys are then retrieved from the tenant of each token. Replace
+// the TenantValidator of the provider to restrict the accepted tenants. The v1.0 tokens are not
+// accepted by the multi-tenant endpoints. As the 'sub' claim differs for each application, set the
+// UserIDClaim of the provider to "oid" to identify the users by their object ID instead. The groups
+// of the user, listed in the 'groups' claim once the application is configured to add it, are the
+// Groups of the User, see GroupsResolver for the users member of too many groups.
+func AzureADProvider(tenantID string, clientIDs ...string) Provider {
+	switch strings.ToLower(tenantID) {
+	case "common":
+		return azureADMultiTenantProvider(clientIDs, func(tenant string) error {
+			return nil
+		})
+	case "organizations":
+		return azureADMultiTenantProvider(clientIDs, func(tenant string) error {
+			if strings.EqualFold(tenant, azureADConsumersTenant) {
+				return errors.New("The personal Microsoft accounts are not allowed.")
+			}
+
+			return nil
+		})
+	case "consumers":
+		return azureADMultiTenantProvider(clientIDs, func(tenant string) error {
+			if !strings.EqualFold(tenant, azureADConsumersTenant) {
+				return errors.New("Only the personal Microsoft accounts are allowed.")
+			}
+
+			return nil
+		})
+	}
+
+	return Provider{
+		Issuer:        fmt.Sprintf(azureADIssuer, tenantID),
+		IssuerAliases: []string{fmt.Sprintf(azureADV1Issuer, tenantID)},
+		ClientIDs:     clientIDs,
+		GroupsClaim:   groupsClaimName,
+	}
+}
+
+// azureADMultiTenantProvider returns the Provider of the v2.0 endpoint of the Azure AD tenants
+// allowed by tv.
+func azureADMultiTenantProvider(clientIDs []string, tv TenantValidatorFunc) Provider {
+	return Provider{
+		Issuer:          fmt.Sprintf(azureADIssuer, "{tenantid}"),
+		ClientIDs:       clientIDs,
+		GroupsClaim:     groupsClaimName,
+		TenantValidator: tv,
+	}
+}
+
+// Auth0Provider returns the Provider of the Auth0 tenant with the given domain, i.e.:
+// example.us.auth0.com, accepting the tokens issued for the given audiences, the client IDs of
+// its applications or the identifiers of its APIs. Use Auth0CustomDomainProvider when the tenant
+// has a custom domain.
+func Auth0Provider(domain string, audiences ...string) Provider {
+	return Provider{Issuer: auth0Issuer(domain), ClientIDs: audiences}
+}
+
+// Auth0CustomDomainProvider returns the Provider of the Auth0 tenant with the given custom domain,
+// i.e.: login.example.com, and canonical domain, i.e.: example.us.auth0.com. Auth0 issues the tokens
+// with the domain the user authenticated with as their issuer, both are accepted and the discovery
+// document and signing keys are retrieved from the custom domain.
+func Auth0CustomDomainProvider(customDomain string, domain string, audiences ...string) Provider {
+	p := Auth0Provider(customDomain, audiences...)
+	p.IssuerAliases = []string{auth0Issuer(domain)}
+	return p
+}
+
+// auth0Issuer returns the issuer of the tokens issued by Auth0 with the domain, which has
+// a trailing slash. The scheme is optional in the domain.
+func auth0Issuer(domain string) string {
+	return issuerURL(domain) + "/"
+}
+
+// oktaGroupsClaim is the claim Okta lists the groups of the user in, once added to the tokens
+// by the authorization server.
+const oktaGroupsClaim = "groups"
+
+// OktaProvider returns the Provider of the Okta authorization server with the given ID of the Okta
+// org with the given domain, i.e.: example.okta.com, accepting the tokens issued for the given
+// audiences. The ID of the default custom authorization server is "default", an empty ID is the
+// org authorization server, whose access tokens are only meant for the Okta APIs and cannot be
+// validated. The groups of the user, listed in the 'groups' claim once the authorization server
+// is configured to add it, are the Groups of the User forwarded by AuthenticateUser.
+func OktaProvider(domain string, authorizationServerID string, audiences ...string) Provider {
+	iss := issuerURL(domain)
+	if authorizationServerID != "" {
+		iss += "/oauth2/" + authorizationServerID
+	}
+
+	return Provider{Issuer: iss, ClientIDs: audiences, GroupsClaim: oktaGroupsClaim}
+}
+
+// KeycloakProvider returns the Provider of the Keycloak realm with the given name served at baseURL,
+// i.e.: https://keycloak.example.com, or https://keycloak.example.com/auth for the versions prior to
+// 17, accepting the tokens issued to the given client IDs. The realm and client roles of the user
+// are the Roles of the User forwarded by AuthenticateUser, see KeycloakRoles.
+func KeycloakProvider(baseURL string, realm string, clientIDs ...string) Provider {
+	return Provider{
+		Issuer:    issuerURL(baseURL) + "/realms/" + url.PathEscape(realm),
+		ClientIDs: clientIDs,
+		RolesFunc: KeycloakRoles,
+	}
+}
+
+// KeycloakRoles returns the roles of the user from the claims of a token issued by Keycloak, the
+// realm roles listed in the 'realm_access' claim followed by the client roles listed in the
+// 'resource_access' claim, prefixed by their client ID and a colon, i.e.: "app:admin".
+func KeycloakRoles(claims map[string]interface{}) []string {
+	var roles []string
+	if ra, ok := claims["realm_access"].(map[string]interface{}); ok {
+		roles = append(roles, claimStrings(ra["roles"])...)
+	}
+
+	ra, _ := claims["resource_access"].(map[string]interface{})
+	clients := make([]string, 0, len(ra))
+	for c := range ra {
+		clients = append(clients, c)
+	}
+
+	sort.Strings(clients)
+	for _, c := range clients {
+		if ca, ok := ra[c].(map[string]interface{}); ok {
+			for _, r := range claimStrings(ca["roles"]) {
+				roles = append(roles, c+":"+r)
+			}
+		}
+	}
+
+	return roles
+}
+
+// The issuer of the tokens of an AWS Cognito user pool, with its region and ID, and the claims
+// of the client ID of its access tokens and of the groups of the user.
+const (
+	cognitoIssuer        = "https://cognito-idp.%v.amazonaws.com/%v"
+	cognitoClientIDClaim = "client_id"
+	cognitoGroupsClaim   = "cognito:groups"
+)
+
+// CognitoProvider returns the Provider of the AWS Cognito user pool with the given ID in the given
+// region, i.e.: us-east-1, accepting the tokens issued to the given app client IDs. Cognito issues
+// ID tokens, with the 'aud' claim, and access tokens, without 'aud' claim but with the app client ID
+// in their 'client_id' claim, both are accepted. Set the TokenUses of the provider to []string{"access"}
+// to only accept access tokens, as APIs should. The groups of the user, listed in the 'cognito:groups'
+// claim, are the Groups of the User forwarded by AuthenticateUser.
+func CognitoProvider(region string, userPoolID string, clientIDs ...string) Provider {
+	return Provider{
+		Issuer:        fmt.Sprintf(cognitoIssuer, region, userPoolID),
+		ClientIDs:     clientIDs,
+		ClientIDClaim: cognitoClientIDClaim,
+		TokenUses:     []string{"id", "access"},
+		GroupsClaim:   cognitoGroupsClaim,
+	}
+}
+
+// The issuer of the tokens of a Firebase project, with its ID, and the URL of the X.509 certificates
+// signing them.
+const (
+	firebaseIssuer          = "https://securetoken.google.com/%v"
+	firebaseCertificatesURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+)
+
+// FirebaseProvider returns the Provider of the Firebase Authentication of the project with the given
+// ID, accepting the ID tokens issued to its users, whose audience is the project ID. Firebase publishes
+// X.509 certificates rather than a jwk set, the public keys of the certificates validate the tokens,
+// see the CertificatesURL of Provider.
+func FirebaseProvider(projectID string) Provider {
+	return Provider{
+		Issuer:          fmt.Sprintf(firebaseIssuer, projectID),
+		ClientIDs:       []string{projectID},
+		CertificatesURL: firebaseCertificatesURL,
+	}
+}
+
+// The issuer of the tokens of an Azure AD B2C tenant, with its name and ID, and the discovery
+// document of its policies.
+const (
+	azureADB2CIssuer       = "https://%v.b2clogin.com/%v/v2.0/"
+	azureADB2CDiscoveryURL = "https://%v.b2clogin.com/%v.onmicrosoft.com/{policy}/v2.0/.well-known/openid-configuration"
+)
+
+// AzureADB2CProvider returns the Provider of the Azure AD B2C tenant with the given name, i.e.:
+// contoso for contoso.b2clogin.com, and ID, accepting the tokens issued to the given client IDs by
+// the given user flows and custom policies, i.e.: B2C_1_SignIn. The discovery document and signing
+// keys of each policy are retrieved for its tokens, see the DiscoveryURL of Provider. The policies
+// issuing tokens with the 'tfp' issuer, https://contoso.b2clogin.com/tfp/{tenant ID}/{policy}/v2.0/,
+// require their issuers as IssuerAliases of the provider.
+func AzureADB2CProvider(tenantName string, tenantID string, policies []string, clientIDs ...string) Provider {
+	return Provider{
+		Issuer:       fmt.Sprintf(azureADB2CIssuer, tenantName, tenantID),
+		ClientIDs:    clientIDs,
+		DiscoveryURL: fmt.Sprintf(azureADB2CDiscoveryURL, tenantName, tenantName),
+		Policies:     policies,
+	}
+}
@@ -0,0 +1,242 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func expectPresetIssuer(t *testing.T, p Provider, iss string, expected string) {
+	jt := &jwt.Token{Claims: jwt.MapClaims{"iss": iss}}
+	found, err := validateIssuer(jt, []Provider{p}, &issuerEquivalents{map[string]string{}})
+
+	if expected == "" {
+		if err == nil {
+			t.Errorf("Expected the issuer %v to be rejected, but got %v", iss, found.Issuer)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Errorf("Expected the issuer %v to be accepted, but got %v", iss, err)
+		return
+	}
+
+	if found.Issuer != expected {
+		t.Errorf("Expected the issuer %v to be accepted as %v, but got %v", iss, expected, found.Issuer)
+	}
+}
+
+func Test_GoogleProvider(t *testing.T) {
+	p := GoogleProvider("client1", "client2")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(p.ClientIDs) != 2 || p.ClientIDs[1] != "client2" {
+		t.Error("Expected the client IDs, but got", p.ClientIDs)
+	}
+
+	expectPresetIssuer(t, p, "https://accounts.google.com", "https://accounts.google.com")
+	expectPresetIssuer(t, p, "accounts.google.com", "https://accounts.google.com")
+	expectPresetIssuer(t, p, "https://accounts.example.com", "")
+}
+
+func Test_AzureADProvider(t *testing.T) {
+	p := AzureADProvider("tenant1", "client1")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	expectPresetIssuer(t, p, "https://login.microsoftonline.com/tenant1/v2.0", "https://login.microsoftonline.com/tenant1/v2.0")
+	expectPresetIssuer(t, p, "https://sts.windows.net/tenant1/", "https://login.microsoftonline.com/tenant1/v2.0")
+	expectPresetIssuer(t, p, "https://login.microsoftonline.com/tenant2/v2.0", "")
+
+	if p.GroupsClaim != "groups" || AzureADProvider("common").GroupsClaim != "groups" {
+		t.Error("Expected the groups to be read from the 'groups' claim, but got", p.GroupsClaim)
+	}
+}
+
+func Test_AzureADProvider_MultiTenant(t *testing.T) {
+	tests := []struct {
+		tenantID string
+		tenant   string
+		accepted bool
+	}{
+		{"common", "tenant1", true},
+		{"common", azureADConsumersTenant, true},
+		{"Organizations", "tenant1", true},
+		{"organizations", azureADConsumersTenant, false},
+		{"consumers", "tenant1", false},
+		{"consumers", azureADConsumersTenant, true},
+	}
+
+	for _, test := range tests {
+		p := AzureADProvider(test.tenantID, "client1")
+
+		if err := p.Validate(); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		iss := "https://login.microsoftonline.com/" + test.tenant + "/v2.0"
+		expected := ""
+		if test.accepted {
+			expected = iss
+		}
+
+		expectPresetIssuer(t, p, iss, expected)
+		expectPresetIssuer(t, p, "https://sts.windows.net/"+test.tenant+"/", "")
+	}
+}
+
+func Test_Auth0Provider(t *testing.T) {
+	p := Auth0Provider("example.us.auth0.com", "https://api.example.com")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://example.us.auth0.com/" || p.ClientIDs[0] != "https://api.example.com" {
+		t.Errorf("Expected the issuer and audience of the tenant, but got %+v", p)
+	}
+
+	if p := Auth0Provider("https://example.us.auth0.com/", "client1"); p.Issuer != "https://example.us.auth0.com/" {
+		t.Error("Expected the issuer https://example.us.auth0.com/, but got", p.Issuer)
+	}
+}
+
+func Test_Auth0CustomDomainProvider(t *testing.T) {
+	p := Auth0CustomDomainProvider("login.example.com", "example.us.auth0.com", "client1")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	expectPresetIssuer(t, p, "https://login.example.com/", "https://login.example.com/")
+	expectPresetIssuer(t, p, "https://example.us.auth0.com/", "https://login.example.com/")
+	expectPresetIssuer(t, p, "https://other.us.auth0.com/", "")
+}
+
+func Test_OktaProvider(t *testing.T) {
+	tests := []struct {
+		serverID string
+		issuer   string
+	}{
+		{"default", "https://example.okta.com/oauth2/default"},
+		{"aus1", "https://example.okta.com/oauth2/aus1"},
+		{"", "https://example.okta.com"},
+	}
+
+	for _, test := range tests {
+		p := OktaProvider("example.okta.com", test.serverID, "api://default")
+
+		if err := p.Validate(); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if p.Issuer != test.issuer || p.GroupsClaim != "groups" {
+			t.Errorf("Expected the issuer %v with the groups claim, but got %+v", test.issuer, p)
+		}
+	}
+}
+
+func Test_KeycloakProvider(t *testing.T) {
+	p := KeycloakProvider("https://keycloak.example.com/", "my realm", "app")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://keycloak.example.com/realms/my%20realm" || p.RolesFunc == nil {
+		t.Errorf("Expected the issuer of the realm with the roles, but got %+v", p)
+	}
+}
+
+func Test_KeycloakRoles(t *testing.T) {
+	claims := map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []interface{}{"offline_access", "user"}},
+		"resource_access": map[string]interface{}{
+			"app":     map[string]interface{}{"roles": []interface{}{"admin"}},
+			"account": map[string]interface{}{"roles": []interface{}{"view-profile", "manage-account"}},
+		},
+	}
+
+	expected := []string{"offline_access", "user", "account:view-profile", "account:manage-account", "app:admin"}
+	roles := KeycloakRoles(claims)
+
+	if len(roles) != len(expected) {
+		t.Fatalf("Expected the roles %v, but got %v", expected, roles)
+	}
+
+	for i := range expected {
+		if roles[i] != expected[i] {
+			t.Errorf("Expected the roles %v, but got %v", expected, roles)
+			break
+		}
+	}
+
+	if roles := KeycloakRoles(map[string]interface{}{"sub": "user1"}); len(roles) != 0 {
+		t.Error("Expected no roles, but got", roles)
+	}
+}
+
+func Test_CognitoProvider(t *testing.T) {
+	p := CognitoProvider("eu-west-1", "eu-west-1_abc", "client1")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_abc" || p.ClientIDClaim != "client_id" ||
+		len(p.TokenUses) != 2 || p.GroupsClaim != "cognito:groups" {
+		t.Errorf("Expected the issuer of the user pool with its claims, but got %+v", p)
+	}
+}
+
+func Test_FirebaseProvider(t *testing.T) {
+	p := FirebaseProvider("my-project")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://securetoken.google.com/my-project" || len(p.ClientIDs) != 1 || p.ClientIDs[0] != "my-project" ||
+		p.CertificatesURL == "" {
+		t.Errorf("Expected the issuer and audience of the project with its certificates, but got %+v", p)
+	}
+}
+
+func Test_AzureADB2CProvider(t *testing.T) {
+	p := AzureADB2CProvider("contoso", "tenant1", []string{"B2C_1_SignIn"}, "client1")
+
+	if err := p.Validate(); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://contoso.b2clogin.com/tenant1/v2.0/" || !p.isTemplate() {
+		t.Errorf("Expected the issuer of the tenant with a policy template, but got %+v", p)
+	}
+
+	jt := createValidatedToken(p.Issuer, "client1", "kid")
+	jt.Claims.(jwt.MapClaims)["tfp"] = "B2C_1_SignIn"
+
+	pp, err := policyProvider(jt, &p)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if pp.DiscoveryURL != "https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/v2.0/.well-known/openid-configuration" ||
+		pp.keysIssuer() == p.keysIssuer() {
+		t.Errorf("Expected the discovery document and keys of the policy, but got %+v", pp)
+	}
+
+	jt.Claims.(jwt.MapClaims)["tfp"] = "B2C_1A_Other"
+	_, err = policyProvider(jt, &p)
+	expectValidationError(t, err, ValidationErrorTokenPolicyNotFound, http.StatusUnauthorized, nil)
+
+	p.Policies = nil
+	expectSetupError(t, p.Validate(), SetupErrorPoliciesNotFound)
+}
@@ -0,0 +1,130 @@
+package openid
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const rolesClaimName = "roles"
+
+// SessionClaimsFunc represents the function used to provide the claims stored with the session
+// the request r belongs to. Those are usually the claims of the token that started the session.
+// If the request is not associated with a session this function should return nil claims.
+type SessionClaimsFunc func(r *http.Request) (map[string]interface{}, error)
+
+// PrivilegeChange describes how the values of a single claim differ between the claims stored
+// with the session and the claims of a newly validated token.
+//
+// The Claim contains the name of the claim that changed.
+//
+// The Added and Removed contain the values present only in the new token and only in the
+// stored session claims respectively.
+type PrivilegeChange struct {
+	Claim   string
+	Added   []string
+	Removed []string
+}
+
+// PrivilegeChangeFunc represents the function invoked when the privileges carried by a newly
+// validated token differ from the ones stored with the session. Applications can use it to
+// invalidate cached authorization decisions or to force the user to re-authorize.
+// If this function returns an error the request is handled as if the token validation failed.
+type PrivilegeChangeFunc func(r *http.Request, changes []PrivilegeChange) error
+
+// PrivilegeChangeHook option registers the function h to be invoked whenever the values of the given
+// claims in a validated token differ from the ones returned by sc for the same request.
+// Claim values can be either a string, holding one or more values separated by spaces as in the
+// 'scope' claim, or an array of strings. When no claims are provided the 'roles' claim is compared.
+func PrivilegeChangeHook(sc SessionClaimsFunc, h PrivilegeChangeFunc, claims ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if len(claims) == 0 {
+			claims = []string{rolesClaimName}
+		}
+
+		c.tokenCheckers = append(c.tokenCheckers, &privilegeChangeChecker{sc, h, claims})
+		return nil
+	}
+}
+
+type privilegeChangeChecker struct {
+	sessionClaims SessionClaimsFunc
+	handler       PrivilegeChangeFunc
+	claims        []string
+}
+
+func (pc *privilegeChangeChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	sc, err := pc.sessionClaims(r)
+
+	if err != nil {
+		return err
+	}
+
+	if sc == nil {
+		return nil
+	}
+
+	tc := t.Claims.(jwt.MapClaims)
+	var changes []PrivilegeChange
+
+	for _, n := range pc.claims {
+		added, removed := diffClaimValues(claimStrings(sc[n]), claimStrings(tc[n]))
+		if len(added) > 0 || len(removed) > 0 {
+			changes = append(changes, PrivilegeChange{n, added, removed})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return pc.handler(r, changes)
+}
+
+// claimStrings returns the values of a claim holding either a space separated string
+// or an array of strings.
+func claimStrings(v interface{}) []string {
+	switch cv := v.(type) {
+	case string:
+		return strings.Fields(cv)
+	case []string:
+		return cv
+	case []interface{}:
+		s := make([]string, 0, len(cv))
+		for _, e := range cv {
+			if es, ok := e.(string); ok {
+				s = append(s, es)
+			}
+		}
+		return s
+	}
+
+	return nil
+}
+
+func diffClaimValues(old []string, new []string) (added []string, removed []string) {
+	om := make(map[string]bool, len(old))
+	for _, v := range old {
+		om[v] = true
+	}
+
+	nm := make(map[string]bool, len(new))
+	for _, v := range new {
+		nm[v] = true
+		if !om[v] {
+			added = append(added, v)
+		}
+	}
+
+	for _, v := range old {
+		if !nm[v] {
+			removed = append(removed, v)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,98 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func Test_privilegeChangeChecker_WhenSessionClaimsReturnsError(t *testing.T) {
+	ee := errors.New("Error getting session claims")
+	pc := createPrivilegeChangeChecker(t, nil, ee, nil)
+
+	err := pc.check(nil, jwt.New(jwt.SigningMethodRS256), nil)
+
+	if err != ee {
+		t.Error("Expected error", ee, ", but got", err)
+	}
+}
+
+func Test_privilegeChangeChecker_WhenThereIsNoSession(t *testing.T) {
+	pc := createPrivilegeChangeChecker(t, nil, nil, nil)
+
+	if err := pc.check(nil, jwt.New(jwt.SigningMethodRS256), nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
+
+func Test_privilegeChangeChecker_WhenPrivilegesDidNotChange(t *testing.T) {
+	sc := map[string]interface{}{"roles": []interface{}{"admin", "reader"}}
+	pc := createPrivilegeChangeChecker(t, sc, nil, nil)
+
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["roles"] = []interface{}{"reader", "admin"}
+
+	if err := pc.check(nil, jt, nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
+
+func Test_privilegeChangeChecker_WhenPrivilegesChanged(t *testing.T) {
+	sc := map[string]interface{}{"roles": []interface{}{"admin", "reader"}, "scope": "read write"}
+	ee := errors.New("Re-authorization required")
+	var changes []PrivilegeChange
+	pc := createPrivilegeChangeChecker(t, sc, nil, func(r *http.Request, c []PrivilegeChange) error {
+		changes = c
+		return ee
+	})
+	pc.claims = []string{"roles", "scope"}
+
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["roles"] = []interface{}{"reader", "writer"}
+	jt.Claims.(jwt.MapClaims)["scope"] = "read write"
+
+	err := pc.check(nil, jt, nil)
+
+	if err != ee {
+		t.Error("Expected error", ee, ", but got", err)
+	}
+
+	expected := []PrivilegeChange{{Claim: "roles", Added: []string{"writer"}, Removed: []string{"admin"}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected changes %+v, but got %+v", expected, changes)
+	}
+}
+
+func Test_claimStrings(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []string
+	}{
+		{nil, nil},
+		{"read write", []string{"read", "write"}},
+		{[]interface{}{"a", 1, "b"}, []string{"a", "b"}},
+		{[]string{"a"}, []string{"a"}},
+		{10, nil},
+	}
+
+	for _, tt := range tests {
+		if r := claimStrings(tt.value); !reflect.DeepEqual(r, tt.expected) {
+			t.Errorf("Expected %v for %v, but got %v", tt.expected, tt.value, r)
+		}
+	}
+}
+
+func createPrivilegeChangeChecker(t *testing.T, sc map[string]interface{}, se error, h PrivilegeChangeFunc) *privilegeChangeChecker {
+	c, _ := NewConfiguration(PrivilegeChangeHook(func(r *http.Request) (map[string]interface{}, error) {
+		return sc, se
+	}, h))
+
+	if len(c.tokenCheckers) != 1 {
+		t.Fatal("Expected one token checker, but got", len(c.tokenCheckers))
+	}
+
+	return c.tokenCheckers[0].(*privilegeChangeChecker)
+}
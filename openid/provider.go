@@ -1,19 +1,118 @@
 package openid
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
 // Provider represents an OpenId Identity Provider (OP) and contains
 // the information needed to perform validation of ID Token.
 // See OpenId terminology http://openid.net/specs/openid-connect-core-1_0.html#Terminology.
 //
 // The Issuer uniquely identifies an OP. This field will be used
 // to validate the 'iss' claim present in the ID Token.
+// Multi-tenant providers can use an issuer template with a single placeholder for the tenant,
+// i.e.: https://login.microsoftonline.com/{tenantid}/v2.0. The tenant extracted from the 'iss'
+// claim is then validated by the TenantValidator, which is required for templates, and the
+// configuration and signing keys are retrieved from the issuer of the token.
 //
 // The CliendIDs contains the list of client IDs registered with the OP that are meant to be accepted by the service using this package.
 // These values are used to validate the 'aud' clain present in the ID Token.
+//
+// The JwksCredentials is optional and provides the credentials sent to the jwks_uri of
+// providers that only publish their signing keys to authenticated callers.
+//
+// The KeyAudienceMember is optional and contains the name of a non-standard jwk member used by
+// providers that restrict signing keys to certain clients. The member lists the client IDs
+// allowed to use the key, either as a string or as an array of strings. When set, tokens are only
+// validated with keys that list the audience of the token or that do not contain the member.
+//
+// The IntrospectionCredentials is optional and provides the client credentials used to authenticate
+// with the provider's introspection endpoint, usually BasicCredentials with a client ID and secret.
+//
+// The HostedDomains is optional and, when not empty, requires the 'hd' claim of the token to match
+// one of the domains, ignoring case. Use it with Google as the provider to only accept users of
+// certain Google Workspace domains.
+//
+// The Keys is optional and, when not empty, contains the signing keys of the provider, used instead
+// of retrieving its discovery document and jwk set. Use it for providers without a discovery endpoint,
+// i.e.: in air-gapped environments, parsing their jwk set with ParseJwks or loading it from a file
+// with NewJwksFile. Changes to the keys are used once the cached keys reach the minimum lifetime of
+// JwksCaching. The KeyAudienceMember does not apply to these keys.
+//
+// The DiscoveryURL is optional and overrides the URL of the discovery document of the provider,
+// /.well-known/openid-configuration under the Issuer by default. Use it for providers publishing
+// policy specific documents, i.e.: Azure AD B2C. When no DiscoveryURL is set and the default document
+// is not found, the OAuth 2.0 authorization server metadata at /.well-known/oauth-authorization-server
+// (RFC 8414) is used instead, so plain OAuth 2.0 servers issuing JWT access tokens can be providers.
+// The DiscoveryURL can contain the {policy} placeholder, replaced by the Azure AD B2C policy of each
+// token, named by its 'tfp' claim or, for user flows, its 'acr' claim, so a single provider accepts
+// the tokens of all the policies of a tenant with their own discovery document and signing keys, see
+// AzureADB2CProvider. As the policy is read from the token before its signature is verified, the
+// Policies of these providers must list the accepted policies, case insensitive, and the tokens of
+// the other policies are rejected before retrieving any document. As the providers with issuer
+// templates, these providers are skipped by Warmup, Health and Validate.
+//
+// The IssuerAliases is optional and contains the other issuers of the tokens of the provider, i.e.:
+// its custom domains or the URL of a proxy in front of it. Tokens whose 'iss' claim is an alias are
+// validated as tokens of the provider, with the discovery document and signing keys of the Issuer.
+//
+// The GroupsClaim is optional and contains the name of the claim listing the groups of the user,
+// as an array of strings or a space separated string, used as the Groups of the User forwarded by
+// AuthenticateUser.
+//
+// The RolesFunc is optional and returns the roles of the user from the claims of the token, used
+// as the Roles of the User forwarded by AuthenticateUser, i.e.: KeycloakRoles.
+//
+// The ClientIDClaim is optional and contains the name of the claim holding the client ID of the
+// tokens issued without 'aud' claim, i.e.: the 'client_id' claim of AWS Cognito access tokens. The
+// client ID of such tokens is then validated against the ClientIDs.
+//
+// The TokenUses is optional and, when not empty, requires the 'token_use' claim of the token to
+// match one of the values, i.e.: "access" to only accept the access tokens of AWS Cognito.
+//
+// The CertificatesURL is optional and contains the URL of the signing keys of providers publishing
+// X.509 certificates rather than a jwk set, as a JSON object of key IDs to PEM encoded certificates,
+// i.e.: Firebase. The certificates are retrieved from it instead of the discovery document and jwk
+// set, with the JwksCredentials, and the public keys of the certificates validate the tokens.
+//
+// The UserIDClaim is optional and contains the name of the claim used as the ID of the User instead
+// of the 'sub' claim, i.e.: "oid" for Azure AD, whose 'sub' claim is pairwise and differs for each
+// application, or "email". The tokens without the claim are rejected once the User is created.
+//
+// The ValidationPolicy is optional and contains the leeway, signing algorithms, required claims and
+// audience mode the tokens of the provider are validated with, instead of the defaults.
 type Provider struct {
-	Issuer    string
-	ClientIDs []string
+	Issuer                   string
+	ClientIDs                []string
+	JwksCredentials          CredentialsFunc
+	KeyAudienceMember        string
+	IntrospectionCredentials CredentialsFunc
+	HostedDomains            []string
+	TenantValidator          TenantValidatorFunc
+	Keys                     []jose.JSONWebKey
+	DiscoveryURL             string
+	IssuerAliases            []string
+	GroupsClaim              string
+	RolesFunc                RolesFunc
+	ClientIDClaim            string
+	TokenUses                []string
+	CertificatesURL          string
+	ValidationPolicy         *ValidationPolicy
+	UserIDClaim              string
+	Policies                 []string
+
+	// policy is the Azure AD B2C policy of the token the provider was resolved for, see policyProvider.
+	policy string
 }
 
+// TenantValidatorFunc validates the tenant extracted from the 'iss' claim of tokens issued by
+// providers registered with an issuer template. It returns an error when the tenant is not allowed.
+type TenantValidatorFunc func(tenant string) error
+
 // The GetProvidersFunc defines the function type used to retrieve the collection of allowed OP(s) along with the
 // respective client IDs registered with those providers that can access the backend service
 // using this package.
@@ -34,7 +133,7 @@ type providers []Provider
 
 // NewProvider returns a new instance of a Provider created with the given issuer and clientIDs.
 func NewProvider(issuer string, clientIDs []string) (Provider, error) {
-	p := Provider{issuer, clientIDs}
+	p := Provider{Issuer: issuer, ClientIDs: clientIDs}
 
 	if err := p.Validate(); err != nil {
 		return Provider{}, err
@@ -52,7 +151,7 @@ func (ps providers) validate() error {
 	}
 
 	for _, p := range ps {
-		if err := p.validate(); err != nil {
+		if err := p.Validate(); err != nil {
 			return err
 		}
 	}
@@ -65,6 +164,59 @@ func (p Provider) Validate() error {
 		return err
 	}
 
+	if isIssuerTemplate(p.Issuer) {
+		if _, ok := parseIssuerTemplate(p.Issuer); !ok {
+			return &SetupError{
+				Code:    SetupErrorInvalidIssuer,
+				Message: fmt.Sprintf("The issuer template %v must contain a single placeholder.", p.Issuer),
+			}
+		}
+
+		if len(p.IssuerAliases) > 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidIssuer,
+				Message: fmt.Sprintf("The provider with the issuer template %v cannot have issuer aliases.", p.Issuer),
+			}
+		}
+
+		if p.TenantValidator == nil {
+			return &SetupError{
+				Code:    SetupErrorTenantValidatorNotFound,
+				Message: fmt.Sprintf("The provider with the issuer template %v requires a TenantValidator.", p.Issuer),
+			}
+		}
+	}
+
+	if strings.Contains(p.DiscoveryURL, policyPlaceholder) && len(p.Policies) == 0 {
+		return &SetupError{
+			Code:    SetupErrorPoliciesNotFound,
+			Message: fmt.Sprintf("The provider %v with the policy placeholder in its DiscoveryURL requires Policies.", p.Issuer),
+		}
+	}
+
+	for _, a := range p.IssuerAliases {
+		if err := validateProviderIssuer(a); err != nil {
+			return err
+		}
+
+		if isIssuerTemplate(a) {
+			return &SetupError{
+				Code:    SetupErrorInvalidIssuer,
+				Message: fmt.Sprintf("The issuer alias %v of the provider %v cannot be a template.", a, p.Issuer),
+			}
+		}
+	}
+
+	if p.ValidationPolicy != nil {
+		if err := p.ValidationPolicy.validate(p.Issuer); err != nil {
+			return err
+		}
+
+		if p.ValidationPolicy.AudienceMode == AudienceIgnored {
+			return nil
+		}
+	}
+
 	return validateProviderClientIDs(p.ClientIDs)
 }
 
@@ -90,3 +242,23 @@ func validateProviderClientIDs(cIDs []string) error {
 
 	return nil
 }
+
+// ParseJwks returns the keys of the jwk set data, i.e.: to register them as the Keys of a Provider.
+func ParseJwks(data []byte) ([]jose.JSONWebKey, error) {
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, &SetupError{
+			Code:    SetupErrorInvalidJwks,
+			Message: fmt.Sprintf("The jwk set could not be decoded: %v", err),
+		}
+	}
+
+	if len(jwks.Keys) == 0 {
+		return nil, &SetupError{
+			Code:    SetupErrorInvalidJwks,
+			Message: "The jwk set does not contain any key.",
+		}
+	}
+
+	return jwks.Keys, nil
+}
@@ -1,6 +1,12 @@
 package openid
 
-import "testing"
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
 
 func Test_validateProviders_EmptyProviderList(t *testing.T) {
 	var ps providers
@@ -15,19 +21,19 @@ func Test_validateProviders_EmptyProviderList(t *testing.T) {
 
 func Test_validateProvider_EmptyIssuer(t *testing.T) {
 	p := Provider{}
-	se := p.validate()
+	se := p.Validate()
 	expectSetupError(t, se, SetupErrorInvalidIssuer)
 }
 
 func Test_validateProvider_EmptyClientIDs(t *testing.T) {
 	p := Provider{Issuer: "https://test"}
-	se := p.validate()
+	se := p.Validate()
 	expectSetupError(t, se, SetupErrorInvalidClientIDs)
 }
 
 func Test_validateProvider_ValidProvider(t *testing.T) {
 	p := Provider{Issuer: "https://test", ClientIDs: []string{"clientID"}}
-	se := p.validate()
+	se := p.Validate()
 
 	if se != nil {
 		t.Error("An error was returned but not expected", se)
@@ -66,3 +72,51 @@ func expectSetupError(t *testing.T, e error, sec SetupErrorCode) {
 		t.Errorf("Expected error type '*SetupError' but was %T", e)
 	}
 }
+
+func Test_ParseJwks(t *testing.T) {
+	keys, err := ParseJwks([]byte(`{"keys":[{"kty":"oct","kid":"kid1","k":"c2VjcmV0"}]}`))
+
+	if err != nil || len(keys) != 1 || keys[0].KeyID != "kid1" {
+		t.Error("Expected the key kid1, but got", keys, err)
+	}
+
+	for _, data := range []string{`not json`, `{"keys":[]}`} {
+		_, err = ParseJwks([]byte(data))
+
+		expectSetupError(t, err, SetupErrorInvalidJwks)
+	}
+}
+
+func Test_Provider_Keys_ValidatesWithoutDiscovery(t *testing.T) {
+	ks := NewKeySet()
+	ti, _ := NewTokenIssuer("https://internal.issuer", ks)
+	ti.Rotate("RS256")
+
+	data, _ := json.Marshal(ks.Public())
+	keys, err := ParseJwks(data)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://internal.issuer", ClientIDs: []string{"app"}, Keys: keys}}, nil
+	}), HTTPGetter(func(r *http.Request, url string) (*http.Response, error) {
+		t.Error("The provider should not be contacted, but got a request for", url)
+		return nil, http.ErrNotSupported
+	}))
+
+	var u *User
+	h := AuthenticateUser(c, func(au *User, w http.ResponseWriter, r *http.Request) {
+		u = au
+	})
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if u == nil || u.ID != "user1" {
+		t.Error("Expected the user user1, but got", u)
+	}
+}
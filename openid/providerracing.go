@@ -0,0 +1,148 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// RaceProviders option accepts tokens without an 'iss' claim, as issued by some providers of
+// access tokens, instead of rejecting them with ValidationErrorInvalidIssuer. The signature of such
+// tokens is verified concurrently with the keys of every registered provider having a client ID that
+// matches the token audience. The first provider whose key verifies the signature is the issuer of
+// the token and the retrieval of the keys of the other providers is canceled. The token use and
+// subject of the token are validated against each candidate as for the tokens with an 'iss' claim.
+// The cached keys of the candidates are not renewed when they do not verify the signature, so the
+// keys rotated by a provider verify its tokens once the cached keys expire.
+// Providers registered with issuer templates are not candidates. Tokens with an 'iss' claim are
+// validated as usual.
+func RaceProviders() func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.idTokenValidator().raceProviders = true
+		return nil
+	}
+}
+
+func hasIssuer(jt *jwt.Token) bool {
+	iss, _ := getIssuer(jt).(string)
+	return iss != ""
+}
+
+type raceResult struct {
+	candidate int
+	key       interface{}
+	err       error
+}
+
+// raceSigningKey returns the key of the first candidate provider that verifies the signature
+// of the token jt, which has no issuer.
+func (tv *idTokenValidator) raceSigningKey(r *http.Request, jt *jwt.Token) (interface{}, *Provider, error) {
+	provs, err := tv.requestProviders(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []*Provider
+	var auds []string
+	var checkErr error
+	for i := range provs {
+		if provs[i].isTemplate() {
+			continue
+		}
+
+		if _, err := validateAudiences(jt, &provs[i]); err != nil {
+			continue
+		}
+
+		p, aud, err := tv.checkProvider(jt, &provs[i])
+		if err != nil {
+			if checkErr == nil {
+				checkErr = err
+			}
+			continue
+		}
+
+		candidates = append(candidates, p)
+		auds = append(auds, aud)
+	}
+
+	if len(candidates) == 0 && checkErr != nil {
+		return nil, nil, checkErr
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil, &ValidationError{
+			Code:       ValidationErrorAudienceNotFound,
+			Message:    "No provider has a client id matching any of the audiences of the token without issuer.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	// The losers are canceled through the context of the request, when there is one.
+	var rc *http.Request
+	if r != nil {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		rc = r.WithContext(ctx)
+	}
+
+	results := make(chan raceResult, len(candidates))
+	for i := range candidates {
+		go func(i int) {
+			key, err := tv.verifyWithProvider(rc, candidates[i], auds[i], jt)
+			results <- raceResult{i, key, err}
+		}(i)
+	}
+
+	errs := make([]error, len(candidates))
+	for range candidates {
+		res := <-results
+		if res.err == nil {
+			return res.key, candidates[res.candidate], nil
+		}
+
+		errs[res.candidate] = res.err
+	}
+
+	// All the candidates failed, the error of the first one is returned.
+	return nil, nil, errs[0]
+}
+
+// verifyWithProvider returns the signing key of the provider p when it verifies the signature
+// of the token jt. The cached keys of p are not renewed when they do not verify it, as the token
+// could be issued by any of the candidates.
+func (tv *idTokenValidator) verifyWithProvider(r *http.Request, p *Provider, aud string, jt *jwt.Token) (interface{}, error) {
+	key, err := tv.getProviderSigningKey(r, p, aud, jt)
+	if err == nil {
+		if err = verifySignature(jt, key); err == nil {
+			return key, nil
+		}
+	}
+
+	if _, ok := err.(*ValidationError); ok {
+		return nil, err
+	}
+
+	if _, ok := err.(*SetupError); ok {
+		return nil, err
+	}
+
+	return nil, &ValidationError{
+		Code:       ValidationErrorJwtValidationFailure,
+		Message:    "The signature of the token without issuer was not verified by any provider.",
+		Err:        err,
+		HTTPStatus: http.StatusUnauthorized,
+	}
+}
+
+func verifySignature(jt *jwt.Token, key interface{}) error {
+	parts := strings.Split(jt.Raw, ".")
+	if len(parts) != 3 || jt.Method == nil {
+		return jwt.ErrInvalidKey
+	}
+
+	return jt.Method.Verify(strings.Join(parts[0:2], "."), parts[2], key)
+}
@@ -0,0 +1,154 @@
+package openid
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// racingKeyGetter returns the keys of the issuers, blocking for the issuers without a key
+// until the request is canceled.
+type racingKeyGetter struct {
+	keys     map[string]interface{}
+	canceled chan string
+	flushed  []string
+}
+
+func (g *racingKeyGetter) flushCachedSigningKeys(issuer string) error {
+	g.flushed = append(g.flushed, issuer)
+	return nil
+}
+
+func (g *racingKeyGetter) getSigningKey(r *http.Request, p *Provider, ks keySelector) (interface{}, error) {
+	if k, ok := g.keys[p.Issuer]; ok {
+		return k, nil
+	}
+
+	<-r.Context().Done()
+	g.canceled <- p.Issuer
+	return nil, r.Context().Err()
+}
+
+func createRacingKey(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k, &k.PublicKey
+}
+
+func createRacingValidator(t *testing.T, kg signingKeyGetter, provs ...Provider) *idTokenValidator {
+	c := &Configuration{tokenValidator: newIDTokenValidator(func() ([]Provider, error) { return provs, nil },
+		jwtParserFunc(jwt.Parse), kg)}
+	RaceProviders()(c)
+
+	return c.idTokenValidator()
+}
+
+func signRacingToken(t *testing.T, k *rsa.PrivateKey, claims jwt.MapClaims) string {
+	ts, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ts
+}
+
+func Test_RaceProviders_ValidatesTokenWithoutIssuer(t *testing.T) {
+	k1, pk1 := createRacingKey(t)
+	k2, pk2 := createRacingKey(t)
+	kg := &racingKeyGetter{keys: map[string]interface{}{"https://p1": pk1, "https://p2": pk2, "https://p3": pk2}}
+	tv := createRacingValidator(t, kg,
+		Provider{Issuer: "https://p1", ClientIDs: []string{"client"}},
+		Provider{Issuer: "https://p2", ClientIDs: []string{"client"}},
+		Provider{Issuer: "https://p3", ClientIDs: []string{"other"}})
+
+	claims := jwt.MapClaims{"aud": "client", "sub": "user1", "exp": float64(time.Now().Add(time.Hour).Unix())}
+
+	for ts, iss := range map[string]string{signRacingToken(t, k1, claims): "https://p1", signRacingToken(t, k2, claims): "https://p2"} {
+		jt, p, err := tv.validate(httptest.NewRequest(http.MethodGet, "/", nil), ts)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if !jt.Valid || p.Issuer != iss {
+			t.Error("Expected the token to be issued by", iss, "but got", p.Issuer)
+		}
+	}
+}
+
+func Test_RaceProviders_CancelsLosers(t *testing.T) {
+	k, pk := createRacingKey(t)
+	kg := &racingKeyGetter{keys: map[string]interface{}{"https://p2": pk}, canceled: make(chan string, 1)}
+	tv := createRacingValidator(t, kg,
+		Provider{Issuer: "https://p1", ClientIDs: []string{"client"}},
+		Provider{Issuer: "https://p2", ClientIDs: []string{"client"}})
+
+	ts := signRacingToken(t, k, jwt.MapClaims{"aud": "client", "sub": "user1"})
+
+	_, p, err := tv.validate(httptest.NewRequest(http.MethodGet, "/", nil), ts)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if p.Issuer != "https://p2" {
+		t.Error("Expected the provider https://p2, but got", p.Issuer)
+	}
+
+	select {
+	case iss := <-kg.canceled:
+		if iss != "https://p1" {
+			t.Error("Expected https://p1 to be canceled, but got", iss)
+		}
+	case <-time.After(time.Second):
+		t.Error("The retrieval of the keys of https://p1 was not canceled")
+	}
+}
+
+func Test_RaceProviders_WhenNoProviderVerifiesSignature(t *testing.T) {
+	_, pk1 := createRacingKey(t)
+	k2, _ := createRacingKey(t)
+	kg := &racingKeyGetter{keys: map[string]interface{}{"https://p1": pk1}}
+	tv := createRacingValidator(t, kg, Provider{Issuer: "https://p1", ClientIDs: []string{"client"}})
+
+	_, _, err := tv.validate(httptest.NewRequest(http.MethodGet, "/", nil), signRacingToken(t, k2, jwt.MapClaims{"aud": "client", "sub": "user1"}))
+
+	expectValidationError(t, err, ValidationErrorJwtValidationFailure, http.StatusUnauthorized, nil)
+
+	if len(kg.flushed) > 0 {
+		t.Error("Expected the cached keys not to be renewed, but got", kg.flushed)
+	}
+}
+
+func Test_RaceProviders_ValidatesTokenUse(t *testing.T) {
+	k, pk := createRacingKey(t)
+	kg := &racingKeyGetter{keys: map[string]interface{}{"https://p1": pk, "https://p2": pk}}
+	tv := createRacingValidator(t, kg,
+		Provider{Issuer: "https://p1", ClientIDs: []string{"client"}, TokenUses: []string{"access"}},
+		Provider{Issuer: "https://p2", ClientIDs: []string{"other"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, _, err := tv.validate(r, signRacingToken(t, k, jwt.MapClaims{"aud": "client", "sub": "user1", "token_use": "id"}))
+	expectValidationError(t, err, ValidationErrorTokenUseNotAllowed, http.StatusUnauthorized, nil)
+
+	_, p, err := tv.validate(r, signRacingToken(t, k, jwt.MapClaims{"aud": "client", "sub": "user1", "token_use": "access"}))
+	if err != nil || p.Issuer != "https://p1" {
+		t.Error("Expected the access token to be issued by https://p1, but got", p, err)
+	}
+}
+
+func Test_RaceProviders_WhenNoProviderMatchesAudience(t *testing.T) {
+	k, pk := createRacingKey(t)
+	tv := createRacingValidator(t, &racingKeyGetter{keys: map[string]interface{}{"https://p1": pk}},
+		Provider{Issuer: "https://p1", ClientIDs: []string{"client"}})
+
+	_, _, err := tv.validate(httptest.NewRequest(http.MethodGet, "/", nil), signRacingToken(t, k, jwt.MapClaims{"aud": "other", "sub": "user1"}))
+
+	expectValidationError(t, err, ValidationErrorAudienceNotFound, http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,136 @@
+package openid
+
+import (
+	"sync"
+)
+
+// providerRegistry returns the providers of the GetProvidersFunc along with the providers
+// registered at runtime, see AddProvider. A provider registered at runtime replaces the provider
+// with the same issuer returned by the GetProvidersFunc. The issuers are compared once
+// normalized, see normalizeIssuer.
+type providerRegistry struct {
+	base providersGetter
+
+	mu    sync.RWMutex
+	provs []Provider
+	// issuers holds the normalized issuers of the provs.
+	issuers []string
+}
+
+func newProviderRegistry(base providersGetter) *providerRegistry {
+	if pg, ok := base.(GetProvidersFunc); ok && pg == nil {
+		base = nil
+	}
+
+	return &providerRegistry{base: base}
+}
+
+func (pr *providerRegistry) get() ([]Provider, error) {
+	var base []Provider
+	if pr.base != nil {
+		var err error
+		if base, err = pr.base.get(); err != nil {
+			return nil, err
+		}
+	}
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	if len(pr.provs) == 0 {
+		return base, nil
+	}
+
+	provs := make([]Provider, 0, len(base)+len(pr.provs))
+	for _, p := range base {
+		if pr.index(p.Issuer) < 0 {
+			provs = append(provs, p)
+		}
+	}
+
+	return append(provs, pr.provs...), nil
+}
+
+// add registers the provider, replacing the one registered with the same issuer.
+func (pr *providerRegistry) add(p Provider) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if i := pr.index(p.Issuer); i >= 0 {
+		pr.provs[i] = p
+		return
+	}
+
+	pr.provs = append(pr.provs, p)
+	pr.issuers = append(pr.issuers, normalizeIssuer(p.Issuer))
+}
+
+// remove unregisters the provider registered with the issuer and returns its issuer, as
+// registered, and true when there was one.
+func (pr *providerRegistry) remove(issuer string) (string, bool) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	i := pr.index(issuer)
+	if i < 0 {
+		return "", false
+	}
+
+	removed := pr.provs[i].Issuer
+	provs := make([]Provider, 0, len(pr.provs)-1)
+	provs = append(provs, pr.provs[:i]...)
+	pr.provs = append(provs, pr.provs[i+1:]...)
+
+	issuers := make([]string, 0, len(pr.issuers)-1)
+	issuers = append(issuers, pr.issuers[:i]...)
+	pr.issuers = append(issuers, pr.issuers[i+1:]...)
+	return removed, true
+}
+
+// index returns the index of the provider registered with the issuer, or -1. It must be called
+// with the lock held.
+func (pr *providerRegistry) index(issuer string) int {
+	issuer = normalizeIssuer(issuer)
+	for i := range pr.issuers {
+		if pr.issuers[i] == issuer {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// AddProvider registers the provider with the configuration while it is in use, i.e.: to onboard
+// the identity provider of a new tenant without restarting the service. The provider replaces the
+// one previously registered with the same issuer, by AddProvider or by the GetProvidersFunc, and
+// the cached signing keys of the issuer are flushed so the keys of the new provider are used. The
+// issuers are compared ignoring the case of their scheme and host and a trailing slash.
+// The provider is validated, see Provider.Validate. It is safe to call AddProvider concurrently
+// with the validation of tokens, on a configuration created by NewConfiguration.
+func (c *Configuration) AddProvider(p Provider) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	c.providerRegistry().add(p)
+	return c.idTokenValidator().keyGetter.flushCachedSigningKeys(p.Issuer)
+}
+
+// RemoveProvider unregisters the provider registered with the issuer by AddProvider, so the
+// tokens it issues, including the ones already validated and cached, are rejected. It returns
+// false when no provider was registered with the issuer. The providers returned by the
+// GetProvidersFunc cannot be removed, removing a provider that replaced one of them restores it.
+func (c *Configuration) RemoveProvider(issuer string) bool {
+	removed, ok := c.providerRegistry().remove(issuer)
+	if !ok {
+		return false
+	}
+
+	c.idTokenValidator().keyGetter.flushCachedSigningKeys(removed)
+	return true
+}
+
+// providerRegistry returns the registry of the providers installed by NewConfiguration.
+func (c *Configuration) providerRegistry() *providerRegistry {
+	return c.idTokenValidator().provGetter.(*providerRegistry)
+}
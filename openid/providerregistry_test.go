@@ -0,0 +1,141 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createRegistryIssuer(t *testing.T) (*TokenIssuer, *httptest.Server) {
+	var ti *TokenIssuer
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+	return ti, s
+}
+
+func expectAuthenticated(t *testing.T, c *Configuration, ti *TokenIssuer, aud string, expected bool) {
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": aud}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+
+	called := false
+	Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if called != expected {
+		t.Errorf("Expected the token of %v for %v to be authenticated: %v, but got %v", ti.issuer, aud, expected, called)
+	}
+}
+
+func Test_Configuration_AddProvider(t *testing.T) {
+	ti1, s1 := createRegistryIssuer(t)
+	defer s1.Close()
+	ti2, s2 := createRegistryIssuer(t)
+	defer s2.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s1.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	expectAuthenticated(t, c, ti2, "app", false)
+
+	if err := c.AddProvider(Provider{Issuer: s2.URL, ClientIDs: []string{"app"}}); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	expectAuthenticated(t, c, ti1, "app", true)
+	expectAuthenticated(t, c, ti2, "app", true)
+
+	if err := c.AddProvider(Provider{Issuer: s1.URL, ClientIDs: []string{"other"}}); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	expectAuthenticated(t, c, ti1, "app", false)
+	expectAuthenticated(t, c, ti1, "other", true)
+
+	provs, _ := c.idTokenValidator().provGetter.get()
+	if len(provs) != 2 {
+		t.Error("Expected the provider of the GetProvidersFunc to be replaced, but got", provs)
+	}
+
+	if err := c.AddProvider(Provider{Issuer: strings.ToUpper(s2.URL) + "/", ClientIDs: []string{"other"}}); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if provs, _ = c.idTokenValidator().provGetter.get(); len(provs) != 2 {
+		t.Error("Expected the provider with the same normalized issuer to be replaced, but got", provs)
+	}
+}
+
+func Test_Configuration_AddProvider_WhenInvalid(t *testing.T) {
+	c, _ := NewConfiguration()
+
+	expectSetupError(t, c.AddProvider(Provider{Issuer: "https://issuer"}), SetupErrorInvalidClientIDs)
+
+	if provs, _ := c.idTokenValidator().provGetter.get(); len(provs) != 0 {
+		t.Error("Expected the invalid provider not to be registered, but got", provs)
+	}
+}
+
+func Test_Configuration_RemoveProvider(t *testing.T) {
+	ti1, s1 := createRegistryIssuer(t)
+	defer s1.Close()
+	ti2, s2 := createRegistryIssuer(t)
+	defer s2.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s1.URL, ClientIDs: []string{"app"}}}, nil
+	}), ValidationCaching(NewMemoryValidationCache(0), time.Hour))
+	c.AddProvider(Provider{Issuer: s1.URL, ClientIDs: []string{"other"}})
+	c.AddProvider(Provider{Issuer: s2.URL, ClientIDs: []string{"app"}})
+
+	expectAuthenticated(t, c, ti2, "app", true)
+
+	if !c.RemoveProvider(s2.URL + "/") {
+		t.Error("Expected the provider to be removed.")
+	}
+
+	expectAuthenticated(t, c, ti2, "app", false)
+
+	if c.RemoveProvider(s2.URL) {
+		t.Error("Expected no provider to be removed.")
+	}
+
+	c.RemoveProvider(s1.URL)
+	expectAuthenticated(t, c, ti1, "app", true)
+
+	if c.RemoveProvider(s1.URL) {
+		t.Error("Expected the provider of the GetProvidersFunc not to be removed.")
+	}
+}
+
+func Test_Configuration_AddProvider_Concurrently(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.AddProvider(Provider{Issuer: s.URL, ClientIDs: []string{"app"}})
+		}()
+		go func() {
+			defer wg.Done()
+			c.idTokenValidator().provGetter.get()
+		}()
+	}
+
+	wg.Wait()
+	expectAuthenticated(t, c, ti, "app", true)
+}
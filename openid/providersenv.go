@@ -0,0 +1,124 @@
+package openid
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The environment variables configuring a provider, see ProvidersFromEnv.
+const (
+	envIssuer            = "OPENID_ISSUER"
+	envIssuerAliases     = "OPENID_ISSUER_ALIASES"
+	envClientIDs         = "OPENID_CLIENT_IDS"
+	envDiscoveryURL      = "OPENID_DISCOVERY_URL"
+	envHostedDomains     = "OPENID_HOSTED_DOMAINS"
+	envKeyAudienceMember = "OPENID_KEY_AUDIENCE_MEMBER"
+	envGroupsClaim       = "OPENID_GROUPS_CLAIM"
+	envClientIDClaim     = "OPENID_CLIENT_ID_CLAIM"
+	envTokenUses         = "OPENID_TOKEN_USES"
+	envUserIDClaim       = "OPENID_USER_ID_CLAIM"
+	envCertificatesURL   = "OPENID_CERTIFICATES_URL"
+	envTenants           = "OPENID_TENANTS"
+	envJwks              = "OPENID_JWKS"
+)
+
+// ProvidersFromEnv returns the providers configured by environment variables, i.e.: injected
+// in the container of the service:
+//
+//	OPENID_ISSUER=https://accounts.google.com
+//	OPENID_CLIENT_IDS=client1,client2
+//
+// The variables are the fields of the Provider: OPENID_ISSUER, OPENID_ISSUER_ALIASES,
+// OPENID_CLIENT_IDS, OPENID_DISCOVERY_URL, OPENID_HOSTED_DOMAINS, OPENID_KEY_AUDIENCE_MEMBER,
+// OPENID_GROUPS_CLAIM, OPENID_CLIENT_ID_CLAIM, OPENID_TOKEN_USES, OPENID_USER_ID_CLAIM,
+// OPENID_CERTIFICATES_URL and OPENID_JWKS, the jwk set of its Keys. OPENID_TENANTS lists the tenants allowed by the
+// TenantValidator of a provider registered with an issuer template. The lists are separated by
+// commas or spaces.
+// Additional providers are configured by the same variables suffixed by _1, _2, and so on, i.e.:
+// OPENID_ISSUER_1 and OPENID_CLIENT_IDS_1, until the issuer of a suffix is not set. The providers
+// are validated, see Provider.Validate, and a SetupError with SetupErrorEmptyProviderCollection
+// is returned when none is configured.
+func ProvidersFromEnv() ([]Provider, error) {
+	return providersFromEnv(os.LookupEnv)
+}
+
+// EnvProviders option registers the providers configured by environment variables, see
+// ProvidersFromEnv, instead of a GetProvidersFunc. The variables are read once, by the option.
+func EnvProviders() func(*Configuration) error {
+	return func(c *Configuration) error {
+		provs, err := ProvidersFromEnv()
+		if err != nil {
+			return err
+		}
+
+		c.idTokenValidator().provGetter = GetProvidersFunc(func() ([]Provider, error) {
+			return provs, nil
+		})
+		return nil
+	}
+}
+
+func providersFromEnv(lookup func(string) (string, bool)) ([]Provider, error) {
+	var provs []Provider
+	for i := 0; ; i++ {
+		suffix := ""
+		if i > 0 {
+			suffix = "_" + strconv.Itoa(i)
+		}
+
+		iss, ok := lookup(envIssuer + suffix)
+		if !ok {
+			if i == 0 {
+				continue
+			}
+
+			break
+		}
+
+		get := func(name string) string {
+			v, _ := lookup(name + suffix)
+			return v
+		}
+
+		e := providersFileEntry{
+			Issuer:            iss,
+			IssuerAliases:     envList(get(envIssuerAliases)),
+			ClientIDs:         envList(get(envClientIDs)),
+			DiscoveryURL:      get(envDiscoveryURL),
+			HostedDomains:     envList(get(envHostedDomains)),
+			KeyAudienceMember: get(envKeyAudienceMember),
+			GroupsClaim:       get(envGroupsClaim),
+			ClientIDClaim:     get(envClientIDClaim),
+			TokenUses:         envList(get(envTokenUses)),
+			UserIDClaim:       get(envUserIDClaim),
+			CertificatesURL:   get(envCertificatesURL),
+			Tenants:           envList(get(envTenants)),
+		}
+
+		if jwks := get(envJwks); jwks != "" {
+			e.Jwks = json.RawMessage(jwks)
+		}
+
+		p, err := e.provider()
+		if err != nil {
+			return nil, err
+		}
+
+		provs = append(provs, p)
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return nil, err
+	}
+
+	return provs, nil
+}
+
+// envList returns the values of a list separated by commas or spaces.
+func envList(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,103 @@
+package openid
+
+import (
+	"os"
+	"testing"
+)
+
+func envLookup(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func Test_providersFromEnv(t *testing.T) {
+	provs, err := providersFromEnv(envLookup(map[string]string{
+		"OPENID_ISSUER":              "https://accounts.google.com",
+		"OPENID_CLIENT_IDS":          "client1, client2",
+		"OPENID_ISSUER_ALIASES":      "https://alias1,https://alias2",
+		"OPENID_HOSTED_DOMAINS":      "example.com",
+		"OPENID_DISCOVERY_URL":       "https://accounts.google.com/discovery",
+		"OPENID_KEY_AUDIENCE_MEMBER": "aud",
+		"OPENID_CLIENT_ID_CLAIM":     "client_id",
+		"OPENID_TOKEN_USES":          "access",
+		"OPENID_USER_ID_CLAIM":       "oid",
+		"OPENID_CERTIFICATES_URL":    "https://certificates",
+		"OPENID_ISSUER_1":            "https://login/{tenant}/v2.0",
+		"OPENID_CLIENT_IDS_1":        "client3",
+		"OPENID_TENANTS_1":           "tenant1 tenant2",
+		"OPENID_ISSUER_2":            "https://internal",
+		"OPENID_CLIENT_IDS_2":        "client4",
+		"OPENID_JWKS_2":              `{"keys":[{"kty":"oct","kid":"kid1","k":"c2VjcmV0"}]}`,
+		"OPENID_ISSUER_4":            "https://skipped",
+		"OPENID_CLIENT_IDS_4":        "client5",
+	}))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(provs) != 3 {
+		t.Fatal("Expected 3 providers, but got", provs)
+	}
+
+	p := provs[0]
+	if p.Issuer != "https://accounts.google.com" || len(p.ClientIDs) != 2 || p.ClientIDs[1] != "client2" ||
+		len(p.HostedDomains) != 1 || p.DiscoveryURL != "https://accounts.google.com/discovery" || p.KeyAudienceMember != "aud" ||
+		len(p.IssuerAliases) != 2 || p.IssuerAliases[1] != "https://alias2" ||
+		p.ClientIDClaim != "client_id" || len(p.TokenUses) != 1 || p.TokenUses[0] != "access" ||
+		p.UserIDClaim != "oid" || p.CertificatesURL != "https://certificates" {
+		t.Errorf("Expected the provider to be configured by the variables, but got %+v", p)
+	}
+
+	if tv := provs[1].TenantValidator; tv == nil || tv("tenant2") != nil || tv("tenant3") == nil {
+		t.Error("Expected a TenantValidator allowing tenant1 and tenant2 only.")
+	}
+
+	if keys := provs[2].Keys; len(keys) != 1 || keys[0].KeyID != "kid1" {
+		t.Error("Expected the keys of the jwk set, but got", keys)
+	}
+}
+
+func Test_providersFromEnv_WhenSuffixedOnly(t *testing.T) {
+	provs, err := providersFromEnv(envLookup(map[string]string{
+		"OPENID_ISSUER_1":     "https://issuer1",
+		"OPENID_CLIENT_IDS_1": "client1",
+	}))
+	if err != nil || len(provs) != 1 || provs[0].Issuer != "https://issuer1" {
+		t.Errorf("Expected the provider https://issuer1, but got %v %v", provs, err)
+	}
+}
+
+func Test_providersFromEnv_WhenInvalid(t *testing.T) {
+	_, err := providersFromEnv(envLookup(map[string]string{}))
+	expectSetupError(t, err, SetupErrorEmptyProviderCollection)
+
+	_, err = providersFromEnv(envLookup(map[string]string{"OPENID_ISSUER": "https://issuer"}))
+	expectSetupError(t, err, SetupErrorInvalidClientIDs)
+
+	_, err = providersFromEnv(envLookup(map[string]string{"OPENID_ISSUER": "https://issuer", "OPENID_CLIENT_IDS": "client", "OPENID_JWKS": "{"}))
+	expectSetupError(t, err, SetupErrorInvalidJwks)
+}
+
+func Test_EnvProviders(t *testing.T) {
+	defer os.Unsetenv("OPENID_ISSUER")
+	defer os.Unsetenv("OPENID_CLIENT_IDS")
+	os.Setenv("OPENID_ISSUER", "https://issuer")
+	os.Setenv("OPENID_CLIENT_IDS", "client")
+
+	c, err := NewConfiguration(EnvProviders())
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	provs, _ := c.idTokenValidator().provGetter.get()
+	if len(provs) != 1 || provs[0].Issuer != "https://issuer" || provs[0].ClientIDs[0] != "client" {
+		t.Error("Expected the provider of the environment, but got", provs)
+	}
+
+	os.Unsetenv("OPENID_ISSUER")
+
+	_, err = NewConfiguration(EnvProviders())
+	expectSetupError(t, err, SetupErrorEmptyProviderCollection)
+}
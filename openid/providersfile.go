@@ -0,0 +1,232 @@
+package openid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProvidersFile holds the providers of a JSON or YAML file, reloading them when the file changes
+// so providers are added, changed and removed without restarting. Register its Providers method
+// as the GetProvidersFunc:
+//
+//	pf, err := openid.NewProvidersFile("/etc/myservice/providers.yaml", time.Minute)
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(pf.Providers))
+//
+// Files with the .yaml or .yml extension are decoded as YAML, other files as JSON:
+//
+//	providers:
+//	  - issuer: https://accounts.google.com
+//	    client_ids: [client1, client2]
+//	    hosted_domains: [example.com]
+//	  - issuer: https://login.microsoftonline.com/{tenantid}/v2.0
+//	    client_ids: [client3]
+//	    tenants: [tenant1, tenant2]
+//	  - issuer: https://internal.issuer
+//	    client_ids: [client4]
+//	    jwks: {"keys": [...]}
+//
+// The members are the fields of the Provider: issuer, issuer_aliases, client_ids, discovery_url,
+// hosted_domains, key_audience_member, groups_claim, client_id_claim, token_uses, user_id_claim,
+// certificates_url and jwks, the jwk set of its Keys. The tenants are the tenants allowed by the
+// TenantValidator of the providers registered with an issuer template. The providers requiring
+// credentials must be returned by a GetProvidersFunc of the application.
+// It is safe for concurrent use.
+type ProvidersFile struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	provs   []Provider
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// providersFileEntry is the representation of a provider in a providers file.
+type providersFileEntry struct {
+	Issuer            string          `json:"issuer"`
+	IssuerAliases     []string        `json:"issuer_aliases"`
+	ClientIDs         []string        `json:"client_ids"`
+	DiscoveryURL      string          `json:"discovery_url"`
+	HostedDomains     []string        `json:"hosted_domains"`
+	KeyAudienceMember string          `json:"key_audience_member"`
+	GroupsClaim       string          `json:"groups_claim"`
+	ClientIDClaim     string          `json:"client_id_claim"`
+	TokenUses         []string        `json:"token_uses"`
+	UserIDClaim       string          `json:"user_id_claim"`
+	CertificatesURL   string          `json:"certificates_url"`
+	Tenants           []string        `json:"tenants"`
+	Jwks              json.RawMessage `json:"jwks"`
+}
+
+// NewProvidersFile returns a ProvidersFile with the providers of the file at path, checked for
+// changes at most once every interval. The error of reading, decoding or validating the providers
+// of the file is returned.
+func NewProvidersFile(path string, interval time.Duration) (*ProvidersFile, error) {
+	pf := &ProvidersFile{path: path, interval: interval, now: time.Now}
+	if err := pf.load(); err != nil {
+		return nil, err
+	}
+
+	return pf, nil
+}
+
+// Providers returns the providers of the file, reloading them first when the interval elapsed
+// since the last check and the modification time or size of the file changed. The providers are
+// replaced all at once, the previous ones are kept when the file can no longer be read, decoded
+// or validated. The cached signing keys of a changed provider are used until they expire.
+func (pf *ProvidersFile) Providers() ([]Provider, error) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if pf.now().Sub(pf.checked) >= pf.interval {
+		pf.reload()
+	}
+
+	return pf.provs, nil
+}
+
+func (pf *ProvidersFile) load() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	fi, err := os.Stat(pf.path)
+	if err != nil {
+		return err
+	}
+
+	return pf.read(fi)
+}
+
+// reload reads the file when it changed since the last time it was read.
+func (pf *ProvidersFile) reload() {
+	pf.checked = pf.now()
+
+	fi, err := os.Stat(pf.path)
+	if err != nil || (fi.ModTime().Equal(pf.modTime) && fi.Size() == pf.size) {
+		return
+	}
+
+	pf.read(fi)
+}
+
+func (pf *ProvidersFile) read(fi os.FileInfo) error {
+	data, err := ioutil.ReadFile(pf.path)
+	if err != nil {
+		return err
+	}
+
+	provs, err := parseProvidersFile(data, isYAMLFile(pf.path))
+	if err != nil {
+		return err
+	}
+
+	pf.provs, pf.modTime, pf.size, pf.checked = provs, fi.ModTime(), fi.Size(), pf.now()
+	return nil
+}
+
+// ParseProviders returns the validated providers of the JSON document data, in the format of
+// a ProvidersFile, i.e.: to load the providers from a configuration store.
+func ParseProviders(data []byte) ([]Provider, error) {
+	return parseProvidersFile(data, false)
+}
+
+// isYAMLFile returns true when the file at path has a YAML extension.
+func isYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// parseProvidersFile returns the validated providers of the file data. YAML data is converted
+// to JSON first, so both formats share the representation of the providers.
+func parseProvidersFile(data []byte, isYAML bool) ([]Provider, error) {
+	if isYAML {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, invalidProvidersFile(err)
+		}
+
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, invalidProvidersFile(err)
+		}
+	}
+
+	var f struct {
+		Providers []providersFileEntry `json:"providers"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, invalidProvidersFile(err)
+	}
+
+	provs := make([]Provider, len(f.Providers))
+	for i, e := range f.Providers {
+		p, err := e.provider()
+		if err != nil {
+			return nil, err
+		}
+
+		provs[i] = p
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return nil, err
+	}
+
+	return provs, nil
+}
+
+func invalidProvidersFile(err error) error {
+	return &SetupError{
+		Code:    SetupErrorInvalidProvidersFile,
+		Message: fmt.Sprintf("The providers file could not be decoded: %v", err),
+	}
+}
+
+// provider returns the Provider represented by the entry.
+func (e providersFileEntry) provider() (Provider, error) {
+	p := Provider{
+		Issuer:            e.Issuer,
+		IssuerAliases:     e.IssuerAliases,
+		ClientIDs:         e.ClientIDs,
+		DiscoveryURL:      e.DiscoveryURL,
+		HostedDomains:     e.HostedDomains,
+		KeyAudienceMember: e.KeyAudienceMember,
+		GroupsClaim:       e.GroupsClaim,
+		ClientIDClaim:     e.ClientIDClaim,
+		TokenUses:         e.TokenUses,
+		UserIDClaim:       e.UserIDClaim,
+		CertificatesURL:   e.CertificatesURL,
+	}
+
+	if len(e.Jwks) > 0 && string(e.Jwks) != "null" {
+		keys, err := ParseJwks(e.Jwks)
+		if err != nil {
+			return Provider{}, err
+		}
+
+		p.Keys = keys
+	}
+
+	if len(e.Tenants) > 0 {
+		tenants := e.Tenants
+		p.TenantValidator = func(tenant string) error {
+			if containsString(tenants, tenant) {
+				return nil
+			}
+
+			return fmt.Errorf("The tenant %v is not allowed.", tenant)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,132 @@
+package openid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectFileProviders(t *testing.T, pf *ProvidersFile, issuers ...string) []Provider {
+	provs, err := pf.Providers()
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(provs) != len(issuers) {
+		t.Fatalf("Expected the providers %v, but got %v", issuers, provs)
+	}
+
+	for i, iss := range issuers {
+		if provs[i].Issuer != iss {
+			t.Errorf("Expected the provider %v, but got %v", iss, provs[i].Issuer)
+		}
+	}
+
+	return provs
+}
+
+func Test_ProvidersFile_ReloadsChangedFile(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	ioutil.WriteFile(path, []byte(`{"providers":[{"issuer":"https://issuer1","client_ids":["client1"]}]}`), 0600)
+
+	pf, err := NewProvidersFile(path, time.Minute)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	now := time.Now()
+	pf.now = func() time.Time { return now }
+
+	expectFileProviders(t, pf, "https://issuer1")
+
+	ioutil.WriteFile(path, []byte(`{"providers":[{"issuer":"https://issuer2","client_ids":["client2"]},{"issuer":"https://issuer3","client_ids":["client3"]}]}`), 0600)
+	os.Chtimes(path, now.Add(time.Second), now.Add(time.Second))
+
+	// The file is not checked again before the interval elapses.
+	expectFileProviders(t, pf, "https://issuer1")
+
+	now = now.Add(time.Minute)
+	expectFileProviders(t, pf, "https://issuer2", "https://issuer3")
+
+	// The previous providers are kept when the file becomes invalid.
+	ioutil.WriteFile(path, []byte(`{"providers":[{"issuer":"https://issuer4"}]}`), 0600)
+	os.Chtimes(path, now.Add(2*time.Second), now.Add(2*time.Second))
+
+	now = now.Add(time.Minute)
+	expectFileProviders(t, pf, "https://issuer2", "https://issuer3")
+}
+
+func Test_NewProvidersFile_DecodesYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openid")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "providers.yaml")
+	ioutil.WriteFile(path, []byte(`providers:
+  - issuer: https://accounts.google.com
+    issuer_aliases: [accounts.example.com]
+    client_ids: [client1, client2]
+    hosted_domains: [example.com]
+    discovery_url: https://accounts.google.com/discovery
+    key_audience_member: aud
+  - issuer: https://login/{tenant}/v2.0
+    client_ids: [client3]
+    tenants: [tenant1]
+  - issuer: https://internal
+    client_ids: [client4]
+    jwks: {"keys": [{"kty": "oct", "kid": "kid1", "k": "c2VjcmV0"}]}
+`), 0600)
+
+	pf, err := NewProvidersFile(path, time.Minute)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	provs := expectFileProviders(t, pf, "https://accounts.google.com", "https://login/{tenant}/v2.0", "https://internal")
+
+	p := provs[0]
+	if len(p.ClientIDs) != 2 || p.ClientIDs[1] != "client2" || len(p.HostedDomains) != 1 || p.HostedDomains[0] != "example.com" ||
+		p.DiscoveryURL != "https://accounts.google.com/discovery" || p.KeyAudienceMember != "aud" ||
+		len(p.IssuerAliases) != 1 || p.IssuerAliases[0] != "accounts.example.com" {
+		t.Errorf("Expected the members of the provider to be decoded, but got %+v", p)
+	}
+
+	if tv := provs[1].TenantValidator; tv == nil || tv("tenant1") != nil || tv("tenant2") == nil {
+		t.Error("Expected a TenantValidator allowing tenant1 only.")
+	}
+
+	if keys := provs[2].Keys; len(keys) != 1 || keys[0].KeyID != "kid1" {
+		t.Error("Expected the keys of the jwk set, but got", keys)
+	}
+}
+
+func Test_NewProvidersFile_WhenFileInvalid(t *testing.T) {
+	path, cleanup := createFileCachePath(t)
+	defer cleanup()
+
+	if _, err := NewProvidersFile(path, time.Minute); !os.IsNotExist(err) {
+		t.Error("Expected a not exist error, but got", err)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"providers":`), 0600)
+	_, err := NewProvidersFile(path, time.Minute)
+	expectSetupError(t, err, SetupErrorInvalidProvidersFile)
+
+	ioutil.WriteFile(path, []byte(`{"providers":[]}`), 0600)
+	_, err = NewProvidersFile(path, time.Minute)
+	expectSetupError(t, err, SetupErrorEmptyProviderCollection)
+
+	ioutil.WriteFile(path, []byte(`{"providers":[{"issuer":"https://login/{tenant}/v2.0","client_ids":["client"]}]}`), 0600)
+	_, err = NewProvidersFile(path, time.Minute)
+	expectSetupError(t, err, SetupErrorTenantValidatorNotFound)
+
+	ioutil.WriteFile(path, []byte(`{"providers":[{"issuer":"https://issuer","client_ids":["client"],"jwks":{"keys":[]}}]}`), 0600)
+	_, err = NewProvidersFile(path, time.Minute)
+	expectSetupError(t, err, SetupErrorInvalidJwks)
+}
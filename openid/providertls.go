@@ -0,0 +1,197 @@
+package openid
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errPinnedPublicKeyNotFound is returned by the TLS handshakes with providers whose certificates
+// do not contain any of the public keys registered with the PinnedPublicKeys option.
+var errPinnedPublicKeyNotFound = errors.New("The certificate of the provider does not match any pinned public key.")
+
+// ProviderTLSConfig option sets the TLS configuration of the calls to the providers, i.e.: to
+// present a client certificate or to restrict the cipher suites, without altering the transport
+// of the whole process. The configuration is copied. Register it before the ProviderRootCAs and
+// PinnedPublicKeys options, which complete the configuration in effect when they are applied.
+func ProviderTLSConfig(tc *tls.Config) func(*Configuration) error {
+	return func(c *Configuration) error {
+		return c.setProviderTLS(tc.Clone())
+	}
+}
+
+// ProviderRootCAs option trusts the PEM encoded CA certificates, in addition to the system ones,
+// to verify the certificates of the providers, i.e.: internal providers signed by a private CA:
+//
+//	ca, _ := ioutil.ReadFile("/etc/myservice/internal-ca.pem")
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	                                openid.ProviderRootCAs(ca))
+func ProviderRootCAs(pemCerts []byte) func(*Configuration) error {
+	return func(c *Configuration) error {
+		tc, err := c.providerTLSConfig()
+		if err != nil {
+			return err
+		}
+
+		if tc.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+
+			tc.RootCAs = pool
+		}
+
+		if !tc.RootCAs.AppendCertsFromPEM(pemCerts) {
+			return &SetupError{
+				Code:    SetupErrorInvalidCertificates,
+				Message: "The root CAs do not contain any PEM encoded certificate.",
+			}
+		}
+
+		return nil
+	}
+}
+
+// PinnedPublicKeys option only accepts the providers presenting a certificate chain containing
+// one of the given public keys, in addition to the regular verification of the certificates.
+// The pins are the base64 encoded SHA-256 digests of the DER encoded SubjectPublicKeyInfo of the
+// keys, i.e.: the output of
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+//
+// Pin the keys of the intermediate CAs, or a backup key, so the providers can renew their certificates.
+func PinnedPublicKeys(pins ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if len(pins) == 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidCertificates,
+				Message: "At least one public key pin must be provided.",
+			}
+		}
+
+		digests := make(map[string]bool, len(pins))
+		for _, p := range pins {
+			d, err := base64.StdEncoding.DecodeString(p)
+			if err != nil || len(d) != sha256.Size {
+				return &SetupError{
+					Code:    SetupErrorInvalidCertificates,
+					Message: fmt.Sprintf("The public key pin %v is not a base64 encoded SHA-256 digest.", p),
+				}
+			}
+
+			digests[string(d)] = true
+		}
+
+		tc, err := c.providerTLSConfig()
+		if err != nil {
+			return err
+		}
+
+		tc.VerifyPeerCertificate = verifyPinnedPublicKeys(digests)
+		return nil
+	}
+}
+
+// verifyPinnedPublicKeys returns the function verifying that the certificates presented by a
+// provider contain one of the public keys with the given digests. The verified chains are checked
+// when available, the raw certificates otherwise, i.e.: with InsecureSkipVerify.
+func verifyPinnedPublicKeys(digests map[string]bool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		var certs []*x509.Certificate
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+
+		if len(verifiedChains) == 0 {
+			for _, raw := range rawCerts {
+				if cert, err := x509.ParseCertificate(raw); err == nil {
+					certs = append(certs, cert)
+				}
+			}
+		}
+
+		for _, cert := range certs {
+			d := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if digests[string(d[:])] {
+				return nil
+			}
+		}
+
+		return errPinnedPublicKeyNotFound
+	}
+}
+
+// providerTLSConfig returns the TLS configuration of the calls to the providers, creating it
+// along with the client using it when none was set. The created configuration is a copy of the
+// one of the transport of the HTTPClient, if any.
+func (c *Configuration) providerTLSConfig() (*tls.Config, error) {
+	if c.providerTLS != nil {
+		return c.providerTLS, nil
+	}
+
+	tc := &tls.Config{}
+	if c.httpClient != nil {
+		if t, ok := c.httpClient.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+			tc = t.TLSClientConfig.Clone()
+		}
+	}
+
+	if err := c.setProviderTLS(tc); err != nil {
+		return nil, err
+	}
+
+	return tc, nil
+}
+
+// setProviderTLS sets the TLS configuration of the calls to the providers and the client using it,
+// a copy of the HTTPClient with a copy of its transport when it was set, so the client of the
+// application is left unchanged.
+func (c *Configuration) setProviderTLS(tc *tls.Config) error {
+	hc := &http.Client{Transport: newProviderTransport(tc)}
+	if c.httpClient != nil {
+		cc := *c.httpClient
+		switch t := cc.Transport.(type) {
+		case nil:
+			cc.Transport = newProviderTransport(tc)
+		case *http.Transport:
+			ct := t.Clone()
+			ct.TLSClientConfig = tc
+			cc.Transport = ct
+		default:
+			return &SetupError{
+				Code:    SetupErrorUnsupportedTransport,
+				Message: fmt.Sprintf("The TLS configuration cannot be applied to the transport %T of the HTTPClient, configure its TLS instead.", t),
+			}
+		}
+
+		hc = &cc
+	}
+
+	c.providerTLS = tc
+	c.setHTTPGetter(defaultHTTPGetter{client: hc})
+	return nil
+}
+
+// newProviderTransport returns a transport with the settings of the http.DefaultTransport
+// and the given TLS configuration.
+func newProviderTransport(tc *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tc,
+	}
+}
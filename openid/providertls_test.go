@@ -0,0 +1,137 @@
+package openid
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTLSTestIssuer returns a token issuer served over TLS with a certificate of an unknown CA.
+func newTLSTestIssuer() (*httptest.Server, *TokenIssuer) {
+	var ti *TokenIssuer
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.Handler().ServeHTTP(w, r)
+	}))
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+	return s, ti
+}
+
+func expectTLSValidation(t *testing.T, s *httptest.Server, ti *TokenIssuer, valid bool, options ...option) {
+	options = append([]option{ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	})}, options...)
+
+	c, err := NewConfiguration(options...)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	_, _, err = c.validate(nil, ts)
+
+	if valid && err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if !valid && err == nil {
+		t.Error("An error was expected but not returned")
+	}
+}
+
+func pemCertificate(s *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+func publicKeyPin(s *httptest.Server) string {
+	d := sha256.Sum256(s.Certificate().RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(d[:])
+}
+
+func Test_ProviderRootCAs(t *testing.T) {
+	s, ti := newTLSTestIssuer()
+	defer s.Close()
+
+	expectTLSValidation(t, s, ti, false)
+	expectTLSValidation(t, s, ti, true, ProviderRootCAs(pemCertificate(s)))
+}
+
+func Test_ProviderRootCAs_WhenInvalid(t *testing.T) {
+	_, err := NewConfiguration(ProviderRootCAs([]byte("not a certificate")))
+
+	expectSetupError(t, err, SetupErrorInvalidCertificates)
+}
+
+func Test_ProviderTLSConfig(t *testing.T) {
+	s, ti := newTLSTestIssuer()
+	defer s.Close()
+
+	expectTLSValidation(t, s, ti, true, ProviderTLSConfig(s.Client().Transport.(*http.Transport).TLSClientConfig))
+}
+
+func Test_PinnedPublicKeys(t *testing.T) {
+	s, ti := newTLSTestIssuer()
+	defer s.Close()
+
+	other := sha256.Sum256([]byte("other key"))
+	otherPin := base64.StdEncoding.EncodeToString(other[:])
+
+	expectTLSValidation(t, s, ti, true, ProviderRootCAs(pemCertificate(s)), PinnedPublicKeys(otherPin, publicKeyPin(s)))
+	expectTLSValidation(t, s, ti, false, ProviderRootCAs(pemCertificate(s)), PinnedPublicKeys(otherPin))
+	expectTLSValidation(t, s, ti, true, ProviderTLSConfig(&tls.Config{InsecureSkipVerify: true}), PinnedPublicKeys(publicKeyPin(s)))
+	expectTLSValidation(t, s, ti, false, ProviderTLSConfig(&tls.Config{InsecureSkipVerify: true}), PinnedPublicKeys(otherPin))
+}
+
+func Test_PinnedPublicKeys_WhenInvalid(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"not base64!"},
+		{base64.StdEncoding.EncodeToString([]byte("too short"))},
+	}
+
+	for _, pins := range tests {
+		_, err := NewConfiguration(PinnedPublicKeys(pins...))
+
+		expectSetupError(t, err, SetupErrorInvalidCertificates)
+	}
+}
+
+type wrappingTransport struct {
+	http.RoundTripper
+}
+
+func Test_ProviderRootCAs_AfterHTTPClient(t *testing.T) {
+	s, ti := newTLSTestIssuer()
+	defer s.Close()
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{ServerName: "example.com"}}
+	hc := &http.Client{Transport: transport, Timeout: time.Minute}
+
+	c, err := NewConfiguration(HTTPClient(hc), ProviderRootCAs(pemCertificate(s)))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	g := c.introspector.getter.(defaultHTTPGetter)
+	ct, _ := g.client.Transport.(*http.Transport)
+	if g.client == hc || g.client.Timeout != time.Minute || ct == nil || ct == transport ||
+		ct.TLSClientConfig.ServerName != "example.com" || ct.TLSClientConfig.RootCAs == nil {
+		t.Errorf("Expected a copy of the client with the root CAs, but got %+v", g.client)
+	}
+
+	if hc.Transport != transport || transport.TLSClientConfig.RootCAs != nil {
+		t.Error("Expected the client of the application to be left unchanged.")
+	}
+
+	expectTLSValidation(t, s, ti, true, HTTPClient(&http.Client{}), ProviderRootCAs(pemCertificate(s)))
+	expectTLSValidation(t, s, ti, false, ProviderRootCAs(pemCertificate(s)), HTTPClient(&http.Client{}))
+
+	_, err = NewConfiguration(HTTPClient(&http.Client{Transport: wrappingTransport{http.DefaultTransport}}), PinnedPublicKeys(publicKeyPin(s)))
+	expectSetupError(t, err, SetupErrorUnsupportedTransport)
+}
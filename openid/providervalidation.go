@@ -0,0 +1,117 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ProviderValidationError contains the errors returned by Validate keyed by the issuer of the
+// provider they occurred for.
+type ProviderValidationError map[string]error
+
+// Error returns a formatted string containing the errors of all the providers.
+func (pe ProviderValidationError) Error() string {
+	issuers := make([]string, 0, len(pe))
+	for iss := range pe {
+		issuers = append(issuers, iss)
+	}
+
+	sort.Strings(issuers)
+
+	msgs := make([]string, len(issuers))
+	for i, iss := range issuers {
+		msgs[i] = fmt.Sprintf("%v: %v", iss, pe[iss])
+	}
+
+	return "Provider validation error. " + strings.Join(msgs, "; ")
+}
+
+// Validate retrieves the discovery document of every provider returned by the GetProvidersFunc,
+// checks that its issuer is the Issuer, or one of the IssuerAliases, of the provider and that the
+// signing keys of the provider can be retrieved and parsed, so the application fails when it
+// starts rather than on the first request. Unlike Warmup, the signing keys are not cached.
+// The error returned by the GetProvidersFunc or the validation of the providers is returned as is,
+// the errors of the individual providers are returned in a ProviderValidationError, a SetupError
+// with SetupErrorIssuerMismatch when the issuers differ. Providers registered with issuer templates
+// are skipped, their configuration depends on the tenant of each token.
+// The HTTPGetFunc and JwksCredentials receive a request carrying ctx, which bounds the validation.
+func (c *Configuration) Validate(ctx context.Context) error {
+	tv := c.idTokenValidator()
+	if tv.provGetter == nil {
+		return nil
+	}
+
+	provs, err := tv.provGetter.get()
+	if err != nil {
+		return err
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return err
+	}
+
+	sksp, _ := tv.keyGetter.(*signingKeyProvider).keySetGetter.(*signingKeySetProvider)
+	if sksp == nil {
+		return nil
+	}
+
+	r := newBackgroundRequest(ctx)
+	pe := ProviderValidationError{}
+
+	for i := range provs {
+		if provs[i].isTemplate() {
+			continue
+		}
+
+		if err := sksp.validate(r, &provs[i]); err != nil {
+			pe[provs[i].Issuer] = err
+		}
+	}
+
+	if len(pe) > 0 {
+		return pe
+	}
+
+	return nil
+}
+
+// validate checks the issuer of the discovery document of the provider, unless it has Keys or a
+// CertificatesURL, and retrieves its signing keys.
+func (signProv *signingKeySetProvider) validate(r *http.Request, p *Provider) error {
+	if len(p.Keys) == 0 && p.CertificatesURL == "" {
+		conf, err := getProviderConfiguration(signProv.configGetter, r, p)
+		if err != nil {
+			return err
+		}
+
+		if !issuerOf(p, conf.Issuer) {
+			return &SetupError{
+				Code:    SetupErrorIssuerMismatch,
+				Message: fmt.Sprintf("The discovery document of the provider %v has the issuer '%v'.", p.Issuer, conf.Issuer),
+			}
+		}
+	}
+
+	_, _, err := signProv.get(r, p)
+	return err
+}
+
+// issuerOf returns true when iss is the issuer of the provider p or one of its aliases, once
+// normalized as the issuers of the tokens.
+func issuerOf(p *Provider, iss string) bool {
+	iss = normalizeIssuer(iss)
+	if iss == normalizeIssuer(p.Issuer) {
+		return true
+	}
+
+	for _, a := range p.IssuerAliases {
+		if iss == normalizeIssuer(a) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package openid
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Configuration_Validate(t *testing.T) {
+	_, s, _ := createHealthConfiguration(t)
+	defer s.Close()
+
+	provs := []Provider{
+		{Issuer: s.URL, ClientIDs: []string{"app"}},
+		{Issuer: "https://other", ClientIDs: []string{"app"}, DiscoveryURL: s.URL + wellKnownOpenIDConfiguration},
+		{Issuer: "https://alias", IssuerAliases: []string{s.URL}, ClientIDs: []string{"app"}, DiscoveryURL: s.URL + wellKnownOpenIDConfiguration},
+		{Issuer: s.URL + "/", ClientIDs: []string{"app"}, DiscoveryURL: s.URL + wellKnownOpenIDConfiguration},
+		{Issuer: "https://alias2", IssuerAliases: []string{s.URL + "/"}, ClientIDs: []string{"app"}, DiscoveryURL: s.URL + wellKnownOpenIDConfiguration},
+		{Issuer: "http://127.0.0.1:1", ClientIDs: []string{"app"}},
+		{Issuer: "https://login/{tenant}/v2.0", ClientIDs: []string{"app"}, TenantValidator: func(string) error { return nil }},
+	}
+
+	c, err := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return provs, nil
+	}))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	err = c.Validate(context.Background())
+
+	pe, ok := err.(ProviderValidationError)
+	if !ok || len(pe) != 2 {
+		t.Fatal("Expected the errors of 2 providers, but got", err)
+	}
+
+	if pe["http://127.0.0.1:1"] == nil {
+		t.Error("Expected the error of the unreachable provider, but got", pe)
+	}
+
+	expectSetupError(t, pe["https://other"], SetupErrorIssuerMismatch)
+
+	if kh := c.idTokenValidator().keyGetter.(*signingKeyProvider).keysHealth(s.URL); kh.Cached {
+		t.Errorf("Expected the signing keys not to be cached, but got %+v", kh)
+	}
+}
+
+func Test_Configuration_Validate_WhenProvidersValid(t *testing.T) {
+	_, s, c := createHealthConfiguration(t)
+	defer s.Close()
+
+	if err := c.Validate(context.Background()); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
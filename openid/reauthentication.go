@@ -0,0 +1,166 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// silentAuthenticationErrors are the errors returned by the providers to the redirect URI when an
+// authentication request with prompt=none cannot complete without the user, see
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthError.
+var silentAuthenticationErrors = []string{"login_required", "interaction_required", "consent_required", "account_selection_required"}
+
+// Reauthenticator builds the authorization requests that re-authenticate the users of server
+// rendered applications whose session, the token found by the GetIDTokenFunc, is absent or expired.
+// It first attempts a silent re-authentication with prompt=none and, once the provider answers
+// the redirect URI with an error requiring the user, an interactive login:
+//
+//	ra := openid.NewReauthenticator(conf, "https://accounts.google.com", clientID, "https://app/callback", "email")
+//
+//	func pageHandler(w http.ResponseWriter, r *http.Request) {
+//	    if ra.SessionExpired(r) {
+//	        ra.Redirect(w, r, newState(), newNonce())
+//	        return
+//	    }
+//	    ...
+//	}
+//
+//	func callbackHandler(w http.ResponseWriter, r *http.Request) {
+//	    if ra.SilentAuthenticationFailed(r) {
+//	        // The user is redirected to the interactive login.
+//	        ra.Redirect(w, r, newState(), newNonce())
+//	        return
+//	    }
+//	    ...
+//	}
+//
+// Generating and verifying the state and nonce, and processing the response of the provider,
+// remain the responsibility of the application.
+type Reauthenticator struct {
+	conf        *Configuration
+	issuer      string
+	clientID    string
+	redirectURI string
+	scopes      []string
+}
+
+// NewReauthenticator returns a Reauthenticator sending the users to the authorization endpoint
+// of the provider with the given issuer. The 'openid' scope is always requested.
+func NewReauthenticator(conf *Configuration, issuer string, clientID string, redirectURI string, scopes ...string) *Reauthenticator {
+	return &Reauthenticator{conf, issuer, clientID, redirectURI, scopes}
+}
+
+// SessionExpired returns true when the request does not contain a token or the token is no
+// longer valid, i.e.: it has expired or its session was revoked. The token is validated as by
+// the middleware, except that it is not recorded by the ReplayProtection, so the request can
+// still be authenticated afterwards.
+func (ra *Reauthenticator) SessionExpired(r *http.Request) bool {
+	tg := ra.conf.idTokenGetter
+	if tg == nil {
+		tg = getIDTokenAuthorizationHeader
+	}
+
+	ts, err := tg(r)
+	if err != nil {
+		return true
+	}
+
+	vt, p, err := ra.conf.validate(r, ts)
+	if err != nil {
+		return true
+	}
+
+	return checkToken(withoutReplayChecker(ra.conf.tokenCheckers), r, vt, p) != nil
+}
+
+// AuthorizationURL returns the URL of the authorization request re-authenticating the user with
+// the given state and nonce. The request has prompt=none unless r is the response of the provider
+// to a silent attempt that failed because it requires the user, i.e.: error=login_required.
+func (ra *Reauthenticator) AuthorizationURL(r *http.Request, state string, nonce string) (string, error) {
+	p := ra.provider()
+	conf, err := getProviderConfiguration(ra.conf.configGetter, r, &p)
+	if err != nil {
+		return "", err
+	}
+
+	if conf.AuthorizationEndpoint == "" {
+		return "", &ValidationError{
+			Code:       ValidationErrorAuthorizationEndpointNotFound,
+			Message:    fmt.Sprintf("The configuration of the issuer %v does not contain an authorization endpoint.", ra.issuer),
+			HTTPStatus: http.StatusBadGateway,
+		}
+	}
+
+	scopes := []string{"openid"}
+	for _, s := range ra.scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {ra.clientID},
+		"redirect_uri":  {ra.redirectURI},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+
+	if !ra.SilentAuthenticationFailed(r) {
+		q.Set("prompt", "none")
+	}
+
+	sep := "?"
+	if strings.Contains(conf.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return conf.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// provider returns the provider registered with the issuer, compared once normalized, i.e.: with
+// a DiscoveryURL, or a provider with only the issuer when none is registered.
+func (ra *Reauthenticator) provider() Provider {
+	p := Provider{Issuer: ra.issuer}
+
+	if tv := ra.conf.idTokenValidator(); tv.provGetter != nil {
+		if provs, err := tv.provGetter.get(); err == nil {
+			iss := normalizeIssuer(ra.issuer)
+			for i := range provs {
+				if normalizeIssuer(provs[i].Issuer) == iss {
+					p = provs[i]
+					break
+				}
+			}
+		}
+	}
+
+	return p
+}
+
+// Redirect redirects the user to the URL returned by AuthorizationURL. Errors are handled by
+// the ErrorHandlerFunc of the configuration.
+func (ra *Reauthenticator) Redirect(w http.ResponseWriter, r *http.Request, state string, nonce string) {
+	u, err := ra.AuthorizationURL(r, state, nonce)
+	if err != nil {
+		eh := ra.conf.errorHandler
+		if eh == nil {
+			eh = validationErrorToHTTPStatus
+		}
+
+		eh(err, w, r)
+		return
+	}
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// SilentAuthenticationFailed returns true when r is the response of the provider to a silent
+// re-authentication that requires the user, i.e.: error=login_required. Other errors, i.e.:
+// error=access_denied, are not retried.
+func (ra *Reauthenticator) SilentAuthenticationFailed(r *http.Request) bool {
+	return r != nil && containsString(silentAuthenticationErrors, r.URL.Query().Get("error"))
+}
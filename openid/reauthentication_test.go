@@ -0,0 +1,154 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func createReauthenticator(t *testing.T, endpoint string) (*mockConfigurationGetter, *Reauthenticator) {
+	c, _ := NewConfiguration(ErrorHandler(errorHandlerHalt))
+	cg := &mockConfigurationGetter{}
+	cg.On("get", mock.Anything, "https://issuer/.well-known/openid-configuration").Return(configuration{AuthorizationEndpoint: endpoint}, nil)
+	c.configGetter = cg
+
+	return cg, NewReauthenticator(c, "https://issuer", "client1", "https://app/callback", "email", "openid")
+}
+
+func Test_Reauthenticator_SessionExpired(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	rs := NewMemoryRevocationStore()
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"client1"}}}, nil
+	}), TokenRevocation(rs, time.Hour), ReplayProtection(NewMemoryReplayStore()))
+	ra := NewReauthenticator(c, s.URL, "client1", "https://app/callback")
+
+	claims := func(sid string) map[string]interface{} {
+		return map[string]interface{}{"sub": "user1", "aud": "client1", "sid": sid, "jti": sid, "iat": time.Now().Add(-time.Minute).Unix()}
+	}
+
+	valid, _ := ti.Issue(claims("s1"), time.Hour)
+	expired, _ := ti.Issue(claims("s2"), -time.Hour)
+	revoked, _ := ti.Issue(claims("s3"), time.Hour)
+	rs.Revoke(s.URL+" sid s3", time.Now(), time.Now().Add(time.Hour))
+
+	tests := []struct {
+		token   string
+		expired bool
+	}{
+		{valid, false},
+		{valid, false},
+		{expired, true},
+		{revoked, true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		if ra.SessionExpired(r) != test.expired {
+			t.Errorf("Expected the session of the token %q to be expired: %v.", test.token, test.expired)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+valid)
+	called := false
+	Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if !called {
+		t.Error("Expected the token checked by SessionExpired to be authenticated.")
+	}
+}
+
+func Test_Reauthenticator_AuthorizationURL(t *testing.T) {
+	_, ra := createReauthenticator(t, "https://issuer/authorize?tenant=t1")
+
+	tests := []struct {
+		request string
+		prompt  string
+	}{
+		{"/page", "none"},
+		{"/callback?error=login_required&state=s0", ""},
+		{"/callback?error=interaction_required&state=s0", ""},
+		{"/callback?error=access_denied&state=s0", "none"},
+	}
+
+	for _, test := range tests {
+		u, err := ra.AuthorizationURL(httptest.NewRequest(http.MethodGet, test.request, nil), "s1", "n1")
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		pu, _ := url.Parse(u)
+		q := pu.Query()
+
+		if pu.Host != "issuer" || pu.Path != "/authorize" || q.Get("tenant") != "t1" {
+			t.Error("Unexpected authorization endpoint", u)
+		}
+
+		if q.Get("response_type") != "code" || q.Get("client_id") != "client1" || q.Get("redirect_uri") != "https://app/callback" ||
+			q.Get("scope") != "openid email" || q.Get("state") != "s1" || q.Get("nonce") != "n1" {
+			t.Error("Unexpected authorization request", u)
+		}
+
+		if q.Get("prompt") != test.prompt {
+			t.Errorf("Request %v: expected the prompt %q, but got %q.", test.request, test.prompt, q.Get("prompt"))
+		}
+	}
+}
+
+func Test_Reauthenticator_AuthorizationURL_WhenEndpointNotFound(t *testing.T) {
+	_, ra := createReauthenticator(t, "")
+
+	_, err := ra.AuthorizationURL(httptest.NewRequest(http.MethodGet, "/", nil), "s1", "n1")
+
+	expectValidationError(t, err, ValidationErrorAuthorizationEndpointNotFound, http.StatusBadGateway, nil)
+}
+
+func Test_Reauthenticator_Redirect(t *testing.T) {
+	_, ra := createReauthenticator(t, "https://issuer/authorize")
+	w := httptest.NewRecorder()
+
+	ra.Redirect(w, httptest.NewRequest(http.MethodGet, "/", nil), "s1", "n1")
+
+	if w.Code != http.StatusFound {
+		t.Fatal("Expected status 302, but got", w.Code)
+	}
+
+	if l, _ := url.Parse(w.Header().Get("Location")); l.Query().Get("prompt") != "none" {
+		t.Error("Expected a silent authorization request, but got", l)
+	}
+}
+
+func Test_Reauthenticator_AuthorizationURL_UsesDiscoveryURL(t *testing.T) {
+	du := "https://issuer/b2c_1_signin/v2.0/.well-known/openid-configuration"
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client1"}, DiscoveryURL: du}}, nil
+	}))
+	cg := &mockConfigurationGetter{}
+	cg.On("get", (*http.Request)(nil), du).Return(configuration{AuthorizationEndpoint: "https://issuer/b2c_1_signin/authorize"}, nil)
+	c.configGetter = cg
+
+	for _, iss := range []string{"https://issuer", "https://ISSUER/"} {
+		u, err := NewReauthenticator(c, iss, "client1", "https://app/callback").AuthorizationURL(nil, "s1", "n1")
+
+		if err != nil || !strings.HasPrefix(u, "https://issuer/b2c_1_signin/authorize?") {
+			t.Error("Expected the authorization endpoint of the policy, but got", u, err)
+		}
+	}
+
+	cg.AssertExpectations(t)
+}
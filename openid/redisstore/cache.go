@@ -0,0 +1,48 @@
+package redisstore
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Cache is an implementation of openid.SharedCache storing the values as Redis keys, to be
+// registered with openid.NewSharedProviderCache so a fleet of service instances shares the
+// discovery documents and signing keys retrieved from the providers:
+//
+//	cache := openid.NewSharedProviderCache(redisstore.NewCache(client, "openid:cache:"))
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	                                openid.ProviderCache(cache))
+//
+// Failures of Redis are treated as cache misses, the documents are then retrieved from the
+// providers.
+type Cache struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewCache returns a Cache using the given client. The prefix is prepended to the keys of the
+// values.
+func NewCache(client redis.Cmdable, prefix string) *Cache {
+	return &Cache{client, prefix}
+}
+
+// Get returns the value stored with the key using GET.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	v, err := c.client.Get(c.prefix + key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// Set stores the value v with the key for ttl using SET, without expiration when ttl is zero.
+func (c *Cache) Set(key string, v []byte, ttl time.Duration) {
+	c.client.Set(c.prefix+key, v, ttl)
+}
+
+// Delete removes the value stored with the key using DEL.
+func (c *Cache) Delete(key string) {
+	c.client.Del(c.prefix + key)
+}
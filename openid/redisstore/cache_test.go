@@ -0,0 +1,62 @@
+package redisstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type fakeCacheClient struct {
+	redis.Cmdable
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func (c *fakeCacheClient) Get(key string) *redis.StringCmd {
+	v, ok := c.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(v, nil)
+}
+
+func (c *fakeCacheClient) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	c.values[key] = string(value.([]byte))
+	c.ttls[key] = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (c *fakeCacheClient) Del(keys ...string) *redis.IntCmd {
+	for _, k := range keys {
+		delete(c.values, k)
+	}
+
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+func Test_Cache(t *testing.T) {
+	c := &fakeCacheClient{values: make(map[string]string), ttls: make(map[string]time.Duration)}
+	s := NewCache(c, "cache:")
+
+	if _, ok := s.Get("k1"); ok {
+		t.Error("Expected no value for a missing key.")
+	}
+
+	s.Set("k1", []byte("v1"), time.Minute)
+
+	if c.values["cache:k1"] != "v1" || c.ttls["cache:k1"] != time.Minute {
+		t.Error("Expected the key 'cache:k1' with a ttl of one minute, but got", c.values, c.ttls)
+	}
+
+	if v, ok := s.Get("k1"); !ok || string(v) != "v1" {
+		t.Error("Expected the value v1, but got", string(v))
+	}
+
+	s.Delete("k1")
+
+	if _, ok := s.Get("k1"); ok {
+		t.Error("Expected the value to be deleted.")
+	}
+}
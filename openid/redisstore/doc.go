@@ -0,0 +1,10 @@
+/*Package redisstore implements the stores used by the openid package on top of Redis, so a
+fleet of service instances can share the same state.
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+
+	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+	                                openid.ReplayProtection(redisstore.NewReplayStore(client, "openid:jti:")),
+	                                openid.ProviderCache(openid.NewSharedProviderCache(redisstore.NewCache(client, "openid:cache:"))))
+*/
+package redisstore
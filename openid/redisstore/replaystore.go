@@ -0,0 +1,35 @@
+package redisstore
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// minTTL is the ttl of the identifiers recorded until a time in less than it, so the identifiers
+// recorded until now are not accepted again right away.
+const minTTL = time.Minute
+
+// ReplayStore is an implementation of openid.ReplayStore that records the token identifiers
+// as Redis keys expiring together with the tokens.
+type ReplayStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewReplayStore returns a ReplayStore using the given client. The prefix is prepended
+// to the token identifiers to build the Redis keys.
+func NewReplayStore(client redis.Cmdable, prefix string) *ReplayStore {
+	return &ReplayStore{client, prefix}
+}
+
+// Add records the identifier id until the time exp, or for one minute when exp is sooner, using
+// SETNX. It returns false when the key already exists.
+func (s *ReplayStore) Add(id string, exp time.Time) (bool, error) {
+	ttl := time.Until(exp)
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+
+	return s.client.SetNX(s.prefix+id, 1, ttl).Result()
+}
@@ -0,0 +1,81 @@
+package redisstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type fakeClient struct {
+	redis.Cmdable
+	keys map[string]time.Duration
+	err  error
+}
+
+func (c *fakeClient) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	if c.err != nil {
+		return redis.NewBoolResult(false, c.err)
+	}
+
+	if _, ok := c.keys[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+
+	c.keys[key] = expiration
+	return redis.NewBoolResult(true, nil)
+}
+
+func Test_ReplayStore_Add(t *testing.T) {
+	c := &fakeClient{keys: make(map[string]time.Duration)}
+	s := NewReplayStore(c, "jti:")
+
+	added, err := s.Add("id1", time.Now().Add(time.Minute))
+
+	if err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if !added {
+		t.Error("The id should have been added.")
+	}
+
+	if ttl, ok := c.keys["jti:id1"]; !ok || ttl <= 0 || ttl > time.Minute {
+		t.Error("Expected the key 'jti:id1' with a ttl up to one minute, but got", c.keys)
+	}
+
+	if added, _ = s.Add("id1", time.Now().Add(time.Minute)); added {
+		t.Error("The id should not have been added twice.")
+	}
+}
+
+func Test_ReplayStore_Add_WhenExpired(t *testing.T) {
+	c := &fakeClient{keys: make(map[string]time.Duration)}
+	s := NewReplayStore(c, "jti:")
+
+	added, err := s.Add("id1", time.Now().Add(-time.Minute))
+
+	if err != nil || !added {
+		t.Error("Expected the expired id to be accepted without error, but got", added, err)
+	}
+
+	if ttl := c.keys["jti:id1"]; ttl != minTTL {
+		t.Error("Expected the expired id to be recorded for the minimum ttl, but got", c.keys)
+	}
+
+	if added, _ = s.Add("id1", time.Now().Add(-time.Minute)); added {
+		t.Error("The expired id should not have been added twice.")
+	}
+}
+
+func Test_ReplayStore_Add_WhenClientReturnsError(t *testing.T) {
+	ee := errors.New("Connection error")
+	s := NewReplayStore(&fakeClient{err: ee}, "")
+
+	_, err := s.Add("id1", time.Now().Add(time.Minute))
+
+	if err != ee {
+		t.Error("Expected error", ee, ", but got", err)
+	}
+}
@@ -0,0 +1,163 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const tokenIDClaimName = "jti"
+const expirationClaimName = "exp"
+
+// ReplayStore is the interface implemented by the stores used to record the identifiers of
+// the tokens already accepted by the middleware. Implementations must be safe for concurrent use.
+//
+// Add records the identifier id until the time exp. It returns false when id is already
+// recorded and has not yet expired, meaning the token is being replayed.
+type ReplayStore interface {
+	Add(id string, exp time.Time) (bool, error)
+}
+
+// ReplayProtection option enables the rejection of tokens that were already accepted once.
+// Once enabled every token must contain the 'jti' and 'exp' claims. The token identifier,
+// qualified by its issuer, is recorded in the store s until the token expires, extended by the
+// Leeway of the ValidationPolicy of its provider as the token is accepted until then.
+// Use NewMemoryReplayStore for a single instance service or a distributed implementation,
+// i.e.: the one from the redisstore package, when the service runs on multiple instances.
+func ReplayProtection(s ReplayStore) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &replayChecker{s})
+		return nil
+	}
+}
+
+type replayChecker struct {
+	store ReplayStore
+}
+
+func (rc *replayChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	claims := t.Claims.(jwt.MapClaims)
+	jti, _ := claims[tokenIDClaimName].(string)
+
+	if jti == "" {
+		return &ValidationError{
+			Code:       ValidationErrorTokenIDNotFound,
+			Message:    "The token 'jti' claim was not found or was empty.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	exp, ok := getTimeClaim(claims, expirationClaimName)
+
+	if !ok {
+		return &ValidationError{
+			Code:       ValidationErrorExpirationNotFound,
+			Message:    "The token 'exp' claim was not found or was not a number.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	iss, _ := claims[issuerClaimName].(string)
+	added, err := rc.store.Add(iss+" "+jti, replayRetention(exp, p))
+
+	if err != nil {
+		return &ValidationError{
+			Code:       ValidationErrorReplayStoreFailure,
+			Message:    "Failure while recording the token identifier.",
+			Err:        err,
+			HTTPStatus: http.StatusInternalServerError,
+		}
+	}
+
+	if !added {
+		return &ValidationError{
+			Code:       ValidationErrorTokenReplayed,
+			Message:    "The token was already used.",
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return nil
+}
+
+// withoutReplayChecker returns the checkers of cs other than the ones of ReplayProtection, to
+// check again the tokens already recorded.
+func withoutReplayChecker(cs []tokenChecker) []tokenChecker {
+	var checkers []tokenChecker
+	for _, c := range cs {
+		if _, replay := c.(*replayChecker); !replay {
+			checkers = append(checkers, c)
+		}
+	}
+
+	return checkers
+}
+
+// replayRetention returns the time until which the identifier of a token expiring at exp is
+// kept, the latest of exp and now extended by the Leeway of the ValidationPolicy of the provider
+// p, so the tokens accepted after their expiration within the leeway cannot be replayed.
+func replayRetention(exp time.Time, p *Provider) time.Time {
+	if now := time.Now(); exp.Before(now) {
+		exp = now
+	}
+
+	if p != nil && p.ValidationPolicy != nil {
+		exp = exp.Add(p.ValidationPolicy.Leeway)
+	}
+
+	return exp
+}
+
+// getTimeClaim returns the time represented by a claim holding the number of seconds since epoch.
+func getTimeClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	switch v := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// memoryReplayStore is a ReplayStore keeping the identifiers in memory.
+type memoryReplayStore struct {
+	mu       sync.Mutex
+	ids      map[string]time.Time
+	expiries expiryQueue
+	now      func() time.Time
+}
+
+// NewMemoryReplayStore returns a ReplayStore that keeps the token identifiers in memory.
+// Expired identifiers are removed, in expiration order, as new ones are added.
+func NewMemoryReplayStore() ReplayStore {
+	return &memoryReplayStore{ids: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *memoryReplayStore) Add(id string, exp time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiries.expire(s.now(), func(k string, e time.Time) {
+		if ie, ok := s.ids[k]; ok && ie.Equal(e) {
+			delete(s.ids, k)
+		}
+	})
+
+	if _, ok := s.ids[id]; ok {
+		return false, nil
+	}
+
+	s.ids[id] = exp
+	s.expiries.add(id, exp)
+	return true, nil
+}
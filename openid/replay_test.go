@@ -0,0 +1,148 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type replayStoreFunc func(id string, exp time.Time) (bool, error)
+
+func (f replayStoreFunc) Add(id string, exp time.Time) (bool, error) {
+	return f(id, exp)
+}
+
+func Test_replayChecker_WhenTokenHasNoID(t *testing.T) {
+	rc := &replayChecker{NewMemoryReplayStore()}
+	jt := jwt.New(jwt.SigningMethodRS256)
+
+	err := rc.check(nil, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorTokenIDNotFound, http.StatusUnauthorized, nil)
+}
+
+func Test_replayChecker_WhenTokenHasNoExpiration(t *testing.T) {
+	rc := &replayChecker{NewMemoryReplayStore()}
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["jti"] = "id1"
+
+	err := rc.check(nil, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorExpirationNotFound, http.StatusUnauthorized, nil)
+}
+
+func Test_replayChecker_WhenStoreReturnsError(t *testing.T) {
+	ee := errors.New("Store error")
+	rc := &replayChecker{replayStoreFunc(func(id string, exp time.Time) (bool, error) {
+		return false, ee
+	})}
+
+	err := rc.check(nil, createReplayableToken("id1", time.Now().Add(time.Minute)), nil)
+
+	expectValidationError(t, err, ValidationErrorReplayStoreFailure, http.StatusInternalServerError, ee)
+}
+
+func Test_replayChecker_QualifiesIDWithIssuer(t *testing.T) {
+	exp := time.Unix(time.Now().Add(time.Minute).Unix(), 0)
+	var rid string
+	var rexp time.Time
+	rc := &replayChecker{replayStoreFunc(func(id string, e time.Time) (bool, error) {
+		rid, rexp = id, e
+		return true, nil
+	})}
+
+	if err := rc.check(nil, createReplayableToken("id1", exp), nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if rid != "https://issuer id1" {
+		t.Error("Expected id 'https://issuer id1', but got", rid)
+	}
+
+	if !rexp.Equal(exp) {
+		t.Error("Expected expiration", exp, ", but got", rexp)
+	}
+}
+
+func Test_replayChecker_WhenTokenIsReplayedWithinLeeway(t *testing.T) {
+	rc := &replayChecker{NewMemoryReplayStore()}
+	p := &Provider{Issuer: "https://issuer", ValidationPolicy: &ValidationPolicy{Leeway: 2 * time.Minute}}
+	jt := createReplayableToken("id1", time.Now().Add(-30*time.Second))
+
+	if err := rc.check(nil, jt, p); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	err := rc.check(nil, jt, p)
+
+	expectValidationError(t, err, ValidationErrorTokenReplayed, http.StatusUnauthorized, nil)
+}
+
+func Test_replayRetention(t *testing.T) {
+	now := time.Now()
+	p := &Provider{ValidationPolicy: &ValidationPolicy{Leeway: time.Minute}}
+
+	if r := replayRetention(now.Add(time.Hour), p); !r.Equal(now.Add(time.Hour + time.Minute)) {
+		t.Error("Expected the expiration extended by the leeway, but got", r)
+	}
+
+	if r := replayRetention(now.Add(-time.Hour), p); r.Before(now.Add(time.Minute)) {
+		t.Error("Expected the retention of an expired token to last the leeway, but got", r)
+	}
+
+	if r := replayRetention(now.Add(time.Hour), nil); !r.Equal(now.Add(time.Hour)) {
+		t.Error("Expected the expiration without leeway, but got", r)
+	}
+}
+
+func Test_replayChecker_WhenTokenIsReplayed(t *testing.T) {
+	rc := &replayChecker{NewMemoryReplayStore()}
+	jt := createReplayableToken("id1", time.Now().Add(time.Minute))
+
+	if err := rc.check(nil, jt, nil); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	err := rc.check(nil, jt, nil)
+
+	expectValidationError(t, err, ValidationErrorTokenReplayed, http.StatusUnauthorized, nil)
+}
+
+func Test_memoryReplayStore_RemovesExpiredIDs(t *testing.T) {
+	now := time.Now()
+	s := &memoryReplayStore{ids: make(map[string]time.Time), now: func() time.Time { return now }}
+
+	s.Add("id1", now.Add(time.Second))
+	s.Add("id2", now.Add(time.Minute))
+
+	now = now.Add(2 * time.Second)
+
+	added, err := s.Add("id1", now.Add(time.Second))
+
+	if err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+
+	if !added {
+		t.Error("The expired id should have been added again.")
+	}
+
+	if added, _ = s.Add("id2", now.Add(time.Minute)); added {
+		t.Error("The unexpired id should not have been added again.")
+	}
+
+	if len(s.ids) != 2 || len(s.expiries) != 2 {
+		t.Error("Expected the expired id to be removed once, but got", s.ids, s.expiries)
+	}
+}
+
+func createReplayableToken(jti string, exp time.Time) *jwt.Token {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
+	jt.Claims.(jwt.MapClaims)["jti"] = jti
+	jt.Claims.(jwt.MapClaims)["exp"] = float64(exp.Unix())
+	return jt
+}
@@ -0,0 +1,103 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// maxRetryBackoff caps the delay between two attempts to retrieve a document from a provider.
+const maxRetryBackoff = 30 * time.Second
+
+// ProviderRetries option retries the retrievals of the discovery documents and signing keys that
+// fail with a network error or a 5xx status up to retries times, before failing the validation.
+// The delay before the nth retry is drawn between half and all of backoff*2^(n-1), capped at 30s,
+// and is interrupted when the request being validated is canceled. By default a single attempt is made.
+func ProviderRetries(retries int, backoff time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if retries <= 0 || backoff <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidRetries,
+				Message: fmt.Sprintf("The retries %v and backoff %v must be positive.", retries, backoff),
+			}
+		}
+
+		rp := &retryPolicy{retries: retries, backoff: backoff, wait: waitContext}
+		sksp := c.idTokenValidator().
+			keyGetter.(*signingKeyProvider).
+			keySetGetter.(*signingKeySetProvider)
+		sksp.configGetter.(*httpConfigurationProvider).conditional.retry = rp
+		sksp.jwksGetter.(*httpJwksProvider).conditional.retry = rp
+		return nil
+	}
+}
+
+// retryPolicy retries the requests to the providers failing with transient errors. A nil
+// retryPolicy makes a single attempt.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+	wait    func(ctx context.Context, d time.Duration) error
+}
+
+// do calls get until it succeeds with a status below 500 or the retries are exhausted, and
+// returns its last result. The bodies of the responses that are retried are closed.
+func (rp *retryPolicy) do(r *http.Request, get func() (*http.Response, error)) (*http.Response, error) {
+	resp, err := get()
+	if rp == nil {
+		return resp, err
+	}
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+
+	for i := 0; i < rp.retries && retryable(resp, err); i++ {
+		if rp.wait(ctx, rp.delay(i)) != nil {
+			break
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		resp, err = get()
+	}
+
+	return resp, err
+}
+
+// delay returns the jittered delay before the retry n, starting at 0.
+func (rp *retryPolicy) delay(n int) time.Duration {
+	d := maxRetryBackoff
+	if n < 32 && rp.backoff<<uint(n) < maxRetryBackoff {
+		d = rp.backoff << uint(n)
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryable returns true when the result of a request is a network error or a server error.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return err != errCredentialsNotSupported && err != errCircuitOpen
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// waitContext waits for d or until ctx is done, in which case it returns the error of ctx.
+func waitContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
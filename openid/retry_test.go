@@ -0,0 +1,150 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(""))}
+}
+
+// sequenceGetter returns the results in order, one per call.
+func sequenceGetter(results ...interface{}) (func() (*http.Response, error), *int) {
+	calls := 0
+	return func() (*http.Response, error) {
+		res := results[calls]
+		calls++
+		if err, ok := res.(error); ok {
+			return nil, err
+		}
+
+		return response(res.(int)), nil
+	}, &calls
+}
+
+func newTestRetryPolicy(retries int) (*retryPolicy, *[]time.Duration) {
+	var delays []time.Duration
+	return &retryPolicy{retries: retries, backoff: time.Second, wait: func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}}, &delays
+}
+
+func Test_retryPolicy_do_WhenNil(t *testing.T) {
+	var rp *retryPolicy
+	get, calls := sequenceGetter(http.StatusBadGateway)
+
+	resp, err := rp.do(nil, get)
+
+	if err != nil || resp.StatusCode != http.StatusBadGateway || *calls != 1 {
+		t.Error("Expected a single attempt, but got", *calls, resp, err)
+	}
+}
+
+func Test_retryPolicy_do_RetriesTransientErrors(t *testing.T) {
+	rp, delays := newTestRetryPolicy(3)
+	get, calls := sequenceGetter(errors.New("connection reset"), http.StatusServiceUnavailable, http.StatusOK)
+
+	resp, err := rp.do(nil, get)
+
+	if err != nil || resp.StatusCode != http.StatusOK || *calls != 3 {
+		t.Error("Expected the third attempt to succeed, but got", *calls, resp, err)
+	}
+
+	if len(*delays) != 2 {
+		t.Fatal("Expected 2 delays, but got", *delays)
+	}
+
+	for i, d := range *delays {
+		max := time.Second << uint(i)
+		if d < max/2 || d > max {
+			t.Errorf("Expected the delay %v between %v and %v, but got %v", i, max/2, max, d)
+		}
+	}
+}
+
+func Test_retryPolicy_do_WhenRetriesExhausted(t *testing.T) {
+	rp, _ := newTestRetryPolicy(2)
+	get, calls := sequenceGetter(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadGateway)
+
+	resp, err := rp.do(nil, get)
+
+	if err != nil || resp.StatusCode != http.StatusBadGateway || *calls != 3 {
+		t.Error("Expected the last response after 3 attempts, but got", *calls, resp, err)
+	}
+}
+
+func Test_retryPolicy_do_DoesNotRetryClientErrors(t *testing.T) {
+	rp, _ := newTestRetryPolicy(2)
+	get, calls := sequenceGetter(http.StatusNotFound)
+
+	resp, err := rp.do(nil, get)
+
+	if err != nil || resp.StatusCode != http.StatusNotFound || *calls != 1 {
+		t.Error("Expected a single attempt, but got", *calls, resp, err)
+	}
+}
+
+func Test_retryPolicy_do_WhenRequestCanceled(t *testing.T) {
+	rp, _ := newTestRetryPolicy(2)
+	get, calls := sequenceGetter(http.StatusServiceUnavailable)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, _ := rp.do(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), get)
+
+	if resp.StatusCode != http.StatusServiceUnavailable || *calls != 1 {
+		t.Error("Expected no retry of a canceled request, but got", *calls, resp)
+	}
+}
+
+func Test_retryPolicy_delay_IsCapped(t *testing.T) {
+	rp := &retryPolicy{retries: 100, backoff: time.Second}
+
+	if d := rp.delay(99); d < maxRetryBackoff/2 || d > maxRetryBackoff {
+		t.Error("Expected the delay to be capped at", maxRetryBackoff, "but got", d)
+	}
+}
+
+func Test_ProviderRetries_WhenInvalid(t *testing.T) {
+	_, err := NewConfiguration(ProviderRetries(0, time.Second))
+	expectSetupError(t, err, SetupErrorInvalidRetries)
+
+	_, err = NewConfiguration(ProviderRetries(1, 0))
+	expectSetupError(t, err, SetupErrorInvalidRetries)
+}
+
+func Test_ProviderRetries_RetriesFailedRetrievals(t *testing.T) {
+	var ti *TokenIssuer
+	failures := 2
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		ti.Handler().ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	ti, _ = NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ProviderRetries(2, time.Millisecond))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	if _, _, err := c.validate(nil, ts); err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
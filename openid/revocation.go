@@ -0,0 +1,152 @@
+package openid
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const sessionIDClaimName = "sid"
+
+// RevocationStore is the interface implemented by the stores used to record the sessions and
+// subjects revoked, i.e.: through the back-channel logout handler. Implementations must be safe
+// for concurrent use.
+//
+// Revoke records that the tokens identified by key and issued up to the time at are revoked,
+// keeping the record until the time exp.
+//
+// RevokedAt returns the time at which key was revoked, or false when key is not revoked.
+type RevocationStore interface {
+	Revoke(key string, at time.Time, exp time.Time) error
+	RevokedAt(key string) (time.Time, bool, error)
+}
+
+// TokenRevocation option enables the rejection of tokens belonging to a session ('sid' claim) or
+// a subject ('sub' claim) revoked after the token was issued. Revocations are recorded in the
+// store s by the handler returned from BackChannelLogoutHandler and are kept for the retention
+// duration, which should be at least the maximum lifetime of the tokens accepted by the service.
+func TokenRevocation(s RevocationStore, retention time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.revocation = &revocationChecker{store: s, retention: retention, now: time.Now}
+		c.tokenCheckers = append(c.tokenCheckers, c.revocation)
+		return nil
+	}
+}
+
+type revocationChecker struct {
+	store     RevocationStore
+	retention time.Duration
+	now       func() time.Time
+}
+
+func (rc *revocationChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	claims := t.Claims.(jwt.MapClaims)
+	iat, _ := getTimeClaim(claims, issuedAtClaimName)
+
+	for _, key := range revocationKeys(claims) {
+		at, revoked, err := rc.store.RevokedAt(key)
+
+		if err != nil {
+			return revocationStoreError(err)
+		}
+
+		// Tokens without 'iat' cannot prove they were issued after the revocation.
+		if revoked && !iat.After(at) {
+			return &ValidationError{
+				Code:       ValidationErrorTokenRevoked,
+				Message:    "The token session was revoked.",
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	return nil
+}
+
+// revoke records the revocation of the session and of all the sessions of the subject of a
+// logout token, for the ones of its 'sid' and 'sub' claims it contains.
+func (rc *revocationChecker) revoke(claims jwt.MapClaims) error {
+	now := rc.now()
+
+	for _, key := range revocationKeys(claims) {
+		if err := rc.store.Revoke(key, now, now.Add(rc.retention)); err != nil {
+			return revocationStoreError(err)
+		}
+	}
+
+	return nil
+}
+
+// revocationKeys returns the keys identifying the session and the subject of a token,
+// qualified by its issuer, with the session first.
+func revocationKeys(claims jwt.MapClaims) []string {
+	iss, _ := claims[issuerClaimName].(string)
+	var keys []string
+
+	if sid, _ := claims[sessionIDClaimName].(string); sid != "" {
+		keys = append(keys, iss+" sid "+sid)
+	}
+
+	if sub, _ := claims[subjectClaimName].(string); sub != "" {
+		keys = append(keys, iss+" sub "+sub)
+	}
+
+	return keys
+}
+
+func revocationStoreError(err error) error {
+	return &ValidationError{
+		Code:       ValidationErrorRevocationStoreFailure,
+		Message:    "Failure while accessing the revocation store.",
+		Err:        err,
+		HTTPStatus: http.StatusInternalServerError,
+	}
+}
+
+// memoryRevocationStore is a RevocationStore keeping the revocations in memory.
+type memoryRevocationStore struct {
+	mu          sync.Mutex
+	revocations map[string]revocation
+	expiries    expiryQueue
+	now         func() time.Time
+}
+
+type revocation struct {
+	at  time.Time
+	exp time.Time
+}
+
+// NewMemoryRevocationStore returns a RevocationStore that keeps the revocations in memory.
+// Revocations are removed once they expire.
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{revocations: make(map[string]revocation), now: time.Now}
+}
+
+func (s *memoryRevocationStore) Revoke(key string, at time.Time, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiries.expire(s.now(), func(k string, exp time.Time) {
+		if r, ok := s.revocations[k]; ok && r.exp.Equal(exp) {
+			delete(s.revocations, k)
+		}
+	})
+
+	s.revocations[key] = revocation{at, exp}
+	s.expiries.add(key, exp)
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokedAt(key string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.revocations[key]
+	if !ok || !r.exp.After(s.now()) {
+		return time.Time{}, false, nil
+	}
+
+	return r.at, true, nil
+}
@@ -0,0 +1,114 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type revocationStoreFunc func(key string) (time.Time, bool, error)
+
+func (f revocationStoreFunc) Revoke(key string, at time.Time, exp time.Time) error {
+	return nil
+}
+
+func (f revocationStoreFunc) RevokedAt(key string) (time.Time, bool, error) {
+	return f(key)
+}
+
+func TestRevocationChecker_Check(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	s := NewMemoryRevocationStore()
+	s.Revoke("https://issuer sid session1", now, now.Add(time.Hour))
+	s.Revoke("https://issuer sub user2", now, now.Add(time.Hour))
+
+	tests := []struct {
+		claims  jwt.MapClaims
+		revoked bool
+	}{
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1", "iat": float64(now.Add(-time.Minute).Unix())}, true},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1", "iat": float64(now.Unix())}, true},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1", "iat": float64(now.Add(time.Minute).Unix())}, false},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1"}, true},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session2", "iat": float64(now.Add(-time.Minute).Unix())}, false},
+		{jwt.MapClaims{"iss": "https://other", "sub": "user1", "sid": "session1", "iat": float64(now.Add(-time.Minute).Unix())}, false},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user2", "sid": "session3", "iat": float64(now.Add(-time.Minute).Unix())}, true},
+		{jwt.MapClaims{"iss": "https://issuer", "sub": "user2", "iat": float64(now.Add(time.Minute).Unix())}, false},
+	}
+
+	rc := &revocationChecker{store: s, retention: time.Hour, now: time.Now}
+	for i, test := range tests {
+		e := rc.check(nil, &jwt.Token{Claims: test.claims}, nil)
+
+		if test.revoked {
+			expectValidationError(t, e, ValidationErrorTokenRevoked, http.StatusUnauthorized, nil)
+		} else if e != nil {
+			t.Error("Test", i, "an error was returned but not expected", e)
+		}
+	}
+}
+
+func TestRevocationChecker_Check_WhenStoreReturnsError(t *testing.T) {
+	se := errors.New("Store error")
+	rc := &revocationChecker{store: revocationStoreFunc(func(key string) (time.Time, bool, error) {
+		return time.Time{}, false, se
+	})}
+
+	e := rc.check(nil, &jwt.Token{Claims: jwt.MapClaims{"iss": "https://issuer", "sub": "user1"}}, nil)
+
+	expectValidationError(t, e, ValidationErrorRevocationStoreFailure, http.StatusInternalServerError, se)
+}
+
+func TestRevocationChecker_Revoke_SessionAndSubject(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	s := NewMemoryRevocationStore()
+	rc := &revocationChecker{store: s, retention: time.Hour, now: func() time.Time { return now }}
+
+	if e := rc.revoke(jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session1"}); e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	if at, ok, _ := s.RevokedAt("https://issuer sid session1"); !ok || !at.Equal(now) {
+		t.Error("Expected the session to be revoked at", now, "but got", at, ok)
+	}
+
+	if at, ok, _ := s.RevokedAt("https://issuer sub user1"); !ok || !at.Equal(now) {
+		t.Error("Expected the subject to be revoked at", now, "but got", at, ok)
+	}
+
+	other := &jwt.Token{Claims: jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "sid": "session2", "iat": float64(now.Add(-time.Minute).Unix())}}
+	e := rc.check(nil, other, nil)
+
+	expectValidationError(t, e, ValidationErrorTokenRevoked, http.StatusUnauthorized, nil)
+}
+
+func TestMemoryRevocationStore_RevokedAt_WhenExpired(t *testing.T) {
+	now := time.Now()
+	s := &memoryRevocationStore{revocations: make(map[string]revocation), now: func() time.Time { return now }}
+
+	s.Revoke("key1", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	s.Revoke("key2", now, now.Add(time.Hour))
+
+	if _, ok, _ := s.RevokedAt("key1"); ok {
+		t.Error("The expired revocation should not be returned")
+	}
+
+	if _, ok, _ := s.RevokedAt("key2"); !ok {
+		t.Error("The revocation should be returned")
+	}
+
+	if len(s.revocations) != 1 {
+		t.Error("Expected the expired revocation to be removed, but got", len(s.revocations), "revocations")
+	}
+
+	s.Revoke("key2", now, now.Add(2*time.Hour))
+	now = now.Add(time.Hour)
+	s.Revoke("key3", now, now.Add(time.Hour))
+
+	if _, ok, _ := s.RevokedAt("key2"); !ok {
+		t.Error("The revocation recorded again should be returned until its new expiration")
+	}
+}
@@ -0,0 +1,60 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RolesClaim returns a RolesFunc reading the roles of the user from the claim at the given path,
+// whose members are separated by dots, i.e.: "roles", "realm_access.roles" or "cognito:groups". The
+// claim holds the roles as an array of strings or a space separated string. Use it as the RolesFunc
+// of the providers whose roles are enforced by RequireRoles or RequireAnyRole.
+func RolesClaim(path string) RolesFunc {
+	members := strings.Split(path, ".")
+	return func(claims map[string]interface{}) []string {
+		var v interface{} = claims
+		for _, m := range members {
+			o, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+
+			v = o[m]
+		}
+
+		return claimStrings(v)
+	}
+}
+
+// RequireRoles middleware rejects the requests whose user does not have all the given roles,
+// among the Roles returned by the RolesFunc of its provider, with status 403/Forbidden. As
+// RequireScopes, it must be registered after AuthenticateUserContext, New or AuthenticateOptional,
+// and the errors are handled by the ErrorHandlerFunc of conf, with the error code
+// ValidationErrorInsufficientRole.
+func RequireRoles(conf *Configuration, roles ...string) func(next http.Handler) http.Handler {
+	policy := RequireRole(roles...)
+	return authorizationMiddleware(conf, "", func(r *http.Request, u *User) error {
+		return policy(u, r)
+	})
+}
+
+// RequireAnyRole middleware rejects the requests whose user does not have any of the given roles,
+// and is otherwise registered as RequireRoles.
+func RequireAnyRole(conf *Configuration, roles ...string) func(next http.Handler) http.Handler {
+	return authorizationMiddleware(conf, "", func(r *http.Request, u *User) error {
+		if u != nil {
+			for _, role := range roles {
+				if containsString(u.Roles, role) {
+					return nil
+				}
+			}
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorInsufficientRole,
+			Message:    fmt.Sprintf("The user does not have any of the roles %v.", roles),
+			HTTPStatus: http.StatusForbidden,
+		}
+	})
+}
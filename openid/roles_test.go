@@ -0,0 +1,81 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_RolesClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"roles":          []interface{}{"admin", "user"},
+		"realm_access":   map[string]interface{}{"roles": []interface{}{"editor"}},
+		"cognito:groups": "viewers auditors",
+		"scope":          map[string]interface{}{"roles": 5},
+	}
+
+	tests := []struct {
+		path  string
+		roles []string
+	}{
+		{"roles", []string{"admin", "user"}},
+		{"realm_access.roles", []string{"editor"}},
+		{"cognito:groups", []string{"viewers", "auditors"}},
+		{"roles.admin", nil},
+		{"scope.roles", nil},
+		{"missing.roles", nil},
+	}
+
+	for _, test := range tests {
+		if roles := RolesClaim(test.path)(claims); !reflect.DeepEqual(roles, test.roles) {
+			t.Errorf("Expected the roles %v at %v, but got %v", test.roles, test.path, roles)
+		}
+	}
+}
+
+func Test_RequireRoles(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}, RolesFunc: RolesClaim("realm_access.roles")}}, nil
+	}))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	all := New(c)(RequireRoles(c, "admin", "editor")(ok))
+	oneOf := New(c)(RequireAnyRole(c, "admin", "editor")(ok))
+
+	tests := []struct {
+		h      http.Handler
+		roles  []interface{}
+		status int
+	}{
+		{all, []interface{}{"admin", "editor"}, http.StatusNoContent},
+		{all, []interface{}{"admin"}, http.StatusForbidden},
+		{all, nil, http.StatusForbidden},
+		{oneOf, []interface{}{"editor"}, http.StatusNoContent},
+		{oneOf, []interface{}{"viewer"}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		claims := map[string]interface{}{"sub": "user1", "aud": "app", "realm_access": map[string]interface{}{"roles": test.roles}}
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		test.h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Expected the status %v for the roles %v, but got %v", test.status, test.roles, w.Code)
+		}
+
+		if ch := w.Header().Get("WWW-Authenticate"); ch != "" {
+			t.Error("Expected no challenge, but got", ch)
+		}
+	}
+}
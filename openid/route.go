@@ -0,0 +1,100 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// RouteOption overrides a setting of the Configuration of a route, see Route.
+type RouteOption func(*Configuration)
+
+// Route returns a Configuration for the handlers of a route, sharing the providers, signing keys,
+// caches and checks of c, with the given overrides applied, so routes enforce different
+// requirements without registering the providers again:
+//
+//	admin := conf.Route(openid.RouteScopes("admin"), openid.RouteErrorHandler(adminErrorHandler))
+//	http.Handle("/admin/", openid.Authenticate(admin, adminHandler))
+//	http.Handle("/public/", openid.Authenticate(conf, publicHandler))
+//
+// The requirements of the route are checked after the ones of c. The configuration of the route
+// is lightweight and can be derived for each route. Options changing the providers or how they
+// are retrieved must be applied to c, they apply to all its routes.
+func (c *Configuration) Route(options ...RouteOption) *Configuration {
+	rc := *c
+	rc.tokenCheckers = append([]tokenChecker(nil), c.tokenCheckers...)
+
+	for _, option := range options {
+		option(&rc)
+	}
+
+	return &rc
+}
+
+// RouteErrorHandler option replaces the ErrorHandlerFunc of the route.
+func RouteErrorHandler(eh ErrorHandlerFunc) RouteOption {
+	return func(c *Configuration) {
+		c.errorHandler = eh
+	}
+}
+
+// RouteScopes option requires the tokens of the route to grant all the given scopes, as
+// RequireScope, and rejects the others with status 403/Forbidden.
+func RouteScopes(scopes ...string) RouteOption {
+	return func(c *Configuration) {
+		c.tokenCheckers = append(c.tokenCheckers, tokenCheckerFunc(func(r *http.Request, t *jwt.Token, p *Provider) error {
+			return requireScopes(t.Claims.(jwt.MapClaims), scopes)
+		}))
+	}
+}
+
+// RoutePolicies option requires the authenticated users of the route to satisfy all the given
+// policies, as the patterns protected with Protect. The errors of the policies are handled by the
+// ErrorHandlerFunc of the route, and halt the request by default.
+func RoutePolicies(policies ...Policy) RouteOption {
+	return func(c *Configuration) {
+		c.tokenCheckers = append(c.tokenCheckers, tokenCheckerFunc(func(r *http.Request, t *jwt.Token, p *Provider) error {
+			u, err := c.newUser(t, p)
+			if err != nil {
+				return err
+			}
+
+			for _, policy := range policies {
+				if err := policy(u, r); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}))
+	}
+}
+
+// RouteAudiences option requires the tokens of the route to be issued for one of the given
+// audiences, among the client IDs of their provider, and rejects the others with status
+// 403/Forbidden, i.e.: to only accept the tokens of the admin client on the admin routes. The
+// client ID of the tokens without 'aud' claim is read from the ClientIDClaim of their provider.
+func RouteAudiences(audiences ...string) RouteOption {
+	return func(c *Configuration) {
+		c.tokenCheckers = append(c.tokenCheckers, tokenCheckerFunc(func(r *http.Request, t *jwt.Token, p *Provider) error {
+			claims := t.Claims.(jwt.MapClaims)
+			auds, ok := claims[audiencesClaimName]
+			if !ok && p != nil && p.ClientIDClaim != "" {
+				auds = claims[p.ClientIDClaim]
+			}
+
+			for _, a := range claimStrings(auds) {
+				if containsString(audiences, a) {
+					return nil
+				}
+			}
+
+			return &ValidationError{
+				Code:       ValidationErrorAudienceNotFound,
+				Message:    fmt.Sprintf("The token audiences %v are not allowed by the route.", claimStrings(auds)),
+				HTTPStatus: http.StatusForbidden,
+			}
+		}))
+	}
+}
@@ -0,0 +1,58 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func expectRouteStatus(t *testing.T, c *Configuration, ti *TokenIssuer, claims map[string]interface{}, expected int) {
+	ts, _ := ti.Issue(claims, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+
+	w := httptest.NewRecorder()
+	Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(w, r)
+
+	if w.Code != expected {
+		t.Errorf("Expected the status %v for the claims %v, but got %v", expected, claims, w.Code)
+	}
+}
+
+func Test_Configuration_Route(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app", "admin"}}}, nil
+	}))
+
+	admin := c.Route(RouteScopes("admin"), RouteAudiences("admin"))
+	teapot := c.Route(RouteErrorHandler(func(e error, w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusTeapot)
+		return true
+	}))
+
+	expectRouteStatus(t, c, ti, map[string]interface{}{"sub": "user1", "aud": "app"}, http.StatusNoContent)
+	expectRouteStatus(t, admin, ti, map[string]interface{}{"sub": "user1", "aud": "app"}, http.StatusForbidden)
+	expectRouteStatus(t, admin, ti, map[string]interface{}{"sub": "user1", "aud": "admin"}, http.StatusForbidden)
+	expectRouteStatus(t, admin, ti, map[string]interface{}{"sub": "user1", "aud": "app", "scope": "admin"}, http.StatusForbidden)
+	expectRouteStatus(t, admin, ti, map[string]interface{}{"sub": "user1", "aud": "admin", "scope": "read admin"}, http.StatusNoContent)
+	expectRouteStatus(t, teapot, ti, map[string]interface{}{"sub": "user1", "aud": "other"}, http.StatusTeapot)
+	expectRouteStatus(t, c, ti, map[string]interface{}{"sub": "user1", "aud": "other"}, http.StatusUnauthorized)
+
+	policies := c.Route(RoutePolicies(RequireScope("read")))
+	expectRouteStatus(t, policies, ti, map[string]interface{}{"sub": "user1", "aud": "app", "scp": "read"}, http.StatusNoContent)
+	expectRouteStatus(t, policies, ti, map[string]interface{}{"sub": "user1", "aud": "app"}, http.StatusForbidden)
+
+	if len(c.tokenCheckers) != 0 {
+		t.Error("Expected the checks of the routes not to change the configuration, but got", c.tokenCheckers)
+	}
+
+	if admin.idTokenValidator() != c.idTokenValidator() {
+		t.Error("Expected the route to share the token validator of the configuration.")
+	}
+}
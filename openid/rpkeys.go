@@ -0,0 +1,196 @@
+package openid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// WellKnownJwksPath is the conventional path where the JwksHandler publishes the public
+// keys of the relying party (RP).
+const WellKnownJwksPath = "/.well-known/jwks.json"
+
+const rsaKeySize = 2048
+
+// KeyUseSignature and KeyUseEncryption are the values of the 'use' member of generated keys.
+const (
+	KeyUseSignature  = "sig"
+	KeyUseEncryption = "enc"
+)
+
+// GenerateKey generates a new key pair of the relying party for the JWA algorithm alg. The key ID
+// is the JWK thumbprint of the key (RFC 7638) and the use is derived from the algorithm:
+//
+//	RS256, RS384, RS512, PS256, PS384, PS512   2048 bits RSA signing key.
+//	ES256, ES384, ES512                        ECDSA signing key on the respective curve.
+//	RSA-OAEP, RSA-OAEP-256                     2048 bits RSA encryption key.
+//	ECDH-ES, ECDH-ES+A128KW, ECDH-ES+A256KW    P-256 encryption key.
+//
+// Any other algorithm fails with a SetupError.
+func GenerateKey(alg string) (jose.JSONWebKey, error) {
+	var key crypto.PrivateKey
+	var use string
+	var err error
+
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+		use = KeyUseSignature
+	case "ES256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		use = KeyUseSignature
+	case "ES384":
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		use = KeyUseSignature
+	case "ES512":
+		key, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		use = KeyUseSignature
+	case "RSA-OAEP", "RSA-OAEP-256":
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+		use = KeyUseEncryption
+	case "ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A256KW":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		use = KeyUseEncryption
+	default:
+		return jose.JSONWebKey{}, &SetupError{
+			Code:    SetupErrorUnsupportedKeyAlgorithm,
+			Message: fmt.Sprintf("Keys cannot be generated for the algorithm %v.", alg),
+		}
+	}
+
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	k := jose.JSONWebKey{Key: key, Algorithm: alg, Use: use}
+
+	tp, err := k.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	k.KeyID = base64.RawURLEncoding.EncodeToString(tp)
+	return k, nil
+}
+
+// KeySet holds the key pairs of the relying party, i.e.: generated with GenerateKey. It is safe
+// for concurrent use so keys can be rotated while the JwksHandler publishes them.
+// A KeySet is serialized as a JWK set including the private keys, use it to persist the keys
+// in a secure storage. Only the public keys are published by the JwksHandler.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []jose.JSONWebKey
+}
+
+// NewKeySet returns a KeySet containing the given keys.
+func NewKeySet(keys ...jose.JSONWebKey) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// Add adds the key k to the set, replacing the key with the same key ID.
+func (ks *KeySet) Add(k jose.JSONWebKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for i := range ks.keys {
+		if ks.keys[i].KeyID == k.KeyID {
+			ks.keys[i] = k
+			return
+		}
+	}
+
+	ks.keys = append(ks.keys, k)
+}
+
+// Remove removes the key with the key ID kid from the set.
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for i := range ks.keys {
+		if ks.keys[i].KeyID == kid {
+			ks.keys = append(ks.keys[:i], ks.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// Key returns the key with the key ID kid.
+func (ks *KeySet) Key(kid string) (jose.JSONWebKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, k := range ks.keys {
+		if k.KeyID == kid {
+			return k, true
+		}
+	}
+
+	return jose.JSONWebKey{}, false
+}
+
+// Public returns the JWK set with the public keys of the set.
+func (ks *KeySet) Public() jose.JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	pks := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(ks.keys))}
+	for i := range ks.keys {
+		pks.Keys = append(pks.Keys, ks.keys[i].Public())
+	}
+
+	return pks
+}
+
+// MarshalJSON serializes the set, including the private keys, as a JWK set.
+func (ks *KeySet) MarshalJSON() ([]byte, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return json.Marshal(jose.JSONWebKeySet{Keys: ks.keys})
+}
+
+// UnmarshalJSON replaces the keys of the set with the ones of the serialized JWK set.
+func (ks *KeySet) UnmarshalJSON(data []byte) error {
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = jwks.Keys
+	return nil
+}
+
+// JwksHandler returns an http.Handler publishing the public keys of the set ks as a JWK set,
+// usually registered with the path WellKnownJwksPath. The keys are read on every request, so
+// keys added to or removed from the set are published immediately.
+func JwksHandler(ks *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := json.Marshal(ks.Public())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(body)
+	})
+}
@@ -0,0 +1,111 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func Test_GenerateKey(t *testing.T) {
+	tests := []struct {
+		alg string
+		use string
+	}{
+		{"RS256", KeyUseSignature},
+		{"PS384", KeyUseSignature},
+		{"ES256", KeyUseSignature},
+		{"ES512", KeyUseSignature},
+		{"RSA-OAEP-256", KeyUseEncryption},
+		{"ECDH-ES", KeyUseEncryption},
+	}
+
+	for _, test := range tests {
+		k, err := GenerateKey(test.alg)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", test.alg, err)
+		}
+
+		if k.Algorithm != test.alg || k.Use != test.use || k.KeyID == "" || k.IsPublic() || !k.Valid() {
+			t.Errorf("Unexpected key generated for %v: %+v", test.alg, k)
+		}
+	}
+}
+
+func Test_GenerateKey_UnsupportedAlgorithm(t *testing.T) {
+	_, err := GenerateKey("HS256")
+
+	expectSetupError(t, err, SetupErrorUnsupportedKeyAlgorithm)
+}
+
+func Test_KeySet(t *testing.T) {
+	k1, _ := GenerateKey("ES256")
+	k2, _ := GenerateKey("ES256")
+	ks := NewKeySet(k1)
+
+	ks.Add(k2)
+	ks.Add(k1)
+
+	if len(ks.Public().Keys) != 2 {
+		t.Fatal("Expected 2 keys, but got", len(ks.Public().Keys))
+	}
+
+	if k, ok := ks.Key(k2.KeyID); !ok || k.KeyID != k2.KeyID {
+		t.Error("Expected the key", k2.KeyID, "but got", k, ok)
+	}
+
+	ks.Remove(k1.KeyID)
+
+	if _, ok := ks.Key(k1.KeyID); ok {
+		t.Error("The key", k1.KeyID, "was not removed")
+	}
+}
+
+func Test_KeySet_JSON(t *testing.T) {
+	k, _ := GenerateKey("RS256")
+
+	data, err := json.Marshal(NewKeySet(k))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	var ks KeySet
+	if err := json.Unmarshal(data, &ks); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	rk, ok := ks.Key(k.KeyID)
+	if !ok || rk.IsPublic() {
+		t.Error("Expected the private key", k.KeyID, "but got", rk, ok)
+	}
+}
+
+func Test_JwksHandler(t *testing.T) {
+	k, _ := GenerateKey("RS256")
+	h := JwksHandler(NewKeySet(k))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, WellKnownJwksPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected status 200, but got", w.Code)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != k.KeyID || !jwks.Keys[0].IsPublic() {
+		t.Error("Expected the public key", k.KeyID, "but got", jwks.Keys)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, WellKnownJwksPath, nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("Expected status 405, but got", w.Code)
+	}
+}
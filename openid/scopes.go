@@ -0,0 +1,85 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const wwwAuthenticateHeaderName = "WWW-Authenticate"
+
+// RequireScopes middleware rejects the requests whose token does not grant all the given scopes,
+// through the 'scope' or 'scp' claims, with status 403/Forbidden and the insufficient_scope
+// challenge of RFC 6750 in the WWW-Authenticate header. It reads the user stored in the request
+// context, so it must be registered after AuthenticateUserContext, New or AuthenticateOptional:
+//
+//	h := openid.New(conf)(openid.RequireScopes(conf, "read:orders")(ordersHandler))
+//
+// The requests without user are rejected. The errors are handled by the ErrorHandlerFunc of conf
+// with the error code ValidationErrorInsufficientScope.
+func RequireScopes(conf *Configuration, scopes ...string) func(next http.Handler) http.Handler {
+	return authorizationMiddleware(conf, scopesChallenge(scopes), func(r *http.Request, u *User) error {
+		return requireScopes(userClaims(u), scopes)
+	})
+}
+
+// RequireAnyScope middleware rejects the requests whose token does not grant any of the given
+// scopes, as RequireScopes.
+func RequireAnyScope(conf *Configuration, scopes ...string) func(next http.Handler) http.Handler {
+	return authorizationMiddleware(conf, scopesChallenge(scopes), func(r *http.Request, u *User) error {
+		claims := userClaims(u)
+		granted := append(claimStrings(claims[scopeClaimName]), claimStrings(claims[scopesClaimName])...)
+		for _, s := range scopes {
+			if containsString(granted, s) {
+				return nil
+			}
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorInsufficientScope,
+			Message:    fmt.Sprintf("The token does not grant any of the scopes %v.", scopes),
+			HTTPStatus: http.StatusForbidden,
+		}
+	})
+}
+
+// scopesChallenge returns the insufficient_scope challenge of RFC 6750 for the scopes.
+func scopesChallenge(scopes []string) string {
+	return fmt.Sprintf("Bearer error=\"insufficient_scope\", scope=\"%v\"", strings.Join(scopes, " "))
+}
+
+// userClaims returns the claims of the user u, nil when u is nil.
+func userClaims(u *User) map[string]interface{} {
+	if u == nil {
+		return nil
+	}
+
+	return u.Claims
+}
+
+// authorizationMiddleware returns the middleware rejecting the requests whose user, read from the
+// request context, is rejected by check for the request. The challenge, when not empty, is set as the
+// WWW-Authenticate header of the rejected requests.
+func authorizationMiddleware(conf *Configuration, challenge string, check func(r *http.Request, u *User) error) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, _ := UserFromContext(r.Context())
+			if err := check(r, u); err != nil {
+				if challenge != "" {
+					w.Header().Set(wwwAuthenticateHeaderName, challenge)
+				}
+
+				eh := conf.errorHandler
+				if eh == nil {
+					eh = validationErrorToHTTPStatus
+				}
+
+				if eh(err, w, r) {
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_RequireScopes(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	all := New(c)(RequireScopes(c, "read", "write")(ok))
+	oneOf := New(c)(RequireAnyScope(c, "read", "write")(ok))
+
+	tests := []struct {
+		h      http.Handler
+		scope  interface{}
+		status int
+	}{
+		{all, "read write", http.StatusNoContent},
+		{all, "read", http.StatusForbidden},
+		{all, nil, http.StatusForbidden},
+		{oneOf, []interface{}{"write"}, http.StatusNoContent},
+		{oneOf, "admin", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		claims := map[string]interface{}{"sub": "user1", "aud": "app"}
+		if test.scope != nil {
+			claims["scope"] = test.scope
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		test.h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Expected the status %v for the scope %v, but got %v", test.status, test.scope, w.Code)
+		}
+
+		ch := w.Header().Get("WWW-Authenticate")
+		if test.status == http.StatusForbidden && ch != `Bearer error="insufficient_scope", scope="read write"` {
+			t.Error("Expected the insufficient_scope challenge, but got", ch)
+		}
+
+		if test.status != http.StatusForbidden && ch != "" {
+			t.Error("Expected no challenge, but got", ch)
+		}
+	}
+}
+
+func Test_RequireScopes_WhenUserNotFound(t *testing.T) {
+	c, _ := NewConfiguration()
+
+	w := httptest.NewRecorder()
+	RequireScopes(c, "read")(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected the request without user to be rejected, but got", w.Code)
+	}
+}
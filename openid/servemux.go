@@ -0,0 +1,179 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const scopeClaimName = "scope"
+const scopesClaimName = "scp"
+
+// Policy represents an authorization requirement enforced on the authenticated user of the
+// requests matching a pattern protected with Protect. The u is nil when the ErrorHandlerFunc
+// chose to continue after a failed authentication.
+// If the policy returns an error it is handled by the ErrorHandlerFunc of the configuration.
+type Policy func(u *User, r *http.Request) error
+
+// RequireScope returns a Policy requiring the user token to grant all the given scopes, either
+// through the 'scope' claim, holding the scopes separated by spaces, or the 'scp' claim.
+func RequireScope(scopes ...string) Policy {
+	return func(u *User, r *http.Request) error {
+		var claims map[string]interface{}
+		if u != nil {
+			claims = u.Claims
+		}
+
+		return requireScopes(claims, scopes)
+	}
+}
+
+// requireScopes returns an error when the claims do not grant all the scopes.
+func requireScopes(claims map[string]interface{}, scopes []string) error {
+	granted := append(claimStrings(claims[scopeClaimName]), claimStrings(claims[scopesClaimName])...)
+
+	for _, s := range scopes {
+		if !containsString(granted, s) {
+			return &ValidationError{
+				Code:       ValidationErrorInsufficientScope,
+				Message:    fmt.Sprintf("The token does not grant the scope %v.", s),
+				HTTPStatus: http.StatusForbidden,
+			}
+		}
+	}
+
+	return nil
+}
+
+// RequireRole returns a Policy requiring the user to have all the given roles, among the Roles
+// returned by the RolesFunc of its provider, as RequireScope and the RequireRoles middleware.
+func RequireRole(roles ...string) Policy {
+	return func(u *User, r *http.Request) error {
+		for _, role := range roles {
+			if u == nil || !containsString(u.Roles, role) {
+				return &ValidationError{
+					Code:       ValidationErrorInsufficientRole,
+					Message:    fmt.Sprintf("The user does not have the role %v.", role),
+					HTTPStatus: http.StatusForbidden,
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// ServeMux is a request multiplexer, wrapping an http.ServeMux, authenticating the requests
+// matching the patterns protected with Protect and enforcing their policies. Requests matching
+// any other pattern are served without authentication. Handlers are registered with Handle and
+// HandleFunc, so the method and wildcard patterns of Go 1.22 and later can be used:
+//
+//	mux := openid.NewServeMux(configuration)
+//	mux.HandleFunc("GET /admin/", adminHandler)
+//	mux.HandleFunc("GET /users/{id}/orders", ordersHandler)
+//	openid.Protect(mux, "GET /admin/", openid.RequireScope("admin"))
+//	openid.Protect(mux, "GET /users/{id}/orders", openid.RequirePathValue("id", "sub"))
+//
+// The requests are authenticated once matched, so the policies and handlers read the wildcards of
+// the pattern with r.PathValue. The user is stored in the request context, see UserFromContext.
+type ServeMux struct {
+	mux  *http.ServeMux
+	conf *Configuration
+
+	mu       sync.RWMutex
+	policies map[string][]Policy
+	patterns map[string]bool
+}
+
+// NewServeMux returns a new ServeMux authenticating the requests with the given configuration.
+func NewServeMux(conf *Configuration) *ServeMux {
+	return &ServeMux{
+		mux:      http.NewServeMux(),
+		conf:     conf,
+		policies: make(map[string][]Policy),
+		patterns: make(map[string]bool),
+	}
+}
+
+// Protect requires the requests matching the pattern, exactly as registered with Handle or
+// HandleFunc, to be authenticated and to satisfy all the policies. Protecting a pattern again
+// replaces its policies. Protect panics when no handler is registered for the pattern, so a
+// pattern differing from the registered one does not leave its requests unprotected.
+func Protect(mux *ServeMux, pattern string, policies ...Policy) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if !mux.patterns[pattern] {
+		panic(fmt.Sprintf("openid: no handler is registered for the protected pattern %q", pattern))
+	}
+
+	mux.policies[pattern] = policies
+}
+
+// ServeHTTP dispatches the request to the handler whose pattern most closely matches the request
+// URL, as http.ServeMux.
+func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux.mux.ServeHTTP(w, r)
+}
+
+// Handle registers the handler for the given pattern, enforcing the authentication and policies
+// of the pattern when it is protected.
+func (mux *ServeMux) Handle(pattern string, handler http.Handler) {
+	mux.mux.Handle(pattern, mux.protect(pattern, handler))
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.patterns[pattern] = true
+}
+
+// HandleFunc registers the handler function for the given pattern, enforcing the authentication
+// and policies of the pattern when it is protected.
+func (mux *ServeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	mux.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// protect returns a handler enforcing the authentication and policies of the pattern before
+// calling h. It runs once the request is matched, so the path values of the request are set.
+func (mux *ServeMux) protect(pattern string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.mu.RLock()
+		policies, protected := mux.policies[pattern]
+		mux.mu.RUnlock()
+
+		if !protected {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var eh ErrorHandlerFunc
+		if mux.conf.errorHandler == nil {
+			eh = validationErrorToHTTPStatus
+		} else {
+			eh = mux.conf.errorHandler
+		}
+
+		ar, u, halt := authenticateUser(mux.conf, w, r)
+		if halt {
+			return
+		}
+
+		for _, p := range policies {
+			if err := p(u, ar); err != nil && eh(err, w, ar) {
+				return
+			}
+		}
+
+		h.ServeHTTP(w, ar)
+	})
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
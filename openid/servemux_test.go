@@ -0,0 +1,134 @@
+//go:build go1.22
+// +build go1.22
+
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		claims  map[string]interface{}
+		scopes  []string
+		allowed bool
+	}{
+		{map[string]interface{}{"scope": "read admin"}, []string{"admin"}, true},
+		{map[string]interface{}{"scope": "read admin"}, []string{"admin", "read"}, true},
+		{map[string]interface{}{"scp": []interface{}{"read", "admin"}}, []string{"admin"}, true},
+		{map[string]interface{}{"scope": "read"}, []string{"admin"}, false},
+		{map[string]interface{}{"scope": "read admin"}, []string{"admin", "write"}, false},
+		{map[string]interface{}{}, []string{"admin"}, false},
+	}
+
+	for i, test := range tests {
+		e := RequireScope(test.scopes...)(&User{Claims: test.claims}, nil)
+
+		if test.allowed {
+			if e != nil {
+				t.Error("Test", i, "an error was returned but not expected", e)
+			}
+			continue
+		}
+
+		expectValidationError(t, e, ValidationErrorInsufficientScope, http.StatusForbidden, nil)
+	}
+}
+
+func TestRequireScope_WhenUserNil(t *testing.T) {
+	e := RequireScope("admin")(nil, nil)
+
+	expectValidationError(t, e, ValidationErrorInsufficientScope, http.StatusForbidden, nil)
+}
+
+func TestRequireRole(t *testing.T) {
+	if e := RequireRole("admin", "editor")(&User{Roles: []string{"viewer", "editor", "admin"}}, nil); e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	e := RequireRole("admin", "editor")(&User{Roles: []string{"viewer", "editor"}}, nil)
+	expectValidationError(t, e, ValidationErrorInsufficientRole, http.StatusForbidden, nil)
+
+	e = RequireRole("admin")(&User{Roles: []string{"viewer"}}, nil)
+	expectValidationError(t, e, ValidationErrorInsufficientRole, http.StatusForbidden, nil)
+
+	e = RequireRole("admin")(nil, nil)
+	expectValidationError(t, e, ValidationErrorInsufficientRole, http.StatusForbidden, nil)
+}
+
+func TestServeMux_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		scope  string
+		status int
+	}{
+		{http.MethodGet, "/public", "", http.StatusOK},
+		{http.MethodGet, "/admin/users", "admin", http.StatusOK},
+		{http.MethodGet, "/admin/users", "read", http.StatusForbidden},
+		{http.MethodPost, "/admin/users", "read", http.StatusOK},
+		{http.MethodGet, "/items/1", "read", http.StatusOK},
+		{http.MethodGet, "/items/1", "", http.StatusBadRequest},
+		{http.MethodGet, "/users/user1", "read", http.StatusOK},
+		{http.MethodGet, "/users/user2", "read", http.StatusForbidden},
+	}
+
+	for i, test := range tests {
+		vm := &mockJwtTokenValidator{}
+		c, _ := NewConfiguration()
+		c.tokenValidator = vm
+		if test.scope != "" {
+			jt := jwt.New(jwt.SigningMethodRS256)
+			jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
+			jt.Claims.(jwt.MapClaims)["sub"] = "user1"
+			jt.Claims.(jwt.MapClaims)["scope"] = test.scope
+			vm.On("validate", mock.Anything, "token").Return(jt, &Provider{Issuer: "https://issuer"}, nil)
+		}
+
+		mux := NewServeMux(c)
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		mux.Handle("/public", ok)
+		mux.Handle("GET /admin/", ok)
+		mux.Handle("POST /admin/", ok)
+		mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("id") != "1" {
+				t.Error("Test", i, "expected the path value 1, but got", r.PathValue("id"))
+			}
+		})
+		mux.Handle("GET /users/{id}", ok)
+		Protect(mux, "GET /users/{id}", RequirePathValue("id", "sub"))
+		Protect(mux, "GET /admin/", RequireScope("admin"))
+		Protect(mux, "GET /items/{id}")
+
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.scope != "" {
+			r.Header.Set("Authorization", "Bearer token")
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Error("Test", i, "expected status", test.status, "but got", w.Code)
+		}
+	}
+}
+
+func TestProtect_WhenPatternNotRegistered(t *testing.T) {
+	c, _ := NewConfiguration()
+	mux := NewServeMux(c)
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Protect to panic for a pattern without handler")
+		}
+	}()
+
+	Protect(mux, "GET /admin/", RequireScope("admin"))
+}
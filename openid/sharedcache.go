@@ -0,0 +1,167 @@
+package openid
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SharedCache is the interface implemented by the caches storing values outside of the process,
+// i.e.: in Redis, so a fleet of service instances shares the documents retrieved from the providers.
+// Implementations must be safe for concurrent use and should treat their own failures as cache misses.
+//
+// Get returns the value stored with the key, or false when there is none or it expired.
+// Set stores the value v with the key for ttl, or until deleted when ttl is zero.
+// Delete removes the value stored with the key, if any.
+type SharedCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, v []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// NewSharedProviderCache returns a Cache, to be registered with the ProviderCache option, storing
+// the discovery documents and signing keys as JSON in the shared cache sc:
+//
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	                                openid.ProviderCache(openid.NewSharedProviderCache(sc)))
+//
+// Values that cannot be decoded, i.e.: stored by a different version of this package, are
+// treated as cache misses.
+func NewSharedProviderCache(sc SharedCache) Cache {
+	return &sharedProviderCache{sc}
+}
+
+type sharedProviderCache struct {
+	cache SharedCache
+}
+
+// The kinds of the values stored in a shared cache.
+const (
+	sharedSigningKeys   = "keys"
+	sharedConfiguration = "configuration"
+	sharedJwks          = "jwks"
+)
+
+// sharedCacheEntry is the JSON representation of the values stored in a shared cache.
+type sharedCacheEntry struct {
+	Kind string `json:"kind"`
+
+	// Signing keys.
+	Keys    []sharedSigningKey `json:"keys,omitempty"`
+	Expiry  time.Time          `json:"expiry,omitempty"`
+	Flushed bool               `json:"flushed,omitempty"`
+
+	// Documents of conditional requests.
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Document     json.RawMessage `json:"document,omitempty"`
+}
+
+// sharedSigningKey is the JSON representation of a signing key, its Key is the DER encoding
+// of the public key.
+type sharedSigningKey struct {
+	KeyID     string   `json:"kid,omitempty"`
+	Key       []byte   `json:"key"`
+	Audiences []string `json:"aud,omitempty"`
+	X5t       string   `json:"x5t,omitempty"`
+	X5tS256   string   `json:"x5t#S256,omitempty"`
+}
+
+func (c *sharedProviderCache) Get(key string) (interface{}, bool) {
+	data, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return decodeCacheValue(data)
+}
+
+func (c *sharedProviderCache) Set(key string, v interface{}, ttl time.Duration) {
+	if data, ok := encodeCacheValue(v); ok {
+		c.cache.Set(key, data, ttl)
+	}
+}
+
+func (c *sharedProviderCache) Delete(key string) {
+	c.cache.Delete(key)
+}
+
+// decodeCacheValue returns the value of a Cache encoded by encodeCacheValue.
+func decodeCacheValue(data []byte) (interface{}, bool) {
+	var e sharedCacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false
+	}
+
+	switch e.Kind {
+	case sharedSigningKeys:
+		ck := &cachedSigningKeys{keys: make([]signingKey, len(e.Keys)), expiry: e.Expiry, flushed: e.Flushed}
+		for i, k := range e.Keys {
+			pk, err := parsePublicKey(k.Key)
+			if err != nil {
+				return nil, false
+			}
+
+			ck.keys[i] = signingKey{keyID: k.KeyID, key: pk, audiences: k.Audiences, x5t: k.X5t, x5tS256: k.X5tS256}
+		}
+
+		return ck, true
+	case sharedConfiguration:
+		var conf configuration
+		if err := json.Unmarshal(e.Document, &conf); err != nil {
+			return nil, false
+		}
+
+		return &conditionalEntry{etag: e.ETag, lastModified: e.LastModified, value: conf}, true
+	case sharedJwks:
+		var jwks jsonWebKeySet
+		if err := json.Unmarshal(e.Document, &jwks); err != nil {
+			return nil, false
+		}
+
+		return &conditionalEntry{etag: e.ETag, lastModified: e.LastModified, value: jwks}, true
+	}
+
+	return nil, false
+}
+
+// encodeCacheValue returns the JSON representation of a value of a Cache, or false when
+// the value is not one stored by this package.
+func encodeCacheValue(v interface{}) ([]byte, bool) {
+	var e sharedCacheEntry
+
+	switch v := v.(type) {
+	case *cachedSigningKeys:
+		e = sharedCacheEntry{Kind: sharedSigningKeys, Expiry: v.expiry, Flushed: v.flushed}
+		for _, k := range v.keys {
+			der, err := marshalPublicKey(k.key)
+			if err != nil {
+				return nil, false
+			}
+
+			e.Keys = append(e.Keys, sharedSigningKey{k.keyID, der, k.audiences, k.x5t, k.x5tS256})
+		}
+	case *conditionalEntry:
+		e = sharedCacheEntry{ETag: v.etag, LastModified: v.lastModified}
+
+		switch v.value.(type) {
+		case configuration:
+			e.Kind = sharedConfiguration
+		case jsonWebKeySet:
+			e.Kind = sharedJwks
+		default:
+			return nil, false
+		}
+
+		doc, err := json.Marshal(v.value)
+		if err != nil {
+			return nil, false
+		}
+
+		e.Document = doc
+	default:
+		return nil, false
+	}
+
+	data, err := json.Marshal(e)
+	return data, err == nil
+}
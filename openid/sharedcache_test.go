@@ -0,0 +1,124 @@
+package openid
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSharedCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *fakeSharedCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *fakeSharedCache) Set(key string, v []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] = v
+}
+
+func (c *fakeSharedCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.values, key)
+}
+
+func Test_sharedProviderCache_RoundTrips(t *testing.T) {
+	c := NewSharedProviderCache(&fakeSharedCache{values: make(map[string][]byte)})
+	exp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	pk := createPublicKey(t)
+	c.Set("keys", &cachedSigningKeys{keys: []signingKey{{keyID: "kid1", key: pk, audiences: []string{"client1"}, x5t: "t1", x5tS256: "s1"}}, expiry: exp, flushed: true}, 0)
+
+	v, ok := c.Get("keys")
+	ck, _ := v.(*cachedSigningKeys)
+	if !ok || ck == nil || len(ck.keys) != 1 || ck.keys[0].keyID != "kid1" || keyString(ck.keys[0].key) != keyString(pk) ||
+		ck.keys[0].audiences[0] != "client1" || ck.keys[0].x5t != "t1" || ck.keys[0].x5tS256 != "s1" || !ck.expiry.Equal(exp) || !ck.flushed {
+		t.Errorf("Expected the cached signing keys, but got %+v", v)
+	}
+
+	c.Set("conf", &conditionalEntry{etag: `"v1"`, value: configuration{Issuer: "https://issuer", JwksURI: "https://issuer/jwks"}}, 0)
+
+	v, ok = c.Get("conf")
+	ce, _ := v.(*conditionalEntry)
+	if !ok || ce == nil || ce.etag != `"v1"` || ce.value.(configuration).JwksURI != "https://issuer/jwks" {
+		t.Errorf("Expected the cached configuration, but got %+v", v)
+	}
+
+	var k jsonWebKey
+	if err := k.UnmarshalJSON([]byte(`{"kty":"oct","kid":"kid1","k":"c2VjcmV0","client_id":"client1"}`)); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	c.Set("jwks", &conditionalEntry{lastModified: "Wed, 01 Jan 2020 00:00:00 GMT", value: jsonWebKeySet{Keys: []jsonWebKey{k}}}, 0)
+
+	v, ok = c.Get("jwks")
+	ce, _ = v.(*conditionalEntry)
+	if !ok || ce == nil || ce.lastModified != "Wed, 01 Jan 2020 00:00:00 GMT" {
+		t.Fatalf("Expected the cached jwk set, but got %+v", v)
+	}
+
+	keys := ce.value.(jsonWebKeySet).Keys
+	if len(keys) != 1 || keys[0].KeyID != "kid1" || keys[0].members["client_id"] != "client1" {
+		t.Errorf("Expected the key kid1 with its members, but got %+v", keys)
+	}
+
+	c.Delete("jwks")
+
+	if _, ok := c.Get("jwks"); ok {
+		t.Error("Expected the jwk set to be deleted.")
+	}
+}
+
+func Test_sharedProviderCache_WhenValueInvalid(t *testing.T) {
+	sc := &fakeSharedCache{values: map[string][]byte{"k1": []byte("not json"), "k2": []byte(`{"kind":"unknown"}`)}}
+	c := NewSharedProviderCache(sc)
+
+	for _, k := range []string{"k1", "k2"} {
+		if v, ok := c.Get(k); ok {
+			t.Error("Expected a cache miss, but got", v)
+		}
+	}
+}
+
+func Test_sharedProviderCache_SharesKeysBetweenConfigurations(t *testing.T) {
+	sc := &fakeSharedCache{values: make(map[string][]byte)}
+
+	c1, _ := NewConfiguration(ProviderCache(NewSharedProviderCache(sc)))
+	c2, _ := NewConfiguration(ProviderCache(NewSharedProviderCache(sc)))
+
+	kp1 := c1.idTokenValidator().keyGetter.(*signingKeyProvider)
+	kg := &mockSigningKeySetGetter{}
+	kp1.keySetGetter = kg
+	pk := createPublicKey(t)
+	kg.On("get", (*http.Request)(nil), &Provider{Issuer: "issuer"}).Return([]signingKey{{keyID: "kid1", key: pk}}, time.Hour, nil).Once()
+
+	expectKey(t, kp1, "issuer", "kid1", keyString(pk))
+
+	// The second configuration finds the keys retrieved by the first one.
+	expectKey(t, c2.idTokenValidator().keyGetter, "issuer", "kid1", keyString(pk))
+
+	kg.AssertExpectations(t)
+}
+
+func Test_sharedProviderCache_Set_WhenKeyNotSupported(t *testing.T) {
+	sc := &fakeSharedCache{values: make(map[string][]byte)}
+	c := NewSharedProviderCache(sc)
+
+	c.Set("keys", &cachedSigningKeys{keys: []signingKey{{keyID: "kid1", key: []byte("secret")}}}, 0)
+
+	if _, ok := c.Get("keys"); ok || len(sc.values) != 0 {
+		t.Error("Expected the keys not to be stored, but got", sc.values)
+	}
+}
@@ -1,33 +1,40 @@
 package openid
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"net/http"
 )
 
-type pemEncoder interface {
-	encode(key interface{}) ([]byte, error)
+// publicKey returns the key of a jwk, ready to verify the signatures of the tokens, when it
+// is a supported public key, RSA or ECDSA.
+func publicKey(key interface{}) (interface{}, error) {
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+
+	return nil, &ValidationError{
+		Code:       ValidationErrorMarshallingKey,
+		Message:    fmt.Sprintf("The jwk key of type %T is not a supported public key.", key),
+		HTTPStatus: http.StatusInternalServerError,
+	}
 }
 
-type pemPublicKeyEncoder struct {
+// marshalPublicKey returns the DER encoding of a public key returned by publicKey, stored
+// in the caches shared between processes.
+func marshalPublicKey(key interface{}) ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(key)
 }
 
-func (e *pemPublicKeyEncoder) encode(key interface{}) ([]byte, error) {
-	mk, err := x509.MarshalPKIXPublicKey(key)
+// parsePublicKey returns the public key encoded by marshalPublicKey.
+func parsePublicKey(der []byte) (interface{}, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
 	if err != nil {
-		return nil, &ValidationError{
-			Code:       ValidationErrorMarshallingKey,
-			Message:    fmt.Sprint("The jwk key could not be marshalled."),
-			Err:        err,
-			HTTPStatus: http.StatusInternalServerError,
-		}
+		return nil, err
 	}
 
-	ed := pem.EncodeToMemory(&pem.Block{
-		Bytes: mk,
-	})
-
-	return ed, nil
+	return publicKey(key)
 }
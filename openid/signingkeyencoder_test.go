@@ -1,70 +1,62 @@
 package openid
 
 import (
+	"crypto/ecdsa"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"math/big"
 	"net/http"
 	"testing"
 )
 
-func TestPemPublicKeyEncoder_Encode_ReturnsMarshallingKeyError(t *testing.T) {
-	e := &pemPublicKeyEncoder{}
-	_, err := e.encode(nil)
+func Test_publicKey_WhenNotSupported(t *testing.T) {
+	for _, k := range []interface{}{nil, []byte("secret"), &rsa.PrivateKey{}} {
+		_, err := publicKey(k)
 
-	if err == nil {
-		t.Fatal("An error was expected but not returned.")
+		expectValidationError(t, err, ValidationErrorMarshallingKey, http.StatusInternalServerError, nil)
 	}
-
-	expectValidationError(t, err, ValidationErrorMarshallingKey, http.StatusInternalServerError, nil)
-
 }
 
-func TestPemPublicKeyEncoder_Encode_UsingRSAPublicKey(t *testing.T) {
-	rsaKey := &rsa.PublicKey{N: big.NewInt(9871234), E: 15}
-
-	e := &pemPublicKeyEncoder{}
-	ek, err := e.encode(rsaKey)
+func Test_publicKey_WhenSupported(t *testing.T) {
+	rk, _ := GenerateKey("RS256")
+	ek, _ := GenerateKey("ES256")
 
-	if err != nil {
-		t.Error("An error was not expected but returned.")
-	}
+	for _, k := range []interface{}{rk.Public().Key, ek.Public().Key} {
+		pk, err := publicKey(k)
 
-	if ek == nil {
-		t.Error("The encoded key should not be nil.")
+		if err != nil || pk != k {
+			t.Errorf("Expected the key %T to be returned as is, but got %v %v", k, pk, err)
+		}
 	}
+}
 
-	pBlock, rest := pem.Decode(ek)
+func Test_marshalPublicKey_RoundTrips(t *testing.T) {
+	rsaKey := &rsa.PublicKey{N: big.NewInt(9871234), E: 15}
 
-	if pBlock == nil {
-		t.Fatal("A pem block was not found in the encoded key.")
+	der, err := marshalPublicKey(rsaKey)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
 	}
 
-	if len(rest) != 0 {
-		t.Errorf("The encoded key was not fully pem decoded. Remaining buffer len %v.", len(rest))
+	pub, err := parsePublicKey(der)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
 	}
 
-	pub, err := x509.ParsePKIXPublicKey(pBlock.Bytes)
-
-	if err != nil {
-		t.Errorf("Parsing the key as DER public key returned the error %v.", err)
+	if rpk, ok := pub.(*rsa.PublicKey); !ok || rpk.N.Cmp(rsaKey.N) != 0 || rpk.E != rsaKey.E {
+		t.Errorf("Expected the key %+v, but got %+v", rsaKey, pub)
 	}
 
-	if pub == nil {
-		t.Fatal("The key could not be parsed as a DER public key.")
+	ek, _ := GenerateKey("ES256")
+	der, _ = marshalPublicKey(ek.Public().Key)
+	if pub, err := parsePublicKey(der); err != nil {
+		t.Error("An error was returned but not expected", err)
+	} else if _, ok := pub.(*ecdsa.PublicKey); !ok {
+		t.Errorf("Expected an ECDSA key, but got %T", pub)
 	}
+}
 
-	if rpk, ok := pub.(*rsa.PublicKey); ok {
-		rn := rpk.N.Int64()
-		en := rsaKey.N.Int64()
-		if en != rn {
-			t.Error("Expected N", en, "but got", rn)
-		}
-		if rpk.E != rsaKey.E {
-			t.Error("Expected E", rsaKey.E, "but got", rpk.E)
-		}
-	} else {
-		t.Errorf("Expected public key type '*rsa.PublicKey' but got %T.", pub)
+func Test_parsePublicKey_WhenInvalid(t *testing.T) {
+	if _, err := parsePublicKey([]byte("not a key")); err == nil {
+		t.Error("An error was expected but not returned")
 	}
 }
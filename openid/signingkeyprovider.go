@@ -3,58 +3,324 @@ package openid
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
+)
+
+// The default bounds of the time the signing keys of a provider are cached. The lifetime
+// advertised by the jwks_uri response, through the Cache-Control or Expires headers, is clamped
+// to them and the maximum is used when the response advertises none.
+const (
+	defaultMinJwksCacheTTL = time.Minute
+	defaultMaxJwksCacheTTL = 24 * time.Hour
 )
 
 type signingKeyGetter interface {
 	flushCachedSigningKeys(issuer string) error
-	getSigningKey(r *http.Request, issuer string, kid string) ([]byte, error)
+	getSigningKey(r *http.Request, p *Provider, ks keySelector) (interface{}, error)
+}
+
+// keySelector contains the information from a token used to select its signing key. The key is
+// identified by the kid or, in its absence, by the thumbprint of its certificate, x5t#S256 or x5t.
+type keySelector struct {
+	kid      string
+	audience string
+	x5t      string
+	x5tS256  string
+}
+
+// identifier returns the description of the identifier of the key in the error messages.
+func (ks keySelector) identifier() string {
+	switch {
+	case ks.kid != "":
+		return ks.kid
+	case ks.x5tS256 != "":
+		return "with the x5t#S256 " + ks.x5tS256
+	case ks.x5t != "":
+		return "with the x5t " + ks.x5t
+	}
+
+	return ""
+}
+
+// signingKeysCacheKeyPrefix prefixes the issuers of the signing keys in the cache.
+const signingKeysCacheKeyPrefix = "keys "
+
+// cachedSigningKeys are the signing keys of an issuer stored in the cache until expiry. The
+// flushed keys are only used when they cannot be retrieved again.
+type cachedSigningKeys struct {
+	keys    []signingKey
+	expiry  time.Time
+	flushed bool
 }
 
 type signingKeyProvider struct {
 	keySetGetter signingKeySetGetter
-	jwksMap      map[string][]signingKey
+	cache        Cache
+	minTTL       time.Duration
+	maxTTL       time.Duration
+	now          func() time.Time
+	mu           sync.Mutex
+
+	// keepExpired keeps serving the expired keys while the background refresh,
+	// see BackgroundKeyRefresh, retrieves them again.
+	keepExpired bool
+
+	// staleGrace is the time the keys are served after expiring while they are retrieved again in
+	// the background, at most once every retryInterval, see StaleKeys. With StaleKeys or
+	// JwksRefreshLimit the flushed keys are not deleted but marked as such, so they can still be
+	// served when they cannot be retrieved again.
+	staleGrace    time.Duration
+	retryInterval time.Duration
+	retries       map[string]time.Time
+
+	// rotationHook is notified when the identifiers of the keys of an issuer, recorded in keyIDs,
+	// change, see KeyRotationHook.
+	rotationHook KeyRotationFunc
+	keyIDs       map[string][]string
+
+	// refreshLimit is the minimum time between two forced retrievals of the keys of an issuer,
+	// see JwksRefreshLimit, and forced the time of the last one.
+	refreshLimit time.Duration
+	forced       map[string]time.Time
 }
 
 func newSigningKeyProvider(kg signingKeySetGetter) *signingKeyProvider {
-	keyMap := make(map[string][]signingKey)
-	return &signingKeyProvider{kg, keyMap}
+	return &signingKeyProvider{
+		keySetGetter: kg,
+		cache:        NewMemoryCache(0),
+		retries:      make(map[string]time.Time),
+		forced:       make(map[string]time.Time),
+		minTTL:       defaultMinJwksCacheTTL,
+		maxTTL:       defaultMaxJwksCacheTTL,
+		now:          time.Now,
+	}
+}
+
+// JwksCaching option bounds the time the signing keys of each provider are cached. The keys
+// are cached for the lifetime advertised by the Cache-Control or Expires headers of the jwks_uri
+// response, clamped between minTTL and maxTTL, or for maxTTL when the response advertises none.
+// The defaults are 1 minute and 24 hours. Regardless of the lifetime, the keys are retrieved again
+// when a token is signed by a key not found among the cached ones.
+func JwksCaching(minTTL time.Duration, maxTTL time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if minTTL < 0 || minTTL > maxTTL {
+			return &SetupError{
+				Code:    SetupErrorInvalidCacheBounds,
+				Message: fmt.Sprintf("The minimum cache lifetime %v must not be negative nor greater than the maximum %v.", minTTL, maxTTL),
+			}
+		}
+
+		kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+		kp.minTTL, kp.maxTTL = minTTL, maxTTL
+		return nil
+	}
+}
+
+// StaleKeys option keeps validating tokens with the last signing keys retrieved from a provider
+// for grace after they expire, while they are retrieved again in the background at most once every
+// retryInterval, so an outage of the provider does not fail the requests. The expired keys are
+// also used when the keys cannot be retrieved again on demand, i.e.: to look for a rotated key,
+// until grace elapses. Once it elapses the keys are only retrieved on demand, as when the option is
+// not used. The background retrievals are not made on behalf of a request, so the HTTPGetFunc and
+// the JwksCredentials receive a nil request.
+func StaleKeys(grace time.Duration, retryInterval time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if grace <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidCacheBounds,
+				Message: fmt.Sprintf("The grace period %v of the stale keys must be positive.", grace),
+			}
+		}
+
+		if retryInterval <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidRefreshInterval,
+				Message: fmt.Sprintf("The retry interval %v of the stale keys must be positive.", retryInterval),
+			}
+		}
+
+		kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+		kp.staleGrace, kp.retryInterval = grace, retryInterval
+		return nil
+	}
+}
+
+// JwksRefreshLimit option limits the retrievals of the signing keys of each provider forced by
+// tokens, i.e.: signed by a key ID not found among the cached keys, to one every interval, so
+// tokens with random key IDs cannot make the service contact the provider constantly. The tokens
+// forcing a retrieval before interval elapses are rejected immediately with the error code
+// ValidationErrorKidNotFound, or validated with the cached keys when signed by a known key ID.
+// The retrievals of missing or expired keys are not limited.
+func JwksRefreshLimit(interval time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		if interval <= 0 {
+			return &SetupError{
+				Code:    SetupErrorInvalidRefreshInterval,
+				Message: fmt.Sprintf("The jwks refresh limit %v must be positive.", interval),
+			}
+		}
+
+		c.idTokenValidator().keyGetter.(*signingKeyProvider).refreshLimit = interval
+		return nil
+	}
 }
 
 func (s *signingKeyProvider) flushCachedSigningKeys(issuer string) error {
-	delete(s.jwksMap, issuer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ck, ok := s.cachedKeys(issuer)
+	if !ok {
+		return nil
+	}
+
+	if s.staleGrace > 0 || s.refreshLimit > 0 {
+		s.store(issuer, &cachedSigningKeys{ck.keys, ck.expiry, true})
+		return nil
+	}
+
+	s.cache.Delete(signingKeysCacheKeyPrefix + issuer)
 	return nil
 }
 
-func (s *signingKeyProvider) refreshSigningKeys(r *http.Request, issuer string) error {
-	skeys, err := s.keySetGetter.get(r, issuer)
+func (s *signingKeyProvider) refreshSigningKeys(r *http.Request, p *Provider) error {
+	skeys, lifetime, err := s.keySetGetter.get(r, p)
 
 	if err != nil {
 		return err
 	}
 
-	s.jwksMap[issuer] = skeys
+	if lifetime == unknownLifetime || lifetime > s.maxTTL {
+		lifetime = s.maxTTL
+	} else if lifetime < s.minTTL {
+		lifetime = s.minTTL
+	}
+
+	s.mu.Lock()
+	s.store(p.keysIssuer(), &cachedSigningKeys{keys: skeys, expiry: s.now().Add(lifetime)})
+	ids := keyIdentifiers(skeys)
+	previous, rotated := s.rotated(p.keysIssuer(), ids)
+	s.mu.Unlock()
+
+	if rotated {
+		s.rotationHook(p.Issuer, previous, ids)
+	}
+
 	return nil
 }
 
-func (s *signingKeyProvider) getSigningKey(r *http.Request, issuer string, kid string) ([]byte, error) {
-	sk := findKey(s.jwksMap, issuer, kid)
+// cachedKeys returns the signing keys of the issuer stored in the cache.
+func (s *signingKeyProvider) cachedKeys(issuer string) (*cachedSigningKeys, bool) {
+	v, ok := s.cache.Get(signingKeysCacheKeyPrefix + issuer)
+	if !ok {
+		return nil, false
+	}
+
+	ck, ok := v.(*cachedSigningKeys)
+	return ck, ok
+}
+
+// store stores the signing keys of the issuer in the cache as long as they can be used,
+// or until replaced while the background refresh keeps the expired keys.
+func (s *signingKeyProvider) store(issuer string, ck *cachedSigningKeys) {
+	var ttl time.Duration
+	if !s.keepExpired {
+		if ttl = ck.expiry.Add(s.staleGrace).Sub(s.now()); ttl <= 0 {
+			s.cache.Delete(signingKeysCacheKeyPrefix + issuer)
+			return
+		}
+	}
+
+	s.cache.Set(signingKeysCacheKeyPrefix+issuer, ck, ttl)
+}
+
+// cachedKey returns the key selected by ks among the cached keys of the issuer, unless
+// they have expired or were flushed.
+func (s *signingKeyProvider) cachedKey(issuer string, ks keySelector) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ck, ok := s.cachedKeys(issuer)
+	if !ok || ck.flushed || (!s.keepExpired && !s.now().Before(ck.expiry)) {
+		return nil
+	}
+
+	return findKey(ck.keys, ks)
+}
+
+// staleKey returns the key selected by ks among the cached keys of the issuer, expired or
+// flushed, until the grace period of the StaleKeys option elapses after they expire, along
+// with whether the keys were flushed.
+func (s *signingKeyProvider) staleKey(issuer string, ks keySelector) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ck, ok := s.cachedKeys(issuer)
+	if !ok || !s.now().Before(ck.expiry.Add(s.staleGrace)) {
+		return nil, false
+	}
+
+	return findKey(ck.keys, ks), ck.flushed
+}
+
+// revalidate retrieves the keys of the provider in the background, unless they were retrieved
+// less than retryInterval ago.
+func (s *signingKeyProvider) revalidate(p *Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Before(s.retries[p.keysIssuer()]) {
+		return
+	}
+
+	s.retries[p.keysIssuer()] = now.Add(s.retryInterval)
+
+	pc := *p
+	go s.refreshSigningKeys(nil, &pc)
+}
+
+func (s *signingKeyProvider) getSigningKey(r *http.Request, p *Provider, ks keySelector) (interface{}, error) {
+	sk := s.cachedKey(p.keysIssuer(), ks)
 
 	if sk != nil {
 		return sk, nil
 	}
 
-	err := s.refreshSigningKeys(r, issuer)
+	if s.staleGrace > 0 {
+		sk, flushed := s.staleKey(p.keysIssuer(), ks)
+		if sk != nil && !flushed {
+			s.revalidate(p)
+			return sk, nil
+		}
+	}
+
+	var err error
+	if s.allowRefresh(p.keysIssuer()) {
+		err = s.refreshSigningKeys(r, p)
+	} else {
+		err = &ValidationError{
+			Code:       ValidationErrorKidNotFound,
+			Message:    fmt.Sprintf("The cached jwk set of the issuer %v does not contain a key identifier %v and was retrieved less than %v ago.", p.Issuer, ks.identifier(), s.refreshLimit),
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
 
 	if err != nil {
+		if sk, _ = s.staleKey(p.keysIssuer(), ks); sk != nil {
+			return sk, nil
+		}
+
 		return nil, err
 	}
 
-	sk = findKey(s.jwksMap, issuer, kid)
+	sk = s.cachedKey(p.keysIssuer(), ks)
 
 	if sk == nil {
 		return nil, &ValidationError{
 			Code:       ValidationErrorKidNotFound,
-			Message:    fmt.Sprintf("The jwk set retrieved for the issuer %v does not contain a key identifier %v.", issuer, kid),
+			Message:    fmt.Sprintf("The jwk set retrieved for the issuer %v does not contain a key identifier %v.", p.Issuer, ks.identifier()),
 			HTTPStatus: http.StatusUnauthorized,
 		}
 	}
@@ -62,17 +328,92 @@ func (s *signingKeyProvider) getSigningKey(r *http.Request, issuer string, kid s
 	return sk, nil
 }
 
-func findKey(km map[string][]signingKey, issuer string, kid string) []byte {
-	if skSet, ok := km[issuer]; ok {
-		if kid == "" {
-			return skSet[0].key
+// allowRefresh returns false when retrieving the keys of the issuer would be forced, the cached
+// keys not being expired, less than refreshLimit after the previous forced retrieval.
+func (s *signingKeyProvider) allowRefresh(issuer string) bool {
+	if s.refreshLimit <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	ck, ok := s.cachedKeys(issuer)
+	if !ok || (!s.keepExpired && !now.Before(ck.expiry)) {
+		return true
+	}
+
+	if now.Before(s.forced[issuer].Add(s.refreshLimit)) {
+		return false
+	}
+
+	s.forced[issuer] = now
+	return true
+}
+
+// keyCandidates are the signing keys that may have signed a token, returned when the token has no
+// identifier, or an identifier matching none of the keys while some keys have none. The key verifying
+// the signature of the token is used, see keyCandidates.verifying.
+type keyCandidates []interface{}
+
+// findKey returns the key identified by the selector allowing its audience or, when there is none,
+// the candidates: all the keys when the selector has no identifier, the keys without an identifier of
+// the same kind otherwise.
+func findKey(keys []signingKey, ks keySelector) interface{} {
+	var candidates keyCandidates
+	for _, sk := range keys {
+		if !sk.allowsAudience(ks.audience) {
+			continue
 		}
-		for _, sk := range skSet {
-			if sk.keyID == kid {
-				return sk.key
-			}
+
+		match, candidate := sk.selectedBy(ks)
+		if match {
+			return sk.key
+		}
+
+		if candidate {
+			candidates = append(candidates, sk.key)
 		}
 	}
 
-	return nil
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+
+	return candidates
+}
+
+// selectedBy returns whether the key is the one identified by the selector, by kid or by the
+// thumbprint of its certificate, and whether it may be the one, lacking an identifier of that kind.
+func (sk signingKey) selectedBy(ks keySelector) (match bool, candidate bool) {
+	switch {
+	case ks.kid != "":
+		return sk.keyID == ks.kid, sk.keyID == ""
+	case ks.x5tS256 != "":
+		return sk.x5tS256 == ks.x5tS256, sk.x5tS256 == ""
+	case ks.x5t != "":
+		return sk.x5t == ks.x5t, sk.x5t == ""
+	}
+
+	return false, true
+}
+
+// allowsAudience returns true if the key is not restricted to any client or if
+// it is restricted to the given audience.
+func (sk signingKey) allowsAudience(aud string) bool {
+	if len(sk.audiences) == 0 {
+		return true
+	}
+
+	for _, a := range sk.audiences {
+		if a == aud {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,8 +1,12 @@
 package openid
 
 import (
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_getSigningKey_WhenKeyIsCached(t *testing.T) {
@@ -11,7 +15,7 @@ func Test_getSigningKey_WhenKeyIsCached(t *testing.T) {
 	iss := "issuer"
 	kid := "kid1"
 	key := "signingKey"
-	keyCache.jwksMap[iss] = []signingKey{{keyID: kid, key: []byte(key)}}
+	cacheKeys(keyCache, iss, []signingKey{{keyID: kid, key: []byte(key)}})
 
 	expectKey(t, keyCache, iss, kid, key)
 }
@@ -22,9 +26,9 @@ func Test_getSigningKey_WhenKeyIsNotCached_WhenProviderReturnsKey(t *testing.T)
 	iss := "issuer"
 	kid := "kid1"
 	key := "signingKey"
-	keyGetter.On("get", (*http.Request)(nil), iss).Return([]signingKey{{keyID: kid, key: []byte(key)}}, nil)
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, unknownLifetime, nil)
 
-	// rk, re := keyCache.getSigningKey(nil, iss, kid)
+	// rk, re := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: kid})
 	expectKey(t, keyCache, iss, kid, key)
 
 	// Validate that the key is cached
@@ -40,9 +44,9 @@ func Test_getSigningKey_WhenProviderReturnsError(t *testing.T) {
 	kid := "kid1"
 	ee := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusUnauthorized}
 
-	keyGetter.On("get", (*http.Request)(nil), iss).Return(nil, ee)
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return(nil, time.Duration(0), ee)
 
-	rk, re := keyCache.getSigningKey(nil, iss, kid)
+	rk, re := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: kid})
 
 	expectValidationError(t, re, ee.Code, ee.HTTPStatus, nil)
 
@@ -50,8 +54,8 @@ func Test_getSigningKey_WhenProviderReturnsError(t *testing.T) {
 		t.Error("A key was returned but not expected")
 	}
 
-	cachedKeys := keyCache.jwksMap[iss]
-	if len(cachedKeys) != 0 {
+	ck := cachedKeys(keyCache, iss)
+	if len(ck) != 0 {
 		t.Fatal("There shouldnt be cached keys for the targeted issuer.")
 	}
 
@@ -66,9 +70,9 @@ func Test_getSigningKey_WhenKeyIsNotFound(t *testing.T) {
 	tkid := "kid2"
 	key := "signingKey"
 
-	keyGetter.On("get", (*http.Request)(nil), iss).Return([]signingKey{{keyID: kid, key: []byte(key)}}, nil)
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, unknownLifetime, nil)
 
-	rk, re := keyCache.getSigningKey(nil, iss, tkid)
+	rk, re := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: tkid})
 
 	expectValidationError(t, re, ValidationErrorKidNotFound, http.StatusUnauthorized, nil)
 
@@ -88,12 +92,12 @@ func Test_flushCachedSigningKeys_FlushedKeysAreDeleted(t *testing.T) {
 	iss2 := "issuer2"
 	kid := "kid1"
 	key := "signingKey"
-	keyCache.jwksMap[iss] = []signingKey{{keyID: kid, key: []byte(key)}}
-	keyCache.jwksMap[iss2] = []signingKey{{keyID: kid, key: []byte(key)}}
+	cacheKeys(keyCache, iss, []signingKey{{keyID: kid, key: []byte(key)}})
+	cacheKeys(keyCache, iss2, []signingKey{{keyID: kid, key: []byte(key)}})
 
 	keyCache.flushCachedSigningKeys(iss2)
 
-	dk := keyCache.jwksMap[iss2]
+	dk := cachedKeys(keyCache, iss2)
 
 	if dk != nil {
 		t.Error("Flushed keys should not be in the cache.")
@@ -109,7 +113,7 @@ func Test_flushCachedSigningKey_RetrieveFlushedKey(t *testing.T) {
 	kid := "kid1"
 	key := "signingKey"
 
-	keyGetter.On("get", (*http.Request)(nil), iss).Return([]signingKey{{keyID: kid, key: []byte(key)}}, nil).Twice()
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, unknownLifetime, nil).Twice()
 
 	// Get the signing key not yet cached will cache it.
 	expectKey(t, keyCache, iss, kid, key)
@@ -127,18 +131,127 @@ func Test_flushCachedSigningKey_RetrieveFlushedKey(t *testing.T) {
 	keyGetter.AssertExpectations(t)
 }
 
+func Test_getSigningKey_WhenKeysAreRestrictedToAudiences(t *testing.T) {
+	_, keyCache := createSigningKeyProvider(t)
+
+	iss := "issuer"
+	cacheKeys(keyCache, iss, []signingKey{
+		{keyID: "kid1", key: []byte("key1"), audiences: []string{"client1"}},
+		{keyID: "kid1", key: []byte("key2"), audiences: []string{"client2", "client3"}},
+		{keyID: "kid2", key: []byte("key3")},
+	})
+
+	tests := []struct {
+		ks  keySelector
+		key string
+	}{
+		{keySelector{kid: "kid1", audience: "client1"}, "key1"},
+		{keySelector{kid: "kid1", audience: "client3"}, "key2"},
+		{keySelector{kid: "kid2", audience: "client3"}, "key3"},
+		{keySelector{audience: "client4"}, "key3"},
+	}
+
+	for _, tt := range tests {
+		sk, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, tt.ks)
+
+		if err != nil {
+			t.Error("An error was returned but not expected.", err)
+		}
+
+		if keyString(sk) != tt.key {
+			t.Errorf("Expected key %v for %+v, but got %v", tt.key, tt.ks, keyString(sk))
+		}
+	}
+}
+
+func Test_getSigningKey_WhenKidIsMissing(t *testing.T) {
+	_, keyCache := createSigningKeyProvider(t)
+
+	iss := "issuer"
+	cacheKeys(keyCache, iss, []signingKey{
+		{keyID: "kid1", key: []byte("key1"), audiences: []string{"client1"}},
+		{key: []byte("key2")},
+		{key: []byte("key3")},
+	})
+
+	tests := []struct {
+		ks   keySelector
+		keys []string
+	}{
+		{keySelector{audience: "client1"}, []string{"key1", "key2", "key3"}},
+		{keySelector{audience: "client2"}, []string{"key2", "key3"}},
+		{keySelector{kid: "kid2", audience: "client1"}, []string{"key2", "key3"}},
+		{keySelector{kid: "kid1", audience: "client1"}, []string{"key1"}},
+	}
+
+	for _, tt := range tests {
+		sk, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, tt.ks)
+
+		if err != nil {
+			t.Error("An error was returned but not expected.", err)
+		}
+
+		var keys []string
+		if kc, ok := sk.(keyCandidates); ok {
+			for _, k := range kc {
+				keys = append(keys, keyString(k))
+			}
+		} else {
+			keys = []string{keyString(sk)}
+		}
+
+		if fmt.Sprint(keys) != fmt.Sprint(tt.keys) {
+			t.Errorf("Expected the keys %v for %+v, but got %v", tt.keys, tt.ks, keys)
+		}
+	}
+}
+
+func Test_getSigningKey_ByThumbprint(t *testing.T) {
+	_, keyCache := createSigningKeyProvider(t)
+
+	iss := "issuer"
+	cacheKeys(keyCache, iss, []signingKey{
+		{key: []byte("key1"), x5t: "t1", x5tS256: "s1"},
+		{key: []byte("key2"), x5t: "t2", x5tS256: "s2"},
+		{keyID: "kid3", key: []byte("key3")},
+	})
+
+	tests := []struct {
+		ks  keySelector
+		key string
+	}{
+		{keySelector{x5t: "t2"}, "key2"},
+		{keySelector{x5tS256: "s1"}, "key1"},
+		{keySelector{x5t: "t2", x5tS256: "s1"}, "key1"},
+		{keySelector{kid: "kid3", x5t: "t1"}, "key3"},
+		{keySelector{x5t: "unknown"}, "key3"},
+	}
+
+	for _, tt := range tests {
+		sk, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, tt.ks)
+
+		if err != nil {
+			t.Error("An error was returned but not expected.", err)
+		}
+
+		if keyString(sk) != tt.key {
+			t.Errorf("Expected key %v for %+v, but got %v", tt.key, tt.ks, keyString(sk))
+		}
+	}
+}
+
 func expectCachedKid(t *testing.T, keyProv *signingKeyProvider, iss string, kid string, key string) {
 
-	cachedKeys := keyProv.jwksMap[iss]
-	if len(cachedKeys) == 0 {
+	ck := cachedKeys(keyProv, iss)
+	if len(ck) == 0 {
 		t.Fatal("The keys were not cached as expected.")
 	}
 
 	foundKid := false
-	for _, cachedKey := range cachedKeys {
+	for _, cachedKey := range ck {
 		if cachedKey.keyID == kid {
 			foundKid = true
-			if keyStr := string(cachedKey.key); keyStr != key {
+			if keyStr := keyString(cachedKey.key); keyStr != key {
 				t.Error("Expected key", key, "but got", keyStr)
 			}
 
@@ -152,7 +265,7 @@ func expectCachedKid(t *testing.T, keyProv *signingKeyProvider, iss string, kid
 }
 
 func expectKey(t *testing.T, c signingKeyGetter, iss string, kid string, key string) {
-	sk, re := c.getSigningKey(nil, iss, kid)
+	sk, re := c.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: kid})
 
 	if re != nil {
 		t.Error("An error was returned but not expected.")
@@ -162,7 +275,7 @@ func expectKey(t *testing.T, c signingKeyGetter, iss string, kid string, key str
 		t.Fatal("The returned signing key should not be nil.")
 	}
 
-	keyStr := string(sk)
+	keyStr := keyString(sk)
 
 	if keyStr != key {
 		t.Error("Expected key", key, "but got", keyStr)
@@ -173,3 +286,221 @@ func createSigningKeyProvider(t *testing.T) (*mockSigningKeySetGetter, *signingK
 	mock := &mockSigningKeySetGetter{}
 	return mock, newSigningKeyProvider(mock)
 }
+
+func Test_getSigningKey_WhenCachedKeysExpire(t *testing.T) {
+	tests := []struct {
+		lifetime time.Duration
+		cached   time.Duration
+	}{
+		{unknownLifetime, defaultMaxJwksCacheTTL},
+		{0, defaultMinJwksCacheTTL},
+		{time.Hour, time.Hour},
+		{48 * time.Hour, defaultMaxJwksCacheTTL},
+	}
+
+	for _, test := range tests {
+		keyGetter, keyCache := createSigningKeyProvider(t)
+		now := time.Now()
+		keyCache.now = func() time.Time { return now }
+
+		iss := "issuer"
+		kid := "kid1"
+		key := "signingKey"
+		keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, test.lifetime, nil).Twice()
+
+		expectKey(t, keyCache, iss, kid, key)
+
+		now = now.Add(test.cached - time.Second)
+		expectKey(t, keyCache, iss, kid, key)
+		keyGetter.AssertNumberOfCalls(t, "get", 1)
+
+		now = now.Add(time.Second)
+		expectKey(t, keyCache, iss, kid, key)
+		keyGetter.AssertNumberOfCalls(t, "get", 2)
+	}
+}
+
+func Test_JwksCaching(t *testing.T) {
+	c, err := NewConfiguration(JwksCaching(time.Second, time.Hour))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	if kp.minTTL != time.Second || kp.maxTTL != time.Hour {
+		t.Error("Expected the cache bounds 1s and 1h, but got", kp.minTTL, kp.maxTTL)
+	}
+
+	_, err = NewConfiguration(JwksCaching(time.Hour, time.Second))
+
+	expectSetupError(t, err, SetupErrorInvalidCacheBounds)
+}
+
+func Test_getSigningKey_WhenStaleKeys(t *testing.T) {
+	keyGetter, keyCache := createSigningKeyProvider(t)
+	keyCache.staleGrace, keyCache.retryInterval = time.Hour, time.Minute
+	now := time.Now()
+	keyCache.now = func() time.Time { return now }
+
+	iss := "issuer"
+	kid := "kid1"
+	key := "signingKey"
+	ge := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	done := make(chan struct{})
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, time.Hour, nil).Once()
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return(nil, time.Duration(0), ge).Once().Run(func(mock.Arguments) { close(done) })
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return(nil, time.Duration(0), ge)
+
+	expectKey(t, keyCache, iss, kid, key)
+
+	// The expired keys are served while retrieved again in the background.
+	now = now.Add(time.Hour + time.Second)
+	expectKey(t, keyCache, iss, kid, key)
+	<-done
+	keyGetter.AssertNumberOfCalls(t, "get", 2)
+
+	// The keys are not retrieved again before the retry interval elapses.
+	expectKey(t, keyCache, iss, kid, key)
+	keyGetter.AssertNumberOfCalls(t, "get", 2)
+
+	// Once the grace period elapses the keys are retrieved on demand.
+	now = now.Add(time.Hour)
+	_, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: kid})
+
+	if err != ge {
+		t.Error("Expected the error", ge, "but got", err)
+	}
+
+	keyGetter.AssertNumberOfCalls(t, "get", 3)
+}
+
+func Test_flushCachedSigningKeys_WhenStaleKeys(t *testing.T) {
+	keyGetter, keyCache := createSigningKeyProvider(t)
+	keyCache.staleGrace, keyCache.retryInterval = time.Hour, time.Minute
+
+	iss := "issuer"
+	kid := "kid1"
+	key := "signingKey"
+	ge := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, time.Hour, nil).Once()
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return(nil, time.Duration(0), ge).Once()
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte("newKey")}}, time.Hour, nil).Once()
+
+	expectKey(t, keyCache, iss, kid, key)
+
+	keyCache.flushCachedSigningKeys(iss)
+
+	// The flushed keys are served when they cannot be retrieved again.
+	expectKey(t, keyCache, iss, kid, key)
+
+	keyCache.flushCachedSigningKeys(iss)
+	expectKey(t, keyCache, iss, kid, "newKey")
+
+	keyGetter.AssertNumberOfCalls(t, "get", 3)
+}
+
+func Test_StaleKeys(t *testing.T) {
+	c, err := NewConfiguration(StaleKeys(time.Hour, time.Minute))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	kp := c.idTokenValidator().keyGetter.(*signingKeyProvider)
+	if kp.staleGrace != time.Hour || kp.retryInterval != time.Minute {
+		t.Error("Expected the grace period 1h and retry interval 1m, but got", kp.staleGrace, kp.retryInterval)
+	}
+
+	_, err = NewConfiguration(StaleKeys(0, time.Minute))
+
+	expectSetupError(t, err, SetupErrorInvalidCacheBounds)
+
+	_, err = NewConfiguration(StaleKeys(time.Hour, 0))
+
+	expectSetupError(t, err, SetupErrorInvalidRefreshInterval)
+}
+
+func Test_getSigningKey_WhenRefreshLimited(t *testing.T) {
+	keyGetter, keyCache := createSigningKeyProvider(t)
+	keyCache.refreshLimit, keyCache.minTTL = time.Minute, time.Second
+	now := time.Now()
+	keyCache.now = func() time.Time { return now }
+
+	iss := "issuer"
+	kid := "kid1"
+	key := "signingKey"
+	keyGetter.On("get", (*http.Request)(nil), &Provider{Issuer: iss}).Return([]signingKey{{keyID: kid, key: []byte(key)}}, 10*time.Second, nil)
+
+	expectKey(t, keyCache, iss, kid, key)
+
+	// The first unknown key ID forces the retrieval of the keys, the next ones are rejected.
+	for i, calls := range []int{2, 2} {
+		_, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: fmt.Sprint("unknown", i)})
+
+		expectValidationError(t, err, ValidationErrorKidNotFound, http.StatusUnauthorized, nil)
+		keyGetter.AssertNumberOfCalls(t, "get", calls)
+	}
+
+	// The flushed keys are kept when they cannot be retrieved again.
+	keyCache.flushCachedSigningKeys(iss)
+	expectKey(t, keyCache, iss, kid, key)
+	keyGetter.AssertNumberOfCalls(t, "get", 2)
+
+	// The retrieval of expired keys is not limited.
+	now = now.Add(10 * time.Second)
+	expectKey(t, keyCache, iss, kid, key)
+	keyGetter.AssertNumberOfCalls(t, "get", 3)
+
+	now = now.Add(time.Minute)
+	_, err := keyCache.getSigningKey(nil, &Provider{Issuer: iss}, keySelector{kid: "unknown"})
+
+	expectValidationError(t, err, ValidationErrorKidNotFound, http.StatusUnauthorized, nil)
+	keyGetter.AssertNumberOfCalls(t, "get", 4)
+}
+
+func Test_JwksRefreshLimit(t *testing.T) {
+	c, err := NewConfiguration(JwksRefreshLimit(time.Minute))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if kp := c.idTokenValidator().keyGetter.(*signingKeyProvider); kp.refreshLimit != time.Minute {
+		t.Error("Expected the refresh limit 1m, but got", kp.refreshLimit)
+	}
+
+	_, err = NewConfiguration(JwksRefreshLimit(0))
+
+	expectSetupError(t, err, SetupErrorInvalidRefreshInterval)
+}
+
+// cacheKeys stores the keys of the issuer in the cache of the provider for an hour.
+func cacheKeys(kp *signingKeyProvider, iss string, keys []signingKey) {
+	kp.cache.Set(signingKeysCacheKeyPrefix+iss, &cachedSigningKeys{keys: keys, expiry: time.Now().Add(time.Hour)}, 0)
+}
+
+// cachedKeys returns the keys of the issuer stored in the cache of the provider.
+func cachedKeys(kp *signingKeyProvider, iss string) []signingKey {
+	if ck, ok := kp.cachedKeys(iss); ok {
+		return ck.keys
+	}
+
+	return nil
+}
+
+// keyString returns the test keys, []byte, as strings and the public keys as their DER encoding.
+func keyString(k interface{}) string {
+	if b, ok := k.([]byte); ok {
+		return string(b)
+	}
+
+	der, _ := marshalPublicKey(k)
+	return string(der)
+}
+
+func createPublicKey(t *testing.T) interface{} {
+	k, err := GenerateKey("RS256")
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	return k.Public().Key
+}
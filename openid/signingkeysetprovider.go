@@ -1,60 +1,138 @@
 package openid
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"net/http"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
 )
 
+// signingKeySetGetter returns the signing keys of a provider along with the lifetime
+// advertised for them, unknownLifetime when none is.
 type signingKeySetGetter interface {
-	get(r *http.Request, issuer string) ([]signingKey, error)
+	get(r *http.Request, p *Provider) ([]signingKey, time.Duration, error)
 }
 
 type signingKeySetProvider struct {
 	configGetter configurationGetter
 	jwksGetter   jwksGetter
-	keyEncoder   pemEncoder
 }
 
+// signingKey is a signing key of a provider. The key is the public key, i.e.: *rsa.PublicKey,
+// ready to verify the signatures of the tokens, see publicKey.
 type signingKey struct {
-	keyID string
-	key   []byte
+	keyID     string
+	key       interface{}
+	audiences []string
+	x5t       string
+	x5tS256   string
 }
 
-func newSigningKeySetProvider(cg configurationGetter, jg jwksGetter, ke pemEncoder) *signingKeySetProvider {
-	return &signingKeySetProvider{cg, jg, ke}
+func newSigningKeySetProvider(cg configurationGetter, jg jwksGetter) *signingKeySetProvider {
+	return &signingKeySetProvider{cg, jg}
 }
 
-func (signProv *signingKeySetProvider) get(r *http.Request, iss string) ([]signingKey, error) {
-	conf, err := signProv.configGetter.get(r, iss)
+func (signProv *signingKeySetProvider) get(r *http.Request, p *Provider) ([]signingKey, time.Duration, error) {
+	if len(p.Keys) > 0 {
+		return signProv.staticKeys(p)
+	}
+
+	iss := p.Issuer
+	u, err := signProv.jwksURL(r, p)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	jwks, err := signProv.jwksGetter.get(r, conf.JwksURI)
+	jwks, err := signProv.jwksGetter.get(r, u, p.JwksCredentials, p.CertificatesURL != "")
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(jwks.Keys) == 0 {
-		return nil, &ValidationError{
+		return nil, 0, &ValidationError{
 			Code:       ValidationErrorEmptyJwk,
 			Message:    fmt.Sprintf("The jwk set retrieved for the issuer %v does not contain any key.", iss),
-			HTTPStatus: http.StatusUnauthorized,
+			HTTPStatus: http.StatusBadGateway,
 		}
 	}
 
 	sk := make([]signingKey, len(jwks.Keys))
 
 	for i, k := range jwks.Keys {
-		ek, err := signProv.keyEncoder.encode(k.Key)
+		pk, err := publicKey(k.Key)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+
+		sk[i] = signingKey{keyID: k.KeyID, key: pk}
+		sk[i].x5t, sk[i].x5tS256 = thumbprints(k.JSONWebKey, k.members)
+
+		if p.KeyAudienceMember != "" {
+			sk[i].audiences = claimStrings(k.members[p.KeyAudienceMember])
 		}
+	}
 
-		sk[i] = signingKey{k.KeyID, ek}
+	return sk, jwks.lifetime, nil
+}
+
+// jwksURL returns the URL of the signing keys of the provider, its CertificatesURL when set and
+// the jwks_uri of its discovery document otherwise.
+func (signProv *signingKeySetProvider) jwksURL(r *http.Request, p *Provider) (string, error) {
+	if p.CertificatesURL != "" {
+		return p.CertificatesURL, nil
+	}
+
+	conf, err := getProviderConfiguration(signProv.configGetter, r, p)
+	if err != nil {
+		return "", err
+	}
+
+	return conf.JwksURI, nil
+}
+
+// staticKeys returns the Keys of the provider, registered instead of retrieving them. They are
+// cached for the minimum lifetime, see JwksCaching, so the removed keys stop being used.
+func (signProv *signingKeySetProvider) staticKeys(p *Provider) ([]signingKey, time.Duration, error) {
+	sk := make([]signingKey, len(p.Keys))
+
+	for i, k := range p.Keys {
+		pk, err := publicKey(k.Public().Key)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sk[i] = signingKey{keyID: k.KeyID, key: pk}
+		sk[i].x5t, sk[i].x5tS256 = thumbprints(k, nil)
+	}
+
+	return sk, 0, nil
+}
+
+// thumbprints returns the SHA-1 and SHA-256 thumbprints of the certificate of the key, the x5t and
+// x5t#S256 members when published, computed from the first certificate of its x5c member otherwise.
+func thumbprints(k jose.JSONWebKey, members map[string]interface{}) (x5t string, x5tS256 string) {
+	x5t, _ = members["x5t"].(string)
+	x5tS256, _ = members["x5t#S256"].(string)
+
+	if len(k.Certificates) > 0 {
+		raw := k.Certificates[0].Raw
+
+		if x5t == "" {
+			d := sha1.Sum(raw)
+			x5t = base64.RawURLEncoding.EncodeToString(d[:])
+		}
+
+		if x5tS256 == "" {
+			d := sha256.Sum256(raw)
+			x5tS256 = base64.RawURLEncoding.EncodeToString(d[:])
+		}
 	}
 
-	return sk, nil
+	return x5t, x5tS256
 }
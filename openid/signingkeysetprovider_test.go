@@ -1,22 +1,30 @@
 package openid
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"gopkg.in/square/go-jose.v2"
 )
 
 func TestSigningKeySetProvider_Get_WhenGetConfigurationReturnsError(t *testing.T) {
-	configGetter, _, _, skProv := createSigningKeySetProvider(t)
+	configGetter, _, skProv := createSigningKeySetProvider(t)
 
 	ee := &ValidationError{Code: ValidationErrorGetOpenIdConfigurationFailure, HTTPStatus: http.StatusUnauthorized}
-	configGetter.On("get", mock.Anything).Return(configuration{}, ee)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, ee)
 
-	sk, re := skProv.get(nil, mock.Anything)
+	sk, _, re := skProv.get(nil, &Provider{Issuer: mock.Anything})
 
 	expectValidationError(t, re, ee.Code, ee.HTTPStatus, nil)
 
@@ -28,16 +36,16 @@ func TestSigningKeySetProvider_Get_WhenGetConfigurationReturnsError(t *testing.T
 }
 
 func TestSigningKeySetProvider_Get_WhenGetJwksReturnsError(t *testing.T) {
-	configGetter, jwksGetter, _, skProv := createSigningKeySetProvider(t)
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 
 	ee := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusUnauthorized}
 
-	jwksGetter.On("get", req, mock.Anything).Return(jose.JSONWebKeySet{}, ee)
+	jwksGetter.On("get", req, mock.Anything, mock.Anything, false).Return(jsonWebKeySet{}, ee)
 
-	configGetter.On("get", mock.Anything).Return(configuration{}, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
 
-	sk, re := skProv.get(req, mock.Anything)
+	sk, _, re := skProv.get(req, &Provider{Issuer: mock.Anything})
 
 	expectValidationError(t, re, ee.Code, ee.HTTPStatus, nil)
 
@@ -50,14 +58,14 @@ func TestSigningKeySetProvider_Get_WhenGetJwksReturnsError(t *testing.T) {
 }
 
 func TestSigningKeySetProvider_Get_WhenJwkSetIsEmpty(t *testing.T) {
-	configGetter, jwksGetter, _, skProv := createSigningKeySetProvider(t)
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
 
-	ee := &ValidationError{Code: ValidationErrorEmptyJwk, HTTPStatus: http.StatusUnauthorized}
+	ee := &ValidationError{Code: ValidationErrorEmptyJwk, HTTPStatus: http.StatusBadGateway}
 
-	jwksGetter.On("get", (*http.Request)(nil), mock.Anything).Return(jose.JSONWebKeySet{}, nil)
-	configGetter.On("get", mock.Anything).Return(configuration{}, nil)
+	jwksGetter.On("get", (*http.Request)(nil), mock.Anything, mock.Anything, false).Return(jsonWebKeySet{}, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
 
-	sk, re := skProv.get(nil, mock.Anything)
+	sk, _, re := skProv.get(nil, &Provider{Issuer: mock.Anything})
 
 	expectValidationError(t, re, ee.Code, ee.HTTPStatus, nil)
 
@@ -69,17 +77,16 @@ func TestSigningKeySetProvider_Get_WhenJwkSetIsEmpty(t *testing.T) {
 	jwksGetter.AssertExpectations(t)
 }
 
-func TestSigningKeySetProvider_Get_WhenKeyEncodingReturnsError(t *testing.T) {
-	configGetter, jwksGetter, pemEncoder, skProv := createSigningKeySetProvider(t)
+func TestSigningKeySetProvider_Get_WhenKeyIsNotSupported(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
 
 	ee := &ValidationError{Code: ValidationErrorMarshallingKey, HTTPStatus: http.StatusInternalServerError}
-	ejwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: nil}}}
+	ejwks := jsonWebKeySet{Keys: []jsonWebKey{{JSONWebKey: jose.JSONWebKey{Key: []byte("secret")}}}}
 
-	jwksGetter.On("get", (*http.Request)(nil), mock.Anything).Return(ejwks, nil)
-	configGetter.On("get", mock.Anything).Return(configuration{}, nil)
-	pemEncoder.On("encode", nil).Return(nil, ee)
+	jwksGetter.On("get", (*http.Request)(nil), mock.Anything, mock.Anything, false).Return(ejwks, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
 
-	sk, re := skProv.get(nil, mock.Anything)
+	sk, _, re := skProv.get(nil, &Provider{Issuer: mock.Anything})
 
 	expectValidationError(t, re, ee.Code, ee.HTTPStatus, nil)
 
@@ -89,31 +96,27 @@ func TestSigningKeySetProvider_Get_WhenKeyEncodingReturnsError(t *testing.T) {
 
 	configGetter.AssertExpectations(t)
 	jwksGetter.AssertExpectations(t)
-	pemEncoder.AssertExpectations(t)
 }
 
-func TestSigningKeySetProvider_Get_WhenKeyEncodingReturnsSuccess(t *testing.T) {
-	configGetter, jwksGetter, pemEncoder, skProv := createSigningKeySetProvider(t)
+func TestSigningKeySetProvider_Get_WhenKeysAreSupported(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 
-	keys := make([]jose.JSONWebKey, 2)
+	keys := make([]jsonWebKey, 2)
 	encryptedKeys := make([]signingKey, 2)
 
 	for i := 0; i < cap(keys); i = i + 1 {
-		keys[i] = jose.JSONWebKey{KeyID: fmt.Sprintf("%v", i), Key: i}
-		encryptedKeys[i] = signingKey{keyID: fmt.Sprintf("%v", i), key: []byte(fmt.Sprintf("%v", i))}
+		k, _ := GenerateKey("RS256")
+		keys[i] = jsonWebKey{JSONWebKey: jose.JSONWebKey{KeyID: fmt.Sprintf("%v", i), Key: k.Public().Key}}
+		encryptedKeys[i] = signingKey{keyID: fmt.Sprintf("%v", i), key: k.Public().Key}
 	}
 
-	ejwks := jose.JSONWebKeySet{Keys: keys}
+	ejwks := jsonWebKeySet{Keys: keys}
 
-	jwksGetter.On("get", req, mock.Anything).Return(ejwks, nil)
-	configGetter.On("get", mock.Anything).Return(configuration{}, nil)
+	jwksGetter.On("get", req, mock.Anything, mock.Anything, false).Return(ejwks, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
 
-	for i, encryptedKey := range encryptedKeys {
-		pemEncoder.On("encode", keys[i].Key).Return(encryptedKey.key, nil)
-	}
-
-	sk, re := skProv.get(req, mock.Anything)
+	sk, _, re := skProv.get(req, &Provider{Issuer: mock.Anything})
 
 	if re != nil {
 		t.Error("An error was returned but not expected.")
@@ -131,21 +134,140 @@ func TestSigningKeySetProvider_Get_WhenKeyEncodingReturnsSuccess(t *testing.T) {
 		if encryptedKey.keyID != sk[i].keyID {
 			t.Error("Key at", i, "should have keyID", encryptedKey.keyID, "but was", sk[i].keyID)
 		}
-		if string(encryptedKey.key) != string(sk[i].key) {
+		if encryptedKey.key != sk[i].key {
 			t.Error("Key at", i, "should be", encryptedKey.key, "but was", sk[i].key)
 		}
 	}
 
 	configGetter.AssertExpectations(t)
 	jwksGetter.AssertExpectations(t)
-	pemEncoder.AssertExpectations(t)
 }
 
-func createSigningKeySetProvider(t *testing.T) (*mockConfigurationGetter, *mockJwksGetter, *mockPemEncoder, signingKeySetProvider) {
+func TestSigningKeySetProvider_Get_WithKeyAudienceMember(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
+
+	k, _ := GenerateKey("RS256")
+	keys := []jsonWebKey{
+		{JSONWebKey: jose.JSONWebKey{KeyID: "kid1", Key: k.Public().Key}, members: map[string]interface{}{"client_id": "client1"}},
+		{JSONWebKey: jose.JSONWebKey{KeyID: "kid2", Key: k.Public().Key}},
+	}
+
+	jwksGetter.On("get", (*http.Request)(nil), mock.Anything, mock.Anything, false).Return(jsonWebKeySet{Keys: keys}, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
+
+	sk, _, re := skProv.get(nil, &Provider{Issuer: mock.Anything, KeyAudienceMember: "client_id"})
+
+	if re != nil {
+		t.Fatal("An error was returned but not expected.", re)
+	}
+
+	if len(sk[0].audiences) != 1 || sk[0].audiences[0] != "client1" {
+		t.Error("Expected the key audiences [client1], but got", sk[0].audiences)
+	}
+
+	if len(sk[1].audiences) != 0 {
+		t.Error("Expected no audiences, but got", sk[1].audiences)
+	}
+}
+
+func createSigningKeySetProvider(t *testing.T) (*mockConfigurationGetter, *mockJwksGetter, signingKeySetProvider) {
 	configGetter := &mockConfigurationGetter{}
 	jwksGetter := &mockJwksGetter{}
-	pemEncoder := &mockPemEncoder{}
 
-	skProv := signingKeySetProvider{configGetter: configGetter, jwksGetter: jwksGetter, keyEncoder: pemEncoder}
-	return configGetter, jwksGetter, pemEncoder, skProv
+	skProv := signingKeySetProvider{configGetter: configGetter, jwksGetter: jwksGetter}
+	return configGetter, jwksGetter, skProv
+}
+
+func TestSigningKeySetProvider_Get_WhenProviderHasKeys(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
+
+	k, _ := GenerateKey("RS256")
+
+	sk, lifetime, err := skProv.get(nil, &Provider{Issuer: "https://issuer", Keys: []jose.JSONWebKey{k}})
+
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if len(sk) != 1 || sk[0].keyID != k.KeyID || sk[0].key != k.Public().Key || lifetime != 0 {
+		t.Errorf("Expected the key %v with no lifetime, but got %+v %v", k.KeyID, sk, lifetime)
+	}
+
+	configGetter.AssertExpectations(t)
+	jwksGetter.AssertExpectations(t)
+}
+
+func TestSigningKeySetProvider_Get_UsesDiscoveryURL(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
+
+	du := "https://login/tenant/b2c_1_signin/v2.0/.well-known/openid-configuration"
+	ee := &ValidationError{Code: ValidationErrorGetOpenIdConfigurationFailure, HTTPStatus: http.StatusBadGateway}
+	configGetter.On("get", (*http.Request)(nil), du).Return(configuration{}, ee)
+
+	_, _, err := skProv.get(nil, &Provider{Issuer: "https://login/tenant/v2.0", DiscoveryURL: du})
+
+	if err != ee {
+		t.Error("Expected the error", ee, "but got", err)
+	}
+
+	configGetter.AssertExpectations(t)
+	jwksGetter.AssertExpectations(t)
+}
+
+func TestSigningKeySetProvider_Get_UsesCertificatesURL(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
+
+	cu := "https://www.example.com/certificates"
+	ee := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	jwksGetter.On("get", (*http.Request)(nil), cu, mock.Anything, true).Return(jsonWebKeySet{}, ee)
+
+	_, _, err := skProv.get(nil, &Provider{Issuer: "https://issuer", CertificatesURL: cu})
+
+	if err != ee {
+		t.Error("Expected the error", ee, "but got", err)
+	}
+
+	configGetter.AssertExpectations(t)
+	jwksGetter.AssertExpectations(t)
+}
+
+func Test_thumbprints(t *testing.T) {
+	k, _ := rsa.GenerateKey(rand.Reader, 2048)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	cert, _ := x509.ParseCertificate(der)
+
+	s1 := sha1.Sum(der)
+	s256 := sha256.Sum256(der)
+	jk := jose.JSONWebKey{Key: &k.PublicKey, Certificates: []*x509.Certificate{cert}}
+
+	x5t, x5tS256 := thumbprints(jk, nil)
+	if x5t != base64.RawURLEncoding.EncodeToString(s1[:]) || x5tS256 != base64.RawURLEncoding.EncodeToString(s256[:]) {
+		t.Error("Expected the thumbprints of the certificate, but got", x5t, x5tS256)
+	}
+
+	x5t, x5tS256 = thumbprints(jk, map[string]interface{}{"x5t": "published"})
+	if x5t != "published" || x5tS256 != base64.RawURLEncoding.EncodeToString(s256[:]) {
+		t.Error("Expected the published x5t, but got", x5t, x5tS256)
+	}
+
+	if x5t, x5tS256 = thumbprints(jose.JSONWebKey{Key: &k.PublicKey}, nil); x5t != "" || x5tS256 != "" {
+		t.Error("Expected no thumbprints without certificate, but got", x5t, x5tS256)
+	}
+}
+
+func TestSigningKeySetProvider_Get_WithThumbprints(t *testing.T) {
+	configGetter, jwksGetter, skProv := createSigningKeySetProvider(t)
+
+	k, _ := GenerateKey("RS256")
+	keys := []jsonWebKey{{JSONWebKey: jose.JSONWebKey{Key: k.Public().Key}, members: map[string]interface{}{"x5t": "t1", "x5t#S256": "s1"}}}
+
+	jwksGetter.On("get", (*http.Request)(nil), mock.Anything, mock.Anything, false).Return(jsonWebKeySet{Keys: keys}, nil)
+	configGetter.On("get", mock.Anything, mock.Anything).Return(configuration{}, nil)
+
+	sk, _, err := skProv.get(nil, &Provider{Issuer: "https://issuer"})
+
+	if err != nil || len(sk) != 1 || sk[0].x5t != "t1" || sk[0].x5tS256 != "s1" {
+		t.Errorf("Expected the key with its thumbprints, but got %+v %v", sk, err)
+	}
 }
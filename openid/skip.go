@@ -0,0 +1,133 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SkipFunc returns true when the request r must be served without authentication, see Skip.
+type SkipFunc func(r *http.Request) bool
+
+// Skip option registers the function deciding which requests are served without authentication,
+// i.e.: health checks and metrics behind the same mux as the protected handlers. The function is
+// evaluated before the transport requirements and the token extraction, and the skipped requests
+// are forwarded to the next handler without token nor user, the *User received by the UserHandler
+// of AuthenticateUser being nil. Skip replaces the function registered by SkipPaths and the other
+// way around.
+func Skip(f SkipFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.skip = f
+		return nil
+	}
+}
+
+// SkipPaths option serves the requests matching any of the given patterns without authentication,
+// as Skip. A pattern is an optional method followed by a path, i.e.: "/healthz" or "GET /public/*".
+// The path is matched with path.Match, and a path ending with "/*" matches all the paths under it,
+// at any depth. Requests whose path is not clean, i.e.: "/public/../admin" with dot segments or
+// repeated slashes, are never skipped, as the handlers and upstream services could resolve them
+// to a path not matching the patterns.
+func SkipPaths(patterns ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		sps := make([]skipPattern, 0, len(patterns))
+		for _, p := range patterns {
+			sp, err := parseSkipPattern(p)
+			if err != nil {
+				return err
+			}
+
+			sps = append(sps, sp)
+		}
+
+		c.skip = func(r *http.Request) bool {
+			for _, sp := range sps {
+				if sp.match(r) {
+					return true
+				}
+			}
+
+			return false
+		}
+		return nil
+	}
+}
+
+// SkipPreflight option serves the CORS preflight requests without authentication, as Skip, since
+// browsers never send the Authorization header with them. A preflight is an OPTIONS request with
+// the Origin and Access-Control-Request-Method headers, so the CORS handler registered after the
+// middleware answers it. The option applies along with the function registered by Skip or SkipPaths.
+func SkipPreflight() func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.skipPreflight = true
+		return nil
+	}
+}
+
+// isPreflight returns true when r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// skipPattern is a pattern of the requests skipped by the middlewares, see SkipPaths.
+type skipPattern struct {
+	method string
+	path   string
+	prefix bool
+}
+
+func parseSkipPattern(p string) (skipPattern, error) {
+	var sp skipPattern
+	sp.path = strings.TrimSpace(p)
+	if i := strings.IndexByte(sp.path, ' '); i >= 0 {
+		sp.method, sp.path = sp.path[:i], strings.TrimSpace(sp.path[i+1:])
+	}
+
+	if strings.HasSuffix(sp.path, "/*") {
+		sp.path, sp.prefix = strings.TrimSuffix(sp.path, "*"), true
+	}
+
+	if _, err := path.Match(sp.path, ""); err != nil || !strings.HasPrefix(sp.path, "/") {
+		return skipPattern{}, &SetupError{
+			Code:    SetupErrorInvalidSkipPattern,
+			Message: fmt.Sprintf("The skip pattern %q must be an optional method followed by a valid path pattern.", p),
+		}
+	}
+
+	return sp, nil
+}
+
+func (sp skipPattern) match(r *http.Request) bool {
+	if sp.method != "" && sp.method != r.Method {
+		return false
+	}
+
+	if !isCleanPath(r.URL.Path) {
+		return false
+	}
+
+	if sp.prefix {
+		return strings.HasPrefix(r.URL.Path, sp.path)
+	}
+
+	ok, _ := path.Match(sp.path, r.URL.Path)
+	return ok
+}
+
+// isCleanPath returns true when the decoded path p has no dot segments, repeated slashes nor
+// backslashes, so it names the same resource for the skip patterns and the handlers.
+func isCleanPath(p string) bool {
+	if strings.ContainsRune(p, '\\') {
+		return false
+	}
+
+	c := path.Clean(p)
+	if strings.HasSuffix(p, "/") && c != "/" {
+		c += "/"
+	}
+
+	return c == p
+}
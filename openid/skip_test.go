@@ -0,0 +1,115 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SkipPaths(t *testing.T) {
+	c, err := NewConfiguration(SkipPaths("/healthz", "GET /public/*", "/metrics/*.json"))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	tests := []struct {
+		method  string
+		path    string
+		skipped bool
+	}{
+		{http.MethodGet, "/healthz", true},
+		{http.MethodPost, "/healthz", true},
+		{http.MethodGet, "/healthz/more", false},
+		{http.MethodGet, "/public/", true},
+		{http.MethodGet, "/public/a/b", true},
+		{http.MethodPost, "/public/a", false},
+		{http.MethodGet, "/public", false},
+		{http.MethodGet, "/metrics/app.json", true},
+		{http.MethodGet, "/metrics/app.txt", false},
+		{http.MethodGet, "/private", false},
+		{http.MethodGet, "/public/../admin", false},
+		{http.MethodGet, "/public/%2e%2e/admin", false},
+		{http.MethodGet, "/public/%2E%2E%2Fadmin", false},
+		{http.MethodGet, "/public/./a", false},
+		{http.MethodGet, "/public//a", false},
+		{http.MethodGet, "/public/..%5Cadmin", false},
+	}
+
+	for _, test := range tests {
+		called := false
+		h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if called != test.skipped {
+			t.Errorf("Expected the request %v %v to be skipped %v, but got %v", test.method, test.path, test.skipped, called)
+		}
+
+		if !test.skipped && w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the request %v %v without token to be rejected, but got %v", test.method, test.path, w.Code)
+		}
+	}
+}
+
+func Test_SkipPaths_WhenInvalidPattern(t *testing.T) {
+	for _, p := range []string{"healthz", "GET /[a", ""} {
+		_, err := NewConfiguration(SkipPaths(p))
+		expectSetupError(t, err, SetupErrorInvalidSkipPattern)
+	}
+}
+
+func Test_Skip_ForwardsNilUser(t *testing.T) {
+	c, _ := NewConfiguration(Skip(func(r *http.Request) bool {
+		return r.Header.Get("X-Internal") != ""
+	}))
+
+	called := false
+	h := AuthenticateUser(c, func(u *User, w http.ResponseWriter, r *http.Request) {
+		called = u == nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Internal", "1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !called {
+		t.Error("Expected the skipped request to be forwarded without user.")
+	}
+}
+
+func Test_SkipPreflight(t *testing.T) {
+	c, _ := NewConfiguration(SkipPreflight(), SkipPaths("/healthz"))
+
+	tests := []struct {
+		method  string
+		path    string
+		headers map[string]string
+		skipped bool
+	}{
+		{http.MethodOptions, "/api", map[string]string{"Origin": "https://app", "Access-Control-Request-Method": "POST"}, true},
+		{http.MethodOptions, "/api", map[string]string{"Origin": "https://app"}, false},
+		{http.MethodOptions, "/api", nil, false},
+		{http.MethodPost, "/api", map[string]string{"Origin": "https://app", "Access-Control-Request-Method": "POST"}, false},
+		{http.MethodGet, "/healthz", nil, true},
+	}
+
+	for _, test := range tests {
+		called := false
+		h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+
+		r := httptest.NewRequest(test.method, test.path, nil)
+		for n, v := range test.headers {
+			r.Header.Set(n, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if called != test.skipped {
+			t.Errorf("Expected the request %v %v with headers %v to be skipped %v, but got %v", test.method, test.path, test.headers, test.skipped, called)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package openid
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ProvidersSelectorFunc returns the providers accepted for the request r among the registered
+// providers provs, i.e.: the providers trusted by the tenant the request is made to. The tokens
+// issued by the other providers are rejected with ValidationErrorIssuerNotFound.
+type ProvidersSelectorFunc func(r *http.Request, provs []Provider) ([]Provider, error)
+
+// TenantFunc returns the tenant a request is made to, or an empty string when there is none.
+type TenantFunc func(r *http.Request) string
+
+// ProvidersSelector option restricts the providers accepted for each request to the ones
+// returned by ps, so a single deployment can serve many tenants each trusting different issuers,
+// see TenantIssuers. The error returned by ps is handled as the errors of the validation.
+// The background retrievals of the signing keys, i.e.: Warmup, are made for all the providers,
+// and the tokens validated by a Validator are accepted from all of them.
+func ProvidersSelector(ps ProvidersSelectorFunc) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.idTokenValidator().selector = ps
+		return nil
+	}
+}
+
+// TenantIssuers returns a ProvidersSelectorFunc selecting the providers registered with the
+// issuers of the tenant of the request, returned by tf. The requests made to an unknown tenant
+// accept no provider:
+//
+//	issuers := map[string][]string{
+//	    "tenant1.example.com": {"https://accounts.google.com"},
+//	    "tenant2.example.com": {"https://login.microsoftonline.com/{tenantid}/v2.0"},
+//	}
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	    openid.ProvidersSelector(openid.TenantIssuers(openid.HostTenant, issuers)))
+func TenantIssuers(tf TenantFunc, issuers map[string][]string) ProvidersSelectorFunc {
+	return func(r *http.Request, provs []Provider) ([]Provider, error) {
+		accepted := issuers[tf(r)]
+
+		var selected []Provider
+		for _, p := range provs {
+			if containsString(accepted, p.Issuer) {
+				selected = append(selected, p)
+			}
+		}
+
+		return selected, nil
+	}
+}
+
+// HostTenant returns the host of the request, without the port, as its tenant.
+func HostTenant(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+// PathTenant returns a TenantFunc returning the segment at index of the path of the request,
+// starting at zero, as its tenant, i.e.: tenant1 for /tenant1/orders with an index of zero.
+func PathTenant(index int) TenantFunc {
+	return func(r *http.Request) string {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if index < 0 || index >= len(segments) {
+			return ""
+		}
+
+		return segments[index]
+	}
+}
+
+// requestProviders returns the validated providers accepted for the request r, all of them when
+// r is nil or a background request, i.e.: for the background retrievals and the Validator.
+func (tv *idTokenValidator) requestProviders(r *http.Request) ([]Provider, error) {
+	provs, err := tv.provGetter.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return nil, err
+	}
+
+	return tv.selectProviders(r, provs)
+}
+
+// selectProviders returns the providers among provs accepted for the request r, see
+// ProvidersSelector. The background requests, which do not belong to a tenant, accept them all.
+func (tv *idTokenValidator) selectProviders(r *http.Request, provs []Provider) ([]Provider, error) {
+	if tv.selector == nil || r == nil || isBackgroundRequest(r) {
+		return provs, nil
+	}
+
+	return tv.selector(r, provs)
+}
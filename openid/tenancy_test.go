@@ -0,0 +1,105 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func expectTenantStatus(t *testing.T, h http.Handler, ti *TokenIssuer, target string, status int) {
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Authorization", "Bearer "+ts)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != status {
+		t.Errorf("Expected the status %v for the token of %v at %v, but got %v", status, ti.issuer, target, w.Code)
+	}
+}
+
+func Test_ProvidersSelector_TenantIssuers(t *testing.T) {
+	ti1, s1 := createRegistryIssuer(t)
+	defer s1.Close()
+	ti2, s2 := createRegistryIssuer(t)
+	defer s2.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s1.URL, ClientIDs: []string{"app"}}, {Issuer: s2.URL, ClientIDs: []string{"app"}}}, nil
+	}), ProvidersSelector(TenantIssuers(HostTenant, map[string][]string{
+		"tenant1.example.com": {s1.URL},
+		"tenant2.example.com": {s1.URL, s2.URL},
+	})), ValidationCaching(NewMemoryValidationCache(0), time.Hour))
+
+	h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	expectTenantStatus(t, h, ti1, "http://tenant1.example.com/", http.StatusOK)
+	expectTenantStatus(t, h, ti2, "http://tenant1.example.com:8080/", http.StatusUnauthorized)
+	expectTenantStatus(t, h, ti2, "http://tenant2.example.com/", http.StatusOK)
+	expectTenantStatus(t, h, ti1, "http://other.example.com/", http.StatusUnauthorized)
+}
+
+func Test_ProvidersSelector_AppliesToCachedTokens(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ProvidersSelector(TenantIssuers(PathTenant(0), map[string][]string{"tenant1": {s.URL}})),
+		ValidationCaching(NewMemoryValidationCache(0), time.Hour))
+
+	h := Authenticate(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	for target, status := range map[string]int{"/tenant1/orders": http.StatusOK, "/tenant2/orders": http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != status {
+			t.Errorf("Expected the status %v at %v, but got %v", status, target, w.Code)
+		}
+	}
+}
+
+func Test_ProvidersSelector_WhenSelectorFails(t *testing.T) {
+	se := &ValidationError{Code: ValidationErrorIssuerNotFound, Message: "Unknown tenant.", Err: errors.New("tenant"), HTTPStatus: http.StatusForbidden}
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"app"}}}, nil
+	}), ProvidersSelector(func(r *http.Request, provs []Provider) ([]Provider, error) {
+		return nil, se
+	}))
+
+	tv := c.idTokenValidator()
+	if _, err := tv.requestProviders(httptest.NewRequest(http.MethodGet, "/", nil)); err != se {
+		t.Error("Expected the error of the selector, but got", err)
+	}
+
+	if provs, err := tv.requestProviders(nil); err != nil || len(provs) != 1 {
+		t.Errorf("Expected all the providers without a request, but got %v %v", provs, err)
+	}
+}
+
+func Test_PathTenant(t *testing.T) {
+	tests := []struct {
+		path   string
+		index  int
+		tenant string
+	}{
+		{"/tenant1/orders", 0, "tenant1"},
+		{"/api/tenant1/orders/", 1, "tenant1"},
+		{"/tenant1", 1, ""},
+		{"/tenant1", -1, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if tenant := PathTenant(test.index)(r); tenant != test.tenant {
+			t.Errorf("Expected the tenant %q of %v at %v, but got %q", test.tenant, test.path, test.index, tenant)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package openid
+
+import (
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// tokenChecker performs additional validation of a token after its signature,
+// issuer and audiences have already been validated. The p is the provider that
+// issued the token.
+type tokenChecker interface {
+	check(r *http.Request, t *jwt.Token, p *Provider) error
+}
+
+type tokenCheckerFunc func(r *http.Request, t *jwt.Token, p *Provider) error
+
+func (f tokenCheckerFunc) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	return f(r, t, p)
+}
+
+func checkToken(cs []tokenChecker, r *http.Request, t *jwt.Token, p *Provider) error {
+	for _, c := range cs {
+		if err := c.check(r, t, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
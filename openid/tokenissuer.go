@@ -0,0 +1,146 @@
+package openid
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// TokenIssuer issues tokens signed with the keys of a KeySet and publishes the discovery
+// document and signing keys needed to validate them, so an application can issue its own
+// tokens and validate them with this package, i.e.: while adopting an identity provider.
+// The tokens are signed with the RSA signing key most recently added to the set, generate a new
+// one with Rotate and remove the previous one from the set once the tokens it signed expired:
+//
+//	keys := openid.NewKeySet()
+//	ti, _ := openid.NewTokenIssuer("https://app.example.com", keys)
+//	ti.Rotate("RS256")
+//
+//	http.Handle("/.well-known/", ti.Handler())
+//
+//	token, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+//
+// The validators of this package only verify RSA signatures, hence only the RS and PS algorithms
+// are used to sign.
+type TokenIssuer struct {
+	issuer string
+	keys   *KeySet
+	now    func() time.Time
+}
+
+// NewTokenIssuer returns a TokenIssuer with the given issuer, the URL the application serves the
+// Handler under, signing the tokens with the keys of ks. The set may be empty until Rotate is called.
+func NewTokenIssuer(issuer string, ks *KeySet) (*TokenIssuer, error) {
+	if err := validateProviderIssuer(issuer); err != nil {
+		return nil, err
+	}
+
+	return &TokenIssuer{issuer: strings.TrimSuffix(issuer, "/"), keys: ks, now: time.Now}, nil
+}
+
+// Rotate generates a new RSA signing key for the algorithm alg, i.e.: RS256, and adds it to the set.
+// The new key signs the tokens issued from then on, the previous keys remain published.
+func (ti *TokenIssuer) Rotate(alg string) error {
+	if !isRSASigningAlgorithm(alg) {
+		return &SetupError{
+			Code:    SetupErrorUnsupportedKeyAlgorithm,
+			Message: fmt.Sprintf("Tokens cannot be signed with the algorithm %v.", alg),
+		}
+	}
+
+	k, err := GenerateKey(alg)
+	if err != nil {
+		return err
+	}
+
+	ti.keys.Add(k)
+	return nil
+}
+
+// Issue returns a token with the given claims signed with the current key. The 'iss', 'iat' and
+// 'exp' claims are set to the issuer, the current time and the time ttl from now, unless present.
+func (ti *TokenIssuer) Issue(claims map[string]interface{}, ttl time.Duration) (string, error) {
+	k, ok := ti.signingKey()
+	if !ok {
+		return "", &SetupError{
+			Code:    SetupErrorSigningKeyNotFound,
+			Message: "The key set of the token issuer does not contain a RSA signing key.",
+		}
+	}
+
+	now := ti.now()
+	tc := jwt.MapClaims{issuerClaimName: ti.issuer, issuedAtClaimName: now.Unix(), expirationClaimName: now.Add(ttl).Unix()}
+	for n, v := range claims {
+		tc[n] = v
+	}
+
+	t := jwt.NewWithClaims(jwt.GetSigningMethod(k.Algorithm), tc)
+	t.Header[keyIDJwtHeaderName] = k.KeyID
+
+	return t.SignedString(k.Key)
+}
+
+// Handler returns an http.Handler serving the discovery document at
+// /.well-known/openid-configuration and the public keys at WellKnownJwksPath, relative
+// to the path of the issuer.
+func (ti *TokenIssuer) Handler() http.Handler {
+	prefix := ""
+	if u, err := url.Parse(ti.issuer); err == nil {
+		prefix = u.Path
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(prefix+WellKnownJwksPath, JwksHandler(ti.keys))
+	mux.HandleFunc(prefix+wellKnownOpenIDConfiguration, ti.serveConfiguration)
+	return mux
+}
+
+func (ti *TokenIssuer) serveConfiguration(w http.ResponseWriter, r *http.Request) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"issuer":                                ti.issuer,
+		"jwks_uri":                              ti.issuer + WellKnownJwksPath,
+		"id_token_signing_alg_values_supported": ti.algorithms(),
+		"subject_types_supported":               []string{"public"},
+		"response_types_supported":              []string{"id_token"},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// signingKey returns the RSA signing key most recently added to the set.
+func (ti *TokenIssuer) signingKey() (jose.JSONWebKey, bool) {
+	ti.keys.mu.RLock()
+	defer ti.keys.mu.RUnlock()
+
+	for i := len(ti.keys.keys) - 1; i >= 0; i-- {
+		k := ti.keys.keys[i]
+		if _, ok := k.Key.(*rsa.PrivateKey); ok && k.Use != KeyUseEncryption && isRSASigningAlgorithm(k.Algorithm) {
+			return k, true
+		}
+	}
+
+	return jose.JSONWebKey{}, false
+}
+
+func (ti *TokenIssuer) algorithms() []string {
+	algs := []string{}
+	for _, k := range ti.keys.Public().Keys {
+		if isRSASigningAlgorithm(k.Algorithm) && !containsString(algs, k.Algorithm) {
+			algs = append(algs, k.Algorithm)
+		}
+	}
+
+	return algs
+}
+
+func isRSASigningAlgorithm(alg string) bool {
+	return containsString([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, alg)
+}
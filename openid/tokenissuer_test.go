@@ -0,0 +1,81 @@
+package openid
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func Test_TokenIssuer_Issue_WhenNoSigningKey(t *testing.T) {
+	k, _ := GenerateKey("ES256")
+	ti, _ := NewTokenIssuer("https://issuer", NewKeySet(k))
+
+	_, err := ti.Issue(map[string]interface{}{"sub": "user1"}, time.Hour)
+
+	expectSetupError(t, err, SetupErrorSigningKeyNotFound)
+}
+
+func Test_TokenIssuer_Rotate_WhenAlgorithmNotSupported(t *testing.T) {
+	ti, _ := NewTokenIssuer("https://issuer", NewKeySet())
+
+	expectSetupError(t, ti.Rotate("ES256"), SetupErrorUnsupportedKeyAlgorithm)
+}
+
+func Test_TokenIssuer_IssuesTokensValidatedByMiddleware(t *testing.T) {
+	var h http.Handler
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	iss := s.URL + "/tenant"
+	ks := NewKeySet()
+	ti, err := NewTokenIssuer(iss, ks)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	h = ti.Handler()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: iss, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	var u *User
+	a := AuthenticateUser(c, func(au *User, w http.ResponseWriter, r *http.Request) {
+		u = au
+	})
+
+	for _, alg := range []string{"RS256", "PS384"} {
+		if err := ti.Rotate(alg); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		ts, err := ti.Issue(map[string]interface{}{"sub": "user-" + alg, "aud": "app"}, time.Hour)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		pt, _ := jwt.Parse(ts, nil)
+		if pt.Header["alg"] != alg || pt.Header["kid"] == "" {
+			t.Error("Unexpected token header", pt.Header)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK || u == nil || u.ID != "user-"+alg || u.Issuer != iss {
+			t.Fatal(fmt.Sprintf("Expected the token signed with %v to be accepted, but got %v %v %+v", alg, w.Code, w.Body, u))
+		}
+	}
+
+	if n := len(ks.Public().Keys); n != 2 {
+		t.Error("Expected the rotated keys to remain published, but got", n)
+	}
+}
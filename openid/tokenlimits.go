@@ -0,0 +1,122 @@
+package openid
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// tokenLimits contains the limits enforced on a raw token before it is parsed
+// and its signature verified. A zero value disables the respective limit.
+type tokenLimits struct {
+	maxLength int
+	maxClaims int
+	maxDepth  int
+}
+
+// TokenLimits option registers limits for the size and structure of the tokens accepted by
+// the middleware. The maxLength limits the number of characters of the raw token, maxClaims limits
+// the number of claims present in the token payload and maxDepth limits how deep objects and arrays
+// can be nested within the payload. Tokens exceeding any of the limits are rejected before any
+// signature verification takes place. A value of zero disables the respective limit.
+func TokenLimits(maxLength int, maxClaims int, maxDepth int) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenLimits = &tokenLimits{maxLength, maxClaims, maxDepth}
+		return nil
+	}
+}
+
+func (l *tokenLimits) check(t string) error {
+	if l.maxLength > 0 && len(t) > l.maxLength {
+		return tokenLimitError(fmt.Sprintf("The token length exceeds the limit of %v characters.", l.maxLength))
+	}
+
+	if l.maxClaims <= 0 && l.maxDepth <= 0 {
+		return nil
+	}
+
+	p := strings.Split(t, ".")
+	if len(p) != 3 {
+		// Malformed tokens are rejected by the jwt parser.
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	return l.checkPayload(payload)
+}
+
+// checkPayload walks through the JSON payload counting the top level claims and the nesting
+// depth without decoding the values.
+func (l *tokenLimits) checkPayload(payload []byte) error {
+	type level struct {
+		object    bool
+		expectKey bool
+	}
+
+	var stack []level
+	claims := 0
+
+	valueRead := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].expectKey = true
+		}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(payload))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			// Malformed payloads are rejected by the jwt parser.
+			return nil
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				valueRead()
+				stack = append(stack, level{object: delim == '{', expectKey: true})
+
+				if l.maxDepth > 0 && len(stack) > l.maxDepth {
+					return tokenLimitError(fmt.Sprintf("The token payload exceeds the nesting depth limit of %v.", l.maxDepth))
+				}
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+			stack[n-1].expectKey = false
+
+			if n == 1 {
+				claims++
+				if l.maxClaims > 0 && claims > l.maxClaims {
+					return tokenLimitError(fmt.Sprintf("The token payload exceeds the limit of %v claims.", l.maxClaims))
+				}
+			}
+			continue
+		}
+
+		valueRead()
+	}
+}
+
+func tokenLimitError(m string) error {
+	return &ValidationError{
+		Code:       ValidationErrorTokenLimitExceeded,
+		Message:    m,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
@@ -0,0 +1,56 @@
+package openid
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_tokenLimits_WhenTokenExceedsMaxLength(t *testing.T) {
+	l := &tokenLimits{maxLength: 10}
+
+	err := l.check(strings.Repeat("a", 11))
+
+	expectValidationError(t, err, ValidationErrorTokenLimitExceeded, http.StatusBadRequest, nil)
+}
+
+func Test_tokenLimits_WhenTokenExceedsMaxClaims(t *testing.T) {
+	l := &tokenLimits{maxClaims: 2}
+
+	err := l.check(createTokenWithPayload(`{"iss":"https://issuer","sub":"SUB1","aud":["a","b"]}`))
+
+	expectValidationError(t, err, ValidationErrorTokenLimitExceeded, http.StatusBadRequest, nil)
+}
+
+func Test_tokenLimits_WhenTokenExceedsMaxDepth(t *testing.T) {
+	l := &tokenLimits{maxDepth: 2}
+
+	err := l.check(createTokenWithPayload(`{"a":{"b":[1]}}`))
+
+	expectValidationError(t, err, ValidationErrorTokenLimitExceeded, http.StatusBadRequest, nil)
+}
+
+func Test_tokenLimits_WhenTokenIsWithinLimits(t *testing.T) {
+	l := &tokenLimits{maxLength: 1000, maxClaims: 3, maxDepth: 2}
+
+	err := l.check(createTokenWithPayload(`{"iss":"https://issuer","cnf":{"x5t#S256":"a","b":1},"aud":["a","b"]}`))
+
+	if err != nil {
+		t.Error("An error was returned but not expected", err)
+	}
+}
+
+func Test_tokenLimits_WhenTokenIsMalformed(t *testing.T) {
+	l := &tokenLimits{maxClaims: 1, maxDepth: 1}
+
+	for _, tk := range []string{"notajwt", "a.!!!.c", createTokenWithPayload(`{"a":`)} {
+		if err := l.check(tk); err != nil {
+			t.Error("An error was returned but not expected", err)
+		}
+	}
+}
+
+func createTokenWithPayload(p string) string {
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(p)) + ".signature"
+}
@@ -0,0 +1,167 @@
+package openid
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const forwardedProtoHeaderName = "X-Forwarded-Proto"
+const forwardedHeaderName = "Forwarded"
+const strictTransportSecurityHeaderName = "Strict-Transport-Security"
+
+// transportPolicy contains the requirements enforced on the transport of the
+// requests before their tokens are processed.
+type transportPolicy struct {
+	requireTLS     bool
+	trustedProxies []*net.IPNet
+	hsts           string
+}
+
+// RequireTLS option rejects the requests not received over TLS before their token is processed,
+// preventing the accidental deployment of bearer authentication over plaintext.
+// When the service runs behind proxies terminating TLS, provide their addresses, either IPs or
+// CIDRs, as trustedProxies: the protocol of requests sent by those proxies is read from the
+// X-Forwarded-Proto or the Forwarded headers. The headers of any other client are ignored.
+func RequireTLS(trustedProxies ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		tp := c.transportPolicy()
+		tp.requireTLS = true
+
+		for _, p := range trustedProxies {
+			n, err := parseTrustedProxy(p)
+			if err != nil {
+				return err
+			}
+
+			tp.trustedProxies = append(tp.trustedProxies, n)
+		}
+
+		return nil
+	}
+}
+
+// StrictTransportSecurity option sets the Strict-Transport-Security (HSTS) header with the given
+// maxAge on the responses to the requests handled by the middlewares, so browsers only contact the
+// service over TLS.
+func StrictTransportSecurity(maxAge time.Duration, includeSubDomains bool) func(*Configuration) error {
+	return func(c *Configuration) error {
+		hsts := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+		if includeSubDomains {
+			hsts += "; includeSubDomains"
+		}
+
+		c.transportPolicy().hsts = hsts
+		return nil
+	}
+}
+
+func (c *Configuration) transportPolicy() *transportPolicy {
+	if c.transport == nil {
+		c.transport = &transportPolicy{}
+	}
+
+	return c.transport
+}
+
+func (tp *transportPolicy) enforce(rw http.ResponseWriter, r *http.Request) error {
+	if tp.hsts != "" {
+		rw.Header().Set(strictTransportSecurityHeaderName, tp.hsts)
+	}
+
+	if tp.requireTLS && !tp.isTLS(r) {
+		return &ValidationError{
+			Code:       ValidationErrorInsecureTransport,
+			Message:    "Tokens are only accepted over TLS.",
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
+
+// isTLS returns true when the request was received over TLS, either directly or
+// through a trusted proxy.
+func (tp *transportPolicy) isTLS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	if !tp.isTrustedProxy(r.RemoteAddr) {
+		return false
+	}
+
+	return strings.EqualFold(forwardedProto(r), "https")
+}
+
+func (tp *transportPolicy) isTrustedProxy(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range tp.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedProto returns the protocol reported by the closest proxy, from the Forwarded
+// header or, when missing, from the X-Forwarded-Proto header.
+func forwardedProto(r *http.Request) string {
+	if f := r.Header.Get(forwardedHeaderName); f != "" {
+		// The last element was added by the closest proxy.
+		es := strings.Split(f, ",")
+		for _, pair := range strings.Split(es[len(es)-1], ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "proto") {
+				return strings.Trim(kv[1], `"`)
+			}
+		}
+
+		return ""
+	}
+
+	ps := strings.Split(r.Header.Get(forwardedProtoHeaderName), ",")
+	return strings.TrimSpace(ps[len(ps)-1])
+}
+
+func parseTrustedProxy(p string) (*net.IPNet, error) {
+	if !strings.Contains(p, "/") {
+		ip := net.ParseIP(p)
+		if ip == nil {
+			return nil, trustedProxyError(p)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, n, err := net.ParseCIDR(p)
+	if err != nil {
+		return nil, trustedProxyError(p)
+	}
+
+	return n, nil
+}
+
+func trustedProxyError(p string) error {
+	return &SetupError{
+		Code:    SetupErrorInvalidTrustedProxy,
+		Message: fmt.Sprintf("The trusted proxy %v is not a valid IP or CIDR.", p),
+	}
+}
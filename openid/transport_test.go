@@ -0,0 +1,99 @@
+package openid
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportPolicy_Enforce(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		tls        bool
+		headers    map[string]string
+		allowed    bool
+	}{
+		{"203.0.113.1:1234", true, nil, true},
+		{"203.0.113.1:1234", false, nil, false},
+		{"203.0.113.1:1234", false, map[string]string{"X-Forwarded-Proto": "https"}, false},
+		{"10.0.0.5:1234", false, map[string]string{"X-Forwarded-Proto": "https"}, true},
+		{"10.0.0.5:1234", false, map[string]string{"X-Forwarded-Proto": "HTTPS"}, true},
+		{"10.0.0.5:1234", false, map[string]string{"X-Forwarded-Proto": "https, http"}, false},
+		{"10.0.0.5:1234", false, map[string]string{"X-Forwarded-Proto": "http"}, false},
+		{"10.0.0.5:1234", false, nil, false},
+		{"192.168.1.1:1234", false, map[string]string{"Forwarded": `for=192.0.2.60;proto="https"`}, true},
+		{"192.168.1.1:1234", false, map[string]string{"Forwarded": "proto=https, for=192.0.2.60;proto=http"}, false},
+		{"192.168.1.1:1234", false, map[string]string{"Forwarded": "for=192.0.2.60", "X-Forwarded-Proto": "https"}, false},
+		{"192.168.1.2:1234", false, map[string]string{"X-Forwarded-Proto": "https"}, false},
+	}
+
+	c, e := NewConfiguration(RequireTLS("10.0.0.0/8", "192.168.1.1"))
+	if e != nil {
+		t.Fatal("An error was returned but not expected", e)
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if !test.tls {
+			r.TLS = nil
+		} else {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+
+		e := c.transport.enforce(httptest.NewRecorder(), r)
+
+		if test.allowed {
+			if e != nil {
+				t.Error("Test", i, "an error was returned but not expected", e)
+			}
+			continue
+		}
+
+		expectValidationError(t, e, ValidationErrorInsecureTransport, http.StatusForbidden, nil)
+	}
+}
+
+func TestRequireTLS_WhenTrustedProxyInvalid(t *testing.T) {
+	_, e := NewConfiguration(RequireTLS("not an ip"))
+
+	expectSetupError(t, e, SetupErrorInvalidTrustedProxy)
+
+	_, e = NewConfiguration(RequireTLS("10.0.0.0/99"))
+
+	expectSetupError(t, e, SetupErrorInvalidTrustedProxy)
+}
+
+func TestStrictTransportSecurity_SetsHeader(t *testing.T) {
+	c, _ := NewConfiguration(StrictTransportSecurity(365*24*time.Hour, true))
+	w := httptest.NewRecorder()
+
+	e := c.transport.enforce(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if e != nil {
+		t.Error("An error was returned but not expected", e)
+	}
+
+	if h := w.Header().Get("Strict-Transport-Security"); h != "max-age=31536000; includeSubDomains" {
+		t.Error("Expected the HSTS header 'max-age=31536000; includeSubDomains', but got", h)
+	}
+}
+
+func Test_authenticate_WhenTransportNotSecure(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	c.transport = &transportPolicy{requireTLS: true}
+
+	_, rt, halt := authenticate(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://service/", nil))
+
+	if rt != nil || !halt {
+		t.Error("The authentication should have halted without a token, but got", rt, halt)
+	}
+
+	vm.AssertExpectations(t)
+}
@@ -0,0 +1,135 @@
+package openid
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const typedClaimsTagName = "openid"
+
+// TypedClaims option decodes the claims of every validated token into a new value of the struct
+// type pointed by v, using the encoding/json rules, and stores the pointer to it in the request
+// context, see openidctx.Claims. Fields tagged with `openid:"required"` must be present in the
+// token and not null:
+//
+//	type MyClaims struct {
+//	    Email  string   `json:"email" openid:"required"`
+//	    Groups []string `json:"groups"`
+//	}
+//
+//	c, _ := openid.NewConfiguration(openid.ProvidersGetter(myGetProviders),
+//	                                openid.TypedClaims(&MyClaims{}))
+//
+// Tokens missing required claims or with claims not matching the types of the fields are rejected
+// with the error code ValidationErrorInvalidClaims and status 401/Unauthorized.
+func TypedClaims(v interface{}) func(*Configuration) error {
+	return func(c *Configuration) error {
+		t := reflect.TypeOf(v)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+			return &SetupError{
+				Code:    SetupErrorInvalidTypedClaims,
+				Message: fmt.Sprintf("The typed claims must be a pointer to a struct, but got %T.", v),
+			}
+		}
+
+		c.typedClaims = &typedClaims{t.Elem(), requiredClaims(t.Elem())}
+		return nil
+	}
+}
+
+type typedClaims struct {
+	typ      reflect.Type
+	required []string
+}
+
+// decode returns a pointer to a new value of the struct type holding the claims of the token.
+func (tc *typedClaims) decode(t *jwt.Token) (interface{}, error) {
+	claims := t.Claims.(jwt.MapClaims)
+
+	for _, n := range tc.required {
+		if !hasClaim(claims, n) {
+			return nil, &ValidationError{
+				Code:       ValidationErrorInvalidClaims,
+				Message:    fmt.Sprintf("The token does not contain the required claim %v.", n),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	v := reflect.New(tc.typ).Interface()
+
+	data, err := json.Marshal(claims)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+
+	if err != nil {
+		m := "The token claims do not match the typed claims."
+		if ute, ok := err.(*json.UnmarshalTypeError); ok && ute.Field != "" {
+			m = fmt.Sprintf("The claim %v is not of the expected type %v.", ute.Field, ute.Type)
+		}
+
+		return nil, &ValidationError{
+			Code:       ValidationErrorInvalidClaims,
+			Message:    m,
+			Err:        err,
+			HTTPStatus: http.StatusUnauthorized,
+		}
+	}
+
+	return v, nil
+}
+
+// hasClaim returns true when the claims contain a non null claim named n, ignoring case
+// as encoding/json does when decoding into the struct.
+func hasClaim(claims jwt.MapClaims, n string) bool {
+	if v, ok := claims[n]; ok {
+		return v != nil
+	}
+
+	for k, v := range claims {
+		if strings.EqualFold(k, n) && v != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requiredClaims returns the names of the claims of the fields of the struct type t, and of
+// its embedded structs, tagged as required.
+func requiredClaims(t reflect.Type) []string {
+	var names []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jt := f.Tag.Get("json")
+		n := strings.Split(jt, ",")[0]
+
+		if n == "-" && jt == "-" {
+			continue
+		}
+
+		if f.Anonymous && n == "" && f.Type.Kind() == reflect.Struct {
+			names = append(names, requiredClaims(f.Type)...)
+			continue
+		}
+
+		if f.Tag.Get(typedClaimsTagName) != "required" {
+			continue
+		}
+
+		if n == "" {
+			n = f.Name
+		}
+
+		names = append(names, n)
+	}
+
+	return names
+}
@@ -0,0 +1,112 @@
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid/internal/ctxkeys"
+	"github.com/stretchr/testify/mock"
+)
+
+type testBaseClaims struct {
+	Subject string `json:"sub" openid:"required"`
+}
+
+type testTypedClaims struct {
+	testBaseClaims
+	Email   string   `json:"email,omitempty" openid:"required"`
+	Groups  []string `json:"groups"`
+	Age     int      `openid:"required"`
+	Ignored string   `json:"-" openid:"required"`
+}
+
+func Test_TypedClaims_WhenNotPointerToStruct(t *testing.T) {
+	for _, v := range []interface{}{nil, testTypedClaims{}, new(string)} {
+		_, err := NewConfiguration(TypedClaims(v))
+
+		expectSetupError(t, err, SetupErrorInvalidTypedClaims)
+	}
+}
+
+func Test_TypedClaims_Decode(t *testing.T) {
+	c := &Configuration{}
+	if err := TypedClaims(&testTypedClaims{})(c); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	v, err := c.typedClaims.decode(&jwt.Token{Claims: jwt.MapClaims{
+		"sub":    "user1",
+		"email":  "user1@example.com",
+		"groups": []interface{}{"admin"},
+		"age":    float64(42),
+	}})
+
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	tc, ok := v.(*testTypedClaims)
+	if !ok {
+		t.Fatalf("Expected *testTypedClaims, but got %T", v)
+	}
+
+	if tc.Subject != "user1" || tc.Email != "user1@example.com" || len(tc.Groups) != 1 || tc.Groups[0] != "admin" || tc.Age != 42 {
+		t.Errorf("Unexpected typed claims %+v", tc)
+	}
+}
+
+func Test_TypedClaims_Decode_WhenInvalid(t *testing.T) {
+	c := &Configuration{}
+	TypedClaims(&testTypedClaims{})(c)
+
+	tests := []jwt.MapClaims{
+		{"email": "user1@example.com", "age": float64(42)},
+		{"sub": "user1", "email": nil, "age": float64(42)},
+		{"sub": "user1", "email": "user1@example.com"},
+		{"sub": "user1", "email": "user1@example.com", "age": "42"},
+		{"sub": "user1", "email": "user1@example.com", "age": float64(42), "groups": "admin"},
+	}
+
+	for _, claims := range tests {
+		_, err := c.typedClaims.decode(&jwt.Token{Claims: claims})
+
+		expectValidationError(t, err, ValidationErrorInvalidClaims, http.StatusUnauthorized, nil)
+	}
+}
+
+func Test_authenticate_StoresTypedClaims(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims = jwt.MapClaims{"sub": "user1", "email": "user1@example.com", "age": float64(42)}
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{}, nil)
+	TypedClaims(&testTypedClaims{})(c)
+
+	ar, _, halt := authenticate(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if halt {
+		t.Fatal("The authentication should not have halted.")
+	}
+
+	if tc, ok := ar.Context().Value(ctxkeys.Claims).(*testTypedClaims); !ok || tc.Subject != "user1" {
+		t.Errorf("Expected the typed claims in the request context, but got %+v", ar.Context().Value(ctxkeys.Claims))
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func Test_authenticate_WhenTypedClaimsInvalid(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	jt := jwt.New(jwt.SigningMethodRS256)
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{}, nil)
+	TypedClaims(&testTypedClaims{})(c)
+
+	_, rt, halt := authenticate(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rt != nil || !halt {
+		t.Error("The authentication should have halted without a token, but got", rt, halt)
+	}
+
+	vm.AssertExpectations(t)
+}
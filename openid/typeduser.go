@@ -0,0 +1,69 @@
+//go:build go1.18
+// +build go1.18
+
+package openid
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// UserHandlerAs represents a handler to be registered by the middleware AuthenticateUserAs. It
+// receives the claims of the authenticated user decoded into a new value of T.
+type UserHandlerAs[T any] func(*T, http.ResponseWriter, *http.Request)
+
+// AuthenticateUserAs middleware performs the validation of the OIDC ID Token as AuthenticateUser,
+// and forwards the claims of the authenticated user to the next handler(h) decoded into a new value
+// of T, as the TypedClaims option, so handlers do not convert the claims of the User themselves:
+//
+//	type MyUser struct {
+//	    Subject string   `json:"sub"`
+//	    Email   string   `json:"email" openid:"required"`
+//	    Groups  []string `json:"groups"`
+//	}
+//
+//	http.Handle("/me", openid.AuthenticateUserAs(configuration, func(u *MyUser, w http.ResponseWriter, r *http.Request) {
+//	    ...
+//	}))
+//
+// Tokens missing the claims required by T or with claims not matching the types of its fields are
+// handled by the ErrorHandlerFunc with the error code ValidationErrorInvalidClaims. The h receives
+// a nil *T when the request was skipped or the ErrorHandlerFunc chose to continue after a failed
+// authentication. The User and the *T are also stored in the request context, see the openidctx
+// package.
+func AuthenticateUserAs[T any](conf *Configuration, h UserHandlerAs[T]) http.Handler {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	tc := &typedClaims{typ: t}
+	if t.Kind() == reflect.Struct {
+		tc.required = requiredClaims(t)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ar, u, halt := authenticateUser(conf, w, r)
+		if halt {
+			return
+		}
+
+		if u == nil {
+			h(nil, w, ar)
+			return
+		}
+
+		v, err := tc.decode(&jwt.Token{Claims: jwt.MapClaims(u.Claims)})
+		if err != nil {
+			eh := conf.errorHandler
+			if eh == nil {
+				eh = validationErrorToHTTPStatus
+			}
+
+			if !eh(err, w, ar) {
+				h(nil, w, ar)
+			}
+			return
+		}
+
+		h(v.(*T), w, withClaims(ar, v))
+	})
+}
@@ -0,0 +1,79 @@
+//go:build go1.18
+// +build go1.18
+
+package openid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testTypedUser struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email" openid:"required"`
+	Groups  []string `json:"groups"`
+}
+
+func Test_AuthenticateUserAs(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	var called bool
+	var u *testTypedUser
+	h := AuthenticateUserAs(c, func(tu *testTypedUser, w http.ResponseWriter, r *http.Request) {
+		called, u = true, tu
+	})
+
+	tests := []struct {
+		claims map[string]interface{}
+		called bool
+		status int
+	}{
+		{map[string]interface{}{"email": "user1@example.com", "groups": []interface{}{"admins"}}, true, http.StatusOK},
+		{map[string]interface{}{"groups": []interface{}{"admins"}}, false, http.StatusUnauthorized},
+		{map[string]interface{}{"email": "user1@example.com", "groups": "admins"}, false, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		called, u = false, nil
+		claims := map[string]interface{}{"sub": "user1", "aud": "app"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ts)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if called != test.called || w.Code != test.status {
+			t.Errorf("Expected called %v and status %v with the claims %v, but got %v and %v", test.called, test.status, test.claims, called, w.Code)
+		}
+
+		if test.called && (u == nil || u.Subject != "user1" || u.Email != "user1@example.com" || len(u.Groups) != 1) {
+			t.Error("Expected the typed user user1, but got", u)
+		}
+	}
+}
+
+func Test_AuthenticateUserAs_WhenSkipped(t *testing.T) {
+	c, _ := NewConfiguration(SkipPaths("/public"))
+
+	called := false
+	h := AuthenticateUserAs(c, func(tu *map[string]interface{}, w http.ResponseWriter, r *http.Request) {
+		called = tu == nil
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/public", nil))
+
+	if !called {
+		t.Error("Expected the skipped request to be forwarded with a nil value.")
+	}
+}
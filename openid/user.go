@@ -1,6 +1,7 @@
 package openid
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dgrijalva/jwt-go"
@@ -10,16 +11,49 @@ import (
 //
 // The Issuer contains the value from the 'iss' claim found in the ID Token.
 //
-// The ID contains the value of the 'sub' claim found in the ID Token.
+// The ID contains the value of the 'sub' claim found in the ID Token, or of the claim named by the
+// UserIDClaim of the provider, when set.
 //
-// The Claims contains all the claims present found in the ID Token
+// The Claims contains all the claims present found in the ID Token. GetString, GetBool, GetInt,
+// GetTime and GetStringSlice read them without type assertions.
+//
+// The Groups contains the groups of the user found in the claim named by the GroupsClaim of the
+// provider, when set, i.e.: to authorize the user downstream.
+//
+// The Roles contains the roles of the user returned by the RolesFunc of the provider, when set.
+//
+// The Email, EmailVerified, Name, GivenName, FamilyName, Picture and Locale contain the values of
+// the respective standard claims, see http://openid.net/specs/openid-connect-core-1_0.html#StandardClaims,
+// and are empty when the token does not contain them.
 type User struct {
 	Issuer string
 	ID     string
 	Claims map[string]interface{}
+	Groups []string
+	Roles  []string
+
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+	Locale        string
+
+	// redacted contains the names of the claims omitted from the JSON encoding, see RedactClaims.
+	redacted []string
+	// idClaim, groupsClaim and rolesFunc are the sources of the ID, the 'sub' claim when empty, of
+	// the Groups and of the Roles, emptied in the JSON encoding when their claims are redacted.
+	idClaim     string
+	groupsClaim string
+	rolesFunc   RolesFunc
 }
 
-func newUser(t *jwt.Token) (*User, error) {
+// RolesFunc returns the roles of the user from the claims of the token, see Provider.
+type RolesFunc func(claims map[string]interface{}) []string
+
+// newUser returns the user authenticated by the token t issued by the provider p, which may be nil.
+func newUser(t *jwt.Token, p *Provider) (*User, error) {
 	if t == nil {
 		return nil, &ValidationError{
 			Code:       ValidationErrorIdTokenEmpty,
@@ -53,5 +87,31 @@ func newUser(t *jwt.Token) (*User, error) {
 	u.Issuer = iss
 	u.ID = sub
 	u.Claims = t.Claims.(jwt.MapClaims)
+	u.setStandardClaims()
+
+	if p != nil && p.UserIDClaim != "" {
+		id, ok := u.GetString(p.UserIDClaim)
+		if !ok || id == "" {
+			return nil, &ValidationError{
+				Code:       ValidationErrorUserIDNotFound,
+				Message:    fmt.Sprintf("The token '%v' claim identifying the user was not found or was empty.", p.UserIDClaim),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+
+		u.ID = id
+		u.idClaim = p.UserIDClaim
+	}
+
+	if p != nil && p.GroupsClaim != "" {
+		u.Groups = claimStrings(u.Claims[p.GroupsClaim])
+		u.groupsClaim = p.GroupsClaim
+	}
+
+	if p != nil && p.RolesFunc != nil {
+		u.Roles = p.RolesFunc(u.Claims)
+		u.rolesFunc = p.RolesFunc
+	}
+
 	return u, nil
 }
@@ -0,0 +1,143 @@
+package openid
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetString returns the value of the claim with the given name as a string. Numbers are
+// formatted in decimal notation. It returns false when the user has no such claim or its value
+// is not a string or a number.
+func (u *User) GetString(name string) (string, bool) {
+	switch v := u.claim(name).(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case int:
+		return strconv.Itoa(v), true
+	}
+
+	return "", false
+}
+
+// GetBool returns the value of the claim with the given name as a bool. Some providers send
+// booleans as strings, i.e.: the 'email_verified' claim, so "true" and "false" are accepted as
+// well, ignoring case. It returns false when the user has no such claim or its value is not a bool.
+func (u *User) GetBool(name string) (bool, bool) {
+	switch v := u.claim(name).(type) {
+	case bool:
+		return v, true
+	case string:
+		if strings.EqualFold(v, "true") {
+			return true, true
+		}
+
+		if strings.EqualFold(v, "false") {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+// GetInt returns the value of the claim with the given name as an int64. Numbers decoded as
+// float64 or json.Number and numeric strings are accepted when they hold an integer. It returns
+// false when the user has no such claim or its value is not an integer.
+func (u *User) GetInt(name string) (int64, bool) {
+	switch v := u.claim(name).(type) {
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return int64(v), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, true
+		}
+	}
+
+	return 0, false
+}
+
+// GetTime returns the value of the claim with the given name as a time. Numbers and numeric
+// strings are the seconds since epoch, as the 'exp', 'iat' and 'auth_time' claims, and other
+// strings must be in the RFC 3339 format. It returns false when the user has no such claim or
+// its value is not a time.
+func (u *User) GetTime(name string) (time.Time, bool) {
+	var secs float64
+
+	switch v := u.claim(name).(type) {
+	case float64:
+		secs = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		secs = f
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			secs = f
+			break
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+
+	if math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return time.Time{}, false
+	}
+
+	s, f := math.Modf(secs)
+	return time.Unix(int64(s), int64(f*1e9)), true
+}
+
+// GetStringSlice returns the value of the claim with the given name as a slice of strings. Arrays
+// keep their string elements, and strings are split on spaces as the 'scope' claim. It returns
+// false when the user has no such claim or its value is neither an array nor a string.
+func (u *User) GetStringSlice(name string) ([]string, bool) {
+	s := claimStrings(u.claim(name))
+	return s, s != nil
+}
+
+// claim returns the value of the claim with the given name, or nil when the user has no such claim.
+func (u *User) claim(name string) interface{} {
+	if u == nil {
+		return nil
+	}
+
+	return u.Claims[name]
+}
+
+// setStandardClaims sets the fields of the user holding the standard claims of its token.
+func (u *User) setStandardClaims() {
+	u.Email, _ = u.Claims[emailClaimName].(string)
+	u.EmailVerified = isEmailVerified(u.Claims[emailVerifiedClaimName])
+	u.Name, _ = u.Claims["name"].(string)
+	u.GivenName, _ = u.Claims["given_name"].(string)
+	u.FamilyName, _ = u.Claims["family_name"].(string)
+	u.Picture, _ = u.Claims["picture"].(string)
+	u.Locale, _ = u.Claims["locale"].(string)
+}
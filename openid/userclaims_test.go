@@ -0,0 +1,205 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newClaimsUser() *User {
+	return &User{Claims: map[string]interface{}{
+		"name":           "User One",
+		"age":            float64(42),
+		"ratio":          float64(1.5),
+		"big":            json.Number("9007199254740993"),
+		"age_str":        "42",
+		"email_verified": "True",
+		"admin":          false,
+		"exp":            float64(1700000000),
+		"auth_time":      json.Number("1700000000.5"),
+		"updated_at":     "2023-11-14T22:13:20Z",
+		"groups":         []interface{}{"a", 1, "b"},
+		"scope":          "read write",
+		"nested":         map[string]interface{}{"a": "b"},
+	}}
+}
+
+func TestUser_GetString(t *testing.T) {
+	u := newClaimsUser()
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"name", "User One", true},
+		{"age", "42", true},
+		{"ratio", "1.5", true},
+		{"big", "9007199254740993", true},
+		{"admin", "", false},
+		{"nested", "", false},
+		{"missing", "", false},
+	}
+
+	for _, test := range tests {
+		if v, ok := u.GetString(test.name); v != test.value || ok != test.ok {
+			t.Errorf("Expected %q, %v for the claim %v, but got %q, %v", test.value, test.ok, test.name, v, ok)
+		}
+	}
+}
+
+func TestUser_GetBool(t *testing.T) {
+	u := newClaimsUser()
+	tests := []struct {
+		name  string
+		value bool
+		ok    bool
+	}{
+		{"email_verified", true, true},
+		{"admin", false, true},
+		{"name", false, false},
+		{"age", false, false},
+		{"missing", false, false},
+	}
+
+	for _, test := range tests {
+		if v, ok := u.GetBool(test.name); v != test.value || ok != test.ok {
+			t.Errorf("Expected %v, %v for the claim %v, but got %v, %v", test.value, test.ok, test.name, v, ok)
+		}
+	}
+}
+
+func TestUser_GetInt(t *testing.T) {
+	u := newClaimsUser()
+	tests := []struct {
+		name  string
+		value int64
+		ok    bool
+	}{
+		{"age", 42, true},
+		{"age_str", 42, true},
+		{"big", 9007199254740993, true},
+		{"ratio", 0, false},
+		{"name", 0, false},
+		{"missing", 0, false},
+	}
+
+	for _, test := range tests {
+		if v, ok := u.GetInt(test.name); v != test.value || ok != test.ok {
+			t.Errorf("Expected %v, %v for the claim %v, but got %v, %v", test.value, test.ok, test.name, v, ok)
+		}
+	}
+}
+
+func TestUser_GetTime(t *testing.T) {
+	u := newClaimsUser()
+	exp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name  string
+		value time.Time
+		ok    bool
+	}{
+		{"exp", exp, true},
+		{"auth_time", exp.Add(500 * time.Millisecond), true},
+		{"updated_at", exp, true},
+		{"age_str", time.Unix(42, 0), true},
+		{"name", time.Time{}, false},
+		{"admin", time.Time{}, false},
+		{"missing", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		if v, ok := u.GetTime(test.name); !v.Equal(test.value) || ok != test.ok {
+			t.Errorf("Expected %v, %v for the claim %v, but got %v, %v", test.value, test.ok, test.name, v, ok)
+		}
+	}
+}
+
+func TestUser_GetStringSlice(t *testing.T) {
+	u := newClaimsUser()
+	tests := []struct {
+		name  string
+		value []string
+		ok    bool
+	}{
+		{"groups", []string{"a", "b"}, true},
+		{"scope", []string{"read", "write"}, true},
+		{"age", nil, false},
+		{"missing", nil, false},
+	}
+
+	for _, test := range tests {
+		if v, ok := u.GetStringSlice(test.name); !reflect.DeepEqual(v, test.value) || ok != test.ok {
+			t.Errorf("Expected %v, %v for the claim %v, but got %v, %v", test.value, test.ok, test.name, v, ok)
+		}
+	}
+
+	var nu *User
+	if _, ok := nu.GetString("name"); ok {
+		t.Error("Expected a nil user to have no claims")
+	}
+}
+
+func Test_newUser_SetsStandardClaims(t *testing.T) {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims = jwt.MapClaims{
+		"iss":            "https://issuer",
+		"sub":            "user1",
+		"email":          "user1@example.com",
+		"email_verified": "true",
+		"name":           "User One",
+		"given_name":     "User",
+		"family_name":    "One",
+		"picture":        "https://example.com/user1.png",
+		"locale":         "en-US",
+	}
+
+	u, err := newUser(jt, nil)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	expected := User{
+		Email:         "user1@example.com",
+		EmailVerified: true,
+		Name:          "User One",
+		GivenName:     "User",
+		FamilyName:    "One",
+		Picture:       "https://example.com/user1.png",
+		Locale:        "en-US",
+	}
+
+	if u.Email != expected.Email || u.EmailVerified != expected.EmailVerified || u.Name != expected.Name ||
+		u.GivenName != expected.GivenName || u.FamilyName != expected.FamilyName || u.Picture != expected.Picture || u.Locale != expected.Locale {
+		t.Errorf("Expected the standard claims %+v, but got %+v", expected, u)
+	}
+
+	jt.Claims = jwt.MapClaims{"iss": "https://issuer", "sub": "user1", "email": 1}
+	if u, _ = newUser(jt, nil); u.Email != "" || u.EmailVerified || u.Name != "" {
+		t.Error("Expected no standard claims, but got", u)
+	}
+}
+
+func Test_newUser_UserIDClaim(t *testing.T) {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims = jwt.MapClaims{"iss": "https://issuer", "sub": "pairwise", "oid": "00000000-0000-0000-0000-000000000001"}
+
+	u, err := newUser(jt, &Provider{Issuer: "https://issuer", UserIDClaim: "oid"})
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if u.ID != "00000000-0000-0000-0000-000000000001" || u.Claims["sub"] != "pairwise" {
+		t.Error("Expected the ID of the 'oid' claim, but got", u.ID)
+	}
+
+	if u, _ = newUser(jt, &Provider{Issuer: "https://issuer"}); u.ID != "pairwise" {
+		t.Error("Expected the ID of the 'sub' claim, but got", u.ID)
+	}
+
+	_, err = newUser(jt, &Provider{Issuer: "https://issuer", UserIDClaim: "email"})
+	expectValidationError(t, err, ValidationErrorUserIDNotFound, http.StatusUnauthorized, nil)
+}
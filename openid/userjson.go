@@ -0,0 +1,140 @@
+package openid
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// defaultRedactedClaims contains the claims redacted from every marshaled User, as they carry
+// credentials of the user rather than information about it.
+var defaultRedactedClaims = []string{"access_token", "id_token", "refresh_token"}
+
+// RedactClaims option redacts the claims with the given names from the JSON encoding of the
+// users authenticated with the configuration, i.e.: to log the users or return them from a /me
+// endpoint without sensitive claims such as a social security number. The names of nested claims
+// are dotted paths, as with RolesClaim. The 'access_token', 'id_token' and 'refresh_token' claims
+// are always redacted. The Claims of the users are not modified.
+func RedactClaims(names ...string) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.redactedClaims = append(c.redactedClaims, names...)
+		return nil
+	}
+}
+
+// newUser returns the user authenticated by the token t issued by the provider p, from the claims
+// transformed by the TransformClaims option and redacting the claims of the RedactClaims option
+// from its JSON encoding.
+func (c *Configuration) newUser(t *jwt.Token, p *Provider) (*User, error) {
+	t, err := c.transformClaims(t, p)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := newUser(t, p)
+	if err != nil {
+		return nil, err
+	}
+
+	u.redacted = c.redactedClaims
+	return u, nil
+}
+
+// Redact returns a copy of the user whose JSON encoding also redacts the claims with the given
+// names, i.e.: to return a user from an endpoint without the claims only needed internally.
+func (u *User) Redact(names ...string) *User {
+	ru := *u
+	ru.redacted = append(append([]string(nil), u.redacted...), names...)
+	return &ru
+}
+
+// userJSON is the JSON encoding of a User, with the field names of the User as keys.
+type userJSON User
+
+// MarshalJSON returns the JSON encoding of the user, without the claims redacted by the
+// RedactClaims option of the configuration that authenticated it and by Redact. The fields holding
+// a redacted claim are emptied as well: the ID for its 'sub' claim, or the claim named by the
+// UserIDClaim of the provider, the Groups for the claim named by the GroupsClaim, the standard
+// claims, such as the Email for the 'email' claim, and the Roles returned by the RolesFunc from the
+// redacted claims.
+func (u User) MarshalJSON() ([]byte, error) {
+	uj := userJSON(u)
+	redacted := false
+
+	idClaim := u.idClaim
+	if idClaim == "" {
+		idClaim = subjectClaimName
+	}
+
+	for _, n := range append(append([]string(nil), defaultRedactedClaims...), u.redacted...) {
+		var ok bool
+		if uj.Claims, ok = redactClaim(uj.Claims, strings.Split(n, ".")); ok {
+			redacted = true
+		}
+
+		if n == idClaim {
+			uj.ID = ""
+		}
+
+		if n == u.groupsClaim {
+			uj.Groups = nil
+		}
+
+		switch n {
+		case emailClaimName:
+			uj.Email = ""
+		case emailVerifiedClaimName:
+			uj.EmailVerified = false
+		case "name":
+			uj.Name = ""
+		case "given_name":
+			uj.GivenName = ""
+		case "family_name":
+			uj.FamilyName = ""
+		case "picture":
+			uj.Picture = ""
+		case "locale":
+			uj.Locale = ""
+		}
+	}
+
+	if redacted && u.rolesFunc != nil {
+		uj.Roles = u.rolesFunc(uj.Claims)
+	}
+
+	return json.Marshal(uj)
+}
+
+// redactClaim returns the claims without the member at the path, copying the objects along the
+// path instead of modifying them, and true when the member was removed. The claims are returned
+// unchanged when they have no such member.
+func redactClaim(claims map[string]interface{}, path []string) (map[string]interface{}, bool) {
+	v, ok := claims[path[0]]
+	if !ok {
+		return claims, false
+	}
+
+	rc := make(map[string]interface{}, len(claims))
+	for n, cv := range claims {
+		rc[n] = cv
+	}
+
+	if len(path) == 1 {
+		delete(rc, path[0])
+		return rc, true
+	}
+
+	o, ok := v.(map[string]interface{})
+	if !ok {
+		return claims, false
+	}
+
+	ro, ok := redactClaim(o, path[1:])
+	if !ok {
+		return claims, false
+	}
+
+	rc[path[0]] = ro
+	return rc, true
+}
@@ -0,0 +1,136 @@
+package openid
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestUser_MarshalJSON(t *testing.T) {
+	u := &User{
+		Issuer: "https://issuer",
+		ID:     "user1",
+		Email:  "user1@example.com",
+		Name:   "User One",
+		Roles:  []string{"admin"},
+		Claims: map[string]interface{}{
+			"sub":          "user1",
+			"email":        "user1@example.com",
+			"name":         "User One",
+			"ssn":          "123-45-6789",
+			"access_token": "secret",
+			"address":      map[string]interface{}{"street": "1 Main St", "country": "US"},
+		},
+	}
+
+	tests := []struct {
+		user     *User
+		expected User
+	}{
+		{
+			u,
+			User{Issuer: "https://issuer", ID: "user1", Email: "user1@example.com", Name: "User One", Roles: []string{"admin"},
+				Claims: map[string]interface{}{"sub": "user1", "email": "user1@example.com", "name": "User One", "ssn": "123-45-6789",
+					"address": map[string]interface{}{"street": "1 Main St", "country": "US"}}},
+		},
+		{
+			u.Redact("ssn", "email", "address.street", "missing.member"),
+			User{Issuer: "https://issuer", ID: "user1", Name: "User One", Roles: []string{"admin"},
+				Claims: map[string]interface{}{"sub": "user1", "name": "User One", "address": map[string]interface{}{"country": "US"}}},
+		},
+		{
+			u.Redact("sub"),
+			User{Issuer: "https://issuer", Email: "user1@example.com", Name: "User One", Roles: []string{"admin"},
+				Claims: map[string]interface{}{"email": "user1@example.com", "name": "User One", "ssn": "123-45-6789",
+					"address": map[string]interface{}{"street": "1 Main St", "country": "US"}}},
+		},
+		{
+			&User{Issuer: "https://issuer", ID: "user1"},
+			User{Issuer: "https://issuer", ID: "user1"},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.user)
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		var actual User
+		if err := json.Unmarshal(data, &actual); err != nil || !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected the JSON of %+v, but got %s", test.expected, data)
+		}
+	}
+
+	data, _ := json.Marshal(u)
+	var keys map[string]interface{}
+	json.Unmarshal(data, &keys)
+	for _, k := range []string{"Issuer", "ID", "Claims", "Groups", "Roles", "Email", "EmailVerified"} {
+		if _, ok := keys[k]; !ok {
+			t.Errorf("Expected the field names of the User as keys, but got %s", data)
+		}
+	}
+
+	if _, ok := u.Claims["ssn"]; !ok || u.Claims["address"].(map[string]interface{})["street"] == nil {
+		t.Error("Expected the claims of the user to be left unchanged, but got", u.Claims)
+	}
+}
+
+func TestRedactClaims(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), RedactClaims("ssn"))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "ssn": "123-45-6789"}, time.Hour)
+	u, err := NewValidator(c).Validate(context.Background(), ts)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	var uj User
+	data, _ := json.Marshal(u)
+	json.Unmarshal(data, &uj)
+
+	if _, ok := uj.Claims["ssn"]; ok || uj.ID != "user1" || uj.Claims["sub"] != "user1" {
+		t.Errorf("Expected the user without the 'ssn' claim, but got %s", data)
+	}
+}
+
+func TestUser_MarshalJSON_RedactsDerivedFields(t *testing.T) {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims = jwt.MapClaims{
+		"iss":          "https://issuer",
+		"sub":          "pairwise",
+		"oid":          "oid1",
+		"groups":       []interface{}{"g1"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+	}
+
+	u, err := newUser(jt, &Provider{Issuer: "https://issuer", UserIDClaim: "oid", GroupsClaim: "groups", RolesFunc: RolesClaim("realm_access.roles")})
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	var uj User
+	data, _ := json.Marshal(u.Redact("sub"))
+	json.Unmarshal(data, &uj)
+
+	if uj.ID != "oid1" || !reflect.DeepEqual(uj.Groups, []string{"g1"}) || !reflect.DeepEqual(uj.Roles, []string{"admin"}) {
+		t.Errorf("Expected the ID, groups and roles of the user, but got %s", data)
+	}
+
+	uj = User{}
+	data, _ = json.Marshal(u.Redact("oid", "groups", "realm_access"))
+	json.Unmarshal(data, &uj)
+
+	if uj.ID != "" || uj.Groups != nil || uj.Roles != nil {
+		t.Errorf("Expected the ID, groups and roles of the redacted claims to be omitted, but got %s", data)
+	}
+}
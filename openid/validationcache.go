@@ -0,0 +1,217 @@
+package openid
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// CachedValidation is the result of a successful token validation stored in a ValidationCache.
+//
+// The Header and Claims contain the token header and claims. The Issuer is the issuer of the
+// provider that issued the token and the Expiration is the time after which the result must
+// no longer be used.
+type CachedValidation struct {
+	Header     map[string]interface{}
+	Claims     map[string]interface{}
+	Issuer     string
+	Expiration time.Time
+}
+
+// ValidationCache is the interface implemented by the caches of validation results.
+// Implementations must be safe for concurrent use and should treat their own failures as
+// cache misses.
+//
+// Get returns the validation result stored with the key, or false when there is none.
+// Set stores the validation result v with the key until v.Expiration.
+type ValidationCache interface {
+	Get(key string) (*CachedValidation, bool)
+	Set(key string, v *CachedValidation)
+}
+
+// ValidationCaching option caches the results of successful token validations in the
+// cache c, keyed by a hash of the token, so repeated requests with the same token skip the
+// signature verification. Results are cached until the token expires or for maxTTL, whichever
+// is earlier. A maxTTL of zero bounds the results only by the token expiration. Tokens without
+// the 'exp' claim are not cached.
+// The checks registered by other options, i.e.: ReplayProtection, still run on every request, as
+// does the validation of the claims of the token against its provider, i.e.: its audiences and
+// tenant, so changes to the providers and the ProvidersSelector apply to cached tokens.
+func ValidationCaching(c ValidationCache, maxTTL time.Duration) func(*Configuration) error {
+	return func(conf *Configuration) error {
+		conf.cache = &tokenCache{cache: c, maxTTL: maxTTL, tv: conf.idTokenValidator(), now: time.Now}
+		return nil
+	}
+}
+
+// validate returns the validated token ts, either from the cache or from the token validator.
+func (c *Configuration) validate(r *http.Request, ts string) (*jwt.Token, *Provider, error) {
+	if c.cache == nil {
+		return c.tokenValidator.validate(r, ts)
+	}
+
+	if t, p, ok := c.cache.get(r, ts); ok {
+		return t, p, nil
+	}
+
+	t, p, err := c.tokenValidator.validate(r, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.cache.set(ts, t, p)
+	return t, p, nil
+}
+
+// tokenCache caches the validated tokens. The tv resolves the providers of the cached tokens.
+type tokenCache struct {
+	cache  ValidationCache
+	maxTTL time.Duration
+	tv     *idTokenValidator
+	now    func() time.Time
+}
+
+func (tc *tokenCache) get(r *http.Request, ts string) (*jwt.Token, *Provider, bool) {
+	v, ok := tc.cache.Get(tokenCacheKey(ts))
+	if !ok || !tc.now().Before(v.Expiration) {
+		return nil, nil, false
+	}
+
+	claims := make(jwt.MapClaims, len(v.Claims))
+	for k, c := range v.Claims {
+		claims[k] = c
+	}
+
+	t := &jwt.Token{Raw: ts, Header: v.Header, Claims: claims, Valid: true}
+	if alg, ok := v.Header["alg"].(string); ok {
+		t.Method = jwt.GetSigningMethod(alg)
+	}
+
+	// The provider is resolved and the token validated against it again, so changes to the
+	// providers, i.e.: to their client IDs or tenants, and the selection of the providers of the
+	// request apply to cached tokens. Only the signature is not verified again.
+	p, err := tc.provider(r, t, v.Issuer)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return t, p, true
+}
+
+// provider returns the provider of the cached token t, issued by the provider with the issuer iss,
+// after validating the token against it as the token validator does.
+func (tc *tokenCache) provider(r *http.Request, t *jwt.Token, iss string) (*Provider, error) {
+	var p *Provider
+	if hasIssuer(t) {
+		var err error
+		if p, _, err = tc.tv.getProvider(r, t); err != nil {
+			return nil, err
+		}
+	} else {
+		// Tokens without issuer are validated by racing the providers, see RaceProviders.
+		provs, err := tc.tv.requestProviders(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if p, _ = tc.tv.issuers.find(iss, provs); p == nil {
+			return nil, &ValidationError{
+				Code:       ValidationErrorIssuerNotFound,
+				Message:    fmt.Sprintf("No provider was registered with issuer: %v", iss),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+
+		if p, _, err = tc.tv.checkProvider(t, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateAlgorithm(t, p); err != nil {
+		return nil, err
+	}
+
+	if err := validateHostedDomain(t, p); err != nil {
+		return nil, err
+	}
+
+	if err := validateRequiredClaims(t, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (tc *tokenCache) set(ts string, t *jwt.Token, p *Provider) {
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return
+	}
+
+	exp, ok := getTimeClaim(claims, expirationClaimName)
+	if !ok {
+		return
+	}
+
+	if tc.maxTTL > 0 {
+		if max := tc.now().Add(tc.maxTTL); max.Before(exp) {
+			exp = max
+		}
+	}
+
+	tc.cache.Set(tokenCacheKey(ts), &CachedValidation{Header: t.Header, Claims: claims, Issuer: p.Issuer, Expiration: exp})
+}
+
+// tokenCacheKey returns the key of the token ts, so tokens are not kept in the cache.
+func tokenCacheKey(ts string) string {
+	h := sha256.Sum256([]byte(ts))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// memoryValidationCache is a ValidationCache keeping the results in memory.
+type memoryValidationCache struct {
+	mu         sync.Mutex
+	results    map[string]*CachedValidation
+	expiries   expiryQueue
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewMemoryValidationCache returns a ValidationCache that keeps up to maxEntries results in
+// memory. Expired results are removed when new results are stored and, once the cache is full,
+// new results are not cached until others expire. A maxEntries of zero does not limit the
+// number of results.
+func NewMemoryValidationCache(maxEntries int) ValidationCache {
+	return &memoryValidationCache{results: make(map[string]*CachedValidation), maxEntries: maxEntries, now: time.Now}
+}
+
+func (c *memoryValidationCache) Get(key string) (*CachedValidation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.results[key]
+	return v, ok
+}
+
+func (c *memoryValidationCache) Set(key string, v *CachedValidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expiries.expire(c.now(), func(k string, exp time.Time) {
+		if r, ok := c.results[k]; ok && r.Expiration.Equal(exp) {
+			delete(c.results, k)
+		}
+	})
+
+	if _, replace := c.results[key]; !replace && c.maxEntries > 0 && len(c.results) >= c.maxEntries {
+		return
+	}
+
+	c.results[key] = v
+	c.expiries.add(key, v.Expiration)
+}
@@ -0,0 +1,181 @@
+package openid
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConfiguration_Validate_CachesResults(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	c.cache = &tokenCache{cache: NewMemoryValidationCache(10), tv: createCacheProviders(), now: time.Now}
+
+	jt := createCacheableToken(time.Now().Add(time.Hour))
+	jt.Raw = "token"
+	vm.On("validate", mock.Anything, "token").Return(jt, &Provider{Issuer: "https://issuer"}, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		rt, p, e := c.validate(nil, "token")
+
+		if e != nil {
+			t.Fatal("An error was returned but not expected", e)
+		}
+
+		if rt.Raw != "token" || rt.Claims.(jwt.MapClaims)["sub"] != "user1" || rt.Method != jwt.SigningMethodRS256 {
+			t.Error("Expected the cached token, but got", rt)
+		}
+
+		if p.Issuer != "https://issuer" {
+			t.Error("Expected the provider https://issuer, but got", p.Issuer)
+		}
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func TestConfiguration_Validate_WhenCachedResultExpired(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	now := time.Now()
+	c.cache = &tokenCache{cache: NewMemoryValidationCache(10), maxTTL: time.Minute, tv: createCacheProviders(), now: func() time.Time { return now }}
+
+	jt := createCacheableToken(now.Add(time.Hour))
+	vm.On("validate", mock.Anything, "token").Return(jt, &Provider{Issuer: "https://issuer"}, nil).Twice()
+
+	c.validate(nil, "token")
+	now = now.Add(time.Minute)
+	c.validate(nil, "token")
+
+	vm.AssertExpectations(t)
+}
+
+func TestConfiguration_Validate_WhenValidationFails(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	cache := NewMemoryValidationCache(10)
+	c.cache = &tokenCache{cache: cache, now: time.Now}
+
+	ee := errors.New("Validation error")
+	vm.On("validate", mock.Anything, "token").Return(nil, nil, ee)
+
+	_, _, e := c.validate(nil, "token")
+
+	if e != ee {
+		t.Error("Expected error", ee, "but got", e)
+	}
+
+	if _, ok := cache.Get(tokenCacheKey("token")); ok {
+		t.Error("The failed validation should not be cached")
+	}
+}
+
+func TestTokenCache_Set_WhenExpirationMissing(t *testing.T) {
+	cache := NewMemoryValidationCache(10)
+	tc := &tokenCache{cache: cache, now: time.Now}
+
+	tc.set("token", jwt.New(jwt.SigningMethodRS256), &Provider{Issuer: "https://issuer"})
+
+	if _, ok := cache.Get(tokenCacheKey("token")); ok {
+		t.Error("The token without expiration should not be cached")
+	}
+}
+
+func TestTokenCache_Get_WhenProviderRemoved(t *testing.T) {
+	cache := NewMemoryValidationCache(10)
+	tv := &idTokenValidator{issuers: newIssuerEquivalents(), provGetter: GetProvidersFunc(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://other", ClientIDs: []string{"client"}}}, nil
+	})}
+	tc := &tokenCache{cache: cache, tv: tv, now: time.Now}
+
+	tc.set("token", createCacheableToken(time.Now().Add(time.Hour)), &Provider{Issuer: "https://issuer"})
+
+	if _, _, ok := tc.get(nil, "token"); ok {
+		t.Error("The cached token of a removed provider should not be returned")
+	}
+}
+
+func TestTokenCache_Get_WhenProviderChanged(t *testing.T) {
+	var provs []Provider
+	tv := &idTokenValidator{issuers: newIssuerEquivalents(), provGetter: GetProvidersFunc(func() ([]Provider, error) {
+		return provs, nil
+	})}
+	tc := &tokenCache{cache: NewMemoryValidationCache(10), tv: tv, now: time.Now}
+	tc.set("token", createCacheableToken(time.Now().Add(time.Hour)), &Provider{Issuer: "https://issuer"})
+
+	tests := []struct {
+		provider Provider
+		ok       bool
+	}{
+		{Provider{Issuer: "https://issuer", ClientIDs: []string{"client"}}, true},
+		{Provider{Issuer: "https://issuer", ClientIDs: []string{"other"}}, false},
+		{Provider{Issuer: "https://issuer", ClientIDs: []string{"client"}, TokenUses: []string{"access"}}, false},
+		{Provider{Issuer: "https://issuer", ClientIDs: []string{"client"}, HostedDomains: []string{"example.com"}}, false},
+		{Provider{Issuer: "https://issuer", ClientIDs: []string{"client"}, ValidationPolicy: &ValidationPolicy{Algorithms: []string{"ES256"}}}, false},
+	}
+
+	for _, test := range tests {
+		provs = []Provider{test.provider}
+		if _, _, ok := tc.get(nil, "token"); ok != test.ok {
+			t.Errorf("Expected the cached token to be returned for the provider %+v: %v, but got %v", test.provider, test.ok, ok)
+		}
+	}
+}
+
+func TestMemoryValidationCache_Set_WhenFull(t *testing.T) {
+	now := time.Now()
+	c := &memoryValidationCache{results: make(map[string]*CachedValidation), maxEntries: 2, now: func() time.Time { return now }}
+
+	c.Set("key1", &CachedValidation{Expiration: now.Add(-time.Second)})
+	c.Set("key2", &CachedValidation{Expiration: now.Add(time.Hour)})
+	c.Set("key3", &CachedValidation{Expiration: now.Add(time.Hour)})
+	c.Set("key4", &CachedValidation{Expiration: now.Add(time.Hour)})
+
+	if _, ok := c.Get("key1"); ok {
+		t.Error("The expired result should have been removed")
+	}
+
+	if _, ok := c.Get("key3"); !ok {
+		t.Error("The result should have replaced the expired one")
+	}
+
+	if _, ok := c.Get("key4"); ok {
+		t.Error("The result should not be cached when the cache is full")
+	}
+}
+
+func Test_authenticate_UsesValidationCache(t *testing.T) {
+	vm, c := createConfiguration(t, errorHandlerHalt, getIDTokenReturnsSuccess)
+	c.cache = &tokenCache{cache: NewMemoryValidationCache(10), tv: createCacheProviders(), now: time.Now}
+
+	jt := createCacheableToken(time.Now().Add(time.Hour))
+	jt.Raw = idToken
+	vm.On("validate", mock.Anything, idToken).Return(jt, &Provider{Issuer: "https://issuer"}, nil).Once()
+
+	for i := 0; i < 2; i++ {
+		_, rt, halt := authenticate(c, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if halt || rt == nil {
+			t.Fatal("The authentication should have succeeded")
+		}
+	}
+
+	vm.AssertExpectations(t)
+}
+
+func createCacheableToken(exp time.Time) *jwt.Token {
+	jt := jwt.New(jwt.SigningMethodRS256)
+	jt.Claims.(jwt.MapClaims)["iss"] = "https://issuer"
+	jt.Claims.(jwt.MapClaims)["sub"] = "user1"
+	jt.Claims.(jwt.MapClaims)["aud"] = "client"
+	jt.Claims.(jwt.MapClaims)["exp"] = float64(exp.Unix())
+	return jt
+}
+
+func createCacheProviders() *idTokenValidator {
+	return &idTokenValidator{issuers: newIssuerEquivalents(), provGetter: GetProvidersFunc(func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://issuer", ClientIDs: []string{"client"}}}, nil
+	})}
+}
@@ -0,0 +1,163 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// AudienceMode defines how the audiences of the tokens are validated against the client IDs
+// of a provider, see ValidationPolicy.
+type AudienceMode int
+
+// Audience mode constants.
+const (
+	AudienceAny     AudienceMode = iota // One of the audiences of the token is a client ID of the provider.
+	AudienceAll                         // All the audiences of the token are client IDs of the provider.
+	AudienceIgnored                     // The audiences of the token are not validated.
+)
+
+// ValidationPolicy contains the settings a provider validates its tokens with, so tokens of
+// providers with different requirements are validated by the same Configuration, i.e.: a strict
+// external provider and a lenient internal issuer.
+//
+// The Leeway is the clock skew tolerated when validating the 'exp', 'nbf' and 'iat' claims.
+//
+// The Algorithms contains the signing algorithms accepted in the 'alg' header of the tokens, all
+// the supported algorithms are accepted when empty.
+//
+// The RequiredClaims contains the names of the claims the tokens must contain.
+//
+// The AudienceMode defines how the audiences of the tokens are validated, AudienceAny by default.
+// The provider requires no ClientIDs with AudienceIgnored.
+type ValidationPolicy struct {
+	Leeway         time.Duration
+	Algorithms     []string
+	RequiredClaims []string
+	AudienceMode   AudienceMode
+}
+
+// validate returns a SetupError when the policy is invalid.
+func (vp *ValidationPolicy) validate(iss string) error {
+	if vp.Leeway < 0 {
+		return &SetupError{
+			Code:    SetupErrorInvalidValidationPolicy,
+			Message: fmt.Sprintf("The validation policy of the provider %v has a negative leeway.", iss),
+		}
+	}
+
+	if vp.AudienceMode < AudienceAny || vp.AudienceMode > AudienceIgnored {
+		return &SetupError{
+			Code:    SetupErrorInvalidValidationPolicy,
+			Message: fmt.Sprintf("The validation policy of the provider %v has an unknown audience mode %v.", iss, vp.AudienceMode),
+		}
+	}
+
+	return nil
+}
+
+// audienceMode returns the AudienceMode of the ValidationPolicy of the provider, AudienceAny
+// when it has none.
+func (p *Provider) audienceMode() AudienceMode {
+	if p.ValidationPolicy == nil {
+		return AudienceAny
+	}
+
+	return p.ValidationPolicy.AudienceMode
+}
+
+// validateAlgorithm validates the 'alg' header of the token jt against the Algorithms of the
+// ValidationPolicy of the provider.
+func validateAlgorithm(jt *jwt.Token, p *Provider) error {
+	if p == nil || p.ValidationPolicy == nil || len(p.ValidationPolicy.Algorithms) == 0 {
+		return nil
+	}
+
+	alg, _ := jt.Header["alg"].(string)
+	if containsString(p.ValidationPolicy.Algorithms, alg) {
+		return nil
+	}
+
+	return &ValidationError{
+		Code:       ValidationErrorAlgorithmNotAllowed,
+		Message:    fmt.Sprintf("The token signing algorithm '%v' is not allowed by the provider %v.", alg, p.Issuer),
+		HTTPStatus: http.StatusUnauthorized,
+	}
+}
+
+// validateRequiredClaims validates that the token jt contains the RequiredClaims of the
+// ValidationPolicy of the provider.
+func validateRequiredClaims(jt *jwt.Token, p *Provider) error {
+	if p == nil || p.ValidationPolicy == nil {
+		return nil
+	}
+
+	claims, _ := jt.Claims.(jwt.MapClaims)
+	for _, c := range p.ValidationPolicy.RequiredClaims {
+		if _, ok := claims[c]; !ok {
+			return &ValidationError{
+				Code:       ValidationErrorInvalidClaims,
+				Message:    fmt.Sprintf("The token is missing the claim '%v' required by the provider %v.", c, p.Issuer),
+				HTTPStatus: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	return nil
+}
+
+// timeErrors are the errors of the 'exp', 'nbf' and 'iat' claims of a token.
+const timeErrors = jwt.ValidationErrorExpired | jwt.ValidationErrorNotValidYet | jwt.ValidationErrorIssuedAt
+
+// validateTimes validates the 'exp', 'nbf' and 'iat' claims, tolerating the Leeway of the
+// ValidationPolicy of the provider. The error is a *jwt.ValidationError, as returned by the parser.
+func validateTimes(claims jwt.MapClaims, p *Provider) error {
+	var leeway time.Duration
+	if p != nil && p.ValidationPolicy != nil {
+		leeway = p.ValidationPolicy.Leeway
+	}
+
+	now := time.Now()
+	verr := &jwt.ValidationError{}
+
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), false) {
+		verr.Inner = errors.New("token is expired")
+		verr.Errors |= jwt.ValidationErrorExpired
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		verr.Inner = errors.New("token used before issued")
+		verr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) {
+		verr.Inner = errors.New("token is not valid yet")
+		verr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+
+	if verr.Errors != 0 {
+		return verr
+	}
+
+	return nil
+}
+
+// toleratedTimeError returns true when the error err of the parsing of the token jt of the
+// provider is only caused by its 'exp', 'nbf' or 'iat' claims and they are valid within the
+// Leeway of the ValidationPolicy of the provider, whose signature was then verified.
+func toleratedTimeError(jt *jwt.Token, p *Provider, err error) bool {
+	verr, ok := err.(*jwt.ValidationError)
+	if !ok || jt == nil || p == nil || p.ValidationPolicy == nil || p.ValidationPolicy.Leeway == 0 {
+		return false
+	}
+
+	if verr.Errors&^timeErrors != 0 {
+		return false
+	}
+
+	claims, ok := jt.Claims.(jwt.MapClaims)
+	return ok && validateTimes(claims, p) == nil
+}
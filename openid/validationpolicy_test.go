@@ -0,0 +1,89 @@
+package openid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_validate_ValidationPolicy(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	now := time.Now()
+	tests := []struct {
+		policy ValidationPolicy
+		claims map[string]interface{}
+		code   ValidationErrorCode
+		valid  bool
+	}{
+		{ValidationPolicy{}, map[string]interface{}{"aud": "app"}, 0, true},
+		{ValidationPolicy{}, map[string]interface{}{"aud": "app", "exp": now.Add(-time.Minute).Unix()}, ValidationErrorJwtValidationFailure, false},
+		{ValidationPolicy{Leeway: 2 * time.Minute}, map[string]interface{}{"aud": "app", "exp": now.Add(-time.Minute).Unix()}, 0, true},
+		{ValidationPolicy{Leeway: 2 * time.Minute}, map[string]interface{}{"aud": "app", "nbf": now.Add(time.Minute).Unix()}, 0, true},
+		{ValidationPolicy{Leeway: 2 * time.Minute}, map[string]interface{}{"aud": "app", "exp": now.Add(-3 * time.Minute).Unix()}, ValidationErrorJwtValidationFailure, false},
+		{ValidationPolicy{Algorithms: []string{"RS256"}}, map[string]interface{}{"aud": "app"}, 0, true},
+		{ValidationPolicy{Algorithms: []string{"PS256"}}, map[string]interface{}{"aud": "app"}, ValidationErrorAlgorithmNotAllowed, false},
+		{ValidationPolicy{RequiredClaims: []string{"email"}}, map[string]interface{}{"aud": "app", "email": "user1@example.com"}, 0, true},
+		{ValidationPolicy{RequiredClaims: []string{"email"}}, map[string]interface{}{"aud": "app"}, ValidationErrorInvalidClaims, false},
+		{ValidationPolicy{}, map[string]interface{}{"aud": []interface{}{"app", "other"}}, 0, true},
+		{ValidationPolicy{AudienceMode: AudienceAll}, map[string]interface{}{"aud": []interface{}{"app", "other"}}, ValidationErrorAudienceNotFound, false},
+		{ValidationPolicy{AudienceMode: AudienceAll}, map[string]interface{}{"aud": []interface{}{"app"}}, 0, true},
+		{ValidationPolicy{AudienceMode: AudienceIgnored}, map[string]interface{}{"aud": "other"}, 0, true},
+		{ValidationPolicy{AudienceMode: AudienceIgnored}, map[string]interface{}{}, 0, true},
+	}
+
+	for _, test := range tests {
+		vp := test.policy
+		c, err := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+			return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}, ValidationPolicy: &vp}}, nil
+		}))
+		if err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		claims := map[string]interface{}{"sub": "user1"}
+		for n, v := range test.claims {
+			claims[n] = v
+		}
+
+		ts, _ := ti.Issue(claims, time.Hour)
+		_, _, err = c.idTokenValidator().validate(nil, ts)
+
+		if !test.valid {
+			if ve, ok := err.(*ValidationError); !ok || ve.Code != test.code {
+				t.Errorf("Expected the error code %v with the policy %+v and claims %v, but got %v", test.code, test.policy, test.claims, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected the token to be valid with the policy %+v and claims %v, but got %v", test.policy, test.claims, err)
+		}
+	}
+}
+
+func Test_Provider_Validate_ValidationPolicy(t *testing.T) {
+	p := Provider{Issuer: "https://issuer", ValidationPolicy: &ValidationPolicy{AudienceMode: AudienceIgnored}}
+	if err := p.Validate(); err != nil {
+		t.Error("Expected no client IDs to be required, but got", err)
+	}
+
+	p = Provider{Issuer: "https://issuer", ClientIDs: []string{"app"}, ValidationPolicy: &ValidationPolicy{Leeway: -time.Second}}
+	expectSetupError(t, p.Validate(), SetupErrorInvalidValidationPolicy)
+
+	p = Provider{Issuer: "https://issuer", ClientIDs: []string{"app"}, ValidationPolicy: &ValidationPolicy{AudienceMode: AudienceMode(5)}}
+	expectSetupError(t, p.Validate(), SetupErrorInvalidValidationPolicy)
+}
+
+func Test_validateTimes(t *testing.T) {
+	now := time.Now()
+	p := &Provider{ValidationPolicy: &ValidationPolicy{Leeway: time.Minute}}
+
+	if err := validateTimes(map[string]interface{}{"exp": float64(now.Add(-30 * time.Second).Unix())}, p); err != nil {
+		t.Error("Expected the expiration to be tolerated, but got", err)
+	}
+
+	err := validateTimes(map[string]interface{}{"nbf": float64(now.Add(2 * time.Minute).Unix())}, p)
+	expectValidationError(t, jwtErrorToOpenIDError(err), ValidationErrorJwtValidationFailure, http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,67 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+)
+
+// Validator validates tokens received outside of HTTP requests, i.e.: from message queues, command
+// line arguments or custom protocols, with the providers, signing keys, caches and checks of a
+// Configuration:
+//
+//	v := openid.NewValidator(configuration)
+//	u, err := v.Validate(ctx, msg.Token)
+//
+// The checks relying on the HTTP request, such as the certificate binding of CertificateBoundTokens,
+// fail as the tokens are not received with a request. The transport requirements and the Skip
+// options do not apply, nor does the ProvidersSelector as the tokens do not belong to the tenant
+// of a request: the tokens of all the providers are accepted.
+type Validator struct {
+	conf *Configuration
+}
+
+// NewValidator returns a Validator validating the tokens with the given configuration.
+func NewValidator(conf *Configuration) *Validator {
+	return &Validator{conf: conf}
+}
+
+// Validate validates the token t and returns the user it authenticates. The retrievals of the
+// discovery documents and signing keys made to validate it are bound by ctx, and the HTTPGetFunc
+// and JwksCredentials receive a request carrying ctx. The claims required by the TypedClaims option
+// are enforced as by the middlewares. The error is a *ValidationError when the token is invalid.
+func (v *Validator) Validate(ctx context.Context, t string) (*User, error) {
+	return v.conf.validateUser(newBackgroundRequest(ctx), t, v.conf.tokenCheckers)
+}
+
+// validateUser validates the token t received with the request r, checking it with the checkers
+// cs, and returns the user it authenticates.
+func (c *Configuration) validateUser(r *http.Request, t string, cs []tokenChecker) (*User, error) {
+	if c.tokenLimits != nil {
+		if err := c.tokenLimits.check(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.runBeforeValidation(r, t); err != nil {
+		return nil, err
+	}
+
+	vt, p, err := c.validate(r, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkToken(cs, r, vt, p); err != nil {
+		return nil, err
+	}
+
+	c.runAfterValidation(r, vt)
+
+	if c.typedClaims != nil {
+		if _, err := c.typedClaims.decode(vt); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.newUser(vt, p)
+}
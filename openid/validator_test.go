@@ -0,0 +1,108 @@
+package openid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Validator_Validate(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, err := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), TokenLimits(4096, 100, 10))
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	v := NewValidator(c)
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "email": "user1@example.com"}, time.Hour)
+	u, err := v.Validate(context.Background(), ts)
+	if err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	if u.ID != "user1" || u.Issuer != s.URL || u.Claims["email"] != "user1@example.com" {
+		t.Error("Expected the user user1 with its claims, but got", u)
+	}
+
+	ts, _ = ti.Issue(map[string]interface{}{"sub": "user1", "aud": "other"}, time.Hour)
+	_, err = v.Validate(context.Background(), ts)
+	expectValidationError(t, err, ValidationErrorAudienceNotFound, http.StatusUnauthorized, nil)
+}
+
+func Test_Validator_Validate_TypedClaims(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	type claims struct {
+		Email string `json:"email" openid:"required"`
+	}
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), TypedClaims(&claims{}))
+
+	v := NewValidator(c)
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "email": "user1@example.com"}, time.Hour)
+	if _, err := v.Validate(context.Background(), ts); err != nil {
+		t.Fatal("An error was returned but not expected", err)
+	}
+
+	ts, _ = ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	_, err := v.Validate(context.Background(), ts)
+	expectValidationError(t, err, ValidationErrorInvalidClaims, http.StatusUnauthorized, nil)
+}
+
+func Test_Validator_Validate_WithProvidersSelector(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ProvidersSelector(TenantIssuers(HostTenant, map[string][]string{"tenant1.example.com": {s.URL}})))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+	if _, err := NewValidator(c).Validate(context.Background(), ts); err != nil {
+		t.Error("Expected the token to be validated regardless of the selector, but got", err)
+	}
+
+	expectAuthenticated(t, c, ti, "app", false)
+}
+
+func Test_Validator_Validate_CancelsRetrievalWithContext(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer s.Close()
+
+	ti, _ := NewTokenIssuer(s.URL, NewKeySet())
+	ti.Rotate("RS256")
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	start := time.Now()
+	if _, err := NewValidator(c).Validate(ctx, ts); err == nil {
+		t.Error("An error was expected but not returned")
+	}
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Error("Expected the retrieval to be canceled at the deadline of the context, but it took", d)
+	}
+}
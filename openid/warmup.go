@@ -0,0 +1,72 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WarmupError contains the errors returned by Warmup keyed by the issuer of the provider
+// they occurred for.
+type WarmupError map[string]error
+
+// Error returns a formatted string containing the errors of all the providers.
+func (we WarmupError) Error() string {
+	issuers := make([]string, 0, len(we))
+	for iss := range we {
+		issuers = append(issuers, iss)
+	}
+
+	sort.Strings(issuers)
+
+	msgs := make([]string, len(issuers))
+	for i, iss := range issuers {
+		msgs[i] = fmt.Sprintf("%v: %v", iss, we[iss])
+	}
+
+	return "Warmup error. " + strings.Join(msgs, "; ")
+}
+
+// Warmup retrieves the discovery document and signing keys of every provider returned by the
+// GetProvidersFunc, so a misconfiguration is reported when the application starts rather than
+// on the first request, and the first requests do not wait for the providers.
+// The error returned by the GetProvidersFunc or the validation of the providers is returned as is,
+// the errors of the individual providers are returned in a WarmupError. Providers registered with
+// issuer templates are skipped, their configuration depends on the tenant of each token.
+// The HTTPGetFunc and JwksCredentials receive a request carrying ctx, which bounds the warmup.
+func (c *Configuration) Warmup(ctx context.Context) error {
+	tv := c.idTokenValidator()
+	if tv.provGetter == nil {
+		return nil
+	}
+
+	provs, err := tv.provGetter.get()
+	if err != nil {
+		return err
+	}
+
+	if err := providers(provs).validate(); err != nil {
+		return err
+	}
+
+	r := newBackgroundRequest(ctx)
+	kp := tv.keyGetter.(*signingKeyProvider)
+	we := WarmupError{}
+
+	for i := range provs {
+		if provs[i].isTemplate() {
+			continue
+		}
+
+		if err := kp.refreshSigningKeys(r, &provs[i]); err != nil {
+			we[provs[i].Issuer] = err
+		}
+	}
+
+	if len(we) > 0 {
+		return we
+	}
+
+	return nil
+}
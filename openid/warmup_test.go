@@ -0,0 +1,62 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func createWarmupConfiguration(t *testing.T, pg GetProvidersFunc) (*mockSigningKeySetGetter, *signingKeyProvider, *Configuration) {
+	kg, kp := createSigningKeyProvider(t)
+	return kg, kp, &Configuration{tokenValidator: &idTokenValidator{provGetter: pg, keyGetter: kp}}
+}
+
+func Test_Configuration_Warmup(t *testing.T) {
+	ee := &ValidationError{Code: ValidationErrorGetJwksFailure, HTTPStatus: http.StatusBadGateway}
+	kg, kp, c := createWarmupConfiguration(t, func() ([]Provider, error) {
+		return []Provider{
+			{Issuer: "https://p1", ClientIDs: []string{"client"}},
+			{Issuer: "https://p2", ClientIDs: []string{"client"}},
+			{Issuer: "https://login/{tenant}/v2.0", ClientIDs: []string{"client"}, TenantValidator: func(string) error { return nil }},
+		}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), "key", "value")
+	withCtx := mock.MatchedBy(func(r *http.Request) bool { return r.Context().Value("key") == "value" })
+	kg.On("get", withCtx, &Provider{Issuer: "https://p1", ClientIDs: []string{"client"}}).Return([]signingKey{{keyID: "kid1", key: []byte("key")}}, time.Duration(0), nil)
+	kg.On("get", withCtx, &Provider{Issuer: "https://p2", ClientIDs: []string{"client"}}).Return(nil, time.Duration(0), ee)
+
+	err := c.Warmup(ctx)
+
+	we, ok := err.(WarmupError)
+	if !ok || len(we) != 1 || we["https://p2"] != ee {
+		t.Fatalf("Expected a WarmupError for https://p2, but got %#v", err)
+	}
+
+	if k := kp.cachedKey("https://p1", keySelector{kid: "kid1"}); keyString(k) != "key" {
+		t.Error("Expected the keys of https://p1 to be cached, but got", k)
+	}
+
+	kg.AssertExpectations(t)
+}
+
+func Test_Configuration_Warmup_WhenProvidersInvalid(t *testing.T) {
+	pe := errors.New("Providers error")
+	_, _, c := createWarmupConfiguration(t, func() ([]Provider, error) {
+		return nil, pe
+	})
+
+	if err := c.Warmup(context.Background()); err != pe {
+		t.Error("Expected the providers error, but got", err)
+	}
+
+	_, _, c = createWarmupConfiguration(t, func() ([]Provider, error) {
+		return []Provider{{Issuer: "https://p1"}}, nil
+	})
+
+	expectSetupError(t, c.Warmup(context.Background()), SetupErrorInvalidClientIDs)
+}
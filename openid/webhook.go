@@ -0,0 +1,162 @@
+package openid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// maxWebhookDecisions bounds the number of decisions cached by a verification webhook.
+const maxWebhookDecisions = 10000
+
+// WebhookDecision is the JSON response expected from a verification webhook. The Reason is
+// optional and included in the error returned when the request is denied.
+type WebhookDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// VerificationWebhook option POSTs the PolicyInput of every request with a validated token,
+// as JSON, to the webhook at url and enforces the WebhookDecision it responds with. Denied
+// requests are rejected with status 403/Forbidden. Webhook calls taking longer than timeout,
+// failing or not responding with status 200 reject the request with status 500/Internal Server Error.
+// Decisions are cached for cacheTTL, or until the token expires if earlier, per token, method
+// and path. A cacheTTL of zero disables the caching.
+func VerificationWebhook(url string, timeout time.Duration, cacheTTL time.Duration) func(*Configuration) error {
+	return func(c *Configuration) error {
+		c.tokenCheckers = append(c.tokenCheckers, &webhookChecker{
+			url:       url,
+			client:    &http.Client{Timeout: timeout},
+			cacheTTL:  cacheTTL,
+			decisions: make(map[string]*cachedDecision),
+			now:       time.Now,
+		})
+		return nil
+	}
+}
+
+type cachedDecision struct {
+	decision   WebhookDecision
+	expiration time.Time
+}
+
+type webhookChecker struct {
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	decisions map[string]*cachedDecision
+}
+
+func (wc *webhookChecker) check(r *http.Request, t *jwt.Token, p *Provider) error {
+	key := tokenCacheKey(t.Raw) + " " + r.Method + " " + r.URL.Path
+
+	d, ok := wc.cached(key)
+	if !ok {
+		var err error
+		if d, err = wc.call(r, newPolicyInput(r, t, p)); err != nil {
+			return &ValidationError{
+				Code:       ValidationErrorWebhookFailure,
+				Message:    fmt.Sprintf("Failure while calling the verification webhook %v.", wc.url),
+				Err:        err,
+				HTTPStatus: http.StatusInternalServerError,
+			}
+		}
+
+		wc.cache(key, d, t)
+	}
+
+	if !d.Allow {
+		m := "The request was denied by the verification webhook."
+		if d.Reason != "" {
+			m = fmt.Sprintf("The request was denied by the verification webhook: %v", d.Reason)
+		}
+
+		return &ValidationError{
+			Code:       ValidationErrorWebhookDenied,
+			Message:    m,
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
+
+func (wc *webhookChecker) call(r *http.Request, in *PolicyInput) (WebhookDecision, error) {
+	var d WebhookDecision
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return d, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, wc.url, bytes.NewReader(body))
+	if err != nil {
+		return d, err
+	}
+
+	req = req.WithContext(r.Context())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wc.client.Do(req)
+	if err != nil {
+		return d, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return d, fmt.Errorf("The verification webhook returned status %v.", resp.StatusCode)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&d)
+	return d, err
+}
+
+func (wc *webhookChecker) cached(key string) (WebhookDecision, bool) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	cd, ok := wc.decisions[key]
+	if !ok || !wc.now().Before(cd.expiration) {
+		return WebhookDecision{}, false
+	}
+
+	return cd.decision, true
+}
+
+func (wc *webhookChecker) cache(key string, d WebhookDecision, t *jwt.Token) {
+	if wc.cacheTTL <= 0 {
+		return
+	}
+
+	now := wc.now()
+	exp := now.Add(wc.cacheTTL)
+	if te, ok := getTimeClaim(t.Claims.(jwt.MapClaims), expirationClaimName); ok && te.Before(exp) {
+		exp = te
+	}
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	if len(wc.decisions) >= maxWebhookDecisions {
+		for k, cd := range wc.decisions {
+			if !cd.expiration.After(now) {
+				delete(wc.decisions, k)
+			}
+		}
+
+		if len(wc.decisions) >= maxWebhookDecisions {
+			return
+		}
+	}
+
+	wc.decisions[key] = &cachedDecision{d, exp}
+}
@@ -0,0 +1,134 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func createWebhookChecker(t *testing.T, status int, d WebhookDecision, calls *int) (*webhookChecker, func()) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+
+		var in PolicyInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Claims["sub"] != "user1" {
+			t.Error("Unexpected webhook input", in, err)
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(d)
+	}))
+
+	c := &Configuration{}
+	VerificationWebhook(s.URL, time.Second, time.Minute)(c)
+
+	return c.tokenCheckers[0].(*webhookChecker), s.Close
+}
+
+func webhookToken(exp time.Time) *jwt.Token {
+	return &jwt.Token{Raw: "token", Claims: jwt.MapClaims{"sub": "user1", "exp": float64(exp.Unix())}}
+}
+
+func TestWebhookChecker_Check_WhenAllowed_CachesDecision(t *testing.T) {
+	calls := 0
+	wc, close := createWebhookChecker(t, http.StatusOK, WebhookDecision{Allow: true}, &calls)
+	defer close()
+
+	now := time.Now()
+	wc.now = func() time.Time { return now }
+	tk := webhookToken(now.Add(time.Hour))
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	for i := 0; i < 2; i++ {
+		if err := wc.check(r, tk, &Provider{}); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Error("Expected the webhook to be called once, but was called", calls)
+	}
+
+	wc.check(httptest.NewRequest(http.MethodDelete, "/items", nil), tk, &Provider{})
+
+	if calls != 2 {
+		t.Error("Expected the webhook to be called for another method, but was called", calls)
+	}
+
+	now = now.Add(time.Minute)
+	wc.check(r, tk, &Provider{})
+
+	if calls != 3 {
+		t.Error("Expected the webhook to be called after the decision expired, but was called", calls)
+	}
+}
+
+func TestWebhookChecker_Check_WhenTokenExpiresBeforeCacheTTL(t *testing.T) {
+	calls := 0
+	wc, close := createWebhookChecker(t, http.StatusOK, WebhookDecision{Allow: true}, &calls)
+	defer close()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	wc.now = func() time.Time { return now }
+	tk := webhookToken(now.Add(10 * time.Second))
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	wc.check(r, tk, &Provider{})
+	now = now.Add(10 * time.Second)
+	wc.check(r, tk, &Provider{})
+
+	if calls != 2 {
+		t.Error("Expected the decision to expire with the token, but the webhook was called", calls)
+	}
+}
+
+func TestWebhookChecker_Check_WhenDenied(t *testing.T) {
+	calls := 0
+	wc, close := createWebhookChecker(t, http.StatusOK, WebhookDecision{Reason: "suspended"}, &calls)
+	defer close()
+
+	e := wc.check(httptest.NewRequest(http.MethodGet, "/", nil), webhookToken(time.Now().Add(time.Hour)), &Provider{})
+
+	expectValidationError(t, e, ValidationErrorWebhookDenied, http.StatusForbidden, nil)
+
+	if ve := e.(*ValidationError); ve.Message != "The request was denied by the verification webhook: suspended" {
+		t.Error("Unexpected error message", ve.Message)
+	}
+}
+
+func TestWebhookChecker_Check_WhenWebhookFails(t *testing.T) {
+	calls := 0
+	wc, close := createWebhookChecker(t, http.StatusServiceUnavailable, WebhookDecision{Allow: true}, &calls)
+	defer close()
+
+	tk := webhookToken(time.Now().Add(time.Hour))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		e := wc.check(r, tk, &Provider{})
+
+		expectValidationError(t, e, ValidationErrorWebhookFailure, http.StatusInternalServerError, nil)
+	}
+
+	if calls != 2 {
+		t.Error("Failures should not be cached, but the webhook was called", calls)
+	}
+}
+
+func TestWebhookChecker_Check_WhenWebhookTimesOut(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	c := &Configuration{}
+	VerificationWebhook(s.URL, 10*time.Millisecond, 0)(c)
+
+	e := c.tokenCheckers[0].check(httptest.NewRequest(http.MethodGet, "/", nil), webhookToken(time.Now().Add(time.Hour)), &Provider{})
+
+	expectValidationError(t, e, ValidationErrorWebhookFailure, http.StatusInternalServerError, nil)
+}
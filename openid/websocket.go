@@ -0,0 +1,294 @@
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pachapman/openid2go/openid/internal/ctxkeys"
+)
+
+// accessTokenParameterName is the query parameter carrying the token of the WebSocket upgrade
+// requests sent by browsers, which cannot set their headers, see RFC 6750 section 2.3.
+const accessTokenParameterName = "access_token"
+
+// WebSocketHandler represents a handler function of WebSocket upgrade requests authenticated by
+// AuthenticateWebSocket. It upgrades the request(r) with the WebSocket library of choice and
+// serves the connection for as long as the session(s) is not done.
+type WebSocketHandler func(s *WebSocketSession, w http.ResponseWriter, r *http.Request)
+
+// AuthenticateWebSocket middleware authenticates the WebSocket upgrade requests as AuthenticateUser
+// and forwards them to the handler(h) along with the session of the connection, which exposes the
+// authenticated user and ends once the token expires:
+//
+//	openid.AuthenticateWebSocket(configuration, time.Minute, func(s *openid.WebSocketSession, w http.ResponseWriter, r *http.Request) {
+//	    conn, err := upgrader.Upgrade(w, r, nil)
+//	    ...
+//	    go func() {
+//	        <-s.Done()
+//	        conn.Close()
+//	    }()
+//	    ...
+//	})
+//
+// When revalidate is positive the token is validated again at that interval, so connections whose
+// token is revoked, or whose provider or signing key is removed, are also ended. The token is
+// validated again with the upgrade request, i.e.: its TLS connection for CertificateBoundTokens,
+// and is not recorded again by the ReplayProtection. Requests that are
+// not WebSocket upgrade requests are answered with Upgrade Required. As browsers cannot set the
+// headers of upgrade requests, the token is read from the access_token query parameter when the
+// GetIDTokenFunc finds no Authorization header.
+// The session is nil when the ErrorHandlerFunc option chose to continue after a failed authentication.
+// It ends when the handler returns.
+func AuthenticateWebSocket(conf *Configuration, revalidate time.Duration, h WebSocketHandler) http.Handler {
+	wc := conf.Route()
+	tg := wc.idTokenGetter
+	if tg == nil {
+		tg = getIDTokenAuthorizationHeader
+	}
+	wc.idTokenGetter = webSocketTokenGetter(tg)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			w.Header().Set("Upgrade", "websocket")
+			http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
+			return
+		}
+
+		ar, u, halt := authenticateUser(wc, w, r)
+		if halt {
+			return
+		}
+
+		if u == nil {
+			h(nil, w, ar)
+			return
+		}
+
+		t, _ := ar.Context().Value(ctxkeys.RawToken).(string)
+		s := newWebSocketSession(wc, ar, revalidate, u, t)
+		defer s.end(nil)
+
+		h(s, w, s.r)
+	})
+}
+
+// webSocketTokenGetter returns a GetIDTokenFunc reading the token with tg and, when tg finds no
+// Authorization header, from the access_token query parameter.
+func webSocketTokenGetter(tg GetIDTokenFunc) GetIDTokenFunc {
+	return func(r *http.Request) (string, error) {
+		t, err := tg(r)
+		if ve, ok := err.(*ValidationError); ok && ve.Code == ValidationErrorAuthorizationHeaderNotFound {
+			if qt := r.URL.Query().Get(accessTokenParameterName); qt != "" {
+				return qt, nil
+			}
+		}
+
+		return t, err
+	}
+}
+
+// isWebSocketUpgrade returns true when r requests the upgrade of its connection to the WebSocket
+// protocol, see RFC 6455 section 4.1.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, h := range r.Header["Connection"] {
+		for _, t := range strings.Split(h, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WebSocketSession represents the authentication of a WebSocket connection established by
+// AuthenticateWebSocket. The session is done once the token expires, fails to be validated
+// again or the handler returns. Handlers close the connection when the Done channel is closed,
+// or extend the session with Refresh when the client sends a new token over the connection.
+type WebSocketSession struct {
+	conf     *Configuration
+	r        *http.Request
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu     sync.Mutex
+	user   *User
+	token  string
+	expiry time.Time
+	timer  *time.Timer
+	err    error
+}
+
+// newWebSocketSession returns the session of the token t of the user u authenticated by the
+// upgrade request r, whose tokens are validated with the configuration conf.
+func newWebSocketSession(conf *Configuration, r *http.Request, interval time.Duration, u *User, t string) *WebSocketSession {
+	s := &WebSocketSession{conf: conf, interval: interval}
+	s.ctx, s.cancel = context.WithCancel(r.Context())
+	s.r = r.WithContext(s.ctx)
+
+	s.mu.Lock()
+	s.set(u, t)
+	s.mu.Unlock()
+
+	return s
+}
+
+// User returns the user authenticated by the current token of the session.
+func (s *WebSocketSession) User() *User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.user
+}
+
+// Expiry returns the expiration time of the current token of the session, the zero time when the
+// token has no 'exp' claim.
+func (s *WebSocketSession) Expiry() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.expiry
+}
+
+// Context returns the context of the session, canceled once the session is done. It is also the
+// context of the request forwarded to the WebSocketHandler.
+func (s *WebSocketSession) Context() context.Context {
+	return s.ctx
+}
+
+// Done returns a channel closed once the session is done.
+func (s *WebSocketSession) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Err returns the *ValidationError that ended the session, i.e.: the expiration of its token. It
+// returns nil while the session is not done, or when it ended because the handler returned or
+// the request was canceled.
+func (s *WebSocketSession) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Refresh replaces the token of the session with the token t, i.e.: a token renewed by the client
+// and sent over the connection, extending the session until t expires. The token t must be valid
+// and issued to the user of the session, the session is left unchanged otherwise.
+func (s *WebSocketSession) Refresh(ctx context.Context, t string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	u, err := s.conf.validateUser(s.r.WithContext(ctx), t, s.conf.tokenCheckers)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.Issuer != s.user.Issuer || u.ID != s.user.ID {
+		return &ValidationError{
+			Code:       ValidationErrorSubjectChanged,
+			Message:    fmt.Sprintf("The token issued to %v cannot refresh the connection of %v.", u.ID, s.user.ID),
+			HTTPStatus: http.StatusForbidden,
+		}
+	}
+
+	if s.ctx.Err() == nil {
+		s.set(u, t)
+	}
+
+	return nil
+}
+
+// set replaces the user and token of the session and schedules their next validation, at the
+// expiration of the token or at the revalidation interval, whichever comes first. It must be
+// called with mu held.
+func (s *WebSocketSession) set(u *User, t string) {
+	s.user, s.token = u, t
+	s.expiry, _ = getTimeClaim(jwt.MapClaims(u.Claims), expirationClaimName)
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	d := s.interval
+	if !s.expiry.IsZero() {
+		if until := time.Until(s.expiry); d <= 0 || until < d {
+			d = until
+		}
+	}
+
+	if d <= 0 && s.expiry.IsZero() {
+		s.timer = nil
+		return
+	}
+
+	s.timer = time.AfterFunc(d, func() { s.check(t) })
+}
+
+// check validates the token t again, unless the session was refreshed with another token, and
+// ends the session when it expired or is no longer valid.
+func (s *WebSocketSession) check(t string) {
+	s.mu.Lock()
+	if s.token != t || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	expiry := s.expiry
+	s.mu.Unlock()
+
+	if !expiry.IsZero() && !time.Now().Before(expiry) {
+		s.end(&ValidationError{
+			Code:       ValidationErrorJwtValidationFailure,
+			Message:    "The token of the connection expired.",
+			HTTPStatus: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	u, err := s.conf.validateUser(s.r, t, withoutReplayChecker(s.conf.tokenCheckers))
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		s.end(err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == t {
+		s.set(u, t)
+	}
+}
+
+// end ends the session with the error err, unless it is already done.
+func (s *WebSocketSession) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	s.err = err
+	s.cancel()
+}
@@ -0,0 +1,216 @@
+package openid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newWebSocketRequest(target string, token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return r
+}
+
+func Test_AuthenticateWebSocket(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	var session *WebSocketSession
+	h := AuthenticateWebSocket(c, 0, func(ws *WebSocketSession, w http.ResponseWriter, r *http.Request) {
+		session = ws
+		if u, ok := UserFromContext(r.Context()); !ok || u.ID != "user1" {
+			t.Error("Expected the user in the request context, but got", u)
+		}
+	})
+
+	tests := []struct {
+		r      *http.Request
+		status int
+		user   bool
+	}{
+		{newWebSocketRequest("/ws", ts), http.StatusOK, true},
+		{newWebSocketRequest("/ws?access_token="+ts, ""), http.StatusOK, true},
+		{newWebSocketRequest("/ws", ""), http.StatusBadRequest, false},
+		{newWebSocketRequest("/ws", "invalid"), http.StatusBadRequest, false},
+		{httptest.NewRequest(http.MethodGet, "/ws", nil), http.StatusUpgradeRequired, false},
+	}
+
+	for _, test := range tests {
+		session = nil
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, test.r)
+
+		if rw.Code != test.status {
+			t.Errorf("Expected the status %v for %v, but got %v", test.status, test.r.URL, rw.Code)
+		}
+
+		if (session != nil) != test.user {
+			t.Errorf("Expected a session for %v: %v, but got %v", test.r.URL, test.user, session)
+			continue
+		}
+
+		if session == nil {
+			continue
+		}
+
+		if session.User().ID != "user1" || session.Expiry().IsZero() {
+			t.Error("Expected the session of user1 with the expiration of the token, but got", session.User(), session.Expiry())
+		}
+
+		select {
+		case <-session.Done():
+		default:
+			t.Error("Expected the session to end when the handler returns")
+		}
+
+		if session.Err() != nil {
+			t.Error("Expected no error when the handler returns, but got", session.Err())
+		}
+	}
+}
+
+func Test_AuthenticateWebSocket_EndsWhenTokenExpires(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Second)
+
+	AuthenticateWebSocket(c, 0, func(ws *WebSocketSession, w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the session to end when the token expires")
+		}
+
+		expectValidationError(t, ws.Err(), ValidationErrorJwtValidationFailure, http.StatusUnauthorized, nil)
+	}).ServeHTTP(httptest.NewRecorder(), newWebSocketRequest("/ws", ts))
+}
+
+func Test_AuthenticateWebSocket_Revalidates(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	var removed int32
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		if atomic.LoadInt32(&removed) == 1 {
+			return nil, errors.New("provider removed")
+		}
+
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Hour)
+
+	AuthenticateWebSocket(c, 20*time.Millisecond, func(ws *WebSocketSession, w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		if ws.Err() != nil {
+			t.Fatal("Expected the session to be valid, but got", ws.Err())
+		}
+
+		atomic.StoreInt32(&removed, 1)
+
+		select {
+		case <-ws.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the session to end when the token is no longer valid")
+		}
+
+		if ws.Err() == nil || !strings.Contains(ws.Err().Error(), "provider removed") {
+			t.Error("Expected the revalidation error, but got", ws.Err())
+		}
+	}).ServeHTTP(httptest.NewRecorder(), newWebSocketRequest("/ws", ts))
+}
+
+func Test_AuthenticateWebSocket_RevalidatesWithReplayProtectionAndSelector(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}), ReplayProtection(NewMemoryReplayStore()),
+		ProvidersSelector(TenantIssuers(HostTenant, map[string][]string{"example.com": {s.URL}})))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "jti": "id1"}, time.Hour)
+	renewed, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "jti": "id2"}, time.Hour)
+
+	called := false
+	AuthenticateWebSocket(c, 20*time.Millisecond, func(ws *WebSocketSession, w http.ResponseWriter, r *http.Request) {
+		called = true
+		time.Sleep(60 * time.Millisecond)
+		if ws.Err() != nil {
+			t.Fatal("Expected the revalidated session to be valid, but got", ws.Err())
+		}
+
+		if err := ws.Refresh(context.Background(), renewed); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		err := ws.Refresh(context.Background(), renewed)
+		expectValidationError(t, err, ValidationErrorTokenReplayed, http.StatusUnauthorized, nil)
+
+		time.Sleep(60 * time.Millisecond)
+		if ws.Err() != nil {
+			t.Error("Expected the refreshed session to be valid, but got", ws.Err())
+		}
+	}).ServeHTTP(httptest.NewRecorder(), newWebSocketRequest("/ws", ts))
+
+	if !called {
+		t.Error("Expected the upgrade request to be authenticated.")
+	}
+}
+
+func Test_WebSocketSession_Refresh(t *testing.T) {
+	ti, s := createRegistryIssuer(t)
+	defer s.Close()
+
+	c, _ := NewConfiguration(ProvidersGetter(func() ([]Provider, error) {
+		return []Provider{{Issuer: s.URL, ClientIDs: []string{"app"}}}, nil
+	}))
+
+	ts, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app"}, time.Second)
+	renewed, _ := ti.Issue(map[string]interface{}{"sub": "user1", "aud": "app", "email": "user1@example.com"}, time.Hour)
+	other, _ := ti.Issue(map[string]interface{}{"sub": "user2", "aud": "app"}, time.Hour)
+
+	AuthenticateWebSocket(c, 0, func(ws *WebSocketSession, w http.ResponseWriter, r *http.Request) {
+		err := ws.Refresh(context.Background(), other)
+		expectValidationError(t, err, ValidationErrorSubjectChanged, http.StatusForbidden, nil)
+
+		err = ws.Refresh(context.Background(), "invalid")
+		expectValidationError(t, err, ValidationErrorJwtValidationFailure, http.StatusBadRequest, nil)
+
+		if err := ws.Refresh(context.Background(), renewed); err != nil {
+			t.Fatal("An error was returned but not expected", err)
+		}
+
+		if ws.User().Claims["email"] != "user1@example.com" || time.Until(ws.Expiry()) < time.Minute {
+			t.Error("Expected the user and expiration of the renewed token, but got", ws.User(), ws.Expiry())
+		}
+
+		select {
+		case <-ws.Done():
+			t.Error("Expected the refreshed session to outlive the first token, but got", ws.Err())
+		case <-time.After(1500 * time.Millisecond):
+		}
+	}).ServeHTTP(httptest.NewRecorder(), newWebSocketRequest("/ws", ts))
+}